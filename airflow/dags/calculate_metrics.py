@@ -3,8 +3,6 @@ from airflow.providers.postgres.hooks.postgres import PostgresHook
 from airflow.operators.python import PythonOperator
 from airflow.exceptions import AirflowFailException
 from datetime import datetime, timedelta, time as dtime
-import pandas as pd
-import talib
 import requests
 from datetime import timedelta
 from pytz import timezone
@@ -107,77 +105,19 @@ def get_last_processed_batch_metric_calc():
     return [r[0] for r in cursor.fetchall()]
 
 
-def calculate_indicators(symbol):
-    pg = PostgresHook(postgres_conn_id="postgres_local")
-    df = pg.get_pandas_df(
-        f"""
-        SELECT ip.*
-        FROM stocks_intraday ip
-        LEFT JOIN stocks_intraday_indicators ii
-            ON ip.symbol = ii.symbol AND ip.date = ii.date
-        WHERE ii.symbol IS NULL
-        AND ip.date >= NOW() - interval '5 days'
-        AND ip.symbol = '{symbol}'
-        ORDER BY ip.date;
-    """
-    )
-
-    results = []
-    for symbol, group in df.groupby("symbol"):
-        group = group.sort_values("date").reset_index(drop=True)
-
-        open = group["open"].astype(float)
-        high = group["high"].astype(float)
-        low = group["low"].astype(float)
-        close = group["close"].astype(float)
-        volume = group["volume"].astype(float)
-
-        # Indicators
-        price_change_pct = (close - open) / open * 100
-        avg_volume_50 = volume.rolling(window=50).mean()
-        rvol = volume / avg_volume_50
-        volume_spike = volume > 2 * avg_volume_50
-
-        rsi = talib.RSI(close, timeperiod=78)
-        atr = talib.ATR(high, low, close, timeperiod=78)
-        obv = talib.OBV(close, volume)
-
-        group["symbol"] = symbol
-        group["price_change_pct"] = price_change_pct
-        group["rvol"] = rvol
-        group["volume_spike"] = volume_spike
-        group["rsi"] = rsi
-        group["atr"] = atr
-        group["obv"] = obv
-
-        valid_rows = group[
-            [
-                "symbol",
-                "date",
-                "rvol",
-                "price_change_pct",
-                "rsi",
-                "volume_spike",
-                "atr",
-                "obv",
-            ]
-        ].dropna()
-        valid_rows["volume_spike"] = valid_rows["volume_spike"].astype(bool)
-        results += valid_rows.to_records(index=False).tolist()
-
-    insert_query = """
-        INSERT INTO intraday_indicators (
-            symbol, date, rvol, price_change_pct, rsi, volume_spike, atr, obv
-        ) VALUES (%s, %s, %s, %s, %s, %s, %s, %s)
-        ON CONFLICT (symbol, date) DO NOTHING
-    """
-    cursor.executemany(insert_query, results)
-
-
 def calculate_metric_batch():
+    """Calculate intraday indicators for the batch that was just extracted"""
     symbols = get_last_processed_batch_metric_calc()
-    for symbol in symbols:
-        calculate_indicators(symbol)
+    url = f"{API_BASE_URL}/api/calculate/indicators"
+
+    try:
+        response = requests.post(url, json={"symbols": symbols}, timeout=300)
+        response.raise_for_status()
+        print(f"Successfully calculated indicators for {symbols}")
+    except Exception as e:
+        raise AirflowFailException(
+            f"FAILED TO CALCULATE INDICATORS FOR {symbols}: {str(e)}"
+        )
 
 
 with DAG(
@@ -0,0 +1,118 @@
+from airflow import DAG
+from airflow.providers.postgres.hooks.postgres import PostgresHook
+from airflow.operators.python import PythonOperator
+from airflow.exceptions import AirflowFailException, AirflowSkipException
+from datetime import datetime, timedelta, time as dtime
+import requests
+from pytz import timezone
+
+default_args = {
+    "start_date": datetime(2025, 7, 7),
+    "email_on_failure": True,
+    "email_on_retry": False,
+    "retries": 1,
+    "retry_delay": timedelta(minutes=1),
+}
+
+API_BASE_URL = "http://localhost:8081"
+pg = PostgresHook(postgres_conn_id="postgres_local")
+conn = pg.get_conn()
+cursor = conn.cursor()
+
+
+def extract_watched_symbols():
+    """Extract intraday bars for every symbol on a watchlist since the last stored bar"""
+    url = f"{API_BASE_URL}/api/extract/batch"
+    eastern = timezone("US/Eastern")
+
+    now = datetime.now(eastern)
+    # Runs a few minutes past the close so the final bars are picked up
+    if now.weekday() >= 5 or not dtime(9, 30) <= now.time() <= dtime(16, 5):
+        raise AirflowSkipException("Market is closed")
+
+    trading_start = eastern.localize(datetime.combine(now.date(), dtime(9, 30)))
+    if now.time() > dtime(16, 0):
+        now = eastern.localize(datetime.combine(now.date(), dtime(16, 0)))
+
+    # Bars are stored without a zone in the market's wall clock. The batch
+    # shares one window, so it starts at the symbol that is furthest behind;
+    # a symbol with no bars today starts at the open.
+    cursor.execute(
+        """
+        SELECT MIN(COALESCE(last_bar, %(start)s))
+        FROM (
+            SELECT w.symbol, MAX(i.date) AS last_bar
+            FROM (SELECT DISTINCT symbol FROM watchlist_symbols) w
+            LEFT JOIN stocks_intraday i ON i.symbol = w.symbol AND i.date >= %(start)s
+            GROUP BY w.symbol
+        ) last_bars
+        """,
+        {"start": trading_start.replace(tzinfo=None)},
+    )
+    row = cursor.fetchone()
+    conn.commit()
+    if not row or row[0] is None:
+        raise AirflowSkipException("No watched symbols")
+
+    start = eastern.localize(row[0])
+    # A last bar ahead of now means the clocks disagree; fall back to the whole day
+    if start > now:
+        start = trading_start
+    if start >= now:
+        raise AirflowSkipException("No new bars since the last run")
+
+    payload = {
+        "watchlists": True,
+        "from": start.isoformat(),
+        "to": now.isoformat(),
+    }
+
+    try:
+        response = requests.post(url, json=payload, timeout=300)
+        response.raise_for_status()
+        print(f"Extracted watched symbols since {start.isoformat()}: {response.json()}")
+    except Exception as e:
+        raise AirflowFailException(f"FAILED TO EXTRACT WATCHED SYMBOLS: {str(e)}")
+
+
+def calculate_watched_metrics():
+    """Calculate intraday indicators for every symbol on a watchlist"""
+    cursor.execute("SELECT DISTINCT symbol FROM watchlist_symbols ORDER BY symbol")
+    symbols = [r[0] for r in cursor.fetchall()]
+    if not symbols:
+        print("No watched symbols")
+        return
+
+    url = f"{API_BASE_URL}/api/calculate/indicators"
+    try:
+        response = requests.post(url, json={"symbols": symbols}, timeout=300)
+        response.raise_for_status()
+        print(f"Successfully calculated indicators for {symbols}")
+    except Exception as e:
+        raise AirflowFailException(
+            f"FAILED TO CALCULATE INDICATORS FOR {symbols}: {str(e)}"
+        )
+
+
+with DAG(
+    "extract_watchlists_calculate_metrics",
+    default_args=default_args,
+    description="Refresh intraday data and metrics for watched symbols more often than the full exchange rotation",
+    # Every minute on weekdays from 13:00 to 21:59 UTC, which covers 09:30 to
+    # 16:05 Eastern in both summer and winter; the task skips the rest
+    schedule="* 13-21 * * 1-5",
+    catchup=False,
+    tags=["intraday", "metric-calculation", "watchlists"],
+) as dag:
+
+    extract_watched = PythonOperator(
+        task_id="extract_watched_symbols",
+        python_callable=extract_watched_symbols,
+    )
+
+    calculate_watched = PythonOperator(
+        task_id="calculate_watched_metrics",
+        python_callable=calculate_watched_metrics,
+    )
+
+    extract_watched >> calculate_watched
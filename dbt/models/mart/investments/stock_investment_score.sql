@@ -1,10 +1,12 @@
 {{ config(materialized='table') }}
 
+-- composite_score is computed by the API's scoring model; see sub_scores for the per-factor breakdown
 SELECT
   symbol,
   company_name,
-  ROUND((roe_ttm + revenue_5y_growth + operating_margin) / 3, 2) AS investment_score,
+  industry,
+  composite_score AS investment_score,
   pe_ratio,
   dividend_yield
 FROM {{ ref('stg_stock_scorecards') }}
-WHERE roe_ttm IS NOT NULL AND revenue_5y_growth IS NOT NULL AND operating_margin IS NOT NULL
+WHERE composite_score IS NOT NULL
@@ -3,6 +3,7 @@
 SELECT
   symbol,
   company_name,
+  industry,
   pe_ratio,
   peg_ratio,
   price_to_book,
@@ -11,5 +12,7 @@ SELECT
   operating_margin,
   profit_margin,
   dividend_yield,
-  beta
+  beta,
+  composite_score,
+  sub_scores
 FROM {{ source('raw', 'stock_scorecards') }}
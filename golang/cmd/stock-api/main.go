@@ -6,11 +6,13 @@ import (
 	"os"
 	"time"
 
+	"stock-api/internal/alerts"
 	"stock-api/internal/api"
 	"stock-api/internal/config"
 	"stock-api/internal/handler"
 	"stock-api/internal/middleware"
 	"stock-api/internal/repository"
+	"stock-api/internal/scoring"
 	"stock-api/internal/service"
 
 	_ "github.com/lib/pq"
@@ -25,11 +27,19 @@ func main() {
     }
     defer db.Close()
 
+    if len(os.Args) > 1 && os.Args[1] == "migrate" {
+        runMigrate(db, os.Args[2:])
+        return
+    }
+
+    checkSchema(db, cfg.AutoMigrate)
+
     // Initialize API clients
     alphaVantageClient := api.NewAlphaVantageClient(cfg.AlphaVantageAPIKey, cfg.APIRequestTimeout)
     finnHubClient := api.NewFinnhubClient(cfg.FinnhubAPIKey, cfg.APIRequestTimeout)
     polygonClient := api.NewPolygonClient(cfg.PolygonAPIKey, cfg.APIRequestTimeout)
     rowsClient := api.NewRowsClient(cfg.RowsAPIKey, cfg.APIRequestTimeout)
+    providers := api.NewRegistry(cfg.ProviderPriority, polygonClient, alphaVantageClient, finnHubClient)
 
 
     // Initialize repositories and services
@@ -37,18 +47,72 @@ func main() {
     stockScoreRepo := repository.NewStockScoreRepository(db)
     transactionRepo := repository.NewTransactionsRepository(db)
     usersRepo := repository.NewUserRepository(db)
+    quotaRepo := repository.NewQuotaRepository(db)
+    indicatorRepo := repository.NewIndicatorRepository(db)
+    screenerRepo := repository.NewScreenerRepository(db)
+    portfolioRepo := repository.NewPortfolioRepository(db)
+    alertRepo := repository.NewAlertRepository(db)
+    watchlistRepo := repository.NewWatchlistRepository(db)
 
 
-    stockService := service.NewStockService(stockRepo, stockScoreRepo)
-    dataExtractionService := service.NewDataExtractionService(alphaVantageClient, finnHubClient, polygonClient, stockRepo, stockScoreRepo)
+    scoringModel := scoring.DefaultModel()
+    if cfg.ScoringModelFile != "" {
+        scoringModel, err = scoring.LoadModel(cfg.ScoringModelFile)
+        if err != nil {
+            log.Fatalf("could not load scoring model: %v", err)
+        }
+    }
+
+    stockService := service.NewStockService(stockRepo, stockScoreRepo, scoringModel)
+    dataExtractionService := service.NewDataExtractionService(providers, stockRepo, stockScoreRepo)
     transactionService := service.NewTransactionService(rowsClient, transactionRepo)
     userService := service.NewUserService(usersRepo)
+    quotaService := service.NewQuotaService(quotaRepo, cfg.ProviderQuotas, cfg.QuotaFailOpen, cfg.QuotaLedgerRetention)
+    indicatorService := service.NewIndicatorService(stockRepo, indicatorRepo)
+    screenerService := service.NewScreenerService(screenerRepo)
+    portfolioService := service.NewPortfolioService(portfolioRepo, stockRepo)
+    lotService := service.NewLotService(portfolioRepo, stockRepo)
+    performanceService := service.NewPerformanceService(stockRepo, dataExtractionService)
+    riskService := service.NewRiskService(stockRepo, dataExtractionService)
+    watchlistService := service.NewWatchlistService(watchlistRepo)
+    marketService := service.NewMarketService(stockRepo)
+
+    webhookNets, err := alerts.ParseNetworks(cfg.AlertWebhookAllowedNets)
+    if err != nil {
+        log.Fatalf("could not parse ALERT_WEBHOOK_ALLOWED_NETS: %v", err)
+    }
+    notifiers := []alerts.Notifier{alerts.NewWebhookNotifier(cfg.AlertWebhookTimeout, webhookNets...)}
+    if cfg.SMTPHost != "" {
+        notifiers = append(notifiers, alerts.NewSMTPNotifier(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom))
+    }
+    alertService := service.NewAlertService(alertRepo, stockRepo, watchlistRepo, notifiers...)
+
+    // Evaluate alert rules whenever new bars or indicators are stored, and
+    // recompute market movers after each extraction batch
+    dataExtractionService.AddDataListener(alertService)
+    dataExtractionService.AddDataListener(marketService)
+    indicatorService.AddDataListener(alertService)
+
+    // Record every outbound call in the persistent quota ledger
+    alphaVantageClient.SetQuotaTracker(api.ProviderAlphaVantage, quotaService)
+    finnHubClient.SetQuotaTracker(api.ProviderFinnhub, quotaService)
+    polygonClient.SetQuotaTracker(api.ProviderPolygon, quotaService)
+    rowsClient.SetQuotaTracker(api.ProviderRows, quotaService)
 
     // Initialize handlers
     stockHandler := handler.NewStockHandler(stockService)
-    extractionHandler := handler.NewExtractionHandler(dataExtractionService)
+    extractionHandler := handler.NewExtractionHandler(dataExtractionService, watchlistService)
     transactionHandler := handler.NewTransactionHandler(transactionService)
     userHandler := handler.NewUserHandler(userService)
+    quotaHandler := handler.NewQuotaHandler(quotaService)
+    indicatorHandler := handler.NewIndicatorHandler(indicatorService)
+    screenerHandler := handler.NewScreenerHandler(screenerService)
+    portfolioHandler := handler.NewPortfolioHandler(portfolioService)
+    lotHandler := handler.NewLotHandler(lotService)
+    analyticsHandler := handler.NewAnalyticsHandler(performanceService, riskService)
+    alertHandler := handler.NewAlertHandler(alertService)
+    watchlistHandler := handler.NewWatchlistHandler(watchlistService)
+    marketHandler := handler.NewMarketHandler(marketService)
 
     // Setup routes
     mux := http.NewServeMux()
@@ -56,6 +120,11 @@ func main() {
     // Stock data endpoints
     mux.HandleFunc("/api/stocks", stockHandler.GetStockSummary)
     mux.HandleFunc("/api/stocks/data", stockHandler.GetStockData)
+    mux.HandleFunc("/api/stocks/daily", stockHandler.GetDailyData)
+    mux.HandleFunc("/api/stocks/indicators", indicatorHandler.GetIndicators)
+    mux.HandleFunc("/api/stocks/scorecard", stockHandler.GetScorecard)
+    mux.HandleFunc("/api/stocks/scorecard/history", stockHandler.GetScorecardHistory)
+    mux.HandleFunc("/api/stocks/risk", analyticsHandler.GetSymbolRisk)
     
     // Stock metadata endpoints
     mux.HandleFunc("/api/stocks/metadata", stockHandler.GetStockMetadata)
@@ -67,6 +136,7 @@ func main() {
     mux.HandleFunc("/api/extract/stock", extractionHandler.ExtractStockData)
     // mux.HandleFunc("/api/extract/quote", extractionHandler.ExtractLatestQuote)
     mux.HandleFunc("/api/extract/batch", extractionHandler.BatchExtractData)
+    mux.HandleFunc("/api/extract/daily", extractionHandler.ExtractDailyData)
     mux.HandleFunc("/api/extract/status", extractionHandler.GetExtractionStatus)
     mux.HandleFunc("/api/extract/symbols", extractionHandler.ExtractSymbols)
     mux.HandleFunc("/api/extract/stockmetadata", extractionHandler.ExtractStockMetadata)
@@ -75,11 +145,54 @@ func main() {
     mux.HandleFunc("/api/extract/incomestatment", extractionHandler.ExtractCompanyIncomeStatements)
     mux.HandleFunc("/api/extract/balancesheet", extractionHandler.ExtractCompanyBalanceSheets)
     mux.HandleFunc("/api/calculate/scorecard", stockHandler.CalculateStockScoreCard)
+    mux.HandleFunc("/api/calculate/indicators", indicatorHandler.CalculateIndicators)
+
+    // Market endpoints
+    mux.HandleFunc("/api/market/movers", marketHandler.GetMovers)
+
+    // Provider quota endpoints
+    mux.HandleFunc("/api/providers/quota", quotaHandler.GetQuota)
+
+    auth := middleware.AuthMiddleware(cfg.JWTSecret)
+
+    // Screener endpoints
+    mux.HandleFunc("/api/screener", screenerHandler.Screen)
+    mux.Handle("/api/screener/saved", auth(http.HandlerFunc(screenerHandler.SavedScreens)))
+    mux.Handle("/api/screener/saved/run", auth(http.HandlerFunc(screenerHandler.RunSavedScreen)))
+
+    // Portfolio endpoints
+    mux.Handle("/api/portfolio/accounts", auth(http.HandlerFunc(portfolioHandler.Accounts)))
+    mux.Handle("/api/portfolio/trades", auth(http.HandlerFunc(portfolioHandler.Trades)))
+    mux.Handle("/api/portfolio/holdings", auth(http.HandlerFunc(portfolioHandler.Holdings)))
+
+    // Tax lot endpoints
+    mux.Handle("/api/lots/realized", auth(http.HandlerFunc(lotHandler.GetRealizedGains)))
+    mux.Handle("/api/lots/open", auth(http.HandlerFunc(lotHandler.GetOpenLots)))
+    mux.Handle("/api/lots/import", auth(http.HandlerFunc(lotHandler.ImportCSV)))
+
+    // Analytics endpoints
+    mux.HandleFunc("/api/analytics/performance", analyticsHandler.Performance)
+    mux.HandleFunc("/api/analytics/risk", analyticsHandler.Risk)
+    mux.HandleFunc("/api/analytics/correlation", analyticsHandler.Correlation)
+
+    // Watchlist endpoints
+    mux.Handle("/api/watchlists", auth(http.HandlerFunc(watchlistHandler.Watchlists)))
+    mux.Handle("/api/watchlists/", auth(http.HandlerFunc(watchlistHandler.Watchlists)))
+
+    // Alert endpoints
+    mux.Handle("/api/alerts/rules", auth(http.HandlerFunc(alertHandler.Rules)))
+    mux.Handle("/api/alerts/events", auth(http.HandlerFunc(alertHandler.Events)))
 
     //Transaction Endpoints
-    protectedHandler := middleware.AuthMiddleware(config.Load().JWTSecret)(http.HandlerFunc(transactionHandler.ExtractTransactions))
-    mux.Handle("/api/extract/banktransactions", protectedHandler)
-    // mux.HandleFunc("/api/extract/banktransactions", transactionHandler.ExtractTransactions)
+    mux.Handle("/api/extract/banktransactions", auth(http.HandlerFunc(transactionHandler.ExtractTransactions)))
+    mux.Handle("/api/transactions", auth(http.HandlerFunc(transactionHandler.Transactions)))
+    mux.Handle("/api/transactions/sources", auth(http.HandlerFunc(transactionHandler.Sources)))
+    mux.Handle("/api/transactions/imports", auth(http.HandlerFunc(transactionHandler.Imports)))
+    mux.Handle("/api/transactions/import", auth(http.HandlerFunc(transactionHandler.ImportStatement)))
+    mux.Handle("/api/transactions/profiles", auth(http.HandlerFunc(transactionHandler.Profiles)))
+    mux.Handle("/api/transactions/rules", auth(http.HandlerFunc(transactionHandler.Rules)))
+    mux.Handle("/api/transactions/recategorize", auth(http.HandlerFunc(transactionHandler.Recategorize)))
+    mux.Handle("/api/transactions/recurring", auth(http.HandlerFunc(transactionHandler.Recurring)))
 
     mux.HandleFunc("/api/user/register", userHandler.Register)
     mux.HandleFunc("/api/user/login", userHandler.Login)
@@ -101,17 +214,53 @@ func main() {
     log.Printf("  GET  /health - Health check")
     log.Printf("  GET  /api/stocks?symbol=AAPL - Get stock summary")
     log.Printf("  GET  /api/stocks/data?symbol=AAPL&start=2024-01-01&end=2024-12-31 - Get stock data")
+    log.Printf("  GET  /api/stocks/daily?symbol=AAPL&start=2020-01-01&end=2024-12-31 - Get daily history")
+    log.Printf("  GET  /api/stocks/indicators?symbol=AAPL&names=rsi,atr - Get technical indicators")
+    log.Printf("  GET  /api/stocks/scorecard?symbol=AAPL - Get scorecard with score breakdown")
+    log.Printf("  GET  /api/stocks/scorecard/history?symbol=AAPL&from=2024-01-01&to=2024-12-31 - Get scorecard history")
+    log.Printf("  GET  /api/stocks/risk?symbol=AAPL&index=SPY - Get volatility, beta, Sharpe, drawdown and VaR")
     log.Printf("  GET  /api/stocks/metadata?symbol=AAPL - Get stock metadata")
     log.Printf("  GET  /api/stocks/metadata/all - Get all stock metadata")
     log.Printf("  POST /api/stocks/metadata/store - Store stock metadata")
     log.Printf("  DELETE /api/stocks/metadata/delete?symbol=AAPL - Delete stock metadata")
     log.Printf("  POST /api/extract/stock - Extract stock data")
     log.Printf("  POST /api/extract/quote - Extract latest quote")
-    log.Printf("  POST /api/extract/batch - Batch extract data")
+    log.Printf("  POST /api/extract/batch - Batch extract data (symbols, watchlist_id or watchlists)")
+    log.Printf("  POST /api/extract/daily - Batch extract daily history")
     log.Printf("  GET  /api/extract/status?symbol=AAPL - Get extraction status")
     log.Printf("  POST /api/extract/symbols - Extract stock symbols by exchange")
     log.Printf("  POST batch_id - Extract stock metadata by exchange")
     log.Printf("  POST /api/extract/companyprofile - Extract company profile")
+    log.Printf("  POST /api/calculate/indicators - Calculate technical indicators")
+    log.Printf("  GET  /api/market/movers?type=gainers&limit=20 - Get top gainers, losers, most active or unusual volume")
+    log.Printf("  GET  /api/providers/quota - Get provider quota usage")
+    log.Printf("  POST /api/screener - Screen stocks by scorecard and metadata filters")
+    log.Printf("  GET/POST/DELETE /api/screener/saved - Manage saved screens (auth)")
+    log.Printf("  GET  /api/screener/saved/run?id=1 - Run a saved screen (auth)")
+    log.Printf("  GET/POST/DELETE /api/portfolio/accounts - Manage portfolio accounts (auth)")
+    log.Printf("  GET/POST/PUT/DELETE /api/portfolio/trades - Manage trades (auth)")
+    log.Printf("  GET  /api/portfolio/holdings?account_id=1 - Get valued holdings (auth)")
+    log.Printf("  GET  /api/lots/realized?year=2024 - Get realized gains by tax lot (auth)")
+    log.Printf("  GET  /api/lots/open?account_id=1 - Get open lots with unrealized P&L (auth)")
+    log.Printf("  POST /api/lots/import?account_id=1 - Import trades from a broker CSV (auth)")
+    log.Printf("  POST /api/analytics/performance?benchmark=SPY - Calculate portfolio performance")
+    log.Printf("  POST /api/analytics/risk - Calculate risk metrics for a weighted basket")
+    log.Printf("  POST /api/analytics/correlation - Calculate return correlation and covariance matrices")
+    log.Printf("  GET/POST /api/watchlists - Manage watchlists (auth)")
+    log.Printf("  GET/PUT/DELETE /api/watchlists/{id} - Get, rename or delete a watchlist (auth)")
+    log.Printf("  POST/PUT/DELETE /api/watchlists/{id}/symbols - Add, reorder or remove symbols (auth)")
+    log.Printf("  GET  /api/watchlists/{id}/summary - Get price, change, volume and score for each symbol (auth)")
+    log.Printf("  GET/POST/PUT/DELETE /api/alerts/rules - Manage alert rules (auth)")
+    log.Printf("  GET/POST /api/alerts/events?unread=true - Get or mark read the alert feed (auth)")
+    log.Printf("  POST /api/extract/banktransactions?source_id=1&full=true - Import new (or all) rows from a source (auth)")
+    log.Printf("  GET  /api/transactions?from=2024-01-01&to=2024-12-31 - Get your bank transactions (auth)")
+    log.Printf("  GET/POST/PUT/DELETE /api/transactions/sources - Manage transaction sources and header mappings (auth)")
+    log.Printf("  GET  /api/transactions/imports?id=1 - Get import runs with new, duplicate and rejected rows (auth)")
+    log.Printf("  POST /api/transactions/import - Import a CSV, OFX/QFX or QIF statement file, dry_run=true to preview (auth)")
+    log.Printf("  GET/POST/PUT/DELETE /api/transactions/profiles - Manage CSV import profiles (auth)")
+    log.Printf("  GET/POST/PUT/DELETE /api/transactions/rules - Manage categorisation rules (auth)")
+    log.Printf("  POST /api/transactions/recategorize?preview=true - Re-run categorisation rules over history (auth)")
+    log.Printf("  GET  /api/transactions/recurring?months=24 - Detect subscriptions and recurring charges (auth)")
     
     log.Fatal(http.ListenAndServe(":"+port, mux))
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+    "database/sql"
+    "fmt"
+    "log"
+    "strconv"
+
+    "stock-api/internal/migrate"
+    "stock-api/migrations"
+)
+
+// runMigrate implements `stock-api migrate up|down [n]|status|baseline <version>`
+func runMigrate(db *sql.DB, args []string) {
+    migrator, err := migrate.New(db, migrations.FS)
+    if err != nil {
+        log.Fatalf("could not load migrations: %v", err)
+    }
+
+    if len(args) == 0 {
+        log.Fatalf("usage: stock-api migrate up|down [n]|status|baseline <version>")
+    }
+
+    switch args[0] {
+    case "up":
+        applied, err := migrator.Up()
+        if err != nil {
+            log.Fatalf("migrate up failed after %d migrations: %v", applied, err)
+        }
+        log.Printf("Applied %d migrations", applied)
+
+    case "down":
+        steps := 1
+        if len(args) > 1 {
+            steps, err = strconv.Atoi(args[1])
+            if err != nil || steps < 1 {
+                log.Fatalf("invalid number of steps %q", args[1])
+            }
+        }
+        reverted, err := migrator.Down(steps)
+        if err != nil {
+            log.Fatalf("migrate down failed after %d migrations: %v", reverted, err)
+        }
+        log.Printf("Reverted %d migrations", reverted)
+
+    case "status":
+        statuses, err := migrator.Status()
+        if err != nil {
+            log.Fatalf("could not get migration status: %v", err)
+        }
+        for _, s := range statuses {
+            applied := "pending"
+            if s.AppliedAt != nil {
+                applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+            }
+            fmt.Printf("%03d  %-45s %s\n", s.Version, s.Name, applied)
+        }
+
+    case "baseline":
+        if len(args) < 2 {
+            log.Fatalf("usage: stock-api migrate baseline <version>")
+        }
+        version, err := strconv.Atoi(args[1])
+        if err != nil {
+            log.Fatalf("invalid version %q", args[1])
+        }
+        marked, err := migrator.Baseline(version)
+        if err != nil {
+            log.Fatalf("migrate baseline failed: %v", err)
+        }
+        log.Printf("Marked %d migrations as applied", marked)
+
+    default:
+        log.Fatalf("unknown migrate command %q (use up, down, status or baseline)", args[0])
+    }
+}
+
+// checkSchema refuses to start the server while migrations are pending, unless
+// autoMigrate is set, in which case they are applied first
+func checkSchema(db *sql.DB, autoMigrate bool) {
+    migrator, err := migrate.New(db, migrations.FS)
+    if err != nil {
+        log.Fatalf("could not load migrations: %v", err)
+    }
+
+    if autoMigrate {
+        applied, err := migrator.Up()
+        if err != nil {
+            log.Fatalf("automatic migration failed after %d migrations: %v", applied, err)
+        }
+        if applied > 0 {
+            log.Printf("Applied %d migrations", applied)
+        }
+        return
+    }
+
+    pending, err := migrator.Pending()
+    if err != nil {
+        log.Fatalf("could not check schema version: %v", err)
+    }
+    if len(pending) > 0 {
+        log.Fatalf("database schema is behind by %d migrations (next: %03d_%s); run `stock-api migrate up` or set AUTO_MIGRATE=true",
+            len(pending), pending[0].Version, pending[0].Name)
+    }
+}
@@ -3,6 +3,7 @@ module stock-api
 go 1.21
 
 require (
+	github.com/golang-jwt/jwt/v5 v5.3.1
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
 	golang.org/x/time v0.5.0
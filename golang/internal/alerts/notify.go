@@ -0,0 +1,265 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Delivery channels a rule can ask for
+const (
+	ChannelInApp   = "in_app"
+	ChannelWebhook = "webhook"
+	ChannelEmail   = "email"
+)
+
+// Notification is one fired alert on its way to a channel
+type Notification struct {
+	EventID   int       `json:"event_id"`
+	RuleID    int       `json:"rule_id"`
+	RuleName  string    `json:"rule_name"`
+	Symbol    string    `json:"symbol"`
+	Metric    Metric    `json:"metric"`
+	Operator  Operator  `json:"operator"`
+	Threshold float64   `json:"threshold"`
+	Value     float64   `json:"value"`
+	BarDate   time.Time `json:"bar_date"`
+	Message   string    `json:"message"`
+	// WebhookURL and Email are where the rule wants the alert sent
+	WebhookURL string `json:"-"`
+	Email      string `json:"-"`
+}
+
+// Notifier delivers notifications over one channel
+type Notifier interface {
+	Channel() string
+	Notify(ctx context.Context, n Notification) error
+}
+
+// InAppNotifier is the feed channel. Fired alerts are already stored as
+// events, which is all the feed reads, so there is nothing more to do.
+type InAppNotifier struct{}
+
+func (InAppNotifier) Channel() string { return ChannelInApp }
+
+func (InAppNotifier) Notify(ctx context.Context, n Notification) error { return nil }
+
+// ErrWebhookTarget is returned for webhook URLs pointing at addresses the
+// server must not call, such as loopback, private and link-local networks
+var ErrWebhookTarget = errors.New("webhook target is not a public address")
+
+// carrierNAT is the shared address space of RFC 6598, which net.IP doesn't
+// count as private
+var carrierNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicIP reports whether ip is a unicast address on the public internet
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || carrierNAT.Contains(ip))
+}
+
+// ParseNetworks parses CIDR blocks and bare IP addresses, such as the
+// internal hosts an operator lets webhooks reach
+func ParseNetworks(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, item := range list {
+		item = strings.TrimSpace(item)
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", item)
+			}
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", item)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// reachable reports whether a webhook may connect to ip: it must be public
+// or inside one of the allowed networks
+func reachable(ip net.IP, allowed []*net.IPNet) bool {
+	if publicIP(ip) {
+		return true
+	}
+	for _, n := range allowed {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateWebhookURL checks a webhook URL is http(s) and that every address
+// its host resolves to is public or in allowed. Delivery checks the address
+// again when dialling, since DNS can change after the rule is saved.
+func ValidateWebhookURL(ctx context.Context, raw string, allowed ...*net.IPNet) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("webhook_url must be an http or https url")
+	}
+	if u.User != nil {
+		return fmt.Errorf("webhook_url can't contain credentials")
+	}
+
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !reachable(ip, allowed) {
+			return ErrWebhookTarget
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("could not resolve webhook host %q", host)
+	}
+	for _, addr := range addrs {
+		if !reachable(addr.IP, allowed) {
+			return ErrWebhookTarget
+		}
+	}
+	return nil
+}
+
+// dialAllowed returns a dialer control refusing connections to addresses
+// that aren't reachable. It runs after DNS resolution, so a host re-pointed
+// at an internal address is still caught.
+func dialAllowed(allowed []*net.IPNet) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || !reachable(ip, allowed) {
+			return ErrWebhookTarget
+		}
+		return nil
+	}
+}
+
+// WebhookNotifier POSTs the notification as JSON to the rule's webhook URL.
+// It only connects to public addresses and the allowed networks, ignores
+// proxy settings and doesn't follow redirects.
+type WebhookNotifier struct {
+	client  *http.Client
+	allowed []*net.IPNet
+}
+
+// NewWebhookNotifier creates a webhook notifier. Private addresses in allowed
+// can be reached too, for internal receivers and tests.
+func NewWebhookNotifier(timeout time.Duration, allowed ...*net.IPNet) *WebhookNotifier {
+	dialer := &net.Dialer{Timeout: timeout, Control: dialAllowed(allowed)}
+	transport := &http.Transport{
+		Proxy:               nil,
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: timeout,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return &WebhookNotifier{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		allowed: allowed,
+	}
+}
+
+func (w *WebhookNotifier) Channel() string { return ChannelWebhook }
+
+// ValidateURL checks a webhook URL against the networks this notifier can reach
+func (w *WebhookNotifier) ValidateURL(ctx context.Context, raw string) error {
+	return ValidateWebhookURL(ctx, raw, w.allowed...)
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	if n.WebhookURL == "" {
+		return fmt.Errorf("rule %d has no webhook url", n.RuleID)
+	}
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SMTPNotifier emails the notification through an SMTP relay. Auth is only
+// used when a username is set, so a local test server works without it.
+type SMTPNotifier struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTPNotifier(host string, port int, username, password, from string) *SMTPNotifier {
+	n := &SMTPNotifier{
+		addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		from: from,
+	}
+	if username != "" {
+		n.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return n
+}
+
+func (s *SMTPNotifier) Channel() string { return ChannelEmail }
+
+func (s *SMTPNotifier) Notify(ctx context.Context, n Notification) error {
+	if n.Email == "" {
+		return fmt.Errorf("rule %d has no email address", n.RuleID)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", n.Email)
+	fmt.Fprintf(&msg, "Subject: Alert: %s\r\n", n.Message)
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n\r\nRule: %s\r\nValue: %g\r\nBar: %s\r\n",
+		n.Message, n.RuleName, n.Value, n.BarDate.Format(time.RFC3339))
+
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{n.Email}, []byte(msg.String())); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+// Package alerts evaluates alert conditions against recent bars and tracks
+// each rule's firing state.
+package alerts
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"stock-api/internal/indicators"
+	"stock-api/internal/repository"
+)
+
+// Metric is the value a rule watches
+type Metric string
+
+const (
+	MetricOpen           Metric = "open"
+	MetricHigh           Metric = "high"
+	MetricLow            Metric = "low"
+	MetricClose          Metric = "close"
+	MetricVolume         Metric = "volume"
+	MetricPriceChangePct Metric = "price_change_pct"
+	MetricRSI            Metric = "rsi"
+	MetricATR            Metric = "atr"
+	MetricRVOL           Metric = "rvol"
+	MetricOBV            Metric = "obv"
+	MetricSMA            Metric = "sma"
+	MetricEMA            Metric = "ema"
+	MetricMACD           Metric = "macd"
+	MetricMACDSignal     Metric = "macd_signal"
+	MetricMACDHist       Metric = "macd_hist"
+	MetricBBUpper        Metric = "bb_upper"
+	MetricBBMiddle       Metric = "bb_middle"
+	MetricBBLower        Metric = "bb_lower"
+	MetricVWAP           Metric = "vwap"
+	// MetricVolumeSpike is 1 on bars whose volume spikes and 0 otherwise
+	MetricVolumeSpike Metric = "volume_spike"
+)
+
+// defaultPeriods are used when a rule doesn't set a period for a metric that takes one
+var defaultPeriods = map[Metric]int{
+	MetricRSI:         14,
+	MetricATR:         14,
+	MetricRVOL:        50,
+	MetricSMA:         20,
+	MetricEMA:         20,
+	MetricBBUpper:     20,
+	MetricBBMiddle:    20,
+	MetricBBLower:     20,
+	MetricVolumeSpike: 50,
+}
+
+var metrics = map[Metric]bool{
+	MetricOpen: true, MetricHigh: true, MetricLow: true, MetricClose: true, MetricVolume: true,
+	MetricPriceChangePct: true, MetricRSI: true, MetricATR: true, MetricRVOL: true, MetricOBV: true,
+	MetricSMA: true, MetricEMA: true, MetricMACD: true, MetricMACDSignal: true, MetricMACDHist: true,
+	MetricBBUpper: true, MetricBBMiddle: true, MetricBBLower: true, MetricVWAP: true, MetricVolumeSpike: true,
+}
+
+// Operator compares a metric with a rule's threshold
+type Operator string
+
+const (
+	Above        Operator = ">"
+	AtLeast      Operator = ">="
+	Below        Operator = "<"
+	AtMost       Operator = "<="
+	CrossesAbove Operator = "crosses_above"
+	CrossesBelow Operator = "crosses_below"
+)
+
+// State is where a rule is in its firing cycle for one symbol
+type State string
+
+const (
+	// Armed rules fire as soon as their condition holds
+	Armed State = "armed"
+	// Fired rules without a cooldown wait for their condition to clear before re-arming
+	Fired State = "fired"
+	// Cooldown rules stay quiet until the cooldown has passed since they fired
+	Cooldown State = "cooldown"
+)
+
+// maxPeriod bounds rule periods so evaluation loads a bounded number of bars
+const maxPeriod = 500
+
+// ParseMetric validates a metric name
+func ParseMetric(s string) (Metric, error) {
+	m := Metric(strings.ToLower(strings.TrimSpace(s)))
+	if !metrics[m] {
+		return "", fmt.Errorf("unknown metric %q", s)
+	}
+	return m, nil
+}
+
+// ParseOperator validates an operator, accepting "above"/"below" for the
+// strict comparisons
+func ParseOperator(s string) (Operator, error) {
+	switch op := Operator(strings.ToLower(strings.TrimSpace(s))); op {
+	case Above, AtLeast, Below, AtMost, CrossesAbove, CrossesBelow:
+		return op, nil
+	case "above":
+		return Above, nil
+	case "below":
+		return Below, nil
+	}
+	return "", fmt.Errorf("unknown operator %q (use >, >=, <, <=, crosses_above or crosses_below)", s)
+}
+
+// ValidatePeriod checks a rule's period; 0 means the metric's default
+func ValidatePeriod(metric Metric, period int) error {
+	if period < 0 || period > maxPeriod {
+		return fmt.Errorf("period must be between 0 and %d", maxPeriod)
+	}
+	if _, ok := defaultPeriods[metric]; !ok && period != 0 {
+		return fmt.Errorf("metric %s doesn't take a period", metric)
+	}
+	return nil
+}
+
+// Lookback is the number of recent bars needed to evaluate a metric, long
+// enough for smoothed indicators to settle
+func Lookback(metric Metric, period int) int {
+	if period == 0 {
+		period = defaultPeriods[metric]
+	}
+	p := indicators.DefaultParams()
+	warmUp := period + 1
+	switch metric {
+	case MetricMACD, MetricMACDSignal, MetricMACDHist:
+		warmUp = p.MACDSlow + p.MACDSignal
+	case MetricOBV, MetricVWAP:
+		// Both accumulate, so only the change over the window matters
+		warmUp = 78
+	}
+	return 3*warmUp + 2
+}
+
+// Series computes a metric over bars, oldest first
+func Series(metric Metric, period int, bars []repository.StockIntraDayData) indicators.Series {
+	if period == 0 {
+		period = defaultPeriods[metric]
+	}
+	p := indicators.DefaultParams()
+
+	fromBars := func(value func(repository.StockIntraDayData) float64) indicators.Series {
+		s := make(indicators.Series, len(bars))
+		for i, bar := range bars {
+			s[i] = value(bar)
+		}
+		return s
+	}
+
+	switch metric {
+	case MetricOpen:
+		return fromBars(func(b repository.StockIntraDayData) float64 { return b.Open })
+	case MetricHigh:
+		return fromBars(func(b repository.StockIntraDayData) float64 { return b.High })
+	case MetricLow:
+		return fromBars(func(b repository.StockIntraDayData) float64 { return b.Low })
+	case MetricClose:
+		return fromBars(func(b repository.StockIntraDayData) float64 { return b.Close })
+	case MetricVolume:
+		return fromBars(func(b repository.StockIntraDayData) float64 { return b.Volume })
+	case MetricPriceChangePct:
+		return indicators.PriceChangePct(bars)
+	case MetricRSI:
+		return indicators.RSI(bars, period)
+	case MetricATR:
+		return indicators.ATR(bars, period)
+	case MetricRVOL:
+		return indicators.RVOL(bars, period)
+	case MetricOBV:
+		return indicators.OBV(bars)
+	case MetricSMA:
+		return indicators.SMA(indicators.Closes(bars), period)
+	case MetricEMA:
+		return indicators.EMA(indicators.Closes(bars), period)
+	case MetricMACD:
+		return indicators.MACD(bars, p.MACDFast, p.MACDSlow, p.MACDSignal).MACD
+	case MetricMACDSignal:
+		return indicators.MACD(bars, p.MACDFast, p.MACDSlow, p.MACDSignal).Signal
+	case MetricMACDHist:
+		return indicators.MACD(bars, p.MACDFast, p.MACDSlow, p.MACDSignal).Histogram
+	case MetricBBUpper:
+		return indicators.Bollinger(bars, period, p.BollingerK).Upper
+	case MetricBBMiddle:
+		return indicators.Bollinger(bars, period, p.BollingerK).Middle
+	case MetricBBLower:
+		return indicators.Bollinger(bars, period, p.BollingerK).Lower
+	case MetricVWAP:
+		return indicators.VWAP(bars, p.Session)
+	case MetricVolumeSpike:
+		spikes := indicators.VolumeSpikes(bars, period, p.SpikeFactor)
+		rvol := indicators.RVOL(bars, period)
+		s := make(indicators.Series, len(bars))
+		for i := range bars {
+			switch {
+			case !rvol.Valid(i):
+				s[i] = math.NaN()
+			case spikes[i]:
+				s[i] = 1
+			}
+		}
+		return s
+	}
+
+	s := make(indicators.Series, len(bars))
+	for i := range s {
+		s[i] = math.NaN()
+	}
+	return s
+}
+
+// Triggered reports whether the condition holds on the latest bar. Crossing
+// operators compare with the previous bar and never hold without one.
+func Triggered(op Operator, threshold, previous, current float64) bool {
+	if math.IsNaN(current) {
+		return false
+	}
+	switch op {
+	case Above:
+		return current > threshold
+	case AtLeast:
+		return current >= threshold
+	case Below:
+		return current < threshold
+	case AtMost:
+		return current <= threshold
+	case CrossesAbove:
+		return !math.IsNaN(previous) && previous <= threshold && current > threshold
+	case CrossesBelow:
+		return !math.IsNaN(previous) && previous >= threshold && current < threshold
+	}
+	return false
+}
+
+// Step advances a rule's state for a newly evaluated bar at time now and
+// reports whether the rule fires. With a cooldown the rule re-arms once the
+// cooldown has passed, so a condition that keeps holding fires again; without
+// one it stays fired until the condition clears.
+func Step(state State, lastFired time.Time, cooldown time.Duration, triggered bool, now time.Time) (State, bool) {
+	if state == Cooldown && !now.Before(lastFired.Add(cooldown)) {
+		state = Armed
+	}
+
+	switch state {
+	case Cooldown:
+		return Cooldown, false
+	case Fired:
+		if triggered {
+			return Fired, false
+		}
+		return Armed, false
+	default:
+		if !triggered {
+			return Armed, false
+		}
+		if cooldown > 0 {
+			return Cooldown, true
+		}
+		return Fired, true
+	}
+}
+
+// Describe renders a condition such as "AAPL rsi(14) < 30"
+func Describe(symbol string, metric Metric, period int, op Operator, threshold float64) string {
+	name := string(metric)
+	if _, ok := defaultPeriods[metric]; ok {
+		if period == 0 {
+			period = defaultPeriods[metric]
+		}
+		name = fmt.Sprintf("%s(%d)", metric, period)
+	}
+	return fmt.Sprintf("%s %s %s %g", symbol, name, strings.ReplaceAll(string(op), "_", " "), threshold)
+}
@@ -0,0 +1,75 @@
+package alerts
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTriggered(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name      string
+		op        Operator
+		previous  float64
+		current   float64
+		threshold float64
+		want      bool
+	}{
+		{"above", Above, nan, 31, 30, true},
+		{"above is strict", Above, nan, 30, 30, false},
+		{"at least", AtLeast, nan, 30, 30, true},
+		{"below", Below, nan, 29, 30, true},
+		{"at most", AtMost, nan, 30, 30, true},
+		{"at most above threshold", AtMost, nan, 30.1, 30, false},
+		{"no current value", Above, 10, nan, 0, false},
+		{"crosses above", CrossesAbove, 29, 31, 30, true},
+		{"crosses above from the threshold", CrossesAbove, 30, 31, 30, true},
+		{"already above doesn't cross", CrossesAbove, 31, 32, 30, false},
+		{"crosses above without a previous bar", CrossesAbove, nan, 31, 30, false},
+		{"crosses below", CrossesBelow, 31, 29, 30, true},
+		{"already below doesn't cross", CrossesBelow, 29, 28, 30, false},
+		{"crosses below without a previous bar", CrossesBelow, nan, 29, 30, false},
+		{"unknown operator", Operator("=="), nan, 30, 30, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Triggered(tt.op, tt.threshold, tt.previous, tt.current); got != tt.want {
+				t.Errorf("Triggered(%s, %v, %v, %v) = %v, want %v", tt.op, tt.threshold, tt.previous, tt.current, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStep(t *testing.T) {
+	firedAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		state     State
+		cooldown  time.Duration
+		triggered bool
+		now       time.Time
+		wantState State
+		wantFire  bool
+	}{
+		{"armed stays armed", Armed, 0, false, firedAt, Armed, false},
+		{"armed fires", Armed, 0, true, firedAt, Fired, true},
+		{"armed fires into cooldown", Armed, time.Hour, true, firedAt, Cooldown, true},
+		{"fired waits while the condition holds", Fired, 0, true, firedAt.Add(time.Hour), Fired, false},
+		{"fired re-arms once the condition clears", Fired, 0, false, firedAt.Add(time.Hour), Armed, false},
+		{"cooldown stays quiet", Cooldown, time.Hour, true, firedAt.Add(59 * time.Minute), Cooldown, false},
+		{"cooldown fires again once it has passed", Cooldown, time.Hour, true, firedAt.Add(time.Hour), Cooldown, true},
+		{"cooldown re-arms when the condition has cleared", Cooldown, time.Hour, false, firedAt.Add(2 * time.Hour), Armed, false},
+		{"unknown state counts as armed", State(""), 0, true, firedAt, Fired, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, fire := Step(tt.state, firedAt, tt.cooldown, tt.triggered, tt.now)
+			if state != tt.wantState || fire != tt.wantFire {
+				t.Errorf("Step = (%s, %v), want (%s, %v)", state, fire, tt.wantState, tt.wantFire)
+			}
+		})
+	}
+}
@@ -1,10 +1,14 @@
 package api
 
 import (
+    "bytes"
     "context"
+    "encoding/json"
     "fmt"
     "log"
+    "sort"
     "strconv"
+    "strings"
     "time"
 )
 
@@ -15,9 +19,16 @@ type AlphaVantageClient struct {
 
 // NewAlphaVantageClient creates a new Alpha Vantage client
 func NewAlphaVantageClient(apiKey string, timeout time.Duration) *AlphaVantageClient {
-    return &AlphaVantageClient{
+    client := &AlphaVantageClient{
         APIClient: NewAPIClient("https://www.alphavantage.co", apiKey, 5, timeout),
     }
+    // The free tier allows 5 requests per minute, so back off in multiples of that window
+    client.SetRetryPolicy(RetryPolicy{
+        MaxAttempts: 3,
+        BaseDelay:   12 * time.Second,
+        MaxDelay:    time.Minute,
+    })
+    return client
 }
 
 // StockQuote represents a stock quote from Alpha Vantage
@@ -88,6 +99,56 @@ type BalanceSheet struct {
 	} `json:"annualReports"`
 }
 
+// alphaVantageEnvelope holds the fields Alpha Vantage uses to report problems
+// inside an HTTP 200 response
+type alphaVantageEnvelope struct {
+    Note         string `json:"Note"`
+    Information  string `json:"Information"`
+    ErrorMessage string `json:"Error Message"`
+}
+
+// doJSON performs a request like DoJSON, but also turns Alpha Vantage's
+// throttle and error payloads into ErrQuotaExceeded and ErrInvalidSymbol
+func (c *AlphaVantageClient) doJSON(ctx context.Context, req *Request, v interface{}) error {
+    resp, err := c.Do(ctx, req)
+    if err != nil {
+        return err
+    }
+
+    if err := checkStatus(resp); err != nil {
+        return err
+    }
+
+    if err := checkAlphaVantageEnvelope(resp.Body, req.Query["symbol"]); err != nil {
+        return err
+    }
+
+    return json.Unmarshal(resp.Body, v)
+}
+
+func checkAlphaVantageEnvelope(body []byte, symbol string) error {
+    // Unknown symbols on the fundamentals endpoints come back as an empty object
+    if bytes.Equal(bytes.TrimSpace(body), []byte("{}")) {
+        return fmt.Errorf("%w: no data returned for %s", ErrInvalidSymbol, symbol)
+    }
+
+    var envelope alphaVantageEnvelope
+    if err := json.Unmarshal(body, &envelope); err != nil {
+        // Not an object; let the caller's decode report it
+        return nil
+    }
+
+    switch {
+    case envelope.ErrorMessage != "":
+        return fmt.Errorf("%w: %s: %s", ErrInvalidSymbol, symbol, envelope.ErrorMessage)
+    case envelope.Note != "":
+        return fmt.Errorf("%w: %s", ErrQuotaExceeded, envelope.Note)
+    case envelope.Information != "":
+        return fmt.Errorf("%w: %s", ErrQuotaExceeded, envelope.Information)
+    }
+    return nil
+}
+
 // GetStockQuote retrieves the latest stock quote
 func (c *AlphaVantageClient) GetStockQuote(ctx context.Context, symbol string) (*StockQuote, error) {
     log.Printf("Fetching stock quote for symbol: %s", symbol)
@@ -103,7 +164,7 @@ func (c *AlphaVantageClient) GetStockQuote(ctx context.Context, symbol string) (
     }
 
     var response StockQuoteResponse
-    if err := c.DoJSON(ctx, req, &response); err != nil {
+    if err := c.doJSON(ctx, req, &response); err != nil {
         return nil, fmt.Errorf("failed to get stock quote: %w", err)
     }
 
@@ -126,7 +187,7 @@ func (c *AlphaVantageClient) GetIntradayTimeSeries(ctx context.Context, symbol s
     }
 
     var response TimeSeriesResponse
-    if err := c.DoJSON(ctx, req, &response); err != nil {
+    if err := c.doJSON(ctx, req, &response); err != nil {
         return nil, fmt.Errorf("failed to get time series: %w", err)
     }
 
@@ -134,6 +195,188 @@ func (c *AlphaVantageClient) GetIntradayTimeSeries(ctx context.Context, symbol s
     return &response, nil
 }
 
+// Name returns the provider name of the Alpha Vantage client
+func (c *AlphaVantageClient) Name() string {
+    return ProviderAlphaVantage
+}
+
+// adjustedSeriesData is a bar from the adjusted daily, weekly and monthly series
+type adjustedSeriesData struct {
+    Open          string `json:"1. open"`
+    High          string `json:"2. high"`
+    Low           string `json:"3. low"`
+    Close         string `json:"4. close"`
+    AdjustedClose string `json:"5. adjusted close"`
+    Volume        string `json:"6. volume"`
+}
+
+// alphaVantageSeries maps a bar size onto the time series function, extra query
+// parameters, response key and timestamp layout Alpha Vantage uses for it.
+// Daily and longer bars come from the adjusted series so a failover doesn't
+// mix unadjusted bars into stocks_daily next to Polygon's adjusted ones.
+func alphaVantageSeries(multiplier int, timespan Timespan) (function string, query map[string]string, key string, layout string, err error) {
+    switch timespan {
+    case TimespanMinute, TimespanHour:
+        minutes := multiplier
+        if timespan == TimespanHour {
+            minutes = multiplier * 60
+        }
+        switch minutes {
+        case 1, 5, 15, 30, 60:
+        default:
+            return "", nil, "", "", fmt.Errorf("alpha vantage does not support %d-%s bars", multiplier, timespan)
+        }
+        interval := fmt.Sprintf("%dmin", minutes)
+        return "TIME_SERIES_INTRADAY", map[string]string{"interval": interval}, "Time Series (" + interval + ")", "2006-01-02 15:04:05", nil
+    }
+
+    if multiplier != 1 {
+        return "", nil, "", "", fmt.Errorf("alpha vantage does not support %d-%s bars", multiplier, timespan)
+    }
+    switch timespan {
+    case TimespanDay:
+        return "TIME_SERIES_DAILY_ADJUSTED", nil, "Time Series (Daily)", "2006-01-02", nil
+    case TimespanWeek:
+        return "TIME_SERIES_WEEKLY_ADJUSTED", nil, "Weekly Adjusted Time Series", "2006-01-02", nil
+    case TimespanMonth:
+        return "TIME_SERIES_MONTHLY_ADJUSTED", nil, "Monthly Adjusted Time Series", "2006-01-02", nil
+    }
+    return "", nil, "", "", fmt.Errorf("alpha vantage does not support %s bars", timespan)
+}
+
+// GetBars implements BarsProvider using the time series endpoints
+func (c *AlphaVantageClient) GetBars(ctx context.Context, symbol string, from, to time.Time, multiplier int, timespan Timespan) ([]Bar, error) {
+    function, extra, key, layout, err := alphaVantageSeries(multiplier, timespan)
+    if err != nil {
+        return nil, err
+    }
+
+    req := &Request{
+        Method: "GET",
+        Path:   "/query",
+        Query: map[string]string{
+            "function":   function,
+            "outputsize": "full",
+            "symbol":     symbol,
+            "apikey":     c.apiKey,
+        },
+    }
+    for k, v := range extra {
+        req.Query[k] = v
+    }
+
+    var response map[string]json.RawMessage
+    if err := c.doJSON(ctx, req, &response); err != nil {
+        return nil, fmt.Errorf("failed to get time series: %w", err)
+    }
+
+    raw, ok := response[key]
+    if !ok {
+        return nil, fmt.Errorf("no %s returned for %s", key, symbol)
+    }
+
+    // Alpha Vantage reports timestamps in US/Eastern
+    loc, err := time.LoadLocation("America/New_York")
+    if err != nil {
+        loc = time.UTC
+    }
+    inRange := func(t time.Time) bool {
+        return !t.Before(from) && !t.After(to.AddDate(0, 0, 1))
+    }
+
+    var bars []Bar
+    if strings.HasSuffix(function, "_ADJUSTED") {
+        var series map[string]adjustedSeriesData
+        if err := json.Unmarshal(raw, &series); err != nil {
+            return nil, fmt.Errorf("failed to decode time series: %w", err)
+        }
+        for ts, data := range series {
+            t, err := time.ParseInLocation(layout, ts, loc)
+            if err != nil || !inRange(t) {
+                continue
+            }
+            bars = append(bars, adjustBar(t, data))
+        }
+    } else {
+        var series map[string]TimeSeriesData
+        if err := json.Unmarshal(raw, &series); err != nil {
+            return nil, fmt.Errorf("failed to decode time series: %w", err)
+        }
+        for ts, data := range series {
+            t, err := time.ParseInLocation(layout, ts, loc)
+            if err != nil || !inRange(t) {
+                continue
+            }
+            bars = append(bars, Bar{
+                Timestamp: t,
+                Open:      parseFloatOrZero(data.Open),
+                High:      parseFloatOrZero(data.High),
+                Low:       parseFloatOrZero(data.Low),
+                Close:     parseFloatOrZero(data.Close),
+                Volume:    parseFloatOrZero(data.Volume),
+            })
+        }
+    }
+    sort.Slice(bars, func(i, j int) bool { return bars[i].Timestamp.Before(bars[j].Timestamp) })
+
+    log.Printf("Fetched %d bars for %s", len(bars), symbol)
+    return bars, nil
+}
+
+// adjustBar scales a bar by its adjustment factor (adjusted close over close),
+// so open, high and low line up with the adjusted close and volume is
+// restated in post-split shares. Alpha Vantage's adjusted close also
+// accounts for dividends.
+func adjustBar(t time.Time, data adjustedSeriesData) Bar {
+    bar := Bar{
+        Timestamp: t,
+        Open:      parseFloatOrZero(data.Open),
+        High:      parseFloatOrZero(data.High),
+        Low:       parseFloatOrZero(data.Low),
+        Close:     parseFloatOrZero(data.Close),
+        Volume:    parseFloatOrZero(data.Volume),
+    }
+    adjusted := parseFloatOrZero(data.AdjustedClose)
+    if adjusted <= 0 || bar.Close <= 0 {
+        return bar
+    }
+    factor := adjusted / bar.Close
+    bar.Open *= factor
+    bar.High *= factor
+    bar.Low *= factor
+    bar.Close = adjusted
+    bar.Volume /= factor
+    return bar
+}
+
+// GetQuote implements QuoteProvider using the global quote endpoint
+func (c *AlphaVantageClient) GetQuote(ctx context.Context, symbol string) (*Quote, error) {
+    quote, err := c.GetStockQuote(ctx, symbol)
+    if err != nil {
+        return nil, err
+    }
+
+    timestamp, _ := time.Parse("2006-01-02", quote.LatestTradingDay)
+    return &Quote{
+        Symbol:        quote.Symbol,
+        Price:         parseFloatOrZero(quote.Price),
+        Open:          parseFloatOrZero(quote.Open),
+        High:          parseFloatOrZero(quote.High),
+        Low:           parseFloatOrZero(quote.Low),
+        PreviousClose: parseFloatOrZero(quote.PreviousClose),
+        Change:        parseFloatOrZero(quote.Change),
+        ChangePercent: parseFloatOrZero(strings.TrimSuffix(quote.ChangePercent, "%")),
+        Timestamp:     timestamp,
+    }, nil
+}
+
+func parseFloatOrZero(s string) float64 {
+    if f := ParseFloat(s); f != nil {
+        return *f
+    }
+    return 0
+}
+
 func ParseFloat(s string) *float64 {
 	if f, err := strconv.ParseFloat(s, 64); err == nil {
 		return &f
@@ -162,8 +405,8 @@ func (c *AlphaVantageClient) GetOverview(ctx context.Context, symbol string) (*O
         },
     }
     var response OverviewResponse
-    if err := c.DoJSON(ctx, req, &response); err != nil {
-        return nil, fmt.Errorf("failed to get time series: %w", err)
+    if err := c.doJSON(ctx, req, &response); err != nil {
+        return nil, fmt.Errorf("failed to get overview: %w", err)
     }
 
 	// url := fmt.Sprintf("https://www.alphavantage.co/query?function=OVERVIEW&symbol=%s&apikey=%s", symbol, apiKey)
@@ -190,7 +433,7 @@ func (c *AlphaVantageClient) GetIncomeStatement(ctx context.Context, symbol stri
         },
     }
     var response IncomeStatement
-    if err := c.DoJSON(ctx, req, &response); err != nil {
+    if err := c.doJSON(ctx, req, &response); err != nil {
         return nil, fmt.Errorf("failed to get income statement: %w", err)
     }
 	// url := fmt.Sprintf("https://www.alphavantage.co/query?function=INCOME_STATEMENT&symbol=%s&apikey=%s", symbol, apiKey)
@@ -217,8 +460,8 @@ func (c *AlphaVantageClient) GetBalanceSheet(ctx context.Context, symbol string)
         },
     }
     var response BalanceSheet
-    if err := c.DoJSON(ctx, req, &response); err != nil {
-        return nil, fmt.Errorf("failed to get income statement: %w", err)
+    if err := c.doJSON(ctx, req, &response); err != nil {
+        return nil, fmt.Errorf("failed to get balance sheet: %w", err)
     }
 
 	// url := fmt.Sprintf("https://www.alphavantage.co/query?function=BALANCE_SHEET&symbol=%s&apikey=%s", symbol, apiKey)
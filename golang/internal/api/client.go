@@ -1,11 +1,15 @@
 package api
 
 import (
+    "bytes"
     "context"
     "encoding/json"
     "fmt"
     "io"
+    "log"
     "net/http"
+    "net/url"
+    "strings"
     "time"
     "golang.org/x/time/rate"
 )
@@ -17,6 +21,18 @@ type APIClient struct {
     baseURL         string
     apiKey          string
     requestTimeout  time.Duration
+    retryPolicy     RetryPolicy
+    quota           QuotaTracker
+    provider        string
+}
+
+// QuotaTracker decides whether an outbound call fits in a provider's budget and
+// records it. It returns an error wrapping ErrQuotaExceeded when it does not.
+// Exhausted reports, without spending a call, whether the budget is known to
+// be used up.
+type QuotaTracker interface {
+    Acquire(ctx context.Context, provider, apiKey string) error
+    Exhausted(provider, apiKey string) bool
 }
 
 // NewAPIClient creates a new API client with rate limiting
@@ -29,13 +45,36 @@ func NewAPIClient(baseURL, apiKey string, requestsPerMinute int, timeout time.Du
         baseURL:        baseURL,
         apiKey:         apiKey,
         requestTimeout: timeout,
+        retryPolicy:    DefaultRetryPolicy,
+    }
+}
+
+// SetRetryPolicy overrides the retry policy used by Do
+func (c *APIClient) SetRetryPolicy(policy RetryPolicy) {
+    c.retryPolicy = policy
+}
+
+// SetQuotaTracker makes every outbound call go through the tracker under the given provider name
+func (c *APIClient) SetQuotaTracker(provider string, tracker QuotaTracker) {
+    c.provider = provider
+    c.quota = tracker
+}
+
+// Throttled reports whether the next request would have to wait for the rate
+// limiter or would be refused by the persistent quota
+func (c *APIClient) Throttled() bool {
+    if c.quota != nil && c.quota.Exhausted(c.provider, c.apiKey) {
+        return true
     }
+    return c.rateLimiter.Tokens() < 1
 }
 
 // Request represents a generic API request
 type Request struct {
     Method  string
     Path    string
+    // URL, when set, is used instead of the base URL and Path (e.g. pagination links)
+    URL     string
     Query   map[string]string
     Headers map[string]string
     Body    io.Reader
@@ -48,29 +87,90 @@ type Response struct {
     Headers    http.Header
 }
 
-// Do performs an API request with rate limiting
+// Do performs an API request with rate limiting, retrying transient failures
+// according to the client's retry policy. Responses with an error status are
+// returned as-is once retries are exhausted.
 func (c *APIClient) Do(ctx context.Context, req *Request) (*Response, error) {
+    // Buffer the body so it can be replayed on retries
+    var body []byte
+    if req.Body != nil {
+        var err error
+        body, err = io.ReadAll(req.Body)
+        if err != nil {
+            return nil, fmt.Errorf("failed to read request body: %w", err)
+        }
+    }
+
+    maxAttempts := c.retryPolicy.MaxAttempts
+    if maxAttempts < 1 || !isIdempotent(req.Method) {
+        maxAttempts = 1
+    }
+
+    for attempt := 1; ; attempt++ {
+        resp, httpResp, err := c.doOnce(ctx, req, body)
+
+        retryable := false
+        if err != nil {
+            retryable = isTransientError(ctx, err)
+        } else {
+            retryable = isTransientStatus(resp.StatusCode)
+        }
+        if !retryable || attempt >= maxAttempts {
+            return resp, err
+        }
+
+        wait := c.retryPolicy.delay(attempt, httpResp)
+        if err != nil {
+            log.Printf("Request to %s failed (attempt %d/%d), retrying in %s: %v", req.Path, attempt, maxAttempts, wait, err)
+        } else {
+            log.Printf("Request to %s returned %d (attempt %d/%d), retrying in %s", req.Path, resp.StatusCode, attempt, maxAttempts, wait)
+        }
+        if err := sleep(ctx, wait); err != nil {
+            return nil, err
+        }
+    }
+}
+
+// doOnce performs a single attempt of the request
+func (c *APIClient) doOnce(ctx context.Context, req *Request, body []byte) (*Response, *http.Response, error) {
     // Wait for rate limiter
     if err := c.rateLimiter.Wait(ctx); err != nil {
-        return nil, fmt.Errorf("rate limiter error: %w", err)
+        return nil, nil, fmt.Errorf("rate limiter error: %w", err)
+    }
+
+    // Check the persistent quota before spending a call
+    if c.quota != nil {
+        if err := c.quota.Acquire(ctx, c.provider, c.apiKey); err != nil {
+            return nil, nil, err
+        }
     }
 
     // Build URL
-    url := c.baseURL + req.Path
+    requestURL := c.baseURL + req.Path
+    if req.URL != "" {
+        requestURL = req.URL
+    }
     if len(req.Query) > 0 {
-        url += "?"
+        query := url.Values{}
         for key, value := range req.Query {
-            if url[len(url)-1] != '?' {
-                url += "&"
-            }
-            url += fmt.Sprintf("%s=%s", key, value)
+            query.Set(key, value)
+        }
+        separator := "?"
+        if strings.Contains(requestURL, "?") {
+            separator = "&"
         }
+        requestURL += separator + query.Encode()
+    }
+
+    var bodyReader io.Reader
+    if body != nil {
+        bodyReader = bytes.NewReader(body)
     }
 
     // Create HTTP request
-    httpReq, err := http.NewRequestWithContext(ctx, req.Method, url, req.Body)
+    httpReq, err := http.NewRequestWithContext(ctx, req.Method, requestURL, bodyReader)
     if err != nil {
-        return nil, fmt.Errorf("failed to create request: %w", err)
+        return nil, nil, fmt.Errorf("failed to create request: %w", err)
     }
 
     // Add headers
@@ -86,33 +186,34 @@ func (c *APIClient) Do(ctx context.Context, req *Request) (*Response, error) {
     // Make request
     resp, err := c.client.Do(httpReq)
     if err != nil {
-        return nil, fmt.Errorf("request failed: %w", err)
+        return nil, nil, fmt.Errorf("request failed: %w", err)
     }
     defer resp.Body.Close()
 
     // Read response body
-    body, err := io.ReadAll(resp.Body)
+    respBody, err := io.ReadAll(resp.Body)
     if err != nil {
-        return nil, fmt.Errorf("failed to read response body: %w", err)
+        return nil, resp, fmt.Errorf("failed to read response body: %w", err)
     }
 
     return &Response{
         StatusCode: resp.StatusCode,
-        Body:       body,
+        Body:       respBody,
         Headers:    resp.Header,
-    }, nil
+    }, resp, nil
 }
 
-// DoJSON performs a request and unmarshals the response into the provided interface
+// DoJSON performs a request and unmarshals the response into the provided interface.
+// Error statuses are returned as *StatusError.
 func (c *APIClient) DoJSON(ctx context.Context, req *Request, v interface{}) error {
     resp, err := c.Do(ctx, req)
     if err != nil {
         return err
     }
 
-    if resp.StatusCode >= 400 {
-        return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(resp.Body))
+    if err := checkStatus(resp); err != nil {
+        return err
     }
 
     return json.Unmarshal(resp.Body, v)
-} 
\ No newline at end of file
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+)
+
+// Errors returned by API clients, inspectable with errors.Is
+var (
+    // ErrRateLimited is returned when the provider answered with HTTP 429
+    ErrRateLimited = errors.New("rate limited by provider")
+    // ErrUpstream is returned when the provider answered with a 5xx status
+    ErrUpstream = errors.New("upstream provider error")
+    // ErrNotFound is returned when the provider answered with HTTP 404
+    ErrNotFound = errors.New("resource not found")
+    // ErrQuotaExceeded is returned when the provider reports that the call quota is used up
+    ErrQuotaExceeded = errors.New("provider quota exceeded")
+    // ErrInvalidSymbol is returned when the provider does not recognise the requested symbol
+    ErrInvalidSymbol = errors.New("invalid symbol")
+)
+
+// StatusError describes a non-successful HTTP response from a provider
+type StatusError struct {
+    StatusCode int
+    Body       string
+}
+
+func (e *StatusError) Error() string {
+    return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// Unwrap maps the status code onto one of the sentinel errors
+func (e *StatusError) Unwrap() error {
+    switch {
+    case e.StatusCode == http.StatusTooManyRequests:
+        return ErrRateLimited
+    case e.StatusCode == http.StatusNotFound:
+        return ErrNotFound
+    case e.StatusCode >= 500:
+        return ErrUpstream
+    default:
+        return nil
+    }
+}
+
+// checkStatus returns a StatusError for responses with a 4xx or 5xx status
+func checkStatus(resp *Response) error {
+    if resp.StatusCode >= 400 {
+        return &StatusError{StatusCode: resp.StatusCode, Body: string(resp.Body)}
+    }
+    return nil
+}
@@ -21,8 +21,8 @@ func NewFinnhubClient(apiKey string, timeout time.Duration) *FinnhubClient  {
 
 // StockSymbol represents a stock symbol from Finnhub
 type StockSymbol struct {
-    Currency        string `json:"currency`   
-	Description     string `json:description`
+    Currency        string `json:"currency"`   
+	Description     string `json:"description"`
     DisplaySymbol   string `json:"displaySymbol"`
     Figi            string `json:"figi"`
     Mic             string `json:"mic"`
@@ -46,16 +46,22 @@ type CompanyProfile struct {
     FinnhubIndustry        string  `json:"finnhubIndustry"`
 }
 
-// // Quote represents a stock quote from Finnhub
-// type Quote struct [object Object]{
-//     CurrentPrice  float64 `json:"c"`
-//     Change        float64 `json:"d"`
-//     PercentChange float64:"json: "`
-//     HighPrice     float64 `json:"h"`
-//     LowPrice      float64 `json:l
-//     OpenPrice     float64 `json:"o"`
-//     PreviousClose float64 `json:"pc"`
-// }
+// FinnhubQuote represents a stock quote from Finnhub
+type FinnhubQuote struct {
+    CurrentPrice  float64 `json:"c"`
+    Change        float64 `json:"d"`
+    PercentChange float64 `json:"dp"`
+    HighPrice     float64 `json:"h"`
+    LowPrice      float64 `json:"l"`
+    OpenPrice     float64 `json:"o"`
+    PreviousClose float64 `json:"pc"`
+    Timestamp     int64   `json:"t"`
+}
+
+// Name returns the provider name of the Finnhub client
+func (c *FinnhubClient) Name() string {
+    return ProviderFinnhub
+}
 
 // GetStockSymbols retrieves stock symbols for a specific exchange
 func (c *FinnhubClient) GetStockSymbols(ctx context.Context, exchange string) ([]StockSymbol, error) {
@@ -100,32 +106,39 @@ func (c *FinnhubClient) GetCompanyProfile(ctx context.Context, symbol string) (*
     return &profile, nil
 }
 
-// // GetQuote retrieves current quote for a symbol
-// func (c *FinnhubClient) GetQuote(ctx context.Context, symbol string) (*Quote, error) [object Object] log.Printf("Fetching quote for symbol: %s", symbol)
-    
-//     req := &Request{
-//         Method: "GET,        Path:  /quote",
-//         Query: map[string]string{
-//             symbolymbol,
-//             token":  c.apiKey,
-//         },
-//     }
-
-//     var quote Quote
-//     if err := c.DoJSON(ctx, req, &quote); err != nil {
-//         return nil, fmt.Errorf("failed to get quote: %w, err)
-//     }
-
-//     log.Printf("Successfully fetched quote for %s: $%.2bol, quote.CurrentPrice)
-//     return &quote, nil
-// }
-
-// // GetLatestPrice gets the latest price for a symbol
-// func (c *FinnhubClient) GetLatestPrice(ctx context.Context, symbol string) (float64, error) {
-//     quote, err := c.GetQuote(ctx, symbol)
-//     if err != nil [object Object]
-//         return0
-//     }
-
-//     return quote.CurrentPrice, nil
-// } 
\ No newline at end of file
+// GetQuote retrieves current quote for a symbol
+func (c *FinnhubClient) GetQuote(ctx context.Context, symbol string) (*Quote, error) {
+    log.Printf("Fetching quote for symbol: %s", symbol)
+
+    req := &Request{
+        Method: "GET",
+        Path:   "/quote",
+        Query: map[string]string{
+            "symbol": symbol,
+            "token":  c.apiKey,
+        },
+    }
+
+    var quote FinnhubQuote
+    if err := c.DoJSON(ctx, req, &quote); err != nil {
+        return nil, fmt.Errorf("failed to get quote: %w", err)
+    }
+
+    // Finnhub answers unknown symbols with an all-zero quote
+    if quote.Timestamp == 0 {
+        return nil, fmt.Errorf("no quote returned for %s", symbol)
+    }
+
+    log.Printf("Successfully fetched quote for %s: $%.2f", symbol, quote.CurrentPrice)
+    return &Quote{
+        Symbol:        symbol,
+        Price:         quote.CurrentPrice,
+        Open:          quote.OpenPrice,
+        High:          quote.HighPrice,
+        Low:           quote.LowPrice,
+        PreviousClose: quote.PreviousClose,
+        Change:        quote.Change,
+        ChangePercent: quote.PercentChange,
+        Timestamp:     time.Unix(quote.Timestamp, 0),
+    }, nil
+}
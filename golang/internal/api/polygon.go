@@ -2,11 +2,8 @@ package api
 
 import (
     "context"
-    "encoding/json"
     "fmt"
-    "io"
     "log"
-    "net/http"
     "time"
 )
 
@@ -42,37 +39,83 @@ type PolygonAggResponse struct {
     RequestID     string        `json:"request_id"`
 }
 
+// GetIntradayBars retrieves minute aggregates for a symbol
 func (c *PolygonClient) GetIntradayBars(ctx context.Context, symbol string, from, to time.Time, intervalMinutes int) ([]PolygonAgg, string ,error) {
-    url := fmt.Sprintf(
-        "https://api.polygon.io/v2/aggs/ticker/%s/range/%d/minute/%s/%s?adjusted=true&sort=asc&limit=50000&apiKey=%s",
-        symbol,
-        intervalMinutes,
-        from.Format("2006-01-02"),
-        to.Format("2006-01-02"),
-        c.apiKey,
-    )
-	
-    req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-    if err != nil {
-        return nil, symbol, fmt.Errorf("failed to create request: %w", err)
+    aggs, err := c.GetAggregates(ctx, symbol, from, to, intervalMinutes, TimespanMinute)
+    return aggs, symbol, err
+}
+
+// GetAggregates retrieves multiplier * timespan aggregates for a symbol, following
+// next_url until every page has been fetched
+func (c *PolygonClient) GetAggregates(ctx context.Context, symbol string, from, to time.Time, multiplier int, timespan Timespan) ([]PolygonAgg, error) {
+    if multiplier < 1 {
+        multiplier = 1
     }
 
-    resp, err := http.DefaultClient.Do(req)
-    if err != nil {
-        return nil, symbol, fmt.Errorf("request failed: %w", err)
+    req := &Request{
+        Method: "GET",
+        Path: fmt.Sprintf(
+            "/aggs/ticker/%s/range/%d/%s/%s/%s",
+            symbol,
+            multiplier,
+            timespan,
+            from.Format("2006-01-02"),
+            to.Format("2006-01-02"),
+        ),
+        Query: map[string]string{
+            "adjusted": "true",
+            "sort":     "asc",
+            "limit":    "50000",
+            "apiKey":   c.apiKey,
+        },
     }
-    defer resp.Body.Close()
 
-    if resp.StatusCode != 200 {
-        body, _ := io.ReadAll(resp.Body)
-        return nil, symbol, fmt.Errorf("API error: %s", string(body))
+    var aggs []PolygonAgg
+    for page := 1; ; page++ {
+        var polygonResp PolygonAggResponse
+        if err := c.DoJSON(ctx, req, &polygonResp); err != nil {
+            return nil, fmt.Errorf("failed to get aggregates (page %d): %w", page, err)
+        }
+        aggs = append(aggs, polygonResp.Results...)
+
+        if polygonResp.NextURL == "" || len(polygonResp.Results) == 0 {
+            break
+        }
+
+        // next_url carries the cursor but not the API key
+        req = &Request{
+            Method: "GET",
+            URL:    polygonResp.NextURL,
+            Query:  map[string]string{"apiKey": c.apiKey},
+        }
     }
 
-    var polygonResp PolygonAggResponse
-    if err := json.NewDecoder(resp.Body).Decode(&polygonResp); err != nil {
-        return nil, symbol, fmt.Errorf("failed to decode response: %w", err)
+    log.Printf("Fetched %d %d-%s bars for %s", len(aggs), multiplier, timespan, symbol)
+    return aggs, nil
+}
+
+// Name returns the provider name of the Polygon client
+func (c *PolygonClient) Name() string {
+    return ProviderPolygon
+}
+
+// GetBars implements BarsProvider using the aggregates endpoint
+func (c *PolygonClient) GetBars(ctx context.Context, symbol string, from, to time.Time, multiplier int, timespan Timespan) ([]Bar, error) {
+    aggs, err := c.GetAggregates(ctx, symbol, from, to, multiplier, timespan)
+    if err != nil {
+        return nil, err
     }
 
-    log.Printf("Fetched %d bars for %s", len(polygonResp.Results), symbol)
-    return polygonResp.Results, symbol, nil
+    bars := make([]Bar, 0, len(aggs))
+    for _, agg := range aggs {
+        bars = append(bars, Bar{
+            Timestamp: time.UnixMilli(agg.Timestamp),
+            Open:      agg.Open,
+            High:      agg.High,
+            Low:       agg.Low,
+            Close:     agg.Close,
+            Volume:    agg.Volume,
+        })
+    }
+    return bars, nil
 }
@@ -0,0 +1,105 @@
+package api
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "time"
+)
+
+// Provider names used to build the provider priority order
+const (
+    ProviderPolygon      = "polygon"
+    ProviderAlphaVantage = "alphavantage"
+    ProviderFinnhub      = "finnhub"
+)
+
+// Timespan is the unit of a bar's duration; a bar covers multiplier * timespan
+type Timespan string
+
+const (
+    TimespanMinute Timespan = "minute"
+    TimespanHour   Timespan = "hour"
+    TimespanDay    Timespan = "day"
+    TimespanWeek   Timespan = "week"
+    TimespanMonth  Timespan = "month"
+)
+
+// ParseTimespan validates a timespan name, defaulting to minute when empty
+func ParseTimespan(s string) (Timespan, error) {
+    switch Timespan(strings.ToLower(s)) {
+    case "":
+        return TimespanMinute, nil
+    case TimespanMinute, TimespanHour, TimespanDay, TimespanWeek, TimespanMonth:
+        return Timespan(strings.ToLower(s)), nil
+    }
+    return "", fmt.Errorf("invalid timespan %q (use minute, hour, day, week or month)", s)
+}
+
+// Intraday reports whether bars of this timespan are shorter than a trading day
+func (t Timespan) Intraday() bool {
+    return t == TimespanMinute || t == TimespanHour
+}
+
+// Bar represents a single OHLCV bar, independent of the provider it came from
+type Bar struct {
+    Timestamp time.Time
+    Open      float64
+    High      float64
+    Low       float64
+    Close     float64
+    Volume    float64
+}
+
+// Quote represents the latest quote for a symbol, independent of the provider it came from
+type Quote struct {
+    Symbol        string
+    Price         float64
+    Open          float64
+    High          float64
+    Low           float64
+    PreviousClose float64
+    Change        float64
+    ChangePercent float64
+    Timestamp     time.Time
+}
+
+// Provider is implemented by every market-data client
+type Provider interface {
+    Name() string
+    // Throttled reports whether the provider would have to wait for its rate
+    // limit right now, or has used up its call budget
+    Throttled() bool
+}
+
+// BarsProvider fetches historical OHLCV bars
+type BarsProvider interface {
+    Provider
+    GetBars(ctx context.Context, symbol string, from, to time.Time, multiplier int, timespan Timespan) ([]Bar, error)
+}
+
+// QuoteProvider fetches the latest quote for a symbol
+type QuoteProvider interface {
+    Provider
+    GetQuote(ctx context.Context, symbol string) (*Quote, error)
+}
+
+// FundamentalsProvider fetches company fundamentals
+type FundamentalsProvider interface {
+    Provider
+    GetOverview(ctx context.Context, symbol string) (*OverviewResponse, error)
+    GetIncomeStatement(ctx context.Context, symbol string) (*IncomeStatement, error)
+    GetBalanceSheet(ctx context.Context, symbol string) (*BalanceSheet, error)
+}
+
+// SymbolsProvider lists the symbols traded on an exchange
+type SymbolsProvider interface {
+    Provider
+    GetStockSymbols(ctx context.Context, exchange string) ([]StockSymbol, error)
+}
+
+// ProfileProvider fetches company profiles
+type ProfileProvider interface {
+    Provider
+    GetCompanyProfile(ctx context.Context, symbol string) (*CompanyProfile, error)
+}
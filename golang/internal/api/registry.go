@@ -0,0 +1,138 @@
+package api
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "time"
+)
+
+// ErrNoProvider is returned when no registered provider supports a capability
+var ErrNoProvider = errors.New("no provider available")
+
+// Registry holds the configured market-data providers in priority order and
+// falls back to the next provider when a call fails
+type Registry struct {
+    providers []Provider
+}
+
+// NewRegistry creates a registry ordering the given providers by the names in
+// priority. Providers not named in priority keep their relative order after
+// the named ones.
+func NewRegistry(priority []string, providers ...Provider) *Registry {
+    ordered := make([]Provider, 0, len(providers))
+    used := make(map[int]bool)
+
+    for _, name := range priority {
+        for i, p := range providers {
+            if !used[i] && p.Name() == name {
+                ordered = append(ordered, p)
+                used[i] = true
+            }
+        }
+    }
+    for i, p := range providers {
+        if !used[i] {
+            ordered = append(ordered, p)
+        }
+    }
+
+    return &Registry{providers: ordered}
+}
+
+// Providers returns the registered providers in priority order
+func (r *Registry) Providers() []Provider {
+    return r.providers
+}
+
+// candidates returns the providers implementing P in priority order, moving
+// throttled providers behind the ones that can be called right away
+func candidates[P Provider](r *Registry) []P {
+    var ready, throttled []P
+    for _, p := range r.providers {
+        c, ok := p.(P)
+        if !ok {
+            continue
+        }
+        if c.Throttled() {
+            throttled = append(throttled, c)
+        } else {
+            ready = append(ready, c)
+        }
+    }
+    return append(ready, throttled...)
+}
+
+// withFailover calls fn on each provider in turn until one succeeds
+func withFailover[P Provider, T any](ctx context.Context, r *Registry, capability string, fn func(P) (T, error)) (T, error) {
+    var zero T
+    providers := candidates[P](r)
+    if len(providers) == 0 {
+        return zero, fmt.Errorf("%s: %w", capability, ErrNoProvider)
+    }
+
+    var errs []error
+    for _, p := range providers {
+        result, err := fn(p)
+        if err == nil {
+            return result, nil
+        }
+        if ctx.Err() != nil {
+            return zero, ctx.Err()
+        }
+        log.Printf("Provider %s failed for %s, trying next provider: %v", p.Name(), capability, err)
+        errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+    }
+
+    return zero, fmt.Errorf("all providers failed for %s: %w", capability, errors.Join(errs...))
+}
+
+// GetBars fetches bars from the first provider that succeeds
+func (r *Registry) GetBars(ctx context.Context, symbol string, from, to time.Time, multiplier int, timespan Timespan) ([]Bar, error) {
+    return withFailover(ctx, r, "bars", func(p BarsProvider) ([]Bar, error) {
+        return p.GetBars(ctx, symbol, from, to, multiplier, timespan)
+    })
+}
+
+// GetQuote fetches the latest quote from the first provider that succeeds
+func (r *Registry) GetQuote(ctx context.Context, symbol string) (*Quote, error) {
+    return withFailover(ctx, r, "quote", func(p QuoteProvider) (*Quote, error) {
+        return p.GetQuote(ctx, symbol)
+    })
+}
+
+// GetOverview fetches the company overview from the first provider that succeeds
+func (r *Registry) GetOverview(ctx context.Context, symbol string) (*OverviewResponse, error) {
+    return withFailover(ctx, r, "overview", func(p FundamentalsProvider) (*OverviewResponse, error) {
+        return p.GetOverview(ctx, symbol)
+    })
+}
+
+// GetIncomeStatement fetches the income statement from the first provider that succeeds
+func (r *Registry) GetIncomeStatement(ctx context.Context, symbol string) (*IncomeStatement, error) {
+    return withFailover(ctx, r, "income statement", func(p FundamentalsProvider) (*IncomeStatement, error) {
+        return p.GetIncomeStatement(ctx, symbol)
+    })
+}
+
+// GetBalanceSheet fetches the balance sheet from the first provider that succeeds
+func (r *Registry) GetBalanceSheet(ctx context.Context, symbol string) (*BalanceSheet, error) {
+    return withFailover(ctx, r, "balance sheet", func(p FundamentalsProvider) (*BalanceSheet, error) {
+        return p.GetBalanceSheet(ctx, symbol)
+    })
+}
+
+// GetStockSymbols lists exchange symbols from the first provider that succeeds
+func (r *Registry) GetStockSymbols(ctx context.Context, exchange string) ([]StockSymbol, error) {
+    return withFailover(ctx, r, "symbols", func(p SymbolsProvider) ([]StockSymbol, error) {
+        return p.GetStockSymbols(ctx, exchange)
+    })
+}
+
+// GetCompanyProfile fetches the company profile from the first provider that succeeds
+func (r *Registry) GetCompanyProfile(ctx context.Context, symbol string) (*CompanyProfile, error) {
+    return withFailover(ctx, r, "company profile", func(p ProfileProvider) (*CompanyProfile, error) {
+        return p.GetCompanyProfile(ctx, symbol)
+    })
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "reflect"
+    "testing"
+    "time"
+)
+
+// fakeProvider is a BarsProvider that records its calls in a shared log
+type fakeProvider struct {
+    name      string
+    throttled bool
+    err       error
+    calls     *[]string
+}
+
+func (f *fakeProvider) Name() string    { return f.name }
+func (f *fakeProvider) Throttled() bool { return f.throttled }
+
+func (f *fakeProvider) GetBars(ctx context.Context, symbol string, from, to time.Time, multiplier int, timespan Timespan) ([]Bar, error) {
+    *f.calls = append(*f.calls, f.name)
+    if f.err != nil {
+        return nil, f.err
+    }
+    return []Bar{{Close: float64(len(f.name))}}, nil
+}
+
+// fakeQuota is a QuotaTracker whose budget is either free or used up
+type fakeQuota struct {
+    exhausted bool
+}
+
+func (q *fakeQuota) Acquire(ctx context.Context, provider, apiKey string) error {
+    if q.exhausted {
+        return fmt.Errorf("%w: %s", ErrQuotaExceeded, provider)
+    }
+    return nil
+}
+
+func (q *fakeQuota) Exhausted(provider, apiKey string) bool {
+    return q.exhausted
+}
+
+func TestRegistryFailover(t *testing.T) {
+    tests := []struct {
+        name      string
+        priority  []string
+        providers []fakeProvider
+        wantCalls []string
+        // wantErr is nil when a provider should succeed
+        wantErr error
+    }{
+        {
+            name:      "first provider succeeds",
+            priority:  []string{"a", "b"},
+            providers: []fakeProvider{{name: "a"}, {name: "b"}},
+            wantCalls: []string{"a"},
+        },
+        {
+            name:      "priority order overrides registration order",
+            priority:  []string{"b", "a"},
+            providers: []fakeProvider{{name: "a"}, {name: "b"}},
+            wantCalls: []string{"b"},
+        },
+        {
+            name:      "unnamed providers come after named ones",
+            priority:  []string{"c"},
+            providers: []fakeProvider{{name: "a", err: ErrUpstream}, {name: "b"}, {name: "c", err: ErrUpstream}},
+            wantCalls: []string{"c", "a", "b"},
+        },
+        {
+            name:      "falls back on error",
+            priority:  []string{"a", "b"},
+            providers: []fakeProvider{{name: "a", err: ErrUpstream}, {name: "b"}},
+            wantCalls: []string{"a", "b"},
+        },
+        {
+            name:      "falls back when the quota is exceeded",
+            priority:  []string{"a", "b"},
+            providers: []fakeProvider{{name: "a", err: fmt.Errorf("%w: daily budget", ErrQuotaExceeded)}, {name: "b"}},
+            wantCalls: []string{"a", "b"},
+        },
+        {
+            name:      "throttled providers are tried last",
+            priority:  []string{"a", "b", "c"},
+            providers: []fakeProvider{{name: "a", throttled: true}, {name: "b", err: ErrRateLimited}, {name: "c"}},
+            wantCalls: []string{"b", "c"},
+        },
+        {
+            name:      "throttled provider is still used when the rest fail",
+            priority:  []string{"a", "b"},
+            providers: []fakeProvider{{name: "a", throttled: true}, {name: "b", err: ErrUpstream}},
+            wantCalls: []string{"b", "a"},
+        },
+        {
+            name:      "all providers fail",
+            priority:  []string{"a", "b"},
+            providers: []fakeProvider{{name: "a", err: ErrUpstream}, {name: "b", err: ErrQuotaExceeded}},
+            wantCalls: []string{"a", "b"},
+            wantErr:   ErrQuotaExceeded,
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var calls []string
+            providers := make([]Provider, len(tt.providers))
+            for i := range tt.providers {
+                tt.providers[i].calls = &calls
+                providers[i] = &tt.providers[i]
+            }
+
+            bars, err := NewRegistry(tt.priority, providers...).GetBars(context.Background(), "AAPL", time.Time{}, time.Time{}, 1, TimespanDay)
+            if !reflect.DeepEqual(calls, tt.wantCalls) {
+                t.Errorf("called %v, want %v", calls, tt.wantCalls)
+            }
+            if tt.wantErr != nil {
+                if !errors.Is(err, tt.wantErr) {
+                    t.Errorf("error = %v, want %v", err, tt.wantErr)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("unexpected error: %v", err)
+            }
+            if len(bars) != 1 {
+                t.Fatalf("got %d bars, want 1", len(bars))
+            }
+        })
+    }
+}
+
+func TestRegistryNoProvider(t *testing.T) {
+    var calls []string
+    r := NewRegistry(nil, &fakeProvider{name: "a", calls: &calls})
+
+    _, err := r.GetQuote(context.Background(), "AAPL")
+    if !errors.Is(err, ErrNoProvider) {
+        t.Errorf("error = %v, want %v", err, ErrNoProvider)
+    }
+}
+
+func TestRegistryStopsWhenContextEnds(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    var calls []string
+    r := NewRegistry([]string{"a", "b"},
+        &fakeProvider{name: "a", err: context.Canceled, calls: &calls},
+        &fakeProvider{name: "b", calls: &calls})
+
+    _, err := r.GetBars(ctx, "AAPL", time.Time{}, time.Time{}, 1, TimespanDay)
+    if !errors.Is(err, context.Canceled) {
+        t.Errorf("error = %v, want %v", err, context.Canceled)
+    }
+    if !reflect.DeepEqual(calls, []string{"a"}) {
+        t.Errorf("called %v, want [a]", calls)
+    }
+}
+
+func TestClientThrottledByExhaustedQuota(t *testing.T) {
+    quota := &fakeQuota{}
+    client := NewPolygonClient("key", time.Second)
+    client.SetQuotaTracker(ProviderPolygon, quota)
+
+    var calls []string
+    fallback := &fakeProvider{name: "fallback", calls: &calls}
+    r := NewRegistry([]string{ProviderPolygon, "fallback"}, client, fallback)
+
+    if got := candidates[BarsProvider](r); got[0] != client {
+        t.Fatalf("with budget left, first candidate = %s, want %s", got[0].Name(), ProviderPolygon)
+    }
+
+    quota.exhausted = true
+    if !client.Throttled() {
+        t.Fatal("client with an exhausted quota isn't throttled")
+    }
+    if got := candidates[BarsProvider](r); got[0] != fallback || got[1] != client {
+        t.Errorf("with the quota used up, candidates = [%s %s], want [fallback %s]", got[0].Name(), got[1].Name(), ProviderPolygon)
+    }
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+    "context"
+    "errors"
+    "io"
+    "math/rand"
+    "net"
+    "net/http"
+    "strconv"
+    "syscall"
+    "time"
+)
+
+// RetryPolicy controls how APIClient retries transient failures
+type RetryPolicy struct {
+    // MaxAttempts is the total number of attempts, including the first one
+    MaxAttempts int
+    // BaseDelay is the backoff before the first retry; it doubles on every attempt
+    BaseDelay time.Duration
+    // MaxDelay caps both the computed backoff and any Retry-After the provider asks for
+    MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is used by clients that don't configure their own
+var DefaultRetryPolicy = RetryPolicy{
+    MaxAttempts: 4,
+    BaseDelay:   500 * time.Millisecond,
+    MaxDelay:    30 * time.Second,
+}
+
+// NoRetry makes a single attempt
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// backoff returns a jittered exponential delay for the given retry (1-based)
+func (p RetryPolicy) backoff(retry int) time.Duration {
+    if p.BaseDelay <= 0 {
+        return 0
+    }
+    delay := p.BaseDelay << (retry - 1)
+    if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+        delay = p.MaxDelay
+    }
+    // Full jitter spreads retries from concurrent batches apart
+    return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// delay returns how long to wait before the given retry, preferring the
+// provider's Retry-After header when present
+func (p RetryPolicy) delay(retry int, resp *http.Response) time.Duration {
+    if resp != nil {
+        if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+            if p.MaxDelay > 0 && wait > p.MaxDelay {
+                return p.MaxDelay
+            }
+            return wait
+        }
+    }
+    return p.backoff(retry)
+}
+
+// parseRetryAfter understands both the delay-seconds and HTTP-date forms
+func parseRetryAfter(value string) (time.Duration, bool) {
+    if value == "" {
+        return 0, false
+    }
+    if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+        return time.Duration(seconds) * time.Second, true
+    }
+    if t, err := http.ParseTime(value); err == nil {
+        wait := time.Until(t)
+        if wait < 0 {
+            wait = 0
+        }
+        return wait, true
+    }
+    return 0, false
+}
+
+// isIdempotent reports whether a request with this method can safely be replayed
+func isIdempotent(method string) bool {
+    switch method {
+    case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+        return true
+    }
+    return false
+}
+
+// isTransientStatus reports whether a response status is worth retrying
+func isTransientStatus(status int) bool {
+    return status == http.StatusTooManyRequests || status >= 500
+}
+
+// isTransientError reports whether a transport error is worth retrying
+func isTransientError(ctx context.Context, err error) bool {
+    if ctx.Err() != nil {
+        return false
+    }
+    var netErr net.Error
+    if errors.As(err, &netErr) {
+        return true
+    }
+    return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+    if d <= 0 {
+        return nil
+    }
+    timer := time.NewTimer(d)
+    defer timer.Stop()
+    select {
+    case <-ctx.Done():
+        return ctx.Err()
+    case <-timer.C:
+        return nil
+    }
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+    "errors"
+    "net/http"
+    "testing"
+    "time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+    tests := []struct {
+        name   string
+        value  string
+        want   time.Duration
+        wantOK bool
+    }{
+        {name: "missing", value: ""},
+        {name: "seconds", value: "120", want: 2 * time.Minute, wantOK: true},
+        {name: "zero seconds", value: "0", want: 0, wantOK: true},
+        {name: "negative seconds", value: "-5"},
+        {name: "date in the past", value: "Wed, 21 Oct 2015 07:28:00 GMT", want: 0, wantOK: true},
+        {name: "garbage", value: "soon"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, ok := parseRetryAfter(tt.value)
+            if ok != tt.wantOK || got != tt.want {
+                t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+            }
+        })
+    }
+
+    t.Run("date in the future", func(t *testing.T) {
+        at := time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat)
+        got, ok := parseRetryAfter(at)
+        // HTTP dates have whole seconds, so allow for the truncation and the clock moving on
+        if !ok || got < 85*time.Second || got > 90*time.Second {
+            t.Errorf("parseRetryAfter(%q) = %v, %v, want about 90s", at, got, ok)
+        }
+    })
+}
+
+func TestBackoff(t *testing.T) {
+    policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+    tests := []struct {
+        retry int
+        // ceiling is the un-jittered delay the retry is drawn below
+        ceiling time.Duration
+    }{
+        {1, 100 * time.Millisecond},
+        {2, 200 * time.Millisecond},
+        {4, 800 * time.Millisecond},
+        {5, time.Second},
+        {40, time.Second},
+        // Shifts past the width of a Duration overflow and are capped too
+        {80, time.Second},
+    }
+
+    for _, tt := range tests {
+        for i := 0; i < 200; i++ {
+            if got := policy.backoff(tt.retry); got < 0 || got > tt.ceiling {
+                t.Fatalf("backoff(%d) = %v, want within [0, %v]", tt.retry, got, tt.ceiling)
+            }
+        }
+    }
+
+    if got := (RetryPolicy{MaxDelay: time.Second}).backoff(3); got != 0 {
+        t.Errorf("backoff without a base delay = %v, want 0", got)
+    }
+}
+
+func TestDelayPrefersRetryAfter(t *testing.T) {
+    policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Second}
+
+    resp := &http.Response{Header: http.Header{"Retry-After": []string{"5"}}}
+    if got := policy.delay(1, resp); got != 5*time.Second {
+        t.Errorf("delay with Retry-After 5 = %v, want 5s", got)
+    }
+
+    resp.Header.Set("Retry-After", "3600")
+    if got := policy.delay(1, resp); got != 10*time.Second {
+        t.Errorf("delay with Retry-After 3600 = %v, want the 10s cap", got)
+    }
+
+    if got := policy.delay(1, nil); got > time.Millisecond {
+        t.Errorf("delay without a response = %v, want the backoff", got)
+    }
+}
+
+func TestIsIdempotent(t *testing.T) {
+    tests := map[string]bool{
+        "":                 true,
+        http.MethodGet:     true,
+        http.MethodHead:    true,
+        http.MethodOptions: true,
+        http.MethodPut:     true,
+        http.MethodDelete:  true,
+        http.MethodPost:    false,
+        http.MethodPatch:   false,
+    }
+    for method, want := range tests {
+        if got := isIdempotent(method); got != want {
+            t.Errorf("isIdempotent(%q) = %v, want %v", method, got, want)
+        }
+    }
+}
+
+func TestStatusErrorUnwrap(t *testing.T) {
+    sentinels := []error{ErrRateLimited, ErrNotFound, ErrUpstream}
+    tests := []struct {
+        status int
+        // want is nil for statuses that map to no sentinel
+        want error
+    }{
+        {http.StatusTooManyRequests, ErrRateLimited},
+        {http.StatusNotFound, ErrNotFound},
+        {http.StatusInternalServerError, ErrUpstream},
+        {http.StatusBadGateway, ErrUpstream},
+        {http.StatusServiceUnavailable, ErrUpstream},
+        {http.StatusBadRequest, nil},
+        {http.StatusUnauthorized, nil},
+    }
+
+    for _, tt := range tests {
+        err := checkStatus(&Response{StatusCode: tt.status, Body: []byte("body")})
+        var statusErr *StatusError
+        if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.status {
+            t.Errorf("status %d: error = %v, want a StatusError", tt.status, err)
+            continue
+        }
+        for _, sentinel := range sentinels {
+            if got := errors.Is(err, sentinel); got != (sentinel == tt.want) {
+                t.Errorf("status %d: errors.Is(%v) = %v", tt.status, sentinel, got)
+            }
+        }
+    }
+
+    if err := checkStatus(&Response{StatusCode: http.StatusOK}); err != nil {
+        t.Errorf("status 200: error = %v, want nil", err)
+    }
+}
@@ -2,9 +2,10 @@ package api
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"net/http"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -24,6 +25,92 @@ type Transaction struct {
 	Category    string
 	Bank        string
 	Account     string
+	// Row is the 1-based row the transaction was read from in its source
+	Row int
+	// ExternalID is the source's own id for the transaction, such as an OFX
+	// FITID, or empty when it has none
+	ExternalID string
+}
+
+// RowsMapping maps the header names of a Rows table to transaction fields.
+// Empty fields fall back to DefaultRowsMapping.
+type RowsMapping struct {
+    Date        string `json:"date,omitempty"`
+    Amount      string `json:"amount,omitempty"`
+    Currency    string `json:"currency,omitempty"`
+    Description string `json:"description,omitempty"`
+    Category    string `json:"category,omitempty"`
+    Bank        string `json:"bank,omitempty"`
+    Account     string `json:"account,omitempty"`
+    // DateFormat is a Go time layout; empty tries the common date formats
+    DateFormat string `json:"date_format,omitempty"`
+}
+
+// DefaultRowsMapping matches the headers of the Rows bank feed template
+var DefaultRowsMapping = RowsMapping{
+    Date:        "Date",
+    Amount:      "Amount",
+    Currency:    "Currency",
+    Description: "Description",
+    Category:    "Personal Finance Category Primary",
+    Bank:        "Bank",
+    Account:     "Account",
+}
+
+// WithDefaults fills the unmapped fields from DefaultRowsMapping
+func (m RowsMapping) WithDefaults() RowsMapping {
+    pick := func(v, def string) string {
+        if strings.TrimSpace(v) == "" {
+            return def
+        }
+        return strings.TrimSpace(v)
+    }
+    return RowsMapping{
+        Date:        pick(m.Date, DefaultRowsMapping.Date),
+        Amount:      pick(m.Amount, DefaultRowsMapping.Amount),
+        Currency:    pick(m.Currency, DefaultRowsMapping.Currency),
+        Description: pick(m.Description, DefaultRowsMapping.Description),
+        Category:    pick(m.Category, DefaultRowsMapping.Category),
+        Bank:        pick(m.Bank, DefaultRowsMapping.Bank),
+        Account:     pick(m.Account, DefaultRowsMapping.Account),
+        DateFormat:  strings.TrimSpace(m.DateFormat),
+    }
+}
+
+// RowError is why one source row couldn't be read
+type RowError struct {
+    Row   int    `json:"row"`
+    Error string `json:"error"`
+}
+
+// RowsSync is what one read of a Rows table found
+type RowsSync struct {
+    Transactions []Transaction
+    Errors       []RowError
+    // LastRow is the last non-empty row read, or 0 when there was none
+    LastRow int
+}
+
+// ProviderRows is the provider name used for Rows in the quota ledger
+const ProviderRows = "rows"
+
+const (
+    // rowsPageSize is how many rows each cells request reads
+    rowsPageSize = 500
+    // rowsLastColumn bounds the columns read, so mapped headers must sit in A..Z
+    rowsLastColumn = "Z"
+)
+
+// dateLayouts are tried in order when a mapping has no DateFormat
+var dateLayouts = []string{
+    "2006-01-02",
+    time.RFC3339,
+    "2006-01-02 15:04:05",
+    "2006/01/02",
+    "01/02/2006",
+    "02.01.2006",
+    "Jan 2, 2006",
+    "2 Jan 2006",
 }
 
 type RowsClient struct {
@@ -36,69 +123,203 @@ func NewRowsClient(apiKey string, timeout time.Duration) *RowsClient {
     }
 }
 
-func (c *RowsClient) FetchRows(ctx context.Context, spreadsheetId, tableId string) ([]Transaction, error) {
-    url := fmt.Sprintf("https://api.rows.com/v1/spreadsheets/%s/tables/%s/cells/A1:G100", spreadsheetId, tableId)
-
-    req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
-    req.Header.Set("Authorization", "Bearer "+c.apiKey)
-
-    resp, err := http.DefaultClient.Do(req)
-    if err != nil {
-        return nil, fmt.Errorf("http request failed: %w", err)
+// fetchCells reads the cells of rows first..last
+func (c *RowsClient) fetchCells(ctx context.Context, spreadsheetId, tableId string, first, last int) (*RowsAPIResponse, error) {
+    req := &Request{
+        Method: "GET",
+        Path:   fmt.Sprintf("/v1/spreadsheets/%s/tables/%s/cells/A%d:%s%d", spreadsheetId, tableId, first, rowsLastColumn, last),
+        Headers: map[string]string{
+            "Authorization": "Bearer " + c.apiKey,
+        },
     }
-    defer resp.Body.Close()
 
     var apiResp RowsAPIResponse
-    if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-        return nil, fmt.Errorf("failed to decode JSON: %w", err)
+    if err := c.DoJSON(ctx, req, &apiResp); err != nil {
+        return nil, fmt.Errorf("failed to fetch rows %d-%d: %w", first, last, err)
     }
+    return &apiResp, nil
+}
 
-    if len(apiResp.Items) < 2 {
-        return nil, fmt.Errorf("not enough rows to parse data")
+// FetchRows reads the transactions of a Rows table from fromRow (2 or less
+// reads everything below the header) until it runs out of rows, mapping
+// columns by their header names. Rows that can't be parsed are reported in
+// Errors rather than returned with zero values.
+func (c *RowsClient) FetchRows(ctx context.Context, spreadsheetId, tableId string, mapping RowsMapping, fromRow int) (*RowsSync, error) {
+    mapping = mapping.WithDefaults()
+    if fromRow < 2 {
+        fromRow = 2
+    }
+
+    header, err := c.fetchCells(ctx, spreadsheetId, tableId, 1, 1)
+    if err != nil {
+        return nil, err
+    }
+    if len(header.Items) == 0 {
+        return nil, fmt.Errorf("table has no header row")
     }
 
-    // Build column index → name mapping
+    // Build column index → field mapping
+    fields := map[string]string{
+        mapping.Date:        "date",
+        mapping.Amount:      "amount",
+        mapping.Currency:    "currency",
+        mapping.Description: "description",
+        mapping.Category:    "category",
+        mapping.Bank:        "bank",
+        mapping.Account:     "account",
+    }
     colMap := map[int]string{}
-    for _, cell := range apiResp.Items[0] {
+    for _, cell := range header.Items[0] {
         if str, ok := cell.Value.(string); ok {
-            colMap[cell.Col] = str
+            if field, ok := fields[strings.TrimSpace(str)]; ok {
+                colMap[cell.Col] = field
+            }
+        }
+    }
+    for _, required := range []struct{ field, header string }{
+        {"date", mapping.Date}, {"amount", mapping.Amount}, {"description", mapping.Description},
+    } {
+        found := false
+        for _, field := range colMap {
+            found = found || field == required.field
+        }
+        if !found {
+            return nil, fmt.Errorf("header %q for %s not found in columns A-%s", required.header, required.field, rowsLastColumn)
         }
     }
 
-    var transactions []Transaction
-
-    for _, row := range apiResp.Items[1:] {
-        tx := Transaction{}
+    result := &RowsSync{}
+    for first := fromRow; ; first += rowsPageSize {
+        page, err := c.fetchCells(ctx, spreadsheetId, tableId, first, first+rowsPageSize-1)
+        if err != nil {
+            return nil, err
+        }
 
-        for _, cell := range row {
-            column := colMap[cell.Col]
-            switch column {
-            case "Date":
-                if str, ok := cell.Value.(string); ok {
-                    t, _ := time.Parse("2006-01-02", str)
-                    tx.Date = t
+        found := false
+        for i, row := range page.Items {
+            // Cells carry their own row number; counting items would shift
+            // every later row if the API leaves blank rows out
+            rowNumber := first + i
+            values := map[string]interface{}{}
+            for _, cell := range row {
+                if cell.Row > 0 {
+                    rowNumber = cell.Row
                 }
-            case "Amount":
-                switch v := cell.Value.(type) {
-                case float64:
-                    tx.Amount = v
-                case string:
-                    fmt.Sscanf(v, "%f", &tx.Amount)
+                if field, ok := colMap[cell.Col]; ok && cellString(cell.Value) != "" {
+                    values[field] = cell.Value
                 }
-            case "Currency":
-                tx.Currency = fmt.Sprint(cell.Value)
-            case "Description":
-                tx.Description = fmt.Sprint(cell.Value)
-            case "Personal Finance Category Primary":
-                tx.Category = fmt.Sprint(cell.Value)
-            case "Bank":
-                tx.Bank = fmt.Sprint(cell.Value)
-            case "Account":
-                tx.Account = fmt.Sprint(cell.Value)
             }
+            if len(values) == 0 {
+                continue
+            }
+            found = true
+
+            if rowNumber > result.LastRow {
+                result.LastRow = rowNumber
+            }
+            tx, err := parseTransaction(values, mapping.DateFormat)
+            if err != nil {
+                result.Errors = append(result.Errors, RowError{Row: rowNumber, Error: err.Error()})
+                continue
+            }
+            tx.Row = rowNumber
+            result.Transactions = append(result.Transactions, *tx)
+        }
+
+        // A page with no rows means the table has ended. A short page doesn't,
+        // since blank rows may be left out of it.
+        if !found {
+            break
+        }
+    }
+
+    return result, nil
+}
+
+// parseTransaction builds a transaction from one row's mapped, non-empty values
+func parseTransaction(values map[string]interface{}, dateFormat string) (*Transaction, error) {
+    dateValue, ok := values["date"]
+    if !ok {
+        return nil, fmt.Errorf("missing date")
+    }
+    date, err := parseCellDate(dateValue, dateFormat)
+    if err != nil {
+        return nil, err
+    }
+
+    amountValue, ok := values["amount"]
+    if !ok {
+        return nil, fmt.Errorf("missing amount")
+    }
+    amount, err := parseCellAmount(amountValue)
+    if err != nil {
+        return nil, err
+    }
+
+    return &Transaction{
+        Date:        date,
+        Amount:      amount,
+        Currency:    cellString(values["currency"]),
+        Description: cellString(values["description"]),
+        Category:    cellString(values["category"]),
+        Bank:        cellString(values["bank"]),
+        Account:     cellString(values["account"]),
+    }, nil
+}
+
+// parseCellDate reads a date cell, which is either text or a spreadsheet
+// serial day number
+func parseCellDate(value interface{}, layout string) (time.Time, error) {
+    if serial, ok := value.(float64); ok {
+        // Spreadsheet serial dates count days from 1899-12-30
+        return time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(math.Floor(serial))), nil
+    }
+
+    str := cellString(value)
+    if layout != "" {
+        t, err := time.Parse(layout, str)
+        if err != nil {
+            return time.Time{}, fmt.Errorf("invalid date %q (expected format %s)", str, layout)
         }
-        transactions = append(transactions, tx)
+        return t, nil
     }
+    for _, l := range dateLayouts {
+        if t, err := time.Parse(l, str); err == nil {
+            return t, nil
+        }
+    }
+    return time.Time{}, fmt.Errorf("invalid date %q", str)
+}
 
-    return transactions, nil
+// parseCellAmount reads an amount cell, accepting currency symbols, thousands
+// separators and accounting-style parentheses for negatives
+func parseCellAmount(value interface{}) (float64, error) {
+    if v, ok := value.(float64); ok {
+        return v, nil
+    }
+
+    str := cellString(value)
+    negative := strings.HasPrefix(str, "(") && strings.HasSuffix(str, ")")
+    cleaned := strings.Map(func(r rune) rune {
+        if (r >= '0' && r <= '9') || r == '.' || r == '-' {
+            return r
+        }
+        return -1
+    }, str)
+    amount, err := strconv.ParseFloat(cleaned, 64)
+    if err != nil {
+        return 0, fmt.Errorf("invalid amount %q", str)
+    }
+    if negative {
+        amount = -math.Abs(amount)
+    }
+    return amount, nil
+}
+
+// cellString renders a cell value, treating empty cells as ""
+func cellString(value interface{}) string {
+    if value == nil {
+        return ""
+    }
+    return strings.TrimSpace(fmt.Sprint(value))
 }
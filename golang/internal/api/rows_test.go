@@ -0,0 +1,234 @@
+package api
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "math"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "testing"
+    "time"
+)
+
+type rowsCell struct {
+    Col   int         `json:"col"`
+    Row   int         `json:"row"`
+    Value interface{} `json:"value"`
+}
+
+// fakeRowsTable serves the cells endpoint from rows keyed by row number. Like
+// the real API it leaves blank rows out of each page.
+func fakeRowsTable(t *testing.T, rows map[int][]interface{}, requests *[]string) *httptest.Server {
+    return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        *requests = append(*requests, r.URL.Path)
+        if r.Header.Get("Authorization") != "Bearer key" {
+            w.WriteHeader(http.StatusUnauthorized)
+            return
+        }
+
+        var first, last int
+        if _, err := fmt.Sscanf(r.URL.Path, "/v1/spreadsheets/sheet/tables/table/cells/A%d:Z%d", &first, &last); err != nil {
+            t.Errorf("unexpected path %s", r.URL.Path)
+            w.WriteHeader(http.StatusNotFound)
+            return
+        }
+
+        items := [][]rowsCell{}
+        for row := first; row <= last; row++ {
+            values, ok := rows[row]
+            if !ok {
+                continue
+            }
+            cells := make([]rowsCell, len(values))
+            for col, v := range values {
+                cells[col] = rowsCell{Col: col, Row: row, Value: v}
+            }
+            items = append(items, cells)
+        }
+        json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+    }))
+}
+
+func newTestRowsClient(url string) *RowsClient {
+    return &RowsClient{APIClient: NewAPIClient(url, "key", 600000, time.Second)}
+}
+
+func TestFetchRows(t *testing.T) {
+    rows := map[int][]interface{}{
+        // Custom headers in their own order; "Category" isn't mapped
+        1:   {"Description", "Posted", "Value", "Category", "Bank"},
+        2:   {"Coffee", "2024-03-01", -3.5, "Food", "Chase"},
+        3:   {"Salary", "2024-03-01", "$2,500.00", nil, "Chase"},
+        // Row 4 is blank, so page one is short and row 5 is its third item
+        5:   {"Refund", 45352.0, "(12.00)", nil, nil},
+        6:   {nil, nil, nil, nil, nil},
+        501: {"Rent", "2024-03-05", -1200.0, nil, "Chase"},
+        // Page two only has a couple of rows but isn't the end of the table
+        600: {"Books", "2024-03-10", "abc", nil, nil},
+        750: {"Gym", "03/12/2024", -40.0, nil, nil},
+    }
+    var requests []string
+    server := fakeRowsTable(t, rows, &requests)
+    defer server.Close()
+
+    mapping := RowsMapping{Date: "Posted", Amount: "Value"}
+    sync, err := newTestRowsClient(server.URL).FetchRows(context.Background(), "sheet", "table", mapping, 0)
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+
+    wantRequests := []string{
+        "/v1/spreadsheets/sheet/tables/table/cells/A1:Z1",
+        "/v1/spreadsheets/sheet/tables/table/cells/A2:Z501",
+        "/v1/spreadsheets/sheet/tables/table/cells/A502:Z1001",
+        "/v1/spreadsheets/sheet/tables/table/cells/A1002:Z1501",
+    }
+    if !reflect.DeepEqual(requests, wantRequests) {
+        t.Errorf("requested %v, want %v", requests, wantRequests)
+    }
+
+    type summary struct {
+        Row         int
+        Date        string
+        Amount      float64
+        Description string
+        Category    string
+        Bank        string
+    }
+    var got []summary
+    for _, tx := range sync.Transactions {
+        got = append(got, summary{tx.Row, tx.Date.Format("2006-01-02"), tx.Amount, tx.Description, tx.Category, tx.Bank})
+    }
+    want := []summary{
+        {2, "2024-03-01", -3.5, "Coffee", "", "Chase"},
+        {3, "2024-03-01", 2500, "Salary", "", "Chase"},
+        {5, "2024-03-01", -12, "Refund", "", ""},
+        {501, "2024-03-05", -1200, "Rent", "", "Chase"},
+        {750, "2024-03-12", -40, "Gym", "", ""},
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("transactions = %+v\nwant %+v", got, want)
+    }
+
+    if len(sync.Errors) != 1 || sync.Errors[0].Row != 600 {
+        t.Errorf("errors = %+v, want one for row 600", sync.Errors)
+    }
+    if sync.LastRow != 750 {
+        t.Errorf("last row = %d, want 750", sync.LastRow)
+    }
+}
+
+func TestFetchRowsFromRow(t *testing.T) {
+    rows := map[int][]interface{}{
+        1:   {"Date", "Amount", "Description"},
+        2:   {"2024-03-01", -1.0, "Old"},
+        900: {"2024-03-02", -2.0, "New"},
+    }
+    var requests []string
+    server := fakeRowsTable(t, rows, &requests)
+    defer server.Close()
+
+    sync, err := newTestRowsClient(server.URL).FetchRows(context.Background(), "sheet", "table", RowsMapping{}, 900)
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if len(sync.Transactions) != 1 || sync.Transactions[0].Description != "New" || sync.LastRow != 900 {
+        t.Errorf("sync = %+v, want only row 900", sync)
+    }
+    if len(requests) != 3 || requests[1] != "/v1/spreadsheets/sheet/tables/table/cells/A900:Z1399" {
+        t.Errorf("requested %v, want the header then pages from row 900", requests)
+    }
+}
+
+func TestFetchRowsMissingHeader(t *testing.T) {
+    rows := map[int][]interface{}{
+        1: {"Date", "Description"},
+        2: {"2024-03-01", "Coffee"},
+    }
+    var requests []string
+    server := fakeRowsTable(t, rows, &requests)
+    defer server.Close()
+
+    if _, err := newTestRowsClient(server.URL).FetchRows(context.Background(), "sheet", "table", RowsMapping{}, 0); err == nil {
+        t.Error("table without an Amount header was read")
+    }
+}
+
+func TestParseCellDate(t *testing.T) {
+    tests := []struct {
+        name    string
+        value   interface{}
+        layout  string
+        want    string
+        wantErr bool
+    }{
+        {name: "iso text", value: "2024-03-01", want: "2024-03-01"},
+        {name: "rfc3339 text", value: "2024-03-01T10:30:00Z", want: "2024-03-01"},
+        {name: "us text", value: "03/01/2024", want: "2024-03-01"},
+        {name: "month name", value: "Mar 1, 2024", want: "2024-03-01"},
+        {name: "serial date", value: 45352.0, want: "2024-03-01"},
+        {name: "serial date with a time", value: 45352.75, want: "2024-03-01"},
+        {name: "serial epoch", value: 0.0, want: "1899-12-30"},
+        {name: "explicit layout", value: "01/03/2024", layout: "02/01/2006", want: "2024-03-01"},
+        {name: "explicit layout mismatch", value: "2024-03-01", layout: "02/01/2006", wantErr: true},
+        {name: "not a date", value: "yesterday", wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := parseCellDate(tt.value, tt.layout)
+            if tt.wantErr {
+                if err == nil {
+                    t.Errorf("parsed %v, want error", got)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("unexpected error: %v", err)
+            }
+            if got.Format("2006-01-02") != tt.want {
+                t.Errorf("got %s, want %s", got.Format("2006-01-02"), tt.want)
+            }
+        })
+    }
+}
+
+func TestParseCellAmount(t *testing.T) {
+    tests := []struct {
+        name    string
+        value   interface{}
+        want    float64
+        wantErr bool
+    }{
+        {name: "number", value: -12.5, want: -12.5},
+        {name: "plain text", value: "42.10", want: 42.1},
+        {name: "negative text", value: "-42.10", want: -42.1},
+        {name: "currency and thousands", value: "$1,234.56", want: 1234.56},
+        {name: "euro suffix", value: "99.90 €", want: 99.9},
+        {name: "parentheses", value: "(12.00)", want: -12},
+        {name: "parentheses with currency", value: "($1,200.00)", want: -1200},
+        {name: "parentheses around a negative", value: "(-5)", want: -5},
+        {name: "empty", value: "", wantErr: true},
+        {name: "not a number", value: "n/a", wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := parseCellAmount(tt.value)
+            if tt.wantErr {
+                if err == nil {
+                    t.Errorf("parsed %v, want error", got)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("unexpected error: %v", err)
+            }
+            if math.Abs(got-tt.want) > 1e-9 {
+                t.Errorf("got %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
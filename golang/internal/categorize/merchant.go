@@ -0,0 +1,64 @@
+package categorize
+
+import (
+	"regexp"
+	"strings"
+)
+
+// processorPrefixes are put in front of the merchant by card processors,
+// payment apps and banks
+var processorPrefixes = []string{
+	"DEBIT CARD PURCHASE", "CARD PURCHASE", "POS PURCHASE", "PURCHASE AUTHORIZED ON",
+	"POS DEBIT", "CHECKCARD", "VISA DEBIT", "DEBIT", "POS", "ACH",
+	"PAYPAL *", "SQ *", "TST*", "TST *", "SP *", "PY *", "IN *", "GOOGLE *", "PP*", "SQ*", "SP*",
+}
+
+var (
+	// cardMask matches masked card numbers such as XXXX1234 or ****1234
+	cardMask = regexp.MustCompile(`(?:X{2,}|\*{2,})\d{2,4}|\bCARD \d{4}\b`)
+	// dates matches 12/31, 12/31/24 and 2024-12-31
+	dates = regexp.MustCompile(`\b\d{1,2}/\d{1,2}(?:/\d{2,4})?\b|\b\d{4}-\d{2}-\d{2}\b`)
+	// storeNumbers matches store and terminal numbers such as #1234 or STORE 0042
+	storeNumbers = regexp.MustCompile(`#\s*\d+|\bSTORE\s+\d+|\b\d{3,}\b`)
+	// referenceSuffix matches order references processors append after a star,
+	// as in AMZN MKTP US*2K3LM0
+	referenceSuffix = regexp.MustCompile(`\*[A-Z0-9]*\d[A-Z0-9]*`)
+	// stateSuffix matches a trailing US state code
+	stateSuffix = regexp.MustCompile(`\s+[A-Z]{2}$`)
+	spaces      = regexp.MustCompile(`\s+`)
+)
+
+// NormaliseMerchant strips card-processor noise from a bank description so
+// that purchases from the same merchant read the same. The result is upper
+// case; it is empty only for an empty description.
+func NormaliseMerchant(description string) string {
+	s := strings.ToUpper(strings.TrimSpace(description))
+	if s == "" {
+		return ""
+	}
+
+	for stripped := true; stripped; {
+		stripped = false
+		for _, prefix := range processorPrefixes {
+			if strings.HasPrefix(s, prefix+" ") || (strings.HasSuffix(prefix, "*") && strings.HasPrefix(s, prefix)) {
+				s = strings.TrimSpace(strings.TrimPrefix(s, prefix))
+				stripped = true
+			}
+		}
+	}
+
+	cleaned := referenceSuffix.ReplaceAllString(s, " ")
+	cleaned = cardMask.ReplaceAllString(cleaned, " ")
+	cleaned = dates.ReplaceAllString(cleaned, " ")
+	cleaned = storeNumbers.ReplaceAllString(cleaned, " ")
+	cleaned = strings.Trim(spaces.ReplaceAllString(cleaned, " "), " *-#.,")
+	if len(strings.Fields(cleaned)) >= 3 {
+		cleaned = stateSuffix.ReplaceAllString(cleaned, "")
+	}
+
+	// Never normalise a description away entirely
+	if cleaned == "" {
+		return spaces.ReplaceAllString(s, " ")
+	}
+	return cleaned
+}
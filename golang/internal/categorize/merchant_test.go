@@ -0,0 +1,38 @@
+package categorize
+
+import "testing"
+
+func TestNormaliseMerchant(t *testing.T) {
+	tests := []struct {
+		description string
+		want        string
+	}{
+		{"", ""},
+		{"  Netflix.com  ", "NETFLIX.COM"},
+		{"DEBIT CARD PURCHASE XXXX1234 STARBUCKS #4521 SEATTLE WA", "STARBUCKS SEATTLE"},
+		{"POS DEBIT 12/31 SHELL OIL 57442", "SHELL OIL"},
+		// US reads as a trailing state code once there are three words
+		{"AMZN MKTP US*2K3LM0ZQ1", "AMZN MKTP"},
+		{"SQ *BLUE BOTTLE COFFEE", "BLUE BOTTLE COFFEE"},
+		{"TST* JOE'S PIZZA", "JOE'S PIZZA"},
+		{"PAYPAL *SPOTIFY 2024-01-03", "SPOTIFY"},
+		{"PURCHASE AUTHORIZED ON 01/02 TARGET STORE 0042", "TARGET"},
+		// Two words keep their last one even when it looks like a state code
+		{"UBER CA", "UBER CA"},
+		// A description that is all noise is kept rather than emptied
+		{"12345", "12345"},
+	}
+	for _, tt := range tests {
+		if got := NormaliseMerchant(tt.description); got != tt.want {
+			t.Errorf("NormaliseMerchant(%q) = %q, want %q", tt.description, got, tt.want)
+		}
+	}
+}
+
+func TestNormaliseMerchantGroupsPurchases(t *testing.T) {
+	a := NormaliseMerchant("CHECKCARD 0103 WHOLEFDS MKT #10234 XXXX5678")
+	b := NormaliseMerchant("CHECKCARD 0217 WHOLEFDS MKT #10511 XXXX5678")
+	if a != b {
+		t.Errorf("two purchases from one merchant normalise to %q and %q", a, b)
+	}
+}
@@ -0,0 +1,180 @@
+// Package categorize assigns categories to bank transactions using each
+// user's rules, and normalises merchant names out of bank descriptions.
+package categorize
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"stock-api/internal/repository"
+)
+
+// Match types
+const (
+	MatchContains = "contains"
+	MatchRegex    = "regex"
+)
+
+// Directions
+const (
+	DirectionDebit  = "debit"
+	DirectionCredit = "credit"
+)
+
+// Transaction is what rules look at
+type Transaction struct {
+	Description string
+	Amount      float64
+	Account     string
+	// SourceCategory is the category the source provided, if any
+	SourceCategory string
+}
+
+// Result is the category chosen for a transaction
+type Result struct {
+	Category string
+	Merchant string
+	// RuleID is the rule that chose Category, or 0 when it is the source's
+	RuleID int
+}
+
+type compiledRule struct {
+	rule    repository.CategoryRule
+	pattern *regexp.Regexp
+}
+
+// Categorizer applies a user's enabled rules in priority order
+type Categorizer struct {
+	rules []compiledRule
+}
+
+// Normalise tidies a rule's fields and checks it can be compiled
+func Normalise(rule *repository.CategoryRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Category = strings.TrimSpace(rule.Category)
+	rule.MatchType = strings.ToLower(strings.TrimSpace(rule.MatchType))
+	rule.Direction = strings.ToLower(strings.TrimSpace(rule.Direction))
+	rule.Account = strings.TrimSpace(rule.Account)
+	rule.Merchant = strings.TrimSpace(rule.Merchant)
+
+	if rule.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if rule.Category == "" {
+		return fmt.Errorf("category is required")
+	}
+	if len(rule.Name) > 128 || len(rule.Category) > 128 || len(rule.Account) > 128 || len(rule.Merchant) > 128 {
+		return fmt.Errorf("name, category, account and merchant must be at most 128 characters")
+	}
+	if rule.MatchType == "" {
+		rule.MatchType = MatchContains
+	}
+	switch rule.MatchType {
+	case MatchContains:
+	case MatchRegex:
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown match_type %q (use contains or regex)", rule.MatchType)
+	}
+	switch rule.Direction {
+	case "", DirectionDebit, DirectionCredit:
+	default:
+		return fmt.Errorf("unknown direction %q (use debit or credit)", rule.Direction)
+	}
+	if rule.MinAmount != nil && *rule.MinAmount < 0 || rule.MaxAmount != nil && *rule.MaxAmount < 0 {
+		return fmt.Errorf("min_amount and max_amount bound the absolute amount and can't be negative")
+	}
+	if rule.MinAmount != nil && rule.MaxAmount != nil && *rule.MinAmount > *rule.MaxAmount {
+		return fmt.Errorf("min_amount is greater than max_amount")
+	}
+	if rule.Pattern == "" && rule.MinAmount == nil && rule.MaxAmount == nil &&
+		rule.Direction == "" && rule.Account == "" && rule.Merchant == "" {
+		return fmt.Errorf("a rule needs at least one condition")
+	}
+	return nil
+}
+
+// New compiles the enabled rules, ordered by priority then id
+func New(rules []repository.CategoryRule) (*Categorizer, error) {
+	c := &Categorizer{}
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		compiled := compiledRule{rule: rule}
+		if rule.Pattern != "" {
+			expr := `(?i)` + regexp.QuoteMeta(rule.Pattern)
+			if rule.MatchType == MatchRegex {
+				expr = `(?i)` + rule.Pattern
+			}
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: invalid pattern: %w", rule.ID, err)
+			}
+			compiled.pattern = re
+		}
+		c.rules = append(c.rules, compiled)
+	}
+	sort.SliceStable(c.rules, func(i, j int) bool {
+		if c.rules[i].rule.Priority != c.rules[j].rule.Priority {
+			return c.rules[i].rule.Priority < c.rules[j].rule.Priority
+		}
+		return c.rules[i].rule.ID < c.rules[j].rule.ID
+	})
+	return c, nil
+}
+
+// Categorize returns the category of the first rule matching t. Rules without
+// Override leave a category provided by the source alone; with no match the
+// source's category is kept.
+func (c *Categorizer) Categorize(t Transaction) Result {
+	result := Result{Category: t.SourceCategory, Merchant: NormaliseMerchant(t.Description)}
+	for _, cr := range c.rules {
+		if t.SourceCategory != "" && !cr.rule.Override {
+			continue
+		}
+		if cr.matches(t, result.Merchant) {
+			result.Category = cr.rule.Category
+			result.RuleID = cr.rule.ID
+			break
+		}
+	}
+	return result
+}
+
+// matches reports whether every condition the rule sets holds for t
+func (cr compiledRule) matches(t Transaction, merchant string) bool {
+	rule := cr.rule
+	if cr.pattern != nil && !cr.pattern.MatchString(t.Description) && !cr.pattern.MatchString(merchant) {
+		return false
+	}
+	if rule.Merchant != "" && !strings.EqualFold(NormaliseMerchant(rule.Merchant), merchant) {
+		return false
+	}
+	if rule.Account != "" && !strings.EqualFold(rule.Account, strings.TrimSpace(t.Account)) {
+		return false
+	}
+	switch rule.Direction {
+	case DirectionDebit:
+		if t.Amount >= 0 {
+			return false
+		}
+	case DirectionCredit:
+		if t.Amount <= 0 {
+			return false
+		}
+	}
+	amount := math.Abs(t.Amount)
+	if rule.MinAmount != nil && amount < *rule.MinAmount {
+		return false
+	}
+	if rule.MaxAmount != nil && amount > *rule.MaxAmount {
+		return false
+	}
+	return true
+}
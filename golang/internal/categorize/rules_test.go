@@ -0,0 +1,87 @@
+package categorize
+
+import (
+	"testing"
+
+	"stock-api/internal/repository"
+)
+
+func amount(v float64) *float64 {
+	return &v
+}
+
+func TestNormalise(t *testing.T) {
+	rule := repository.CategoryRule{Name: "  Coffee ", Category: " Dining ", Pattern: "starbucks", Direction: " DEBIT "}
+	if err := Normalise(&rule); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rule.Name != "Coffee" || rule.Category != "Dining" || rule.MatchType != MatchContains || rule.Direction != DirectionDebit {
+		t.Errorf("Normalise = %+v, want trimmed fields, contains matching and debit", rule)
+	}
+
+	tests := []struct {
+		name string
+		rule repository.CategoryRule
+	}{
+		{"no name", repository.CategoryRule{Category: "Dining", Pattern: "x"}},
+		{"no category", repository.CategoryRule{Name: "r", Pattern: "x"}},
+		{"bad regex", repository.CategoryRule{Name: "r", Category: "c", MatchType: MatchRegex, Pattern: "("}},
+		{"unknown match type", repository.CategoryRule{Name: "r", Category: "c", MatchType: "glob", Pattern: "x"}},
+		{"unknown direction", repository.CategoryRule{Name: "r", Category: "c", Direction: "both"}},
+		{"negative amount", repository.CategoryRule{Name: "r", Category: "c", MinAmount: amount(-1)}},
+		{"min above max", repository.CategoryRule{Name: "r", Category: "c", MinAmount: amount(10), MaxAmount: amount(5)}},
+		{"no condition", repository.CategoryRule{Name: "r", Category: "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Normalise(&tt.rule); err == nil {
+				t.Error("Normalise accepted the rule")
+			}
+		})
+	}
+}
+
+func TestCategorize(t *testing.T) {
+	rules := []repository.CategoryRule{
+		{ID: 1, Priority: 10, Category: "Groceries", Pattern: "whole foods", MatchType: MatchContains, Enabled: true},
+		{ID: 2, Priority: 20, Category: "Dining", Pattern: `^(starbucks|blue bottle)`, MatchType: MatchRegex, Enabled: true},
+		{ID: 3, Priority: 5, Category: "Big purchases", MinAmount: amount(500), Direction: DirectionDebit, Enabled: true},
+		{ID: 4, Priority: 1, Category: "Disabled", Pattern: "starbucks", MatchType: MatchContains},
+		{ID: 5, Priority: 30, Category: "Salary", Direction: DirectionCredit, Account: "Checking", Enabled: true},
+		{ID: 6, Priority: 40, Category: "Streaming", Merchant: "PAYPAL *NETFLIX", Override: true, Enabled: true},
+		// Same priority as rule 2: the lower id wins
+		{ID: 7, Priority: 20, Category: "Coffee", Pattern: "coffee", MatchType: MatchContains, Enabled: true},
+	}
+	c, err := New(rules)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		t            Transaction
+		wantCategory string
+		wantRule     int
+	}{
+		{"contains, any case", Transaction{Description: "WHOLE FOODS MARKET #123", Amount: -54.2}, "Groceries", 1},
+		{"regex on the merchant", Transaction{Description: "SQ *BLUE BOTTLE COFFEE", Amount: -6}, "Dining", 2},
+		{"priority order", Transaction{Description: "WHOLE FOODS MARKET", Amount: -650}, "Big purchases", 3},
+		{"amount bound excludes credits", Transaction{Description: "REFUND", Amount: 650}, "", 0},
+		{"account and direction", Transaction{Description: "ACME PAYROLL", Amount: 3000, Account: " checking "}, "Salary", 5},
+		{"other account", Transaction{Description: "ACME PAYROLL", Amount: 3000, Account: "Savings"}, "", 0},
+		{"source category kept", Transaction{Description: "STARBUCKS", Amount: -5, SourceCategory: "Coffee shops"}, "Coffee shops", 0},
+		{"override replaces source category", Transaction{Description: "PAYPAL *NETFLIX 4029357733", Amount: -15.49, SourceCategory: "Shopping"}, "Streaming", 6},
+		{"lower id breaks ties", Transaction{Description: "STARBUCKS COFFEE", Amount: -5}, "Dining", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.Categorize(tt.t)
+			if got.Category != tt.wantCategory || got.RuleID != tt.wantRule {
+				t.Errorf("Categorize = %q by rule %d, want %q by rule %d", got.Category, got.RuleID, tt.wantCategory, tt.wantRule)
+			}
+			if got.Merchant != NormaliseMerchant(tt.t.Description) {
+				t.Errorf("merchant = %q, want the normalised description", got.Merchant)
+			}
+		})
+	}
+}
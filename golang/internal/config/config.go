@@ -5,10 +5,17 @@ import (
     "fmt"
     "log"
     "os"
+    "strings"
     "time"
     "github.com/joho/godotenv"
 )
 
+// ProviderQuota is the call budget for one provider key; 0 means unlimited
+type ProviderQuota struct {
+    PerMinute int
+    PerDay    int
+}
+
 type Config struct {
     PostgresURL string
     // External API configurations
@@ -17,12 +24,33 @@ type Config struct {
     YahooFinanceAPIKey string
     PolygonAPIKey      string
     RowsAPIKey          string
+    // Market-data providers in the order they are tried
+    ProviderPriority []string
+    // Persistent call budgets per provider
+    ProviderQuotas map[string]ProviderQuota
+    // Allow provider calls when the quota ledger can't be reached
+    QuotaFailOpen bool
+    // How long quota ledger entries are kept before the current day
+    QuotaLedgerRetention time.Duration
     // API rate limiting
     APIRequestTimeout time.Duration
     MaxRequestsPerMinute int
     // Database settings
     MaxDBConnections int
     DBTimeout        time.Duration
+    // Apply pending migrations on startup instead of refusing to serve
+    AutoMigrate bool
+    // JSON file overriding the default scorecard scoring model
+    ScoringModelFile string
+    // Alert delivery; email alerts are only offered when SMTPHost is set
+    SMTPHost            string
+    SMTPPort            int
+    SMTPUsername        string
+    SMTPPassword        string
+    SMTPFrom            string
+    AlertWebhookTimeout time.Duration
+    // Private networks webhooks may reach, as CIDRs or addresses
+    AlertWebhookAllowedNets []string
     //JWT
     JWTSecret string
 }
@@ -41,10 +69,40 @@ func Load() Config {
         YahooFinanceAPIKey:  os.Getenv("YAHOO_FINANCE_API_KEY"),
         PolygonAPIKey:       os.Getenv("POLYGON_API_KEY"),
         RowsAPIKey:       os.Getenv("ROWS_API_KEY"),
+        ProviderPriority:    getListEnvOrDefault("MARKET_DATA_PROVIDERS", []string{"polygon", "alphavantage", "finnhub"}),
+        ProviderQuotas: map[string]ProviderQuota{
+            "alphavantage": {
+                PerMinute: getIntEnvOrDefault("ALPHA_VANTAGE_QUOTA_PER_MINUTE", 5),
+                PerDay:    getIntEnvOrDefault("ALPHA_VANTAGE_QUOTA_PER_DAY", 25),
+            },
+            "finnhub": {
+                PerMinute: getIntEnvOrDefault("FINNHUB_QUOTA_PER_MINUTE", 60),
+                PerDay:    getIntEnvOrDefault("FINNHUB_QUOTA_PER_DAY", 0),
+            },
+            "polygon": {
+                PerMinute: getIntEnvOrDefault("POLYGON_QUOTA_PER_MINUTE", 60),
+                PerDay:    getIntEnvOrDefault("POLYGON_QUOTA_PER_DAY", 0),
+            },
+            "rows": {
+                PerMinute: getIntEnvOrDefault("ROWS_QUOTA_PER_MINUTE", 5),
+                PerDay:    getIntEnvOrDefault("ROWS_QUOTA_PER_DAY", 0),
+            },
+        },
+        QuotaFailOpen:        os.Getenv("QUOTA_FAIL_OPEN") == "true",
+        QuotaLedgerRetention: getDurationEnvOrDefault("QUOTA_LEDGER_RETENTION", 7*24*time.Hour),
         APIRequestTimeout:   getDurationEnvOrDefault("API_REQUEST_TIMEOUT", 30*time.Second),
         MaxRequestsPerMinute: getIntEnvOrDefault("MAX_REQUESTS_PER_MINUTE", 60),
         MaxDBConnections:    getIntEnvOrDefault("MAX_DB_CONNECTIONS", 10),
         DBTimeout:           getDurationEnvOrDefault("DB_TIMEOUT", 5*time.Second),
+        AutoMigrate:         os.Getenv("AUTO_MIGRATE") == "true",
+        ScoringModelFile:    os.Getenv("SCORING_MODEL_FILE"),
+        SMTPHost:            os.Getenv("SMTP_HOST"),
+        SMTPPort:            getIntEnvOrDefault("SMTP_PORT", 587),
+        SMTPUsername:        os.Getenv("SMTP_USERNAME"),
+        SMTPPassword:        os.Getenv("SMTP_PASSWORD"),
+        SMTPFrom:            getEnvOrDefault("SMTP_FROM", "alerts@localhost"),
+        AlertWebhookTimeout: getDurationEnvOrDefault("ALERT_WEBHOOK_TIMEOUT", 10*time.Second),
+        AlertWebhookAllowedNets: getListEnvOrDefault("ALERT_WEBHOOK_ALLOWED_NETS", nil),
         JWTSecret: os.Getenv("JWT_SECRET"),
     }
 }
@@ -65,6 +123,21 @@ func getIntEnvOrDefault(key string, defaultValue int) int {
     return defaultValue
 }
 
+func getListEnvOrDefault(key string, defaultValue []string) []string {
+    if value := os.Getenv(key); value != "" {
+        var list []string
+        for _, item := range strings.Split(value, ",") {
+            if item = strings.TrimSpace(item); item != "" {
+                list = append(list, item)
+            }
+        }
+        if len(list) > 0 {
+            return list
+        }
+    }
+    return defaultValue
+}
+
 func getDurationEnvOrDefault(key string, defaultValue time.Duration) time.Duration {
     if value := os.Getenv(key); value != "" {
         if parsed, err := time.ParseDuration(value); err == nil {
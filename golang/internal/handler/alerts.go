@@ -0,0 +1,231 @@
+package handler
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "strconv"
+    "time"
+    "stock-api/internal/repository"
+    "stock-api/internal/service"
+)
+
+// AlertHandler manages alert rules and the alert feed for the authenticated user
+type AlertHandler struct {
+    alertService *service.AlertService
+}
+
+// NewAlertHandler creates a new alert handler
+func NewAlertHandler(as *service.AlertService) *AlertHandler {
+    return &AlertHandler{alertService: as}
+}
+
+type AlertRuleRequest struct {
+    Name   string `json:"name"`
+    // Symbol is the symbol to watch; empty watches the watchlist, or every symbol
+    Symbol string `json:"symbol"`
+    WatchlistID int `json:"watchlist_id"`
+    // Metric is a bar field (close, volume, ...) or indicator (rsi, sma, volume_spike, ...)
+    Metric string `json:"metric"`
+    // Period overrides the indicator's default period
+    Period    int     `json:"period"`
+    // Operator is >, >=, <, <=, crosses_above or crosses_below
+    Operator  string  `json:"operator"`
+    Threshold float64 `json:"threshold"`
+    // CooldownMinutes silences the rule for a while after it fires
+    CooldownMinutes int      `json:"cooldown_minutes"`
+    // Channels are in_app (default), webhook and email
+    Channels        []string `json:"channels"`
+    WebhookURL      string   `json:"webhook_url"`
+    Email           string   `json:"email"`
+    // Enabled defaults to true
+    Enabled         *bool    `json:"enabled"`
+}
+
+func (req *AlertRuleRequest) toRule() *repository.AlertRule {
+    enabled := true
+    if req.Enabled != nil {
+        enabled = *req.Enabled
+    }
+    return &repository.AlertRule{
+        Name:            req.Name,
+        Symbol:          req.Symbol,
+        WatchlistID:     req.WatchlistID,
+        Metric:          req.Metric,
+        Period:          req.Period,
+        Operator:        req.Operator,
+        Threshold:       req.Threshold,
+        CooldownMinutes: req.CooldownMinutes,
+        Channels:        req.Channels,
+        WebhookURL:      req.WebhookURL,
+        Email:           req.Email,
+        Enabled:         enabled,
+    }
+}
+
+type MarkAlertsReadRequest struct {
+    // IDs are the alerts to mark read; empty marks every alert
+    IDs []int `json:"ids"`
+}
+
+// writeAlertError maps alert errors to HTTP statuses
+func writeAlertError(w http.ResponseWriter, err error, message string) {
+    switch {
+    case errors.Is(err, service.ErrInvalidAlertRule):
+        http.Error(w, err.Error(), http.StatusBadRequest)
+    case errors.Is(err, service.ErrAlertRuleNotFound):
+        http.Error(w, err.Error(), http.StatusNotFound)
+    default:
+        http.Error(w, message, http.StatusInternalServerError)
+    }
+}
+
+// Rules lists (GET, or one rule with ?id=), creates (POST), updates (PUT ?id=)
+// or deletes (DELETE ?id=) the user's alert rules
+func (h *AlertHandler) Rules(w http.ResponseWriter, r *http.Request) {
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    var response map[string]interface{}
+    status := http.StatusOK
+
+    switch r.Method {
+    case http.MethodGet:
+        id, err := queryInt(r, "id")
+        if err != nil {
+            http.Error(w, "invalid id", http.StatusBadRequest)
+            return
+        }
+        if id > 0 {
+            rule, err := h.alertService.GetRule(userID, id)
+            if err != nil {
+                writeAlertError(w, err, "could not get alert rule")
+                return
+            }
+            response = map[string]interface{}{"rule": rule}
+            break
+        }
+        rules, err := h.alertService.GetRules(userID)
+        if err != nil {
+            writeAlertError(w, err, "could not get alert rules")
+            return
+        }
+        response = map[string]interface{}{
+            "rules": rules,
+            "count": len(rules),
+        }
+
+    case http.MethodPost, http.MethodPut:
+        var req AlertRuleRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "Invalid request body", http.StatusBadRequest)
+            return
+        }
+        rule := req.toRule()
+
+        var err error
+        if r.Method == http.MethodPost {
+            err = h.alertService.CreateRule(userID, rule)
+            status = http.StatusCreated
+        } else {
+            rule.ID, err = strconv.Atoi(r.URL.Query().Get("id"))
+            if err != nil {
+                http.Error(w, "id is required", http.StatusBadRequest)
+                return
+            }
+            err = h.alertService.UpdateRule(userID, rule)
+        }
+        if err != nil {
+            writeAlertError(w, err, "could not save alert rule")
+            return
+        }
+        response = map[string]interface{}{
+            "rule":    rule,
+            "message": "Alert rule saved successfully",
+        }
+
+    case http.MethodDelete:
+        id, err := strconv.Atoi(r.URL.Query().Get("id"))
+        if err != nil {
+            http.Error(w, "id is required", http.StatusBadRequest)
+            return
+        }
+        if err := h.alertService.DeleteRule(userID, id); err != nil {
+            writeAlertError(w, err, "could not delete alert rule")
+            return
+        }
+        response = map[string]interface{}{
+            "id":      id,
+            "message": "Alert rule deleted successfully",
+        }
+
+    default:
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    response["timestamp"] = time.Now()
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(response)
+}
+
+// Events returns the user's alert feed (GET ?unread=true&limit=) or marks
+// alerts as read (POST)
+func (h *AlertHandler) Events(w http.ResponseWriter, r *http.Request) {
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    var response map[string]interface{}
+
+    switch r.Method {
+    case http.MethodGet:
+        limit, err := queryInt(r, "limit")
+        if err != nil {
+            http.Error(w, "invalid limit", http.StatusBadRequest)
+            return
+        }
+        unreadOnly := r.URL.Query().Get("unread") == "true"
+        events, err := h.alertService.GetEvents(userID, unreadOnly, limit)
+        if err != nil {
+            writeAlertError(w, err, "could not get alerts")
+            return
+        }
+        response = map[string]interface{}{
+            "alerts": events,
+            "count":  len(events),
+        }
+
+    case http.MethodPost:
+        var req MarkAlertsReadRequest
+        if r.ContentLength != 0 {
+            if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+                http.Error(w, "Invalid request body", http.StatusBadRequest)
+                return
+            }
+        }
+        marked, err := h.alertService.MarkRead(userID, req.IDs)
+        if err != nil {
+            writeAlertError(w, err, "could not mark alerts read")
+            return
+        }
+        response = map[string]interface{}{
+            "marked":  marked,
+            "message": "Alerts marked read",
+        }
+
+    default:
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    response["timestamp"] = time.Now()
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,277 @@
+package handler
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "strconv"
+    "time"
+    "stock-api/internal/performance"
+    "stock-api/internal/service"
+)
+
+// AnalyticsHandler serves portfolio analytics computed from caller-supplied holdings
+type AnalyticsHandler struct {
+    performanceService *service.PerformanceService
+    riskService        *service.RiskService
+}
+
+// NewAnalyticsHandler creates a new analytics handler
+func NewAnalyticsHandler(ps *service.PerformanceService, rs *service.RiskService) *AnalyticsHandler {
+    return &AnalyticsHandler{performanceService: ps, riskService: rs}
+}
+
+type CashFlowRequest struct {
+    // Date is RFC 3339 or YYYY-MM-DD
+    Date   string  `json:"date"`
+    // Amount is positive for deposits and negative for withdrawals
+    Amount float64 `json:"amount"`
+}
+
+type PositionRequest struct {
+    Date     string  `json:"date"`
+    Symbol   string  `json:"symbol"`
+    Quantity float64 `json:"quantity"`
+}
+
+type PerformanceRequest struct {
+    CashFlows []CashFlowRequest `json:"cash_flows"`
+    Positions []PositionRequest `json:"positions"`
+    Start     string            `json:"start"`
+    End       string            `json:"end"`
+    Windows   []string          `json:"windows"`
+}
+
+type RiskRequest struct {
+    Holdings     []service.RiskHolding `json:"holdings"`
+    Index        string                `json:"index"`
+    Start        string                `json:"start"`
+    End          string                `json:"end"`
+    RiskFreeRate float64               `json:"risk_free_rate"`
+    Confidence   float64               `json:"confidence"`
+}
+
+type CorrelationRequest struct {
+    Symbols []string `json:"symbols"`
+    // Frequency is "daily" (default) or an intraday bucket such as "5m" or "1h"
+    Frequency  string `json:"frequency"`
+    Start      string `json:"start"`
+    End        string `json:"end"`
+    MinOverlap int    `json:"min_overlap"`
+}
+
+// parseDateRange parses optional YYYY-MM-DD start and end dates
+func parseDateRange(startStr, endStr string) (time.Time, time.Time, error) {
+    var start, end time.Time
+    var err error
+    if startStr != "" {
+        if start, err = time.Parse("2006-01-02", startStr); err != nil {
+            return start, end, errors.New("invalid start date format (use YYYY-MM-DD)")
+        }
+    }
+    if endStr != "" {
+        if end, err = time.Parse("2006-01-02", endStr); err != nil {
+            return start, end, errors.New("invalid end date format (use YYYY-MM-DD)")
+        }
+    }
+    return start, end, nil
+}
+
+func (req *PerformanceRequest) toServiceRequest(benchmark string) (service.PerformanceRequest, error) {
+    result := service.PerformanceRequest{Windows: req.Windows, Benchmark: benchmark}
+
+    for _, f := range req.CashFlows {
+        date, err := parseTradeDate(f.Date)
+        if err != nil {
+            return result, errors.New("invalid cash flow date (use YYYY-MM-DD or RFC 3339)")
+        }
+        result.CashFlows = append(result.CashFlows, performance.Flow{Date: date, Amount: f.Amount})
+    }
+
+    for _, p := range req.Positions {
+        date, err := parseTradeDate(p.Date)
+        if err != nil {
+            return result, errors.New("invalid position date (use YYYY-MM-DD or RFC 3339)")
+        }
+        result.Positions = append(result.Positions, service.PositionQuantity{Date: date, Symbol: p.Symbol, Quantity: p.Quantity})
+    }
+
+    var err error
+    result.Start, result.End, err = parseDateRange(req.Start, req.End)
+    return result, err
+}
+
+// writeAnalyticsError maps analytics errors to HTTP statuses
+func writeAnalyticsError(w http.ResponseWriter, err error, message string) {
+    if errors.Is(err, service.ErrInvalidAnalytics) {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    http.Error(w, message, http.StatusInternalServerError)
+}
+
+// Performance values the posted cash flows and positions and returns
+// time-weighted and money-weighted returns per window, compared with the
+// ?benchmark= symbol, plus the daily equity curve
+func (h *AnalyticsHandler) Performance(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var req PerformanceRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, "Invalid request body", http.StatusBadRequest)
+        return
+    }
+
+    performanceReq, err := req.toServiceRequest(r.URL.Query().Get("benchmark"))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    report, err := h.performanceService.Performance(r.Context(), performanceReq)
+    if err != nil {
+        writeAnalyticsError(w, err, "could not calculate performance")
+        return
+    }
+
+    response := map[string]interface{}{
+        "performance": report,
+        "timestamp":   time.Now(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// writeRisk computes and writes risk metrics for a basket
+func (h *AnalyticsHandler) writeRisk(w http.ResponseWriter, r *http.Request, req service.RiskRequest) {
+    metrics, err := h.riskService.Risk(r.Context(), req)
+    if err != nil {
+        writeAnalyticsError(w, err, "could not calculate risk")
+        return
+    }
+
+    response := map[string]interface{}{
+        "risk":      metrics,
+        "timestamp": time.Now(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// GetSymbolRisk returns risk metrics for one ?symbol= against ?index= (default SPY)
+// over ?start= to ?end=, with optional ?risk_free= and ?confidence=
+func (h *AnalyticsHandler) GetSymbolRisk(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    query := r.URL.Query()
+    symbol := query.Get("symbol")
+    if symbol == "" {
+        http.Error(w, "symbol is required", http.StatusBadRequest)
+        return
+    }
+
+    req := service.RiskRequest{
+        Holdings: []service.RiskHolding{{Symbol: symbol, Weight: 1}},
+        Index:    query.Get("index"),
+    }
+
+    var err error
+    req.Start, req.End, err = parseDateRange(query.Get("start"), query.Get("end"))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    if riskFree := query.Get("risk_free"); riskFree != "" {
+        if req.RiskFreeRate, err = strconv.ParseFloat(riskFree, 64); err != nil {
+            http.Error(w, "invalid risk_free", http.StatusBadRequest)
+            return
+        }
+    }
+    if confidence := query.Get("confidence"); confidence != "" {
+        if req.Confidence, err = strconv.ParseFloat(confidence, 64); err != nil {
+            http.Error(w, "invalid confidence", http.StatusBadRequest)
+            return
+        }
+    }
+
+    h.writeRisk(w, r, req)
+}
+
+// Risk returns risk metrics for the posted weighted basket of symbols
+func (h *AnalyticsHandler) Risk(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var body RiskRequest
+    if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+        http.Error(w, "Invalid request body", http.StatusBadRequest)
+        return
+    }
+
+    req := service.RiskRequest{
+        Holdings:     body.Holdings,
+        Index:        body.Index,
+        RiskFreeRate: body.RiskFreeRate,
+        Confidence:   body.Confidence,
+    }
+
+    var err error
+    req.Start, req.End, err = parseDateRange(body.Start, body.End)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    h.writeRisk(w, r, req)
+}
+
+// Correlation returns correlation and covariance matrices of the posted symbols' returns
+func (h *AnalyticsHandler) Correlation(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var body CorrelationRequest
+    if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+        http.Error(w, "Invalid request body", http.StatusBadRequest)
+        return
+    }
+
+    req := service.CorrelationRequest{
+        Symbols:    body.Symbols,
+        Frequency:  body.Frequency,
+        MinOverlap: body.MinOverlap,
+    }
+
+    var err error
+    req.Start, req.End, err = parseDateRange(body.Start, body.End)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    matrix, err := h.riskService.Correlation(req)
+    if err != nil {
+        writeAnalyticsError(w, err, "could not calculate correlation")
+        return
+    }
+
+    response := map[string]interface{}{
+        "correlation": matrix,
+        "timestamp":   time.Now(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
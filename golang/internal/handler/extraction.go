@@ -3,26 +3,57 @@ package handler
 import (
     "context"
     "encoding/json"
+    "errors"
+    "fmt"
     "net/http"
     "time"
+    "stock-api/internal/api"
+    "stock-api/internal/repository"
     "stock-api/internal/service"
 )
 
 // ExtractionHandler handles data extraction endpoints
 type ExtractionHandler struct {
     extractionService *service.DataExtractionService
+    watchlistService  *service.WatchlistService
 }
 
-// NewExtractionHandler creates a new extraction handler
-func NewExtractionHandler(es *service.DataExtractionService) *ExtractionHandler {
-    return &ExtractionHandler{extractionService: es}
+// NewExtractionHandler creates a new extraction handler; watchlists can stand
+// in for the symbol list of batch extractions
+func NewExtractionHandler(es *service.DataExtractionService, ws *service.WatchlistService) *ExtractionHandler {
+    return &ExtractionHandler{extractionService: es, watchlistService: ws}
 }
 
 // ExtractStockDataRequest represents the request for extracting stock data
 type ExtractStockDataRequest struct {
     Symbol string `json:"symbol"`
-    From time.Time `json:"from`
-    To time.Time `json:"to`
+    From time.Time `json:"from"`
+    To time.Time `json:"to"`
+    // Interval is the bar size multiplier, e.g. 5 with timespan "minute" for 5-minute bars
+    Interval int `json:"interval"`
+    // Timespan is minute or hour (default minute); daily history is extracted by /api/extract/daily
+    Timespan string `json:"timespan"`
+}
+
+// barSize validates the requested interval and intraday timespan, defaulting to 5-minute bars
+func barSize(interval int, timespan string) (int, api.Timespan, error) {
+    ts, err := api.ParseTimespan(timespan)
+    if err != nil {
+        return 0, "", err
+    }
+    if !ts.Intraday() {
+        return 0, "", fmt.Errorf("timespan must be minute or hour; use /api/extract/daily for %s bars", ts)
+    }
+    if interval < 0 {
+        return 0, "", fmt.Errorf("interval must be positive")
+    }
+    if interval == 0 {
+        interval = 1
+        if ts == api.TimespanMinute {
+            interval = 5
+        }
+    }
+    return interval, ts, nil
 }
 
 type ExtractByExchangeRequest struct {
@@ -40,6 +71,7 @@ type ExtractStockDataResponse struct {
     Status    string    `json:"status"`
     Message   string    `json:"message"`
     Timestamp time.Time `json:"timestamp"`
+    Rows      *repository.UpsertCounts `json:"rows,omitempty"`
 }
 
 // ExtractStockData extracts stock data for a given symbol
@@ -60,16 +92,23 @@ func (h *ExtractionHandler) ExtractStockData(w http.ResponseWriter, r *http.Requ
         return
     }
 
+    interval, timespan, err := barSize(req.Interval, req.Timespan)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
     // Create context with timeout
     ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
     defer cancel()
 
     // Extract data
-    err := h.extractionService.ExtractAndStoreStockData(ctx, req.Symbol, req.From, req.To)
+    counts, err := h.extractionService.ExtractAndStoreStockData(ctx, req.Symbol, req.From, req.To, interval, timespan)
     
     response := ExtractStockDataResponse{
         Symbol:    req.Symbol,
         Timestamp: time.Now(),
+        Rows:      counts,
     }
 
     if err != nil {
@@ -131,8 +170,14 @@ func (h *ExtractionHandler) ExtractStockData(w http.ResponseWriter, r *http.Requ
 // BatchExtractDataRequest represents the request for batch extraction
 type BatchExtractDataRequest struct {
     Symbols []string `json:"symbols"`
-    From    time.Time `json:"from`
-    To    time.Time `json:"to`
+    // WatchlistID extracts the symbols on one watchlist instead of Symbols
+    WatchlistID int `json:"watchlist_id"`
+    // Watchlists extracts every symbol on any user's watchlist instead of Symbols
+    Watchlists bool `json:"watchlists"`
+    From    time.Time `json:"from"`
+    To    time.Time `json:"to"`
+    Interval int `json:"interval"`
+    Timespan string `json:"timespan"`
 }
 
 // BatchExtractDataResponse represents the response for batch extraction
@@ -142,6 +187,20 @@ type BatchExtractDataResponse struct {
     Timestamp time.Time `json:"timestamp"`
     Processed int       `json:"processed"`
     Failed    int       `json:"failed"`
+    FailedSymbols map[string]string `json:"failed_symbols,omitempty"`
+    Rows      *repository.UpsertCounts `json:"rows,omitempty"`
+}
+
+// batchSymbols returns the request's symbols, or the watched symbols when the
+// request asks for a watchlist
+func (h *ExtractionHandler) batchSymbols(req *BatchExtractDataRequest) ([]string, error) {
+    if req.WatchlistID == 0 && !req.Watchlists {
+        return req.Symbols, nil
+    }
+    if len(req.Symbols) > 0 {
+        return nil, errors.New("symbols can't be combined with watchlist_id or watchlists")
+    }
+    return h.watchlistService.WatchedSymbols(req.WatchlistID)
 }
 
 // BatchExtractData extracts data for multiple symbols
@@ -157,24 +216,41 @@ func (h *ExtractionHandler) BatchExtractData(w http.ResponseWriter, r *http.Requ
         return
     }
 
+    symbols, err := h.batchSymbols(&req)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    req.Symbols = symbols
+
     if len(req.Symbols) == 0 {
         http.Error(w, "At least one symbol is required", http.StatusBadRequest)
         return
     }
 
+    interval, timespan, err := barSize(req.Interval, req.Timespan)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
     // Create context with timeout (longer for batch operations)
     ctx, cancel := context.WithTimeout(r.Context(), 30*time.Minute)
     defer cancel()
 
     // Extract data for all symbols
-    err := h.extractionService.BatchExtractData(ctx, req.Symbols, req.From, req.To)
+    result, err := h.extractionService.BatchExtractData(ctx, req.Symbols, req.From, req.To, interval, timespan)
     
     response := BatchExtractDataResponse{
         Status:    "success",
         Message:    "Batch extraction completed",
         Timestamp:  time.Now(),
-        Processed:  len(req.Symbols),
-        Failed:     0, // This would need to be tracked in the service
+    }
+    if result != nil {
+        response.Processed = len(result.Stored)
+        response.Failed = len(result.Failed)
+        response.FailedSymbols = result.Failed
+        response.Rows = result.Rows
     }
 
     if err != nil {
@@ -187,6 +263,47 @@ func (h *ExtractionHandler) BatchExtractData(w http.ResponseWriter, r *http.Requ
     json.NewEncoder(w).Encode(response)
 }
 
+// ExtractDailyData extracts daily bars for multiple symbols into stocks_daily
+func (h *ExtractionHandler) ExtractDailyData(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var req BatchExtractDataRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, "Invalid request body", http.StatusBadRequest)
+        return
+    }
+
+    symbols, err := h.batchSymbols(&req)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    req.Symbols = symbols
+
+    if len(req.Symbols) == 0 {
+        http.Error(w, "At least one symbol is required", http.StatusBadRequest)
+        return
+    }
+
+    // Default to five years of history
+    if req.To.IsZero() {
+        req.To = time.Now()
+    }
+    if req.From.IsZero() {
+        req.From = req.To.AddDate(-5, 0, 0)
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 30*time.Minute)
+    defer cancel()
+
+    result, err := h.extractionService.BatchExtractAndStoreDailyData(ctx, req.Symbols, req.From, req.To)
+
+    writeBatchResponse(w, req.Symbols, result, err, "Daily data extracted successfully")
+}
+
 // GetExtractionStatus returns the status of data extraction
 func (h *ExtractionHandler) GetExtractionStatus(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodGet {
@@ -353,24 +470,9 @@ func (h *ExtractionHandler) ExtractCompanyOverviews(w http.ResponseWriter, r *ht
     ctx, cancel := context.WithTimeout(r.Context(), 15*time.Minute)
     defer cancel()
 
-    err := h.extractionService.BatchExtractAndStoreStockOverview(ctx, req.Symbols)
-
-    response := map[string]interface{}{
-        "symbols":   req.Symbols,
-        "timestamp": time.Now(),
-    }
+    result, err := h.extractionService.BatchExtractAndStoreStockOverview(ctx, req.Symbols)
 
-    if err != nil {
-        response["status"] = "error"
-        response["message"] = err.Error()
-        w.WriteHeader(http.StatusInternalServerError)
-    } else {
-        response["status"] = "success"
-        response["message"] = "Company overviews extracted successfully"
-    }
-
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(response)
+    writeBatchResponse(w, req.Symbols, result, err, "Company overviews extracted successfully")
 }
 
 func (h *ExtractionHandler) ExtractCompanyIncomeStatements(w http.ResponseWriter, r *http.Request) {
@@ -393,24 +495,9 @@ func (h *ExtractionHandler) ExtractCompanyIncomeStatements(w http.ResponseWriter
     ctx, cancel := context.WithTimeout(r.Context(), 15*time.Minute)
     defer cancel()
 
-    err := h.extractionService.BatchExtractAndStoreStockIncomeStatment(ctx, req.Symbols)
-
-    response := map[string]interface{}{
-        "symbols":   req.Symbols,
-        "timestamp": time.Now(),
-    }
-
-    if err != nil {
-        response["status"] = "error"
-        response["message"] = err.Error()
-        w.WriteHeader(http.StatusInternalServerError)
-    } else {
-        response["status"] = "success"
-        response["message"] = "Company income statments extracted successfully"
-    }
+    result, err := h.extractionService.BatchExtractAndStoreStockIncomeStatment(ctx, req.Symbols)
 
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(response)
+    writeBatchResponse(w, req.Symbols, result, err, "Company income statments extracted successfully")
 }
 
 func (h *ExtractionHandler) ExtractCompanyBalanceSheets(w http.ResponseWriter, r *http.Request) {
@@ -433,22 +520,41 @@ func (h *ExtractionHandler) ExtractCompanyBalanceSheets(w http.ResponseWriter, r
     ctx, cancel := context.WithTimeout(r.Context(), 15*time.Minute)
     defer cancel()
 
-    err := h.extractionService.BatchExtractAndStoreStockBalanceStatement(ctx, req.Symbols)
+    result, err := h.extractionService.BatchExtractAndStoreStockBalanceStatement(ctx, req.Symbols)
 
+    writeBatchResponse(w, req.Symbols, result, err, "Company balance sheets extracted successfully")
+}
+// writeBatchResponse reports the per-symbol outcome of a batch extraction. A batch
+// stopped by an exhausted provider quota is answered with 429 so callers retry it later.
+func writeBatchResponse(w http.ResponseWriter, symbols []string, result *service.BatchResult, err error, successMessage string) {
     response := map[string]interface{}{
-        "symbols":   req.Symbols,
+        "symbols":   symbols,
         "timestamp": time.Now(),
     }
 
+    if result != nil {
+        response["stored"] = result.Stored
+        response["failed"] = result.Failed
+        response["skipped"] = result.Skipped
+        if result.Rows != nil {
+            response["rows"] = result.Rows
+        }
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+
     if err != nil {
         response["status"] = "error"
         response["message"] = err.Error()
-        w.WriteHeader(http.StatusInternalServerError)
+        if errors.Is(err, api.ErrQuotaExceeded) || errors.Is(err, api.ErrRateLimited) {
+            w.WriteHeader(http.StatusTooManyRequests)
+        } else {
+            w.WriteHeader(http.StatusInternalServerError)
+        }
     } else {
         response["status"] = "success"
-        response["message"] = "Company balance sheets extracted successfully"
+        response["message"] = successMessage
     }
 
-    w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(response)
-}
\ No newline at end of file
+}
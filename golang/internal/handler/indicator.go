@@ -0,0 +1,107 @@
+package handler
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "time"
+    "stock-api/internal/service"
+)
+
+// IndicatorHandler serves and recalculates technical indicators
+type IndicatorHandler struct {
+    indicatorService *service.IndicatorService
+}
+
+// NewIndicatorHandler creates a new indicator handler
+func NewIndicatorHandler(is *service.IndicatorService) *IndicatorHandler {
+    return &IndicatorHandler{indicatorService: is}
+}
+
+type CalculateIndicatorsRequest struct {
+    Symbols []string `json:"symbols"`
+}
+
+// GetIndicators returns stored indicators for a symbol, limited to ?names= when given
+func (h *IndicatorHandler) GetIndicators(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    symbol := r.URL.Query().Get("symbol")
+    if symbol == "" {
+        http.Error(w, "symbol is required", http.StatusBadRequest)
+        return
+    }
+
+    names, err := service.ParseIndicatorNames(r.URL.Query().Get("names"))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    startDate := time.Now().AddDate(0, 0, -30)
+    endDate := time.Now()
+
+    if startDateStr := r.URL.Query().Get("start"); startDateStr != "" {
+        startDate, err = time.Parse("2006-01-02", startDateStr)
+        if err != nil {
+            http.Error(w, "invalid start date format (use YYYY-MM-DD)", http.StatusBadRequest)
+            return
+        }
+    }
+
+    if endDateStr := r.URL.Query().Get("end"); endDateStr != "" {
+        endDate, err = time.Parse("2006-01-02", endDateStr)
+        if err != nil {
+            http.Error(w, "invalid end date format (use YYYY-MM-DD)", http.StatusBadRequest)
+            return
+        }
+    }
+
+    // The end date is inclusive, so include every bar on that day
+    data, err := h.indicatorService.GetIndicators(symbol, names, startDate, endDate.AddDate(0, 0, 1))
+    if err != nil {
+        http.Error(w, "could not get indicators", http.StatusInternalServerError)
+        return
+    }
+
+    response := map[string]interface{}{
+        "symbol":     symbol,
+        "names":      names,
+        "start_date": startDate.Format("2006-01-02"),
+        "end_date":   endDate.Format("2006-01-02"),
+        "data":       data,
+        "count":      len(data),
+        "timestamp":  time.Now(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// CalculateIndicators computes indicators for bars that don't have them yet
+func (h *IndicatorHandler) CalculateIndicators(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var req CalculateIndicatorsRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, "Invalid request body", http.StatusBadRequest)
+        return
+    }
+
+    if len(req.Symbols) == 0 {
+        http.Error(w, "At least one symbol is required", http.StatusBadRequest)
+        return
+    }
+
+    ctx, cancel := context.WithTimeout(r.Context(), 15*time.Minute)
+    defer cancel()
+
+    result, err := h.indicatorService.BatchComputeAndStore(ctx, req.Symbols)
+    writeBatchResponse(w, req.Symbols, result, err, "Indicators calculated successfully")
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+    "encoding/json"
+    "io"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+    "stock-api/internal/service"
+)
+
+// maxImportSize caps the size of an uploaded broker export or bank statement
+const maxImportSize = 10 << 20
+
+// LotHandler serves tax-lot reports and broker imports for the authenticated user
+type LotHandler struct {
+    lotService *service.LotService
+}
+
+// NewLotHandler creates a new lot handler
+func NewLotHandler(ls *service.LotService) *LotHandler {
+    return &LotHandler{lotService: ls}
+}
+
+// GetRealizedGains returns the lots closed in tax ?year= (default: this year),
+// optionally for one ?account_id=
+func (h *LotHandler) GetRealizedGains(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    year := time.Now().Year()
+    if yearStr := r.URL.Query().Get("year"); yearStr != "" {
+        parsed, err := strconv.Atoi(yearStr)
+        if err != nil || parsed < 1900 || parsed > 9999 {
+            http.Error(w, "invalid year", http.StatusBadRequest)
+            return
+        }
+        year = parsed
+    }
+
+    accountID, err := queryInt(r, "account_id")
+    if err != nil {
+        http.Error(w, "invalid account_id", http.StatusBadRequest)
+        return
+    }
+
+    report, err := h.lotService.RealizedGains(userID, year, accountID)
+    if err != nil {
+        writePortfolioError(w, err, "could not get realized gains")
+        return
+    }
+
+    response := map[string]interface{}{
+        "report":    report,
+        "count":     len(report.Lots),
+        "timestamp": time.Now(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// GetOpenLots returns the user's unsold lots valued at the latest price, optionally for one ?account_id=
+func (h *LotHandler) GetOpenLots(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    accountID, err := queryInt(r, "account_id")
+    if err != nil {
+        http.Error(w, "invalid account_id", http.StatusBadRequest)
+        return
+    }
+
+    report, err := h.lotService.OpenLots(userID, accountID)
+    if err != nil {
+        writePortfolioError(w, err, "could not get open lots")
+        return
+    }
+
+    response := map[string]interface{}{
+        "report":    report,
+        "count":     len(report.Lots),
+        "timestamp": time.Now(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// ImportCSV imports buys and sells from a broker CSV export into ?account_id=.
+// The CSV is either the raw request body or a multipart "file" field.
+func (h *LotHandler) ImportCSV(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    accountID, err := strconv.Atoi(r.URL.Query().Get("account_id"))
+    if err != nil {
+        http.Error(w, "account_id is required", http.StatusBadRequest)
+        return
+    }
+
+    r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+    var body io.Reader = r.Body
+    if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+        file, _, err := r.FormFile("file")
+        if err != nil {
+            http.Error(w, "file is required", http.StatusBadRequest)
+            return
+        }
+        defer file.Close()
+        body = file
+    }
+
+    result, err := h.lotService.ImportCSV(userID, accountID, body)
+    if err != nil {
+        writePortfolioError(w, err, "could not import trades")
+        return
+    }
+
+    response := map[string]interface{}{
+        "result":    result,
+        "message":   "Trades imported successfully",
+        "timestamp": time.Now(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
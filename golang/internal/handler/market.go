@@ -0,0 +1,71 @@
+package handler
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "strconv"
+    "time"
+    "stock-api/internal/service"
+)
+
+// MarketHandler serves market-wide rankings
+type MarketHandler struct {
+    marketService *service.MarketService
+}
+
+// NewMarketHandler creates a new market handler
+func NewMarketHandler(ms *service.MarketService) *MarketHandler {
+    return &MarketHandler{marketService: ms}
+}
+
+// GetMovers returns the latest session's top gainers, losers, most active or
+// unusual-volume symbols (?type=gainers&limit=20&exchange=&industry=&min_rvol=)
+func (h *MarketHandler) GetMovers(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    query := r.URL.Query()
+    limit, err := queryInt(r, "limit")
+    if err != nil {
+        http.Error(w, "invalid limit", http.StatusBadRequest)
+        return
+    }
+    var minRVOL float64
+    if value := query.Get("min_rvol"); value != "" {
+        minRVOL, err = strconv.ParseFloat(value, 64)
+        if err != nil {
+            http.Error(w, "invalid min_rvol", http.StatusBadRequest)
+            return
+        }
+    }
+
+    result, err := h.marketService.Movers(r.Context(), service.MoversQuery{
+        Type:              query.Get("type"),
+        Limit:             limit,
+        Exchange:          query.Get("exchange"),
+        Industry:          query.Get("industry"),
+        MinRelativeVolume: minRVOL,
+    })
+    if errors.Is(err, service.ErrInvalidMarketQuery) {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    if err != nil {
+        http.Error(w, "could not get market movers", http.StatusInternalServerError)
+        return
+    }
+
+    response := map[string]interface{}{
+        "type":        result.Type,
+        "session":     result.Session,
+        "computed_at": result.ComputedAt,
+        "movers":      result.Movers,
+        "count":       len(result.Movers),
+        "timestamp":   time.Now(),
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
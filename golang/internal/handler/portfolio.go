@@ -0,0 +1,284 @@
+package handler
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "strconv"
+    "time"
+    "stock-api/internal/repository"
+    "stock-api/internal/service"
+)
+
+// PortfolioHandler manages accounts, trades and holdings for the authenticated user
+type PortfolioHandler struct {
+    portfolioService *service.PortfolioService
+}
+
+// NewPortfolioHandler creates a new portfolio handler
+func NewPortfolioHandler(ps *service.PortfolioService) *PortfolioHandler {
+    return &PortfolioHandler{portfolioService: ps}
+}
+
+type AccountRequest struct {
+    Name     string `json:"name"`
+    Broker   string `json:"broker"`
+    Currency string `json:"currency"`
+    // LotMethod is fifo (default), lifo, highest_cost or specific_id
+    LotMethod string `json:"lot_method"`
+}
+
+type TradeRequest struct {
+    AccountID  int     `json:"account_id"`
+    Symbol     string  `json:"symbol"`
+    Type       string  `json:"type"`
+    // TradeDate is RFC 3339 or YYYY-MM-DD
+    TradeDate  string  `json:"trade_date"`
+    Quantity   float64 `json:"quantity"`
+    Price      float64 `json:"price"`
+    Fees       float64 `json:"fees"`
+    Amount     float64 `json:"amount"`
+    SplitRatio float64 `json:"split_ratio"`
+    Notes      string  `json:"notes"`
+    // LotMethod overrides the account's lot method for a sell
+    LotMethod  string  `json:"lot_method"`
+    // Lots chooses the lots a specific_id sell closes
+    Lots       []repository.LotSelection `json:"lots"`
+}
+
+// parseTradeDate accepts a full timestamp or a plain date
+func parseTradeDate(s string) (time.Time, error) {
+    if t, err := time.Parse(time.RFC3339, s); err == nil {
+        return t, nil
+    }
+    return time.Parse("2006-01-02", s)
+}
+
+func (req *TradeRequest) toTrade() (*repository.Trade, error) {
+    tradeDate, err := parseTradeDate(req.TradeDate)
+    if err != nil {
+        return nil, errors.New("invalid trade_date format (use YYYY-MM-DD or RFC 3339)")
+    }
+    return &repository.Trade{
+        AccountID:  req.AccountID,
+        Symbol:     req.Symbol,
+        Type:       repository.TradeType(req.Type),
+        TradeDate:  tradeDate,
+        Quantity:   req.Quantity,
+        Price:      req.Price,
+        Fees:       req.Fees,
+        Amount:     req.Amount,
+        SplitRatio: req.SplitRatio,
+        Notes:      req.Notes,
+        LotMethod:  req.LotMethod,
+        Lots:       req.Lots,
+    }, nil
+}
+
+// writePortfolioError maps portfolio errors to HTTP statuses
+func writePortfolioError(w http.ResponseWriter, err error, message string) {
+    switch {
+    case errors.Is(err, service.ErrInvalidTrade), errors.Is(err, service.ErrInvalidAccount):
+        http.Error(w, err.Error(), http.StatusBadRequest)
+    case errors.Is(err, service.ErrPortfolioNotFound):
+        http.Error(w, err.Error(), http.StatusNotFound)
+    default:
+        http.Error(w, message, http.StatusInternalServerError)
+    }
+}
+
+// queryInt parses an optional integer query parameter, returning 0 when it is absent
+func queryInt(r *http.Request, name string) (int, error) {
+    value := r.URL.Query().Get(name)
+    if value == "" {
+        return 0, nil
+    }
+    return strconv.Atoi(value)
+}
+
+// Accounts lists (GET), creates (POST) or deletes (DELETE ?id=) the user's accounts
+func (h *PortfolioHandler) Accounts(w http.ResponseWriter, r *http.Request) {
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    var response map[string]interface{}
+    status := http.StatusOK
+
+    switch r.Method {
+    case http.MethodGet:
+        accounts, err := h.portfolioService.GetAccounts(userID)
+        if err != nil {
+            writePortfolioError(w, err, "could not get accounts")
+            return
+        }
+        response = map[string]interface{}{
+            "accounts": accounts,
+            "count":    len(accounts),
+        }
+
+    case http.MethodPost:
+        var req AccountRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "Invalid request body", http.StatusBadRequest)
+            return
+        }
+        account := &repository.PortfolioAccount{Name: req.Name, Broker: req.Broker, Currency: req.Currency, LotMethod: req.LotMethod}
+        if err := h.portfolioService.CreateAccount(userID, account); err != nil {
+            writePortfolioError(w, err, "could not create account")
+            return
+        }
+        response = map[string]interface{}{
+            "account": account,
+            "message": "Account created successfully",
+        }
+        status = http.StatusCreated
+
+    case http.MethodDelete:
+        id, err := strconv.Atoi(r.URL.Query().Get("id"))
+        if err != nil {
+            http.Error(w, "id is required", http.StatusBadRequest)
+            return
+        }
+        if err := h.portfolioService.DeleteAccount(userID, id); err != nil {
+            writePortfolioError(w, err, "could not delete account")
+            return
+        }
+        response = map[string]interface{}{
+            "id":      id,
+            "message": "Account deleted successfully",
+        }
+
+    default:
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    response["timestamp"] = time.Now()
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(response)
+}
+
+// Trades lists (GET ?account_id=&symbol=), creates (POST), updates (PUT ?id=)
+// or deletes (DELETE ?id=) the user's trades
+func (h *PortfolioHandler) Trades(w http.ResponseWriter, r *http.Request) {
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    var response map[string]interface{}
+    status := http.StatusOK
+
+    switch r.Method {
+    case http.MethodGet:
+        accountID, err := queryInt(r, "account_id")
+        if err != nil {
+            http.Error(w, "invalid account_id", http.StatusBadRequest)
+            return
+        }
+        trades, err := h.portfolioService.GetTrades(userID, accountID, r.URL.Query().Get("symbol"))
+        if err != nil {
+            writePortfolioError(w, err, "could not get trades")
+            return
+        }
+        response = map[string]interface{}{
+            "trades": trades,
+            "count":  len(trades),
+        }
+
+    case http.MethodPost, http.MethodPut:
+        var req TradeRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "Invalid request body", http.StatusBadRequest)
+            return
+        }
+        trade, err := req.toTrade()
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+
+        if r.Method == http.MethodPost {
+            err = h.portfolioService.CreateTrade(userID, trade)
+            status = http.StatusCreated
+        } else {
+            trade.ID, err = strconv.Atoi(r.URL.Query().Get("id"))
+            if err != nil {
+                http.Error(w, "id is required", http.StatusBadRequest)
+                return
+            }
+            err = h.portfolioService.UpdateTrade(userID, trade)
+        }
+        if err != nil {
+            writePortfolioError(w, err, "could not save trade")
+            return
+        }
+        response = map[string]interface{}{
+            "trade":   trade,
+            "message": "Trade saved successfully",
+        }
+
+    case http.MethodDelete:
+        id, err := strconv.Atoi(r.URL.Query().Get("id"))
+        if err != nil {
+            http.Error(w, "id is required", http.StatusBadRequest)
+            return
+        }
+        if err := h.portfolioService.DeleteTrade(userID, id); err != nil {
+            writePortfolioError(w, err, "could not delete trade")
+            return
+        }
+        response = map[string]interface{}{
+            "id":      id,
+            "message": "Trade deleted successfully",
+        }
+
+    default:
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    response["timestamp"] = time.Now()
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(response)
+}
+
+// Holdings returns the user's open positions valued at the latest price, optionally for one ?account_id=
+func (h *PortfolioHandler) Holdings(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    accountID, err := queryInt(r, "account_id")
+    if err != nil {
+        http.Error(w, "invalid account_id", http.StatusBadRequest)
+        return
+    }
+
+    summary, err := h.portfolioService.GetHoldings(userID, accountID)
+    if err != nil {
+        writePortfolioError(w, err, "could not get holdings")
+        return
+    }
+
+    response := map[string]interface{}{
+        "summary":   summary,
+        "timestamp": time.Now(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
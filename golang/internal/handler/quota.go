@@ -0,0 +1,50 @@
+package handler
+
+import (
+    "encoding/json"
+    "net/http"
+    "time"
+    "stock-api/internal/service"
+)
+
+// QuotaHandler exposes provider quota usage
+type QuotaHandler struct {
+    quotaService *service.QuotaService
+}
+
+// NewQuotaHandler creates a new quota handler
+func NewQuotaHandler(qs *service.QuotaService) *QuotaHandler {
+    return &QuotaHandler{quotaService: qs}
+}
+
+// GetQuota returns used and remaining calls per provider key, optionally filtered by ?provider=
+func (h *QuotaHandler) GetQuota(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    usage, err := h.quotaService.GetUsage(r.Context())
+    if err != nil {
+        http.Error(w, "could not get provider quota", http.StatusInternalServerError)
+        return
+    }
+
+    if provider := r.URL.Query().Get("provider"); provider != "" {
+        filtered := []service.QuotaUsage{}
+        for _, u := range usage {
+            if u.Provider == provider {
+                filtered = append(filtered, u)
+            }
+        }
+        usage = filtered
+    }
+
+    response := map[string]interface{}{
+        "providers": usage,
+        "timestamp": time.Now(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "strconv"
+    "time"
+    "stock-api/internal/middleware"
+    "stock-api/internal/repository"
+    "stock-api/internal/service"
+)
+
+// ScreenerHandler runs stock screens and manages saved screens
+type ScreenerHandler struct {
+    screenerService *service.ScreenerService
+}
+
+// NewScreenerHandler creates a new screener handler
+func NewScreenerHandler(ss *service.ScreenerService) *ScreenerHandler {
+    return &ScreenerHandler{screenerService: ss}
+}
+
+type SaveScreenRequest struct {
+    Name  string                 `json:"name"`
+    Query repository.ScreenQuery `json:"query"`
+}
+
+// userIDFromRequest returns the user id set by the auth middleware
+func userIDFromRequest(r *http.Request) (int, bool) {
+    userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+    return userID, ok
+}
+
+// writeScreenError maps screener errors to HTTP statuses
+func writeScreenError(w http.ResponseWriter, err error, message string) {
+    switch {
+    case errors.Is(err, repository.ErrInvalidScreen):
+        http.Error(w, err.Error(), http.StatusBadRequest)
+    case errors.Is(err, service.ErrScreenNotFound):
+        http.Error(w, err.Error(), http.StatusNotFound)
+    default:
+        http.Error(w, message, http.StatusInternalServerError)
+    }
+}
+
+// Screen runs an ad-hoc screen over scorecards and metadata
+func (h *ScreenerHandler) Screen(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var q repository.ScreenQuery
+    if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+        http.Error(w, "Invalid request body", http.StatusBadRequest)
+        return
+    }
+
+    result, err := h.screenerService.Screen(q)
+    if err != nil {
+        writeScreenError(w, err, "could not run screen")
+        return
+    }
+
+    response := map[string]interface{}{
+        "result":    result,
+        "timestamp": time.Now(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// SavedScreens lists (GET), saves (POST) or deletes (DELETE ?id=) the user's screens
+func (h *ScreenerHandler) SavedScreens(w http.ResponseWriter, r *http.Request) {
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    var response map[string]interface{}
+
+    switch r.Method {
+    case http.MethodGet:
+        screens, err := h.screenerService.GetSavedScreens(userID)
+        if err != nil {
+            writeScreenError(w, err, "could not get saved screens")
+            return
+        }
+        response = map[string]interface{}{
+            "screens": screens,
+            "count":   len(screens),
+        }
+
+    case http.MethodPost:
+        var req SaveScreenRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "Invalid request body", http.StatusBadRequest)
+            return
+        }
+        screen, err := h.screenerService.SaveScreen(userID, req.Name, req.Query)
+        if err != nil {
+            writeScreenError(w, err, "could not save screen")
+            return
+        }
+        response = map[string]interface{}{
+            "screen":  screen,
+            "message": "Screen saved successfully",
+        }
+
+    case http.MethodDelete:
+        id, err := strconv.Atoi(r.URL.Query().Get("id"))
+        if err != nil {
+            http.Error(w, "id is required", http.StatusBadRequest)
+            return
+        }
+        if err := h.screenerService.DeleteSavedScreen(userID, id); err != nil {
+            writeScreenError(w, err, "could not delete screen")
+            return
+        }
+        response = map[string]interface{}{
+            "id":      id,
+            "message": "Screen deleted successfully",
+        }
+
+    default:
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    response["timestamp"] = time.Now()
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// RunSavedScreen runs one of the user's saved screens, with optional ?limit= and ?offset=
+func (h *ScreenerHandler) RunSavedScreen(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    id, err := strconv.Atoi(r.URL.Query().Get("id"))
+    if err != nil {
+        http.Error(w, "id is required", http.StatusBadRequest)
+        return
+    }
+
+    limit, offset := 0, 0
+    if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+        if limit, err = strconv.Atoi(limitStr); err != nil {
+            http.Error(w, "invalid limit", http.StatusBadRequest)
+            return
+        }
+    }
+    if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+        if offset, err = strconv.Atoi(offsetStr); err != nil {
+            http.Error(w, "invalid offset", http.StatusBadRequest)
+            return
+        }
+    }
+
+    screen, result, err := h.screenerService.RunSavedScreen(userID, id, limit, offset)
+    if err != nil {
+        writeScreenError(w, err, "could not run saved screen")
+        return
+    }
+
+    response := map[string]interface{}{
+        "screen":    screen,
+        "result":    result,
+        "timestamp": time.Now(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
@@ -112,6 +112,58 @@ func (h *StockHandler) GetStockData(w http.ResponseWriter, r *http.Request) {
     json.NewEncoder(w).Encode(response)
 }
 
+// GetDailyData gets daily bars for a symbol, defaulting to the last year
+func (h *StockHandler) GetDailyData(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    symbol := r.URL.Query().Get("symbol")
+    if symbol == "" {
+        http.Error(w, "symbol is required", http.StatusBadRequest)
+        return
+    }
+
+    startDate := time.Now().AddDate(-1, 0, 0)
+    endDate := time.Now()
+    var err error
+
+    if startDateStr := r.URL.Query().Get("start"); startDateStr != "" {
+        startDate, err = time.Parse("2006-01-02", startDateStr)
+        if err != nil {
+            http.Error(w, "invalid start date format (use YYYY-MM-DD)", http.StatusBadRequest)
+            return
+        }
+    }
+
+    if endDateStr := r.URL.Query().Get("end"); endDateStr != "" {
+        endDate, err = time.Parse("2006-01-02", endDateStr)
+        if err != nil {
+            http.Error(w, "invalid end date format (use YYYY-MM-DD)", http.StatusBadRequest)
+            return
+        }
+    }
+
+    data, err := h.service.GetDailyData(symbol, startDate, endDate)
+    if err != nil {
+        http.Error(w, "could not get daily data", http.StatusInternalServerError)
+        return
+    }
+
+    response := map[string]interface{}{
+        "symbol":     symbol,
+        "start_date": startDate.Format("2006-01-02"),
+        "end_date":   endDate.Format("2006-01-02"),
+        "data":       data,
+        "count":      len(data),
+        "timestamp":  time.Now(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
 // GetStockMetadata gets metadata for a specific symbol
 func (h *StockHandler) GetStockMetadata(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodGet {
@@ -252,6 +304,94 @@ func (h *StockHandler) DeleteStockMetadata(w http.ResponseWriter, r *http.Reques
     json.NewEncoder(w).Encode(response)
 }
 
+// GetScorecard returns a symbol's scorecard with its composite score, the
+// sub-score of every factor and the model that produced them
+func (h *StockHandler) GetScorecard(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    symbol := r.URL.Query().Get("symbol")
+    if symbol == "" {
+        http.Error(w, "symbol is required", http.StatusBadRequest)
+        return
+    }
+
+    card, err := h.service.GetScorecard(symbol)
+    if err != nil {
+        http.Error(w, "could not get scorecard", http.StatusInternalServerError)
+        return
+    }
+    if card == nil {
+        http.Error(w, "scorecard not found", http.StatusNotFound)
+        return
+    }
+
+    response := map[string]interface{}{
+        "scorecard": card,
+        "model":     h.service.ScoringModel(),
+        "timestamp": time.Now(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// GetScorecardHistory returns a symbol's scorecard snapshots, defaulting to the last year
+func (h *StockHandler) GetScorecardHistory(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    symbol := r.URL.Query().Get("symbol")
+    if symbol == "" {
+        http.Error(w, "symbol is required", http.StatusBadRequest)
+        return
+    }
+
+    from := time.Now().AddDate(-1, 0, 0)
+    to := time.Now()
+    var err error
+
+    if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+        from, err = time.Parse("2006-01-02", fromStr)
+        if err != nil {
+            http.Error(w, "invalid from date format (use YYYY-MM-DD)", http.StatusBadRequest)
+            return
+        }
+    }
+
+    if toStr := r.URL.Query().Get("to"); toStr != "" {
+        to, err = time.Parse("2006-01-02", toStr)
+        if err != nil {
+            http.Error(w, "invalid to date format (use YYYY-MM-DD)", http.StatusBadRequest)
+            return
+        }
+    }
+
+    // The to date is inclusive
+    trend, err := h.service.GetScorecardHistory(symbol, from, to.AddDate(0, 0, 1))
+    if err != nil {
+        http.Error(w, "could not get scorecard history", http.StatusInternalServerError)
+        return
+    }
+
+    response := map[string]interface{}{
+        "symbol":    symbol,
+        "from":      from.Format("2006-01-02"),
+        "to":        to.Format("2006-01-02"),
+        "history":   trend.Snapshots,
+        "change":    trend.Change,
+        "count":     len(trend.Snapshots),
+        "timestamp": time.Now(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
 func (s *StockHandler) CalculateStockScoreCard(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodPost {
         http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -272,22 +412,7 @@ func (s *StockHandler) CalculateStockScoreCard(w http.ResponseWriter, r *http.Re
     ctx, cancel := context.WithTimeout(r.Context(), 15*time.Minute)
     defer cancel()
 
-    err := s.service.CalculateLongTermScoreCard(ctx, req.Symbols)
-
-    response := map[string]interface{}{
-        "symbols":   req.Symbols,
-        "timestamp": time.Now(),
-    }
+    result, err := s.service.CalculateLongTermScoreCard(ctx, req.Symbols)
 
-    if err != nil {
-        response["status"] = "error"
-        response["message"] = err.Error()
-        w.WriteHeader(http.StatusInternalServerError)
-    } else {
-        response["status"] = "success"
-        response["message"] = "Stock Score cards calculated successfully"
-    }
-
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(response)
+    writeBatchResponse(w, req.Symbols, result, err, "Stock Score cards calculated successfully")
 }
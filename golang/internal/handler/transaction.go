@@ -3,11 +3,17 @@ package handler
 import (
     "context"
     "encoding/json"
+    "errors"
     "net/http"
+    "strconv"
     "time"
+    "stock-api/internal/api"
+    "stock-api/internal/repository"
     "stock-api/internal/service"
+    "stock-api/internal/statements"
 )
 
+// TransactionHandler imports and serves the authenticated user's bank transactions
 type TransactionHandler struct {
     transactionService *service.TransactionService
 }
@@ -16,26 +22,648 @@ func NewTransactionHandler(ts *service.TransactionService) *TransactionHandler {
     return &TransactionHandler{transactionService: ts}
 }
 
+type TransactionSourceRequest struct {
+    Name string `json:"name"`
+    // Kind is the source type; only rows is supported
+    Kind          string `json:"kind"`
+    SpreadsheetID string `json:"spreadsheet_id"`
+    TableID       string `json:"table_id"`
+    // Mapping names the header of each field; unset fields use the Rows template headers
+    Mapping api.RowsMapping `json:"mapping"`
+}
+
+type ImportProfileRequest struct {
+    Name string `json:"name"`
+    Bank string `json:"bank"`
+    // Profile is the CSV layout: delimiter, skip_lines, column headers, date_format, decimal_comma and invert_sign
+    Profile statements.CSVProfile `json:"profile"`
+}
+
+type CategoryRuleRequest struct {
+    Name     string `json:"name"`
+    // Priority orders rules; lower runs first and the first match wins (default 100)
+    Priority *int   `json:"priority"`
+    Category string `json:"category"`
+    // Pattern matches the description, or the normalised merchant
+    Pattern string `json:"pattern"`
+    // MatchType is contains (default) or regex
+    MatchType string `json:"match_type"`
+    // MinAmount and MaxAmount bound the absolute amount
+    MinAmount *float64 `json:"min_amount"`
+    MaxAmount *float64 `json:"max_amount"`
+    // Direction is debit, credit or empty for either
+    Direction string `json:"direction"`
+    Account   string `json:"account"`
+    Merchant  string `json:"merchant"`
+    // Override lets the rule replace a category provided by the source
+    Override bool  `json:"override"`
+    // Enabled defaults to true
+    Enabled  *bool `json:"enabled"`
+}
+
+func (req *CategoryRuleRequest) toRule() *repository.CategoryRule {
+    priority, enabled := 100, true
+    if req.Priority != nil {
+        priority = *req.Priority
+    }
+    if req.Enabled != nil {
+        enabled = *req.Enabled
+    }
+    return &repository.CategoryRule{
+        Name:      req.Name,
+        Priority:  priority,
+        Category:  req.Category,
+        Pattern:   req.Pattern,
+        MatchType: req.MatchType,
+        MinAmount: req.MinAmount,
+        MaxAmount: req.MaxAmount,
+        Direction: req.Direction,
+        Account:   req.Account,
+        Merchant:  req.Merchant,
+        Override:  req.Override,
+        Enabled:   enabled,
+    }
+}
+
+type RecategorizeRequest struct {
+    // From and To are optional YYYY-MM-DD bounds on the transaction date
+    From string `json:"from"`
+    To   string `json:"to"`
+    // Preview returns the changed rows without saving them
+    Preview bool `json:"preview"`
+}
+
+// writeTransactionError maps transaction errors to HTTP statuses
+func writeTransactionError(w http.ResponseWriter, err error, message string) {
+    switch {
+    case errors.Is(err, service.ErrInvalidTransactionSource), errors.Is(err, service.ErrInvalidTransactionQuery),
+        errors.Is(err, service.ErrInvalidImportProfile), errors.Is(err, statements.ErrInvalidStatement),
+        errors.Is(err, service.ErrInvalidCategoryRule):
+        http.Error(w, err.Error(), http.StatusBadRequest)
+    case errors.Is(err, service.ErrTransactionSourceNotFound), errors.Is(err, service.ErrImportRunNotFound),
+        errors.Is(err, service.ErrImportProfileNotFound), errors.Is(err, service.ErrCategoryRuleNotFound):
+        http.Error(w, err.Error(), http.StatusNotFound)
+    case errors.Is(err, service.ErrSourceFetch):
+        http.Error(w, err.Error(), http.StatusBadGateway)
+    default:
+        http.Error(w, message, http.StatusInternalServerError)
+    }
+}
+
+// ExtractTransactions imports one of the user's sources (?source_id=, optional
+// when the user has a single source) and reports the import run. It reads
+// the rows added since the last import, or the whole source with ?full=true.
 func (h *TransactionHandler) ExtractTransactions(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodPost {
         http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
         return
     }
 
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    sourceID, err := queryInt(r, "source_id")
+    if err != nil {
+        http.Error(w, "invalid source_id", http.StatusBadRequest)
+        return
+    }
+    if sourceID == 0 {
+        if sourceID, err = h.transactionService.DefaultSourceID(userID); err != nil {
+            writeTransactionError(w, err, "could not get transaction sources")
+            return
+        }
+    }
+
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
 	defer cancel()
 
-    err := h.transactionService.GetBankTransactions(ctx)
+    full := r.URL.Query().Get("full") == "true"
+    run, err := h.transactionService.ImportFromSource(ctx, userID, sourceID, full)
     if err != nil {
-        http.Error(w, "could not get transactions", http.StatusInternalServerError)
+        writeTransactionError(w, err, "could not get transactions")
         return
     }
 
     response := map[string]interface{}{
         "status":    "Success",
+        "import":    run,
+        "timestamp": time.Now(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// Transactions returns the user's transactions (GET ?from=&to=&limit=)
+func (h *TransactionHandler) Transactions(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    from, to, err := parseDateRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    limit, err := queryInt(r, "limit")
+    if err != nil {
+        http.Error(w, "invalid limit", http.StatusBadRequest)
+        return
+    }
+
+    transactions, err := h.transactionService.GetTransactions(userID, from, to, limit)
+    if err != nil {
+        writeTransactionError(w, err, "could not get transactions")
+        return
+    }
+
+    response := map[string]interface{}{
+        "transactions": transactions,
+        "count":        len(transactions),
+        "timestamp":    time.Now(),
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// Sources lists (GET), registers (POST), updates (PUT ?id=) or deletes
+// (DELETE ?id=) the user's transaction sources
+func (h *TransactionHandler) Sources(w http.ResponseWriter, r *http.Request) {
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    var response map[string]interface{}
+    status := http.StatusOK
+
+    switch r.Method {
+    case http.MethodGet:
+        sources, err := h.transactionService.GetSources(userID)
+        if err != nil {
+            writeTransactionError(w, err, "could not get transaction sources")
+            return
+        }
+        response = map[string]interface{}{
+            "sources": sources,
+            "count":   len(sources),
+        }
+
+    case http.MethodPost, http.MethodPut:
+        var req TransactionSourceRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "Invalid request body", http.StatusBadRequest)
+            return
+        }
+        source := &repository.TransactionSource{
+            Name:          req.Name,
+            Kind:          req.Kind,
+            SpreadsheetID: req.SpreadsheetID,
+            TableID:       req.TableID,
+            Mapping:       req.Mapping,
+        }
+
+        var err error
+        if r.Method == http.MethodPost {
+            err = h.transactionService.CreateSource(userID, source)
+            status = http.StatusCreated
+        } else {
+            source.ID, err = strconv.Atoi(r.URL.Query().Get("id"))
+            if err != nil {
+                http.Error(w, "id is required", http.StatusBadRequest)
+                return
+            }
+            err = h.transactionService.UpdateSource(userID, source)
+        }
+        if err != nil {
+            writeTransactionError(w, err, "could not save transaction source")
+            return
+        }
+        response = map[string]interface{}{
+            "source":  source,
+            "message": "Transaction source saved successfully",
+        }
+
+    case http.MethodDelete:
+        id, err := strconv.Atoi(r.URL.Query().Get("id"))
+        if err != nil {
+            http.Error(w, "id is required", http.StatusBadRequest)
+            return
+        }
+        if err := h.transactionService.DeleteSource(userID, id); err != nil {
+            writeTransactionError(w, err, "could not delete transaction source")
+            return
+        }
+        response = map[string]interface{}{
+            "id":      id,
+            "message": "Transaction source deleted successfully",
+        }
+
+    default:
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    response["timestamp"] = time.Now()
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(response)
+}
+
+// Imports returns the user's recent import runs (GET ?limit=), or one run
+// with its rejected rows (GET ?id=)
+func (h *TransactionHandler) Imports(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    id, err := queryInt(r, "id")
+    if err != nil {
+        http.Error(w, "invalid id", http.StatusBadRequest)
+        return
+    }
+
+    var response map[string]interface{}
+    if id > 0 {
+        run, err := h.transactionService.GetImportRun(userID, id)
+        if err != nil {
+            writeTransactionError(w, err, "could not get import run")
+            return
+        }
+        response = map[string]interface{}{"import": run}
+    } else {
+        limit, err := queryInt(r, "limit")
+        if err != nil {
+            http.Error(w, "invalid limit", http.StatusBadRequest)
+            return
+        }
+        runs, err := h.transactionService.GetImportRuns(userID, limit)
+        if err != nil {
+            writeTransactionError(w, err, "could not get import runs")
+            return
+        }
+        response = map[string]interface{}{
+            "imports": runs,
+            "count":   len(runs),
+        }
+    }
+
+    response["timestamp"] = time.Now()
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// ImportStatement imports a bank statement uploaded as a multipart "file"
+// field. Form fields: format (csv, ofx, qfx or qif; defaults to the file
+// extension), profile_id or profile (CSV layout as JSON), account, bank,
+// currency, date_format (QIF) and dry_run=true to preview the parsed rows
+// and duplicates without storing them.
+func (h *TransactionHandler) ImportStatement(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+    file, header, err := r.FormFile("file")
+    if err != nil {
+        http.Error(w, "file is required", http.StatusBadRequest)
+        return
+    }
+    defer file.Close()
+
+    req := service.StatementImport{
+        FileName:   header.Filename,
+        Format:     r.FormValue("format"),
+        Data:       file,
+        Account:    r.FormValue("account"),
+        Bank:       r.FormValue("bank"),
+        Currency:   r.FormValue("currency"),
+        DateFormat: r.FormValue("date_format"),
+        DryRun:     r.FormValue("dry_run") == "true",
+    }
+    if id := r.FormValue("profile_id"); id != "" {
+        if req.ProfileID, err = strconv.Atoi(id); err != nil {
+            http.Error(w, "invalid profile_id", http.StatusBadRequest)
+            return
+        }
+    }
+    if profile := r.FormValue("profile"); profile != "" {
+        req.Profile = &statements.CSVProfile{}
+        if err := json.Unmarshal([]byte(profile), req.Profile); err != nil {
+            http.Error(w, "invalid profile", http.StatusBadRequest)
+            return
+        }
+    }
+
+    result, err := h.transactionService.ImportStatement(r.Context(), userID, req)
+    if err != nil {
+        writeTransactionError(w, err, "could not import statement")
+        return
+    }
+
+    message := "Statement imported successfully"
+    if result.DryRun {
+        message = "Dry run: nothing was stored"
+    }
+    response := map[string]interface{}{
+        "result":    result,
+        "message":   message,
         "timestamp": time.Now(),
     }
 
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(response)
 }
+
+// Profiles lists (GET), saves (POST), updates (PUT ?id=) or deletes
+// (DELETE ?id=) the user's CSV import profiles
+func (h *TransactionHandler) Profiles(w http.ResponseWriter, r *http.Request) {
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    var response map[string]interface{}
+    status := http.StatusOK
+
+    switch r.Method {
+    case http.MethodGet:
+        profiles, err := h.transactionService.GetImportProfiles(userID)
+        if err != nil {
+            writeTransactionError(w, err, "could not get import profiles")
+            return
+        }
+        response = map[string]interface{}{
+            "profiles": profiles,
+            "count":    len(profiles),
+        }
+
+    case http.MethodPost, http.MethodPut:
+        var req ImportProfileRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "Invalid request body", http.StatusBadRequest)
+            return
+        }
+        profile := &repository.ImportProfile{Name: req.Name, Bank: req.Bank, Profile: req.Profile}
+
+        var err error
+        if r.Method == http.MethodPost {
+            err = h.transactionService.CreateImportProfile(userID, profile)
+            status = http.StatusCreated
+        } else {
+            profile.ID, err = strconv.Atoi(r.URL.Query().Get("id"))
+            if err != nil {
+                http.Error(w, "id is required", http.StatusBadRequest)
+                return
+            }
+            err = h.transactionService.UpdateImportProfile(userID, profile)
+        }
+        if err != nil {
+            writeTransactionError(w, err, "could not save import profile")
+            return
+        }
+        response = map[string]interface{}{
+            "profile": profile,
+            "message": "Import profile saved successfully",
+        }
+
+    case http.MethodDelete:
+        id, err := strconv.Atoi(r.URL.Query().Get("id"))
+        if err != nil {
+            http.Error(w, "id is required", http.StatusBadRequest)
+            return
+        }
+        if err := h.transactionService.DeleteImportProfile(userID, id); err != nil {
+            writeTransactionError(w, err, "could not delete import profile")
+            return
+        }
+        response = map[string]interface{}{
+            "id":      id,
+            "message": "Import profile deleted successfully",
+        }
+
+    default:
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    response["timestamp"] = time.Now()
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(response)
+}
+
+// Rules lists (GET, or one rule with ?id=), creates (POST), updates (PUT ?id=)
+// or deletes (DELETE ?id=) the user's categorisation rules
+func (h *TransactionHandler) Rules(w http.ResponseWriter, r *http.Request) {
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    var response map[string]interface{}
+    status := http.StatusOK
+
+    switch r.Method {
+    case http.MethodGet:
+        id, err := queryInt(r, "id")
+        if err != nil {
+            http.Error(w, "invalid id", http.StatusBadRequest)
+            return
+        }
+        if id > 0 {
+            rule, err := h.transactionService.GetCategoryRule(userID, id)
+            if err != nil {
+                writeTransactionError(w, err, "could not get category rule")
+                return
+            }
+            response = map[string]interface{}{"rule": rule}
+            break
+        }
+        rules, err := h.transactionService.GetCategoryRules(userID)
+        if err != nil {
+            writeTransactionError(w, err, "could not get category rules")
+            return
+        }
+        response = map[string]interface{}{
+            "rules": rules,
+            "count": len(rules),
+        }
+
+    case http.MethodPost, http.MethodPut:
+        var req CategoryRuleRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "Invalid request body", http.StatusBadRequest)
+            return
+        }
+        rule := req.toRule()
+
+        var err error
+        if r.Method == http.MethodPost {
+            err = h.transactionService.CreateCategoryRule(userID, rule)
+            status = http.StatusCreated
+        } else {
+            rule.ID, err = strconv.Atoi(r.URL.Query().Get("id"))
+            if err != nil {
+                http.Error(w, "id is required", http.StatusBadRequest)
+                return
+            }
+            err = h.transactionService.UpdateCategoryRule(userID, rule)
+        }
+        if err != nil {
+            writeTransactionError(w, err, "could not save category rule")
+            return
+        }
+        response = map[string]interface{}{
+            "rule":    rule,
+            "message": "Category rule saved successfully",
+        }
+
+    case http.MethodDelete:
+        id, err := strconv.Atoi(r.URL.Query().Get("id"))
+        if err != nil {
+            http.Error(w, "id is required", http.StatusBadRequest)
+            return
+        }
+        if err := h.transactionService.DeleteCategoryRule(userID, id); err != nil {
+            writeTransactionError(w, err, "could not delete category rule")
+            return
+        }
+        response = map[string]interface{}{
+            "id":      id,
+            "message": "Category rule deleted successfully",
+        }
+
+    default:
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    response["timestamp"] = time.Now()
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(response)
+}
+
+// Recategorize runs the user's current rules over their stored transactions.
+// With "preview": true (or ?preview=true) it only reports the rows whose
+// category would change.
+func (h *TransactionHandler) Recategorize(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    var req RecategorizeRequest
+    if r.ContentLength != 0 {
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "Invalid request body", http.StatusBadRequest)
+            return
+        }
+    }
+    from, to, err := parseDateRange(req.From, req.To)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    result, err := h.transactionService.Recategorize(userID, service.RecategorizeRequest{
+        From:    from,
+        To:      to,
+        Preview: req.Preview || r.URL.Query().Get("preview") == "true",
+    })
+    if err != nil {
+        writeTransactionError(w, err, "could not recategorize transactions")
+        return
+    }
+
+    message := "Transactions recategorized successfully"
+    if result.Preview {
+        message = "Preview: nothing was saved"
+    }
+    response := map[string]interface{}{
+        "result":    result,
+        "message":   message,
+        "timestamp": time.Now(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
+
+// Recurring reports the user's subscriptions and other recurring charges with
+// their next expected date and price changes (GET ?months=24). Overdue series
+// are left out unless ?include_inactive=true, and recurring income unless
+// ?include_credits=true.
+func (h *TransactionHandler) Recurring(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    months, err := queryInt(r, "months")
+    if err != nil {
+        http.Error(w, "invalid months", http.StatusBadRequest)
+        return
+    }
+
+    result, err := h.transactionService.Recurring(userID, service.RecurringQuery{
+        Months:          months,
+        IncludeInactive: r.URL.Query().Get("include_inactive") == "true",
+        IncludeCredits:  r.URL.Query().Get("include_credits") == "true",
+    })
+    if err != nil {
+        writeTransactionError(w, err, "could not detect recurring transactions")
+        return
+    }
+
+    response := map[string]interface{}{
+        "recurring":       result.Recurring,
+        "count":           len(result.Recurring),
+        "monthly_total":   result.MonthlyTotal,
+        "price_increases": result.PriceIncreases,
+        "timestamp":       time.Now(),
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(response)
+}
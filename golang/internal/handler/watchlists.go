@@ -0,0 +1,234 @@
+package handler
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+    "stock-api/internal/repository"
+    "stock-api/internal/service"
+)
+
+// WatchlistHandler manages watchlists for the authenticated user
+type WatchlistHandler struct {
+    watchlistService *service.WatchlistService
+}
+
+// NewWatchlistHandler creates a new watchlist handler
+func NewWatchlistHandler(ws *service.WatchlistService) *WatchlistHandler {
+    return &WatchlistHandler{watchlistService: ws}
+}
+
+type WatchlistRequest struct {
+    Name    string   `json:"name"`
+    Symbols []string `json:"symbols"`
+}
+
+type WatchlistSymbolsRequest struct {
+    // Symbols are added (POST) or give the new order (PUT)
+    Symbols []string `json:"symbols"`
+}
+
+// writeWatchlistError maps watchlist errors to HTTP statuses
+func writeWatchlistError(w http.ResponseWriter, err error, message string) {
+    switch {
+    case errors.Is(err, service.ErrInvalidWatchlist):
+        http.Error(w, err.Error(), http.StatusBadRequest)
+    case errors.Is(err, service.ErrWatchlistNotFound):
+        http.Error(w, err.Error(), http.StatusNotFound)
+    default:
+        http.Error(w, message, http.StatusInternalServerError)
+    }
+}
+
+// Watchlists routes /api/watchlists and /api/watchlists/{id}[/symbols|/summary]
+func (h *WatchlistHandler) Watchlists(w http.ResponseWriter, r *http.Request) {
+    userID, ok := userIDFromRequest(r)
+    if !ok {
+        http.Error(w, "Unauthorized", http.StatusUnauthorized)
+        return
+    }
+
+    path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/watchlists"), "/")
+    if path == "" {
+        h.collection(w, r, userID)
+        return
+    }
+
+    parts := strings.Split(path, "/")
+    id, err := strconv.Atoi(parts[0])
+    if err != nil || len(parts) > 2 {
+        http.NotFound(w, r)
+        return
+    }
+
+    switch {
+    case len(parts) == 1:
+        h.watchlist(w, r, userID, id)
+    case parts[1] == "symbols":
+        h.symbols(w, r, userID, id)
+    case parts[1] == "summary":
+        h.summary(w, r, userID, id)
+    default:
+        http.NotFound(w, r)
+    }
+}
+
+// collection lists (GET) or creates (POST) the user's watchlists
+func (h *WatchlistHandler) collection(w http.ResponseWriter, r *http.Request, userID int) {
+    var response map[string]interface{}
+    status := http.StatusOK
+
+    switch r.Method {
+    case http.MethodGet:
+        watchlists, err := h.watchlistService.GetWatchlists(userID)
+        if err != nil {
+            writeWatchlistError(w, err, "could not get watchlists")
+            return
+        }
+        response = map[string]interface{}{
+            "watchlists": watchlists,
+            "count":      len(watchlists),
+        }
+
+    case http.MethodPost:
+        var req WatchlistRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "Invalid request body", http.StatusBadRequest)
+            return
+        }
+        watchlist := &repository.Watchlist{Name: req.Name, Symbols: req.Symbols}
+        if err := h.watchlistService.CreateWatchlist(userID, watchlist); err != nil {
+            writeWatchlistError(w, err, "could not create watchlist")
+            return
+        }
+        response = map[string]interface{}{
+            "watchlist": watchlist,
+            "message":   "Watchlist created successfully",
+        }
+        status = http.StatusCreated
+
+    default:
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    writeWatchlistResponse(w, status, response)
+}
+
+// watchlist gets (GET), renames (PUT) or deletes (DELETE) one watchlist
+func (h *WatchlistHandler) watchlist(w http.ResponseWriter, r *http.Request, userID, id int) {
+    var response map[string]interface{}
+
+    switch r.Method {
+    case http.MethodGet:
+        watchlist, err := h.watchlistService.GetWatchlist(userID, id)
+        if err != nil {
+            writeWatchlistError(w, err, "could not get watchlist")
+            return
+        }
+        response = map[string]interface{}{"watchlist": watchlist}
+
+    case http.MethodPut:
+        var req WatchlistRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "Invalid request body", http.StatusBadRequest)
+            return
+        }
+        watchlist, err := h.watchlistService.RenameWatchlist(userID, id, req.Name)
+        if err != nil {
+            writeWatchlistError(w, err, "could not rename watchlist")
+            return
+        }
+        response = map[string]interface{}{
+            "watchlist": watchlist,
+            "message":   "Watchlist renamed successfully",
+        }
+
+    case http.MethodDelete:
+        if err := h.watchlistService.DeleteWatchlist(userID, id); err != nil {
+            writeWatchlistError(w, err, "could not delete watchlist")
+            return
+        }
+        response = map[string]interface{}{
+            "id":      id,
+            "message": "Watchlist deleted successfully",
+        }
+
+    default:
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    writeWatchlistResponse(w, http.StatusOK, response)
+}
+
+// symbols adds (POST), reorders (PUT) or removes (DELETE ?symbol=) members
+func (h *WatchlistHandler) symbols(w http.ResponseWriter, r *http.Request, userID, id int) {
+    var watchlist *repository.Watchlist
+    var err error
+
+    switch r.Method {
+    case http.MethodPost, http.MethodPut:
+        var req WatchlistSymbolsRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "Invalid request body", http.StatusBadRequest)
+            return
+        }
+        if r.Method == http.MethodPost {
+            watchlist, err = h.watchlistService.AddSymbols(userID, id, req.Symbols)
+        } else {
+            watchlist, err = h.watchlistService.ReorderSymbols(userID, id, req.Symbols)
+        }
+
+    case http.MethodDelete:
+        symbol := r.URL.Query().Get("symbol")
+        if symbol == "" {
+            http.Error(w, "symbol is required", http.StatusBadRequest)
+            return
+        }
+        watchlist, err = h.watchlistService.RemoveSymbol(userID, id, symbol)
+
+    default:
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    if err != nil {
+        writeWatchlistError(w, err, "could not update watchlist")
+        return
+    }
+    writeWatchlistResponse(w, http.StatusOK, map[string]interface{}{
+        "watchlist": watchlist,
+        "message":   "Watchlist updated successfully",
+    })
+}
+
+// summary returns the latest price, day change, relative volume and composite
+// score of every member
+func (h *WatchlistHandler) summary(w http.ResponseWriter, r *http.Request, userID, id int) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    summary, err := h.watchlistService.Summary(userID, id)
+    if err != nil {
+        writeWatchlistError(w, err, "could not get watchlist summary")
+        return
+    }
+    writeWatchlistResponse(w, http.StatusOK, map[string]interface{}{
+        "watchlist": summary.Watchlist,
+        "items":     summary.Items,
+        "count":     len(summary.Items),
+    })
+}
+
+func writeWatchlistResponse(w http.ResponseWriter, status int, response map[string]interface{}) {
+    response["timestamp"] = time.Now()
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(response)
+}
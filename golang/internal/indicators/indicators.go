@@ -0,0 +1,323 @@
+// Package indicators computes technical indicators over intraday bars.
+//
+// Every function expects bars ordered oldest first and returns one value per
+// bar. Values inside an indicator's warm-up window are NaN. Smoothed
+// indicators (RSI, ATR, EMA, MACD) follow TA-Lib's seeding, so results line up
+// with values previously produced by talib.
+package indicators
+
+import (
+	"math"
+	"time"
+
+	"stock-api/internal/repository"
+)
+
+// Series holds one indicator value per input bar
+type Series []float64
+
+// Valid reports whether the value at i has left the warm-up window
+func (s Series) Valid(i int) bool {
+	return i >= 0 && i < len(s) && !math.IsNaN(s[i])
+}
+
+func newSeries(n int) Series {
+	s := make(Series, n)
+	for i := range s {
+		s[i] = math.NaN()
+	}
+	return s
+}
+
+// Closes returns the close price of each bar
+func Closes(bars []repository.StockIntraDayData) []float64 {
+	closes := make([]float64, len(bars))
+	for i, b := range bars {
+		closes[i] = b.Close
+	}
+	return closes
+}
+
+// Volumes returns the volume of each bar
+func Volumes(bars []repository.StockIntraDayData) []float64 {
+	volumes := make([]float64, len(bars))
+	for i, b := range bars {
+		volumes[i] = b.Volume
+	}
+	return volumes
+}
+
+// SMA is the simple moving average of the last period values
+func SMA(values []float64, period int) Series {
+	out := newSeries(len(values))
+	if period < 1 || len(values) < period {
+		return out
+	}
+
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			out[i] = sum / float64(period)
+		}
+	}
+	return out
+}
+
+// EMA is the exponential moving average with alpha 2/(period+1), seeded with
+// the SMA of the first period values. Leading NaNs in values are skipped, so
+// an EMA can be taken of another indicator's output.
+func EMA(values []float64, period int) Series {
+	out := newSeries(len(values))
+	if period < 1 {
+		return out
+	}
+
+	start := 0
+	for start < len(values) && math.IsNaN(values[start]) {
+		start++
+	}
+	if len(values)-start < period {
+		return out
+	}
+
+	sum := 0.0
+	for i := start; i < start+period; i++ {
+		sum += values[i]
+	}
+	prev := sum / float64(period)
+	out[start+period-1] = prev
+
+	alpha := 2.0 / float64(period+1)
+	for i := start + period; i < len(values); i++ {
+		prev = alpha*values[i] + (1-alpha)*prev
+		out[i] = prev
+	}
+	return out
+}
+
+// RSI is Wilder's relative strength index of the closes
+func RSI(bars []repository.StockIntraDayData, period int) Series {
+	out := newSeries(len(bars))
+	if period < 1 || len(bars) <= period {
+		return out
+	}
+
+	gain, loss := 0.0, 0.0
+	for i := 1; i <= period; i++ {
+		change := bars[i].Close - bars[i-1].Close
+		if change > 0 {
+			gain += change
+		} else {
+			loss -= change
+		}
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	out[period] = rsi(gain, loss)
+
+	for i := period + 1; i < len(bars); i++ {
+		change := bars[i].Close - bars[i-1].Close
+		up, down := 0.0, 0.0
+		if change > 0 {
+			up = change
+		} else {
+			down = -change
+		}
+		gain = (gain*float64(period-1) + up) / float64(period)
+		loss = (loss*float64(period-1) + down) / float64(period)
+		out[i] = rsi(gain, loss)
+	}
+	return out
+}
+
+func rsi(gain, loss float64) float64 {
+	if gain+loss == 0 {
+		return 0
+	}
+	return 100 * gain / (gain + loss)
+}
+
+// ATR is Wilder's average true range. As in TA-Lib, the first bar has no
+// previous close and is only used as the previous close of the second.
+func ATR(bars []repository.StockIntraDayData, period int) Series {
+	out := newSeries(len(bars))
+	if period < 1 || len(bars) <= period {
+		return out
+	}
+
+	trueRange := func(i int) float64 {
+		prevClose := bars[i-1].Close
+		return math.Max(bars[i].High-bars[i].Low,
+			math.Max(math.Abs(bars[i].High-prevClose), math.Abs(bars[i].Low-prevClose)))
+	}
+
+	sum := 0.0
+	for i := 1; i <= period; i++ {
+		sum += trueRange(i)
+	}
+	prev := sum / float64(period)
+	out[period] = prev
+
+	for i := period + 1; i < len(bars); i++ {
+		prev = (prev*float64(period-1) + trueRange(i)) / float64(period)
+		out[i] = prev
+	}
+	return out
+}
+
+// OBV is the on-balance volume, starting from the first bar's volume
+func OBV(bars []repository.StockIntraDayData) Series {
+	out := make(Series, len(bars))
+	if len(bars) == 0 {
+		return out
+	}
+
+	out[0] = bars[0].Volume
+	for i := 1; i < len(bars); i++ {
+		switch {
+		case bars[i].Close > bars[i-1].Close:
+			out[i] = out[i-1] + bars[i].Volume
+		case bars[i].Close < bars[i-1].Close:
+			out[i] = out[i-1] - bars[i].Volume
+		default:
+			out[i] = out[i-1]
+		}
+	}
+	return out
+}
+
+// RVOL is each bar's volume relative to the average volume of the last
+// period bars, the bar itself included
+func RVOL(bars []repository.StockIntraDayData, period int) Series {
+	avg := SMA(Volumes(bars), period)
+	out := newSeries(len(bars))
+	for i, b := range bars {
+		if avg.Valid(i) && avg[i] != 0 {
+			out[i] = b.Volume / avg[i]
+		}
+	}
+	return out
+}
+
+// VolumeSpikes flags bars whose volume is more than factor times the average
+// volume of the last period bars. Bars in the warm-up window are never flagged.
+func VolumeSpikes(bars []repository.StockIntraDayData, period int, factor float64) []bool {
+	avg := SMA(Volumes(bars), period)
+	out := make([]bool, len(bars))
+	for i, b := range bars {
+		out[i] = avg.Valid(i) && b.Volume > factor*avg[i]
+	}
+	return out
+}
+
+// PriceChangePct is the percentage move from each bar's open to its close
+func PriceChangePct(bars []repository.StockIntraDayData) Series {
+	out := newSeries(len(bars))
+	for i, b := range bars {
+		if b.Open != 0 {
+			out[i] = (b.Close - b.Open) / b.Open * 100
+		}
+	}
+	return out
+}
+
+// MACDResult holds the MACD line, its signal line and their difference
+type MACDResult struct {
+	MACD      Series
+	Signal    Series
+	Histogram Series
+}
+
+// MACD is the difference between the fast and slow EMAs of the closes, with
+// an EMA of that difference as the signal line. As in TA-Lib, the fast EMA
+// starts on the slow EMA's first bar, seeded with the average of the fast
+// period's closes up to it, and the periods are swapped if slow < fast.
+func MACD(bars []repository.StockIntraDayData, fast, slow, signal int) MACDResult {
+	if slow < fast {
+		fast, slow = slow, fast
+	}
+	closes := Closes(bars)
+	slowEMA := EMA(closes, slow)
+
+	fastCloses := append([]float64(nil), closes...)
+	for i := 0; i < slow-fast && i < len(fastCloses); i++ {
+		fastCloses[i] = math.NaN()
+	}
+	fastEMA := EMA(fastCloses, fast)
+
+	line := newSeries(len(bars))
+	for i := range bars {
+		if fastEMA.Valid(i) && slowEMA.Valid(i) {
+			line[i] = fastEMA[i] - slowEMA[i]
+		}
+	}
+
+	signalLine := EMA(line, signal)
+	histogram := newSeries(len(bars))
+	for i := range bars {
+		if line.Valid(i) && signalLine.Valid(i) {
+			histogram[i] = line[i] - signalLine[i]
+		}
+	}
+
+	return MACDResult{MACD: line, Signal: signalLine, Histogram: histogram}
+}
+
+// BollingerBands holds the bands around a simple moving average
+type BollingerBands struct {
+	Upper  Series
+	Middle Series
+	Lower  Series
+}
+
+// Bollinger computes bands k population standard deviations above and below
+// the period SMA of the closes
+func Bollinger(bars []repository.StockIntraDayData, period int, k float64) BollingerBands {
+	closes := Closes(bars)
+	middle := SMA(closes, period)
+	upper := newSeries(len(bars))
+	lower := newSeries(len(bars))
+
+	for i := range bars {
+		if !middle.Valid(i) {
+			continue
+		}
+		variance := 0.0
+		for _, c := range closes[i-period+1 : i+1] {
+			variance += (c - middle[i]) * (c - middle[i])
+		}
+		stdDev := math.Sqrt(variance / float64(period))
+		upper[i] = middle[i] + k*stdDev
+		lower[i] = middle[i] - k*stdDev
+	}
+
+	return BollingerBands{Upper: upper, Middle: middle, Lower: lower}
+}
+
+// VWAP is the volume-weighted average of each bar's typical price, reset at
+// the start of every trading day in loc
+func VWAP(bars []repository.StockIntraDayData, loc *time.Location) Series {
+	out := newSeries(len(bars))
+
+	var session string
+	var priceVolume, volume float64
+	for i, b := range bars {
+		day := b.Date.In(loc).Format("2006-01-02")
+		if day != session {
+			session = day
+			priceVolume, volume = 0, 0
+		}
+
+		typical := (b.High + b.Low + b.Close) / 3
+		priceVolume += typical * b.Volume
+		volume += b.Volume
+		if volume != 0 {
+			out[i] = priceVolume / volume
+		}
+	}
+	return out
+}
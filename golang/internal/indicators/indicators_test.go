@@ -0,0 +1,180 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"stock-api/internal/repository"
+)
+
+// The expected values below are TA-Lib 0.4's (SMA, EMA, RSI, ATR, OBV, MACD
+// and BBANDS with default settings) for these bars, worked through with a
+// line-by-line port of its C routines rather than with this package. TA-Lib
+// has no VWAP or relative volume, so those use its SMA and plain sums.
+
+// ohlcv is open, high, low, close and volume. Bars 7 and 21 repeat the
+// previous close, and the second half is a new session for VWAP.
+var ohlcv = [][5]float64{
+	{100.05, 100.32, 99.66, 100.09, 4805},
+	{99.81, 100.21, 99.33, 99.64, 7434},
+	{99.45, 99.76, 99.33, 99.48, 9276},
+	{99.25, 99.73, 99.16, 99.57, 7039},
+	{99.82, 100.03, 98.66, 99.08, 4686},
+	{98.8, 99.33, 98.73, 98.93, 9126},
+	{98.92, 99.27, 98.4, 98.82, 8012},
+	{98.89, 99.21, 98.36, 98.82, 3220},
+	{98.57, 99.65, 98.52, 99.5, 9432},
+	{99.41, 100.19, 99.28, 100.06, 2228},
+	{99.83, 100.78, 99.61, 100.65, 5891},
+	{100.58, 100.67, 100.13, 100.24, 4136},
+	{100.3, 100.56, 99.78, 100.24, 1881},
+	{99.98, 100.8, 99.51, 100.54, 5194},
+	{100.25, 100.26, 100.05, 100.19, 4098},
+	{100.45, 101.16, 100.1, 100.96, 3627},
+	{100.76, 101.37, 100.49, 100.93, 1051},
+	{100.72, 101.17, 99.91, 99.96, 2490},
+	{100.04, 100.43, 98.64, 99.09, 1062},
+	{98.88, 98.95, 98.26, 98.74, 6054},
+	{99.02, 99.77, 98.7, 99.5, 5389},
+	{99.45, 99.97, 99.08, 99.5, 8204},
+	{99.43, 99.78, 99.43, 99.43, 9131},
+	{99.72, 100.91, 99.37, 100.57, 6288},
+	{100.82, 101.54, 100.56, 101.11, 3213},
+	{100.99, 102.48, 100.7, 101.98, 7918},
+	{102.14, 102.37, 102.04, 102.06, 7805},
+	{102.35, 103.46, 102.03, 102.99, 5906},
+	{102.88, 102.94, 102.47, 102.61, 3950},
+	{102.65, 103.08, 102.4, 102.96, 8075},
+	{102.85, 103.44, 102.78, 103.33, 9884},
+	{103.08, 103.14, 102.68, 102.68, 4533},
+	{102.79, 103.26, 102.53, 102.95, 3268},
+	{102.81, 103.24, 102.44, 102.72, 3560},
+	{103.02, 104.05, 102.91, 103.72, 7351},
+	{103.49, 104.18, 103.22, 104.03, 8831},
+	{104.31, 104.63, 103.94, 104.39, 6683},
+	{104.68, 104.98, 103.25, 103.73, 2699},
+	{103.44, 103.45, 102.7, 103.2, 9356},
+	{103.26, 104.47, 102.83, 104.11, 7075},
+}
+
+// session is a fixed zone so the tests don't need tzdata
+var session = time.FixedZone("EDT", -4*60*60)
+
+func testBars() []repository.StockIntraDayData {
+	bars := make([]repository.StockIntraDayData, len(ohlcv))
+	for i, b := range ohlcv {
+		day, slot := 7, i
+		if i >= len(ohlcv)/2 {
+			day, slot = 8, i-len(ohlcv)/2
+		}
+		bars[i] = repository.StockIntraDayData{
+			Symbol: "TEST",
+			Date:   time.Date(2025, 7, day, 9, 30+5*slot, 0, 0, session),
+			Open:   b[0],
+			High:   b[1],
+			Low:    b[2],
+			Close:  b[3],
+			Volume: b[4],
+		}
+	}
+	return bars
+}
+
+func TestIndicatorsMatchTALib(t *testing.T) {
+	bars := testBars()
+	closes := Closes(bars)
+	macd := MACD(bars, 12, 26, 9)
+	swapped := MACD(bars, 26, 12, 9)
+	short := MACD(bars, 5, 10, 4)
+	bands := Bollinger(bars, 20, 2)
+
+	tests := []struct {
+		name string
+		got  Series
+		// warmUp is the number of leading NaNs
+		warmUp int
+		want   map[int]float64
+	}{
+		{"SMA(5)", SMA(closes, 5), 4,
+			map[int]float64{4: 99.572, 5: 99.34, 21: 99.358, 30: 102.79, 39: 103.892}},
+		{"EMA(5)", EMA(closes, 5), 4,
+			map[int]float64{4: 99.572, 5: 99.358, 21: 99.52473963, 30: 102.69613152, 39: 103.73806479}},
+		{"RSI(14)", RSI(bars, 14), 14,
+			map[int]float64{14: 51.15207373, 15: 58.98809524, 21: 45.64202338, 30: 69.84848066, 39: 64.10033431}},
+		{"ATR(14)", ATR(bars, 14), 14,
+			map[int]float64{14: 0.84857143, 15: 0.86367347, 21: 0.9519932, 30: 0.92732905, 39: 1.01732824}},
+		{"OBV", OBV(bars), 0,
+			map[int]float64{0: 4805, 1: -2629, 21: -13820, 30: 22188, 39: 35248}},
+		{"RVOL(10)", RVOL(bars, 10), 9,
+			map[int]float64{9: 0.34141408, 10: 0.88794767, 21: 2.10089629, 30: 1.40449598, 39: 1.11875395}},
+		// TA-Lib only reports the MACD line once the signal line starts
+		{"MACD(12,26,9) line", macd.MACD, 25,
+			map[int]float64{33: 0.88412736, 34: 0.9458056, 39: 1.00681269}},
+		{"MACD(12,26,9) signal", macd.Signal, 33,
+			map[int]float64{33: 0.68075001, 34: 0.73376113, 39: 0.92957117}},
+		{"MACD(12,26,9) histogram", macd.Histogram, 33,
+			map[int]float64{33: 0.20337735, 34: 0.21204447, 39: 0.07724152}},
+		{"MACD(26,12,9) line", swapped.MACD, 25,
+			map[int]float64{33: 0.88412736, 34: 0.9458056, 39: 1.00681269}},
+		{"MACD(5,10,4) line", short.MACD, 9,
+			map[int]float64{12: 0.17101681, 13: 0.22169613, 21: -0.17927612, 30: 0.71883115, 39: 0.3445322}},
+		{"MACD(5,10,4) signal", short.Signal, 12,
+			map[int]float64{12: 0.05366628, 13: 0.12087822, 21: -0.14036884, 30: 0.66121201, 39: 0.40273409}},
+		{"MACD(5,10,4) histogram", short.Histogram, 12,
+			map[int]float64{12: 0.11735053, 13: 0.10081791, 21: -0.03890728, 30: 0.05761914, 39: -0.05820189}},
+		{"Bollinger(20,2) upper", bands.Upper, 19,
+			map[int]float64{19: 101.16882575, 20: 101.13650495, 21: 101.13299677, 30: 103.54702051, 39: 105.43408685}},
+		{"Bollinger(20,2) middle", bands.Middle, 19,
+			map[int]float64{19: 99.7765, 20: 99.747, 21: 99.74, 30: 100.8465, 39: 102.3785}},
+		{"Bollinger(20,2) lower", bands.Lower, 19,
+			map[int]float64{19: 98.38417425, 20: 98.35749505, 21: 98.34700323, 30: 98.14597949, 39: 99.32291315}},
+		{"VWAP", VWAP(bars, session), 0,
+			map[int]float64{0: 100.02333333, 1: 99.84313724, 21: 99.44001888, 30: 101.39059616, 39: 102.27079183}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.got) != len(bars) {
+				t.Fatalf("got %d values, want %d", len(tt.got), len(bars))
+			}
+			for i := 0; i < tt.warmUp; i++ {
+				if tt.got.Valid(i) {
+					t.Errorf("value %d = %v inside the warm-up window", i, tt.got[i])
+				}
+			}
+			if !tt.got.Valid(tt.warmUp) {
+				t.Errorf("value %d is NaN after the warm-up window", tt.warmUp)
+			}
+			for i, want := range tt.want {
+				if math.Abs(tt.got[i]-want) > 1e-6 {
+					t.Errorf("value %d = %.8f, want %.8f", i, tt.got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestShortInputIsAllNaN(t *testing.T) {
+	bars := testBars()[:5]
+	tests := []struct {
+		name string
+		got  Series
+	}{
+		{"SMA", SMA(Closes(bars), 6)},
+		{"EMA", EMA(Closes(bars), 6)},
+		{"RSI", RSI(bars, 5)},
+		{"ATR", ATR(bars, 5)},
+		{"RVOL", RVOL(bars, 6)},
+		{"MACD", MACD(bars, 2, 5, 2).Signal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range tt.got {
+				if tt.got.Valid(i) {
+					t.Errorf("value %d = %v, want NaN", i, tt.got[i])
+				}
+			}
+		})
+	}
+}
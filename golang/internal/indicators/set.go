@@ -0,0 +1,92 @@
+package indicators
+
+import (
+	"time"
+
+	"stock-api/internal/repository"
+)
+
+// Params configures the periods used by Compute
+type Params struct {
+	RSIPeriod       int
+	ATRPeriod       int
+	VolumePeriod    int
+	SpikeFactor     float64
+	SMAPeriod       int
+	EMAPeriod       int
+	MACDFast        int
+	MACDSlow        int
+	MACDSignal      int
+	BollingerPeriod int
+	BollingerK      float64
+	// Session is the time zone whose calendar days reset VWAP
+	Session *time.Location
+}
+
+// DefaultParams matches the periods the intraday pipeline has always used:
+// RSI and ATR over one trading day of 5-minute bars (78) and relative volume
+// against the last 50 bars
+func DefaultParams() Params {
+	session, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		session = time.UTC
+	}
+	return Params{
+		RSIPeriod:       78,
+		ATRPeriod:       78,
+		VolumePeriod:    50,
+		SpikeFactor:     2,
+		SMAPeriod:       20,
+		EMAPeriod:       20,
+		MACDFast:        12,
+		MACDSlow:        26,
+		MACDSignal:      9,
+		BollingerPeriod: 20,
+		BollingerK:      2,
+		Session:         session,
+	}
+}
+
+// WarmUp is the number of bars needed before every indicator has a value
+func (p Params) WarmUp() int {
+	warmUp := p.MACDSlow + p.MACDSignal
+	for _, n := range []int{p.RSIPeriod + 1, p.ATRPeriod + 1, p.VolumePeriod, p.SMAPeriod, p.EMAPeriod, p.BollingerPeriod} {
+		if n > warmUp {
+			warmUp = n
+		}
+	}
+	return warmUp
+}
+
+// Set holds every indicator for a run of bars, aligned with the input
+type Set struct {
+	RSI            Series
+	ATR            Series
+	OBV            Series
+	RVOL           Series
+	VolumeSpike    []bool
+	PriceChangePct Series
+	SMA            Series
+	EMA            Series
+	MACD           MACDResult
+	Bollinger      BollingerBands
+	VWAP           Series
+}
+
+// Compute calculates every indicator over bars, which must be oldest first
+func Compute(bars []repository.StockIntraDayData, p Params) *Set {
+	closes := Closes(bars)
+	return &Set{
+		RSI:            RSI(bars, p.RSIPeriod),
+		ATR:            ATR(bars, p.ATRPeriod),
+		OBV:            OBV(bars),
+		RVOL:           RVOL(bars, p.VolumePeriod),
+		VolumeSpike:    VolumeSpikes(bars, p.VolumePeriod, p.SpikeFactor),
+		PriceChangePct: PriceChangePct(bars),
+		SMA:            SMA(closes, p.SMAPeriod),
+		EMA:            EMA(closes, p.EMAPeriod),
+		MACD:           MACD(bars, p.MACDFast, p.MACDSlow, p.MACDSignal),
+		Bollinger:      Bollinger(bars, p.BollingerPeriod, p.BollingerK),
+		VWAP:           VWAP(bars, p.Session),
+	}
+}
@@ -0,0 +1,290 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration is one numbered schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// Migrator applies migrations and records them in schema_migrations
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+?)(\.down)?\.sql$`)
+
+// Load reads NNN_name.sql (up) and NNN_name.down.sql (down) files from fsys
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] != "" {
+			m.Down = string(content)
+		} else {
+			m.Up = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %03d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// New creates a migrator for the migrations in fsys
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func (m *Migrator) ensureTable() error {
+	_, err := m.db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// Status lists every known migration and when it was applied
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		status := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			at := at
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied yet
+func (m *Migrator) Pending() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration, each in its own transaction. Another
+// process may have applied some by the time the lock is held, so each one is
+// checked again under the lock and skipped if it's already recorded.
+func (m *Migrator) Up() (int, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, mig := range pending {
+		err := m.inTx(func(tx *sql.Tx) error {
+			done, err := isApplied(tx, mig.Version)
+			if err != nil || done {
+				return err
+			}
+
+			log.Printf("Applying migration %03d_%s", mig.Version, mig.Name)
+			if _, err := tx.Exec(mig.Up); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mig.Version, mig.Name); err != nil {
+				return err
+			}
+			applied++
+			return nil
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %03d_%s failed: %w", mig.Version, mig.Name, err)
+		}
+	}
+	return applied, nil
+}
+
+// Down reverts the most recently applied migrations, up to steps of them
+func (m *Migrator) Down(steps int) (int, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if strings.TrimSpace(mig.Down) == "" {
+			return reverted, fmt.Errorf("migration %03d_%s has no down script", mig.Version, mig.Name)
+		}
+
+		err := m.inTx(func(tx *sql.Tx) error {
+			// Skip migrations another process reverted while we waited for the lock
+			done, err := isApplied(tx, mig.Version)
+			if err != nil || !done {
+				return err
+			}
+
+			log.Printf("Reverting migration %03d_%s", mig.Version, mig.Name)
+			if _, err := tx.Exec(mig.Down); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, mig.Version); err != nil {
+				return err
+			}
+			reverted++
+			return nil
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("reverting %03d_%s failed: %w", mig.Version, mig.Name, err)
+		}
+	}
+	return reverted, nil
+}
+
+// isApplied reports whether schema_migrations records version, read inside
+// the migration lock
+func isApplied(tx *sql.Tx, version int) (bool, error) {
+	var applied bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&applied)
+	if err != nil {
+		return false, fmt.Errorf("failed to check schema_migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// Baseline marks every migration up to and including version as applied without
+// running it, for databases whose schema was created by hand
+func (m *Migrator) Baseline(version int) (int, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return 0, err
+	}
+
+	marked := 0
+	for _, mig := range pending {
+		if mig.Version > version {
+			break
+		}
+		_, err := m.db.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mig.Version, mig.Name)
+		if err != nil {
+			return marked, fmt.Errorf("failed to mark %03d_%s as applied: %w", mig.Version, mig.Name, err)
+		}
+		marked++
+	}
+	return marked, nil
+}
+
+// inTx runs fn in a transaction holding an advisory lock, so two processes
+// can't migrate the same database at once
+func (m *Migrator) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('schema_migrations'))`); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,102 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"stock-api/migrations"
+)
+
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"002_add_index.sql":          file("CREATE INDEX i ON t (a);"),
+		"001_create_table.sql":       file("CREATE TABLE t (a INT);"),
+		"001_create_table.down.sql":  file("DROP TABLE t;"),
+		"010_seed.sql":               file("INSERT INTO t VALUES (1);"),
+		"README.md":                  file("not a migration"),
+		"notes/003_ignored.sql":      file("SELECT 1;"),
+		"004_missing_number.sql.bak": file("SELECT 1;"),
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []Migration{
+		{Version: 1, Name: "create_table", Up: "CREATE TABLE t (a INT);", Down: "DROP TABLE t;"},
+		{Version: 2, Name: "add_index", Up: "CREATE INDEX i ON t (a);"},
+		{Version: 10, Name: "seed", Up: "INSERT INTO t VALUES (1);"},
+	}
+	if len(migrations) != len(want) {
+		t.Fatalf("got %d migrations, want %d: %+v", len(migrations), len(want), migrations)
+	}
+	for i := range want {
+		if migrations[i] != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, migrations[i], want[i])
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "duplicate version with different names",
+			fsys: fstest.MapFS{
+				"001_create_table.sql": file("CREATE TABLE t (a INT);"),
+				"001_create_other.sql": file("CREATE TABLE u (a INT);"),
+			},
+		},
+		{
+			name: "down script named differently from its up script",
+			fsys: fstest.MapFS{
+				"001_create_table.sql":    file("CREATE TABLE t (a INT);"),
+				"001_drop_table.down.sql": file("DROP TABLE t;"),
+			},
+		},
+		{
+			name: "down script without an up script",
+			fsys: fstest.MapFS{
+				"001_create_table.sql":   file("CREATE TABLE t (a INT);"),
+				"002_add_index.down.sql": file("DROP INDEX i;"),
+			},
+		},
+		{
+			name: "empty up script",
+			fsys: fstest.MapFS{
+				"001_create_table.sql": file("  \n"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if migrations, err := Load(tt.fsys); err == nil {
+				t.Errorf("Load succeeded with %+v, want error", migrations)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("embedded migrations don't load: %v", err)
+	}
+	for i, m := range loaded {
+		if m.Version != i+1 {
+			t.Errorf("migration %03d_%s is out of sequence, want version %d", m.Version, m.Name, i+1)
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %03d_%s has no down script", m.Version, m.Name)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// Package performance measures portfolio returns from daily valuations and
+// external cash flows.
+package performance
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNoSolution is returned when XIRR has no rate that zeroes the flows' NPV
+var ErrNoSolution = errors.New("no rate of return solves the cash flows")
+
+// Flow is money moving into (positive) or out of (negative) the portfolio
+type Flow struct {
+	Date   time.Time `json:"date"`
+	Amount float64   `json:"amount"`
+}
+
+// Day is a portfolio's end-of-day market value and the net external flow
+// received that day. Flows are assumed to arrive before the close, so Value
+// already includes whatever they bought.
+type Day struct {
+	Date  time.Time
+	Value float64
+	Flow  float64
+}
+
+// DailyReturns returns each day's flow-adjusted return. The first day, and
+// any day following a zero valuation, has a return of 0.
+func DailyReturns(days []Day) []float64 {
+	returns := make([]float64, len(days))
+	for i := 1; i < len(days); i++ {
+		if prev := days[i-1].Value; prev > 0 {
+			returns[i] = (days[i].Value-days[i].Flow)/prev - 1
+		}
+	}
+	return returns
+}
+
+// GrowthIndex compounds returns into an index starting at 1
+func GrowthIndex(returns []float64) []float64 {
+	index := make([]float64, len(returns))
+	level := 1.0
+	for i, r := range returns {
+		level *= 1 + r
+		index[i] = level
+	}
+	return index
+}
+
+// Annualise converts a cumulative return over the given number of calendar
+// days to a yearly rate. Periods shorter than a year aren't annualised, since
+// doing so exaggerates short-term moves.
+func Annualise(cumulative float64, days float64) (float64, bool) {
+	if days < 365 || cumulative <= -1 {
+		return 0, false
+	}
+	return math.Pow(1+cumulative, 365/days) - 1, true
+}
+
+// XIRR returns the annual rate at which the flows' net present value is zero.
+// Flows are from the investor's side: contributions negative, withdrawals and
+// the closing value positive.
+func XIRR(flows []Flow) (float64, error) {
+	if len(flows) < 2 {
+		return 0, fmt.Errorf("%w: need at least two flows", ErrNoSolution)
+	}
+	ordered := make([]Flow, len(flows))
+	copy(ordered, flows)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Date.Before(ordered[j].Date) })
+
+	first := ordered[0].Date
+	npv := func(rate float64) float64 {
+		total := 0.0
+		for _, f := range ordered {
+			years := f.Date.Sub(first).Hours() / 24 / 365
+			total += f.Amount / math.Pow(1+rate, years)
+		}
+		return total
+	}
+
+	// NPV falls as the rate rises when money goes in before it comes out, so
+	// bracket a sign change and bisect
+	lo, hi := -0.999999, 1.0
+	fLo, fHi := npv(lo), npv(hi)
+	for fLo*fHi > 0 && hi < 1e6 {
+		hi *= 10
+		fHi = npv(hi)
+	}
+	if fLo*fHi > 0 || math.IsNaN(fLo) || math.IsNaN(fHi) {
+		return 0, ErrNoSolution
+	}
+
+	for i := 0; i < 200; i++ {
+		mid := (lo + hi) / 2
+		fMid := npv(mid)
+		if math.Abs(fMid) < 1e-9 || hi-lo < 1e-12 {
+			return mid, nil
+		}
+		if fLo*fMid < 0 {
+			hi = mid
+		} else {
+			lo, fLo = mid, fMid
+		}
+	}
+	return (lo + hi) / 2, nil
+}
+
+// DefaultWindows are measured when the caller doesn't choose any
+var DefaultWindows = []string{"1m", "3m", "6m", "ytd", "1y", "3y", "5y", "all"}
+
+// WindowStart returns the valuation date a window is measured from, or the
+// zero time for "all"
+func WindowStart(window string, end time.Time) (time.Time, error) {
+	switch strings.ToLower(window) {
+	case "1m":
+		return end.AddDate(0, -1, 0), nil
+	case "3m":
+		return end.AddDate(0, -3, 0), nil
+	case "6m":
+		return end.AddDate(0, -6, 0), nil
+	case "ytd":
+		return time.Date(end.Year()-1, 12, 31, 23, 59, 59, 0, end.Location()), nil
+	case "1y":
+		return end.AddDate(-1, 0, 0), nil
+	case "3y":
+		return end.AddDate(-3, 0, 0), nil
+	case "5y":
+		return end.AddDate(-5, 0, 0), nil
+	case "all":
+		return time.Time{}, nil
+	}
+	return time.Time{}, fmt.Errorf("unknown window %q (use 1m, 3m, 6m, ytd, 1y, 3y, 5y or all)", window)
+}
+
+// WindowReturn summarises performance over one window. Benchmark fields are
+// nil without benchmark prices; annualised fields are nil for windows shorter
+// than a year.
+type WindowReturn struct {
+	Window     string    `json:"window"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	StartValue float64   `json:"start_value"`
+	EndValue   float64   `json:"end_value"`
+	NetFlows   float64   `json:"net_flows"`
+	// TimeWeighted ignores the timing of flows; MoneyWeighted (XIRR) doesn't
+	TimeWeighted           float64  `json:"time_weighted_return"`
+	TimeWeightedAnnualised *float64 `json:"time_weighted_annualised"`
+	MoneyWeighted          *float64 `json:"money_weighted_return"`
+	Benchmark              *float64 `json:"benchmark_return"`
+	BenchmarkAnnualised    *float64 `json:"benchmark_annualised"`
+	Excess                 *float64 `json:"excess_return"`
+}
+
+// Measure summarises days over a window. benchmark holds the benchmark's
+// close on each day (NaN where unknown) and may be nil. The window is
+// measured from the last day on or before its start; ok is false when the
+// history doesn't reach back that far.
+func Measure(days []Day, benchmark []float64, window string) (result WindowReturn, ok bool, err error) {
+	if len(days) == 0 {
+		return result, false, nil
+	}
+	last := len(days) - 1
+	end := days[last].Date
+
+	start, err := WindowStart(window, end)
+	if err != nil {
+		return result, false, err
+	}
+
+	// base is the day the window is measured from; -1 means before the first
+	// valuation, when the portfolio was worth nothing
+	base := -1
+	if !start.IsZero() {
+		base = sort.Search(len(days), func(i int) bool { return days[i].Date.After(start) }) - 1
+		if base < 0 {
+			return result, false, nil
+		}
+	}
+
+	returns := DailyReturns(days)
+	growth := 1.0
+	for i := base + 1; i <= last; i++ {
+		if i > 0 {
+			growth *= 1 + returns[i]
+		}
+	}
+
+	result = WindowReturn{
+		Window:       strings.ToLower(window),
+		EndValue:     days[last].Value,
+		End:          end,
+		TimeWeighted: growth - 1,
+	}
+	first := base
+	if base < 0 {
+		first = 0
+	}
+	result.Start = days[first].Date
+	if base >= 0 {
+		result.StartValue = days[base].Value
+	} else {
+		// Whatever the first valuation holds beyond that day's flows was
+		// already invested when the history starts
+		result.StartValue = days[0].Value - days[0].Flow
+	}
+
+	span := end.Sub(result.Start).Hours() / 24
+	if v, ok := Annualise(result.TimeWeighted, span); ok {
+		result.TimeWeightedAnnualised = &v
+	}
+
+	// Money-weighted: the opening value and every flow are contributions,
+	// the closing value is what the investor walks away with
+	var flows []Flow
+	if result.StartValue != 0 {
+		flows = append(flows, Flow{Date: result.Start, Amount: -result.StartValue})
+	}
+	for i := base + 1; i <= last; i++ {
+		result.NetFlows += days[i].Flow
+		if days[i].Flow != 0 {
+			flows = append(flows, Flow{Date: days[i].Date, Amount: -days[i].Flow})
+		}
+	}
+	flows = append(flows, Flow{Date: end, Amount: days[last].Value})
+	if rate, err := XIRR(flows); err == nil {
+		result.MoneyWeighted = &rate
+	}
+
+	if benchmark != nil && !math.IsNaN(benchmark[first]) && !math.IsNaN(benchmark[last]) && benchmark[first] > 0 {
+		b := benchmark[last]/benchmark[first] - 1
+		result.Benchmark = &b
+		excess := result.TimeWeighted - b
+		result.Excess = &excess
+		if v, ok := Annualise(b, span); ok {
+			result.BenchmarkAnnualised = &v
+		}
+	}
+
+	return result, true, nil
+}
@@ -0,0 +1,169 @@
+package performance
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) < tolerance
+}
+
+func TestDailyReturnsAdjustForFlows(t *testing.T) {
+	days := []Day{
+		{Date: day("2024-01-02"), Value: 1000},
+		// A 500 deposit with no market move
+		{Date: day("2024-01-03"), Value: 1500, Flow: 500},
+		{Date: day("2024-01-04"), Value: 1650},
+		// A withdrawal of everything
+		{Date: day("2024-01-05"), Value: 0, Flow: -1650},
+		{Date: day("2024-01-08"), Value: 100, Flow: 100},
+	}
+	want := []float64{0, 0, 0.1, 0, 0}
+
+	got := DailyReturns(days)
+	for i := range want {
+		if !near(got[i], want[i], 1e-12) {
+			t.Errorf("return %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	index := GrowthIndex(got)
+	if !near(index[len(index)-1], 1.1, 1e-12) {
+		t.Errorf("growth index ends at %v, want 1.1", index[len(index)-1])
+	}
+}
+
+func TestXIRR(t *testing.T) {
+	tests := []struct {
+		name  string
+		flows []Flow
+		want  float64
+	}{
+		{"one year", []Flow{{day("2023-01-01"), -1000}, {day("2024-01-01"), 1100}}, 0.1},
+		// The example from Excel's XIRR documentation
+		{"irregular flows", []Flow{
+			{day("2008-01-01"), -10000},
+			{day("2008-03-01"), 2750},
+			{day("2008-10-30"), 4250},
+			{day("2009-02-15"), 3250},
+			{day("2009-04-01"), 2750},
+		}, 0.373362535},
+		{"loss", []Flow{{day("2023-01-01"), -1000}, {day("2024-01-01"), 800}}, -0.2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := XIRR(tt.flows)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !near(got, tt.want, 1e-6) {
+				t.Errorf("XIRR = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := XIRR([]Flow{{day("2023-01-01"), 1000}, {day("2024-01-01"), 1100}}); !errors.Is(err, ErrNoSolution) {
+		t.Errorf("flows that only pay out: error = %v, want %v", err, ErrNoSolution)
+	}
+}
+
+func TestAnnualise(t *testing.T) {
+	if _, ok := Annualise(0.05, 180); ok {
+		t.Error("a half-year return was annualised")
+	}
+	got, ok := Annualise(0.21, 730)
+	if !ok || !near(got, 0.1, 1e-12) {
+		t.Errorf("Annualise(0.21, 730) = %v, %v, want 0.1, true", got, ok)
+	}
+}
+
+func TestWindowStart(t *testing.T) {
+	end := day("2024-05-31")
+	tests := []struct {
+		window string
+		want   time.Time
+	}{
+		{"1m", day("2024-05-01")},
+		{"1Y", day("2023-05-31")},
+		{"ytd", time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC)},
+		{"all", time.Time{}},
+	}
+	for _, tt := range tests {
+		got, err := WindowStart(tt.window, end)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("WindowStart(%q) = %v, %v, want %v", tt.window, got, err, tt.want)
+		}
+	}
+	if _, err := WindowStart("2w", end); err == nil {
+		t.Error("WindowStart accepted an unknown window")
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	days := []Day{
+		{Date: day("2023-01-02"), Value: 1000, Flow: 1000},
+		{Date: day("2023-07-03"), Value: 1100},
+		{Date: day("2023-07-05"), Value: 2100, Flow: 1000},
+		{Date: day("2024-01-03"), Value: 2310},
+	}
+	benchmark := []float64{100, math.NaN(), 104, 108}
+
+	all, ok, err := Measure(days, benchmark, "all")
+	if err != nil || !ok {
+		t.Fatalf("Measure(all) = %v, %v", ok, err)
+	}
+	// 10% then 10% again, whatever the deposits
+	if !near(all.TimeWeighted, 0.21, 1e-12) {
+		t.Errorf("time-weighted return = %v, want 0.21", all.TimeWeighted)
+	}
+	if all.StartValue != 0 || all.NetFlows != 2000 || all.EndValue != 2310 {
+		t.Errorf("all = %+v, want start 0, flows 2000 and end 2310", all)
+	}
+	if all.TimeWeightedAnnualised == nil || !near(*all.TimeWeightedAnnualised, 0.21, 0.001) {
+		t.Errorf("annualised = %v, want about 0.21 over a year", all.TimeWeightedAnnualised)
+	}
+	// Both halves returned 10%, so the deposits' timing barely matters
+	if all.MoneyWeighted == nil || !near(*all.MoneyWeighted, 0.21, 0.005) {
+		t.Errorf("money-weighted = %v, want about 0.21", all.MoneyWeighted)
+	}
+	if all.Benchmark == nil || !near(*all.Benchmark, 0.08, 1e-12) || !near(*all.Excess, 0.13, 1e-12) {
+		t.Errorf("benchmark = %v, excess = %v, want 0.08 and 0.13", all.Benchmark, all.Excess)
+	}
+
+	sixMonths, ok, err := Measure(days, nil, "6m")
+	if err != nil || !ok {
+		t.Fatalf("Measure(6m) = %v, %v", ok, err)
+	}
+	// Six months before 2024-01-03 is the 2023-07-03 valuation
+	if !sixMonths.Start.Equal(day("2023-07-03")) || sixMonths.StartValue != 1100 || !near(sixMonths.TimeWeighted, 0.1, 1e-12) {
+		t.Errorf("6m = %+v, want a 10%% return from 1100 on 2023-07-03", sixMonths)
+	}
+	if sixMonths.TimeWeightedAnnualised != nil || sixMonths.Benchmark != nil {
+		t.Errorf("6m = %+v, want no annualised or benchmark return", sixMonths)
+	}
+
+	if _, ok, err := Measure(days, nil, "3y"); err != nil || ok {
+		t.Errorf("Measure(3y) = %v, %v, want not ok for a one-year history", ok, err)
+	}
+}
+
+func TestAlignCarriesPricesForward(t *testing.T) {
+	closes := []Close{{day("2024-01-03"), 10}, {day("2024-01-05"), 12}}
+	dates := []time.Time{day("2024-01-02"), day("2024-01-03"), day("2024-01-04"), day("2024-01-08")}
+
+	got := Align(closes, dates)
+	if !math.IsNaN(got[0]) || got[1] != 10 || got[2] != 10 || got[3] != 12 {
+		t.Errorf("Align = %v, want [NaN 10 10 12]", got)
+	}
+}
@@ -0,0 +1,69 @@
+package performance
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"stock-api/internal/repository"
+)
+
+// Close is a symbol's last price on one session day
+type Close struct {
+	Date  time.Time
+	Price float64
+}
+
+// DailyCloses collapses bars in any order to the last close of each calendar
+// day in loc, oldest first. Dates are midnight in loc.
+func DailyCloses(bars []repository.StockIntraDayData, loc *time.Location) []Close {
+	latest := make(map[time.Time]repository.StockIntraDayData)
+	for _, bar := range bars {
+		t := bar.Date.In(loc)
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		if prev, ok := latest[day]; !ok || bar.Date.After(prev.Date) {
+			latest[day] = bar
+		}
+	}
+
+	closes := make([]Close, 0, len(latest))
+	for day, bar := range latest {
+		closes = append(closes, Close{Date: day, Price: bar.Close})
+	}
+	sort.Slice(closes, func(i, j int) bool { return closes[i].Date.Before(closes[j].Date) })
+	return closes
+}
+
+// BucketCloses collapses bars in any order to the last close in each
+// interval of the given size, keyed by the interval's start
+func BucketCloses(bars []repository.StockIntraDayData, size time.Duration) map[time.Time]float64 {
+	latest := make(map[time.Time]time.Time)
+	closes := make(map[time.Time]float64)
+	for _, bar := range bars {
+		bucket := bar.Date.Truncate(size)
+		if prev, ok := latest[bucket]; !ok || bar.Date.After(prev) {
+			latest[bucket] = bar.Date
+			closes[bucket] = bar.Close
+		}
+	}
+	return closes
+}
+
+// Align returns the close on or before each of dates, carrying the last
+// price forward over gaps, and NaN before the first close. dates must be
+// sorted oldest first.
+func Align(closes []Close, dates []time.Time) []float64 {
+	aligned := make([]float64, len(dates))
+	j := -1
+	for i, date := range dates {
+		for j+1 < len(closes) && !closes[j+1].Date.After(date) {
+			j++
+		}
+		if j < 0 {
+			aligned[i] = math.NaN()
+		} else {
+			aligned[i] = closes[j].Price
+		}
+	}
+	return aligned
+}
@@ -0,0 +1,45 @@
+package performance
+
+import (
+	"testing"
+	"time"
+
+	"stock-api/internal/repository"
+)
+
+func TestDailyCloses(t *testing.T) {
+	eastern := time.FixedZone("EST", -5*60*60)
+	bar := func(date string, close float64) repository.StockIntraDayData {
+		t, err := time.Parse(time.RFC3339, date)
+		if err != nil {
+			panic(err)
+		}
+		return repository.StockIntraDayData{Date: t, Close: close}
+	}
+	bars := []repository.StockIntraDayData{
+		bar("2024-01-03T20:55:00Z", 11),
+		bar("2024-01-02T15:00:00Z", 9),
+		bar("2024-01-02T20:55:00Z", 10),
+		// 01:00 UTC on the 4th is still the 3rd in New York
+		bar("2024-01-04T01:00:00Z", 12),
+	}
+
+	got := DailyCloses(bars, eastern)
+	want := []Close{
+		{time.Date(2024, 1, 2, 0, 0, 0, 0, eastern), 10},
+		{time.Date(2024, 1, 3, 0, 0, 0, 0, eastern), 12},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d closes, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].Date.Equal(want[i].Date) || got[i].Price != want[i].Price {
+			t.Errorf("close %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	buckets := BucketCloses(bars, 24*time.Hour)
+	if got := buckets[time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)]; got != 10 {
+		t.Errorf("2024-01-02 bucket = %v, want 10", got)
+	}
+}
@@ -0,0 +1,176 @@
+package portfolio
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock-api/internal/repository"
+)
+
+// ErrInvalidCSV is wrapped by every error caused by an unreadable broker export
+var ErrInvalidCSV = errors.New("invalid broker csv")
+
+// csvColumns maps each field to the header names brokers commonly use for it
+var csvColumns = map[string][]string{
+	"date":     {"date", "trade date", "trade_date", "transaction date", "run date", "activity date"},
+	"action":   {"action", "type", "side", "trade type", "transaction type", "buy/sell"},
+	"symbol":   {"symbol", "ticker", "security"},
+	"quantity": {"quantity", "qty", "shares", "units"},
+	"price":    {"price", "price per share", "trade price", "execution price"},
+	"fees":     {"fees", "fee", "commission", "commissions", "fees & comm"},
+}
+
+var csvDateLayouts = []string{"2006-01-02", "01/02/2006", "1/2/2006", "01/02/06", "1/2/06", time.RFC3339}
+
+// CSVImport is the result of parsing a broker export
+type CSVImport struct {
+	Trades []repository.Trade
+	// Skipped counts rows that aren't buys or sells, such as transfers or interest
+	Skipped int
+}
+
+// ParseBrokerCSV reads buy and sell executions from a broker CSV export. The
+// header row is matched case-insensitively against common column names; date,
+// action, symbol, quantity and price are required and fees are optional.
+// Trades are returned without an account.
+func ParseBrokerCSV(r io.Reader) (*CSVImport, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: file is empty", ErrInvalidCSV)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCSV, err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		// Drop unit suffixes such as "Price ($)"
+		if i := strings.Index(name, "("); i > 0 {
+			name = strings.TrimSpace(name[:i])
+		}
+		for field, aliases := range csvColumns {
+			if _, found := columns[field]; found {
+				continue
+			}
+			for _, alias := range aliases {
+				if name == alias {
+					columns[field] = i
+				}
+			}
+		}
+	}
+	for _, field := range []string{"date", "action", "symbol", "quantity", "price"} {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("%w: no %s column", ErrInvalidCSV, field)
+		}
+	}
+
+	result := &CSVImport{Trades: []repository.Trade{}}
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidCSV, line, err)
+		}
+
+		get := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		if strings.Join(record, "") == "" {
+			continue
+		}
+
+		tradeType, ok := parseAction(get("action"))
+		if !ok {
+			result.Skipped++
+			continue
+		}
+
+		t := repository.Trade{Type: tradeType, Symbol: strings.ToUpper(get("symbol"))}
+		if t.TradeDate, err = parseCSVDate(get("date")); err != nil {
+			return nil, fmt.Errorf("%w: line %d: invalid date %q", ErrInvalidCSV, line, get("date"))
+		}
+		if t.Quantity, err = parseCSVNumber(get("quantity")); err != nil {
+			return nil, fmt.Errorf("%w: line %d: invalid quantity %q", ErrInvalidCSV, line, get("quantity"))
+		}
+		if t.Price, err = parseCSVNumber(get("price")); err != nil {
+			return nil, fmt.Errorf("%w: line %d: invalid price %q", ErrInvalidCSV, line, get("price"))
+		}
+		if fees := get("fees"); fees != "" {
+			if t.Fees, err = parseCSVNumber(fees); err != nil {
+				return nil, fmt.Errorf("%w: line %d: invalid fees %q", ErrInvalidCSV, line, fees)
+			}
+		}
+
+		// Brokers sign quantities and fees by cash direction
+		t.Quantity = math.Abs(t.Quantity)
+		t.Price = math.Abs(t.Price)
+		t.Fees = math.Abs(t.Fees)
+		result.Trades = append(result.Trades, t)
+	}
+
+	// Many brokers export newest first; keep same-day executions in the order they happened
+	if n := len(result.Trades); n > 1 && result.Trades[0].TradeDate.After(result.Trades[n-1].TradeDate) {
+		for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+			result.Trades[i], result.Trades[j] = result.Trades[j], result.Trades[i]
+		}
+	}
+	return result, nil
+}
+
+// parseAction recognises buy and sell actions, including wording like "YOU BOUGHT"
+func parseAction(action string) (repository.TradeType, bool) {
+	action = strings.ToLower(action)
+	switch {
+	case action == "b" || strings.Contains(action, "buy") || strings.Contains(action, "bought"):
+		return repository.TradeBuy, true
+	case action == "s" || strings.Contains(action, "sell") || strings.Contains(action, "sold"):
+		return repository.TradeSell, true
+	}
+	return "", false
+}
+
+func parseCSVDate(s string) (time.Time, error) {
+	for _, layout := range csvDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q", s)
+}
+
+// parseCSVNumber accepts currency symbols, thousands separators and
+// accounting-style negatives such as "(1,234.50)"
+func parseCSVNumber(s string) (float64, error) {
+	s = strings.NewReplacer("$", "", ",", "", " ", "").Replace(s)
+	negative := strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")")
+	s = strings.Trim(s, "()")
+	if s == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if negative {
+		v = -v
+	}
+	return v, err
+}
@@ -0,0 +1,56 @@
+package portfolio
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"stock-api/internal/repository"
+)
+
+func TestParseBrokerCSV(t *testing.T) {
+	// Newest first, with a BOM, unit suffixes, signed quantities and a transfer row
+	input := "\ufeffRun Date,Action,Symbol,Quantity,Price ($),Commission ($)\n" +
+		"01/05/2024,YOU SOLD,aapl,-5,\"$1,200.50\",(1.00)\n" +
+		"01/04/2024,JOURNALED CASH,,,,\n" +
+		",,,,,\n" +
+		"01/03/2024,YOU BOUGHT,AAPL,10,\"1,100\",\n"
+
+	parsed, err := ParseBrokerCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if parsed.Skipped != 1 {
+		t.Errorf("skipped %d rows, want 1", parsed.Skipped)
+	}
+	if len(parsed.Trades) != 2 {
+		t.Fatalf("got %d trades, want 2", len(parsed.Trades))
+	}
+
+	first, second := parsed.Trades[0], parsed.Trades[1]
+	if first.Type != repository.TradeBuy || !first.TradeDate.Equal(day("2024-01-03")) || first.Quantity != 10 || first.Price != 1100 {
+		t.Errorf("first trade = %+v, want the buy of 10 at 1100 on 2024-01-03", first)
+	}
+	if second.Type != repository.TradeSell || second.Symbol != "AAPL" || second.Quantity != 5 || second.Price != 1200.5 || second.Fees != 1 {
+		t.Errorf("second trade = %+v, want the sell of 5 AAPL at 1200.50 with 1.00 fees", second)
+	}
+}
+
+func TestParseBrokerCSVErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"missing price column", "Date,Action,Symbol,Quantity\n2024-01-03,buy,AAPL,1\n"},
+		{"bad date", "Date,Action,Symbol,Quantity,Price\n03 Jan 2024,buy,AAPL,1,100\n"},
+		{"bad quantity", "Date,Action,Symbol,Quantity,Price\n2024-01-03,buy,AAPL,one,100\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseBrokerCSV(strings.NewReader(tt.input)); !errors.Is(err, ErrInvalidCSV) {
+				t.Errorf("error = %v, want %v", err, ErrInvalidCSV)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Package portfolio derives positions and tax lots from a trade ledger.
+package portfolio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"stock-api/internal/repository"
+)
+
+// ErrOversold is returned when a sell exceeds the shares held at that point
+var ErrOversold = errors.New("sell quantity exceeds shares held")
+
+// epsilon absorbs rounding left over from fractional shares and splits
+const epsilon = 1e-9
+
+// Position is what an account holds in one symbol after replaying its trades.
+// Cost basis uses the average-cost method.
+type Position struct {
+	AccountID    int     `json:"account_id"`
+	Symbol       string  `json:"symbol"`
+	Quantity     float64 `json:"quantity"`
+	CostBasis    float64 `json:"cost_basis"`
+	AverageCost  float64 `json:"average_cost"`
+	RealizedGain float64 `json:"realized_gain"`
+	Dividends    float64 `json:"dividends"`
+}
+
+// SortTrades orders trades the way they are replayed: by date, then id
+func SortTrades(trades []repository.Trade) {
+	sort.SliceStable(trades, func(i, j int) bool {
+		if !trades[i].TradeDate.Equal(trades[j].TradeDate) {
+			return trades[i].TradeDate.Before(trades[j].TradeDate)
+		}
+		return trades[i].ID < trades[j].ID
+	})
+}
+
+// Replay applies trades in order and returns the resulting positions sorted
+// by account and symbol, including closed positions with realised gains
+func Replay(trades []repository.Trade) ([]Position, error) {
+	ordered := make([]repository.Trade, len(trades))
+	copy(ordered, trades)
+	SortTrades(ordered)
+
+	type key struct {
+		account int
+		symbol  string
+	}
+	positions := make(map[key]*Position)
+
+	for _, t := range ordered {
+		k := key{t.AccountID, t.Symbol}
+		p, ok := positions[k]
+		if !ok {
+			p = &Position{AccountID: t.AccountID, Symbol: t.Symbol}
+			positions[k] = p
+		}
+
+		switch t.Type {
+		case repository.TradeBuy:
+			p.Quantity += t.Quantity
+			p.CostBasis += t.Quantity*t.Price + t.Fees
+		case repository.TradeSell:
+			if t.Quantity > p.Quantity+epsilon {
+				return nil, fmt.Errorf("%w: selling %g %s on %s with %g held", ErrOversold,
+					t.Quantity, t.Symbol, t.TradeDate.Format("2006-01-02"), p.Quantity)
+			}
+			soldCost := 0.0
+			if p.Quantity > 0 {
+				soldCost = p.CostBasis * t.Quantity / p.Quantity
+			}
+			p.RealizedGain += t.Quantity*t.Price - t.Fees - soldCost
+			p.CostBasis -= soldCost
+			p.Quantity -= t.Quantity
+		case repository.TradeDividend:
+			p.Dividends += t.Amount
+		case repository.TradeSplit:
+			p.Quantity *= t.SplitRatio
+		default:
+			return nil, fmt.Errorf("unknown trade type %q", t.Type)
+		}
+
+		if math.Abs(p.Quantity) < epsilon {
+			p.Quantity = 0
+			p.CostBasis = 0
+		}
+	}
+
+	result := make([]Position, 0, len(positions))
+	for _, p := range positions {
+		if p.Quantity > 0 {
+			p.AverageCost = p.CostBasis / p.Quantity
+		}
+		result = append(result, *p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].AccountID != result[j].AccountID {
+			return result[i].AccountID < result[j].AccountID
+		}
+		return result[i].Symbol < result[j].Symbol
+	})
+	return result, nil
+}
@@ -0,0 +1,100 @@
+package portfolio
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"stock-api/internal/repository"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func buy(id, account int, symbol, date string, quantity, price, fees float64) repository.Trade {
+	return repository.Trade{ID: id, AccountID: account, Symbol: symbol, Type: repository.TradeBuy,
+		TradeDate: day(date), Quantity: quantity, Price: price, Fees: fees}
+}
+
+func sell(id, account int, symbol, date string, quantity, price, fees float64) repository.Trade {
+	return repository.Trade{ID: id, AccountID: account, Symbol: symbol, Type: repository.TradeSell,
+		TradeDate: day(date), Quantity: quantity, Price: price, Fees: fees}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestReplay(t *testing.T) {
+	trades := []repository.Trade{
+		// Out of order on purpose: replay sorts by date, then id
+		sell(4, 1, "AAPL", "2024-03-01", 5, 120, 1),
+		buy(1, 1, "AAPL", "2024-01-02", 10, 100, 2),
+		buy(2, 1, "AAPL", "2024-02-01", 10, 110, 0),
+		{ID: 3, AccountID: 1, Symbol: "AAPL", Type: repository.TradeDividend, TradeDate: day("2024-02-15"), Amount: 4.8},
+		{ID: 5, AccountID: 1, Symbol: "AAPL", Type: repository.TradeSplit, TradeDate: day("2024-04-01"), SplitRatio: 2},
+		buy(6, 2, "MSFT", "2024-01-05", 3, 300, 0),
+		sell(7, 2, "MSFT", "2024-01-10", 3, 310, 0),
+	}
+
+	positions, err := Replay(trades)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(positions) != 2 {
+		t.Fatalf("got %d positions, want 2", len(positions))
+	}
+
+	aapl := positions[0]
+	// 20 shares cost 2102; selling 5 at the average cost of 105.10 leaves 1576.50
+	if aapl.Symbol != "AAPL" || !near(aapl.Quantity, 30) || !near(aapl.CostBasis, 1576.5) {
+		t.Errorf("AAPL = %+v, want 30 shares costing 1576.50", aapl)
+	}
+	if !near(aapl.AverageCost, 52.55) {
+		t.Errorf("AAPL average cost = %v, want 52.55 after the split", aapl.AverageCost)
+	}
+	if !near(aapl.RealizedGain, 5*120-1-525.5) {
+		t.Errorf("AAPL realised gain = %v, want %v", aapl.RealizedGain, 5*120-1-525.5)
+	}
+	if !near(aapl.Dividends, 4.8) {
+		t.Errorf("AAPL dividends = %v, want 4.8", aapl.Dividends)
+	}
+
+	msft := positions[1]
+	if msft.Quantity != 0 || msft.CostBasis != 0 || !near(msft.RealizedGain, 30) {
+		t.Errorf("MSFT = %+v, want a closed position with a 30 gain", msft)
+	}
+}
+
+func TestReplayRejectsOversell(t *testing.T) {
+	tests := []struct {
+		name   string
+		trades []repository.Trade
+	}{
+		{"more than held", []repository.Trade{
+			buy(1, 1, "AAPL", "2024-01-02", 10, 100, 0),
+			sell(2, 1, "AAPL", "2024-01-03", 11, 100, 0),
+		}},
+		{"before the buy", []repository.Trade{
+			sell(1, 1, "AAPL", "2024-01-02", 1, 100, 0),
+			buy(2, 1, "AAPL", "2024-01-03", 10, 100, 0),
+		}},
+		{"from another account", []repository.Trade{
+			buy(1, 1, "AAPL", "2024-01-02", 10, 100, 0),
+			sell(2, 2, "AAPL", "2024-01-03", 1, 100, 0),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Replay(tt.trades); !errors.Is(err, ErrOversold) {
+				t.Errorf("error = %v, want %v", err, ErrOversold)
+			}
+		})
+	}
+}
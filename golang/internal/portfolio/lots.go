@@ -0,0 +1,253 @@
+package portfolio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"stock-api/internal/repository"
+)
+
+// LotMethod decides which open lots a sell closes
+type LotMethod string
+
+const (
+	FIFO        LotMethod = "fifo"
+	LIFO        LotMethod = "lifo"
+	HighestCost LotMethod = "highest_cost"
+	// SpecificID closes the lots listed on the sell
+	SpecificID LotMethod = "specific_id"
+)
+
+// ErrInvalidLotSelection is returned when a specific-ID sell names lots that
+// don't exist or don't cover the quantity sold
+var ErrInvalidLotSelection = errors.New("invalid lot selection")
+
+// Term is the holding-period classification of a gain
+type Term string
+
+const (
+	ShortTerm Term = "short"
+	LongTerm  Term = "long"
+)
+
+// ParseLotMethod validates a lot method name; empty means FIFO
+func ParseLotMethod(s string) (LotMethod, error) {
+	switch m := LotMethod(strings.ToLower(strings.TrimSpace(s))); m {
+	case "":
+		return FIFO, nil
+	case FIFO, LIFO, HighestCost, SpecificID:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid lot method %q (use fifo, lifo, highest_cost or specific_id)", s)
+}
+
+// Lot is the unsold remainder of one buy. Quantity and CostPerShare are
+// adjusted for later splits; CostPerShare includes the buy's fees.
+type Lot struct {
+	BuyTradeID   int       `json:"buy_trade_id"`
+	AccountID    int       `json:"account_id"`
+	Symbol       string    `json:"symbol"`
+	Acquired     time.Time `json:"acquired"`
+	Quantity     float64   `json:"quantity"`
+	CostPerShare float64   `json:"cost_per_share"`
+}
+
+// RealizedLot is the part of a lot closed by one sell
+type RealizedLot struct {
+	SellTradeID int       `json:"sell_trade_id"`
+	BuyTradeID  int       `json:"buy_trade_id"`
+	AccountID   int       `json:"account_id"`
+	Symbol      string    `json:"symbol"`
+	Quantity    float64   `json:"quantity"`
+	Acquired    time.Time `json:"acquired"`
+	Sold        time.Time `json:"sold"`
+	CostBasis   float64   `json:"cost_basis"`
+	Proceeds    float64   `json:"proceeds"`
+	Gain        float64   `json:"gain"`
+	Term        Term      `json:"term"`
+}
+
+// HoldingTerm classifies a holding period: long term once held for more than a year
+func HoldingTerm(acquired, sold time.Time) Term {
+	if sold.After(acquired.AddDate(1, 0, 0)) {
+		return LongTerm
+	}
+	return ShortTerm
+}
+
+// MatchLots replays trades, matching each sell against open lots. A sell uses
+// its own LotMethod when set and defaultMethod(accountID) otherwise. It returns
+// the lots still open and every realised lot, both in trade order.
+func MatchLots(trades []repository.Trade, defaultMethod func(accountID int) LotMethod) ([]Lot, []RealizedLot, error) {
+	ordered := make([]repository.Trade, len(trades))
+	copy(ordered, trades)
+	SortTrades(ordered)
+
+	type key struct {
+		account int
+		symbol  string
+	}
+	open := make(map[key][]*Lot)
+	var all []*Lot
+	var realized []RealizedLot
+
+	for _, t := range ordered {
+		k := key{t.AccountID, t.Symbol}
+
+		switch t.Type {
+		case repository.TradeBuy:
+			lot := &Lot{
+				BuyTradeID:   t.ID,
+				AccountID:    t.AccountID,
+				Symbol:       t.Symbol,
+				Acquired:     t.TradeDate,
+				Quantity:     t.Quantity,
+				CostPerShare: (t.Quantity*t.Price + t.Fees) / t.Quantity,
+			}
+			open[k] = append(open[k], lot)
+			all = append(all, lot)
+
+		case repository.TradeSplit:
+			for _, lot := range open[k] {
+				lot.Quantity *= t.SplitRatio
+				lot.CostPerShare /= t.SplitRatio
+			}
+
+		case repository.TradeSell:
+			method := defaultMethod(t.AccountID)
+			if t.LotMethod != "" {
+				m, err := ParseLotMethod(t.LotMethod)
+				if err != nil {
+					return nil, nil, err
+				}
+				method = m
+			}
+
+			closes, err := selectLots(open[k], t, method)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			proceedsPerShare := t.Price - t.Fees/t.Quantity
+			for _, c := range closes {
+				cost := c.quantity * c.lot.CostPerShare
+				proceeds := c.quantity * proceedsPerShare
+				realized = append(realized, RealizedLot{
+					SellTradeID: t.ID,
+					BuyTradeID:  c.lot.BuyTradeID,
+					AccountID:   t.AccountID,
+					Symbol:      t.Symbol,
+					Quantity:    c.quantity,
+					Acquired:    c.lot.Acquired,
+					Sold:        t.TradeDate,
+					CostBasis:   cost,
+					Proceeds:    proceeds,
+					Gain:        proceeds - cost,
+					Term:        HoldingTerm(c.lot.Acquired, t.TradeDate),
+				})
+				c.lot.Quantity -= c.quantity
+				if c.lot.Quantity < epsilon {
+					c.lot.Quantity = 0
+				}
+			}
+
+			remaining := open[k][:0]
+			for _, lot := range open[k] {
+				if lot.Quantity > 0 {
+					remaining = append(remaining, lot)
+				}
+			}
+			open[k] = remaining
+		}
+	}
+
+	var stillOpen []Lot
+	for _, lot := range all {
+		if lot.Quantity > 0 {
+			stillOpen = append(stillOpen, *lot)
+		}
+	}
+	return stillOpen, realized, nil
+}
+
+type lotClose struct {
+	lot      *Lot
+	quantity float64
+}
+
+// selectLots decides how much of which open lots the sell closes
+func selectLots(open []*Lot, sell repository.Trade, method LotMethod) ([]lotClose, error) {
+	if method == SpecificID {
+		return selectSpecificLots(open, sell)
+	}
+
+	candidates := make([]*Lot, len(open))
+	copy(candidates, open)
+	switch method {
+	case LIFO:
+		for i, j := 0, len(candidates)-1; i < j; i, j = i+1, j-1 {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		}
+	case HighestCost:
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].CostPerShare > candidates[j].CostPerShare
+		})
+	}
+
+	remaining := sell.Quantity
+	var closes []lotClose
+	for _, lot := range candidates {
+		if remaining < epsilon {
+			break
+		}
+		q := math.Min(lot.Quantity, remaining)
+		closes = append(closes, lotClose{lot, q})
+		remaining -= q
+	}
+	if remaining > epsilon {
+		return nil, fmt.Errorf("%w: selling %g %s on %s with %g held", ErrOversold,
+			sell.Quantity, sell.Symbol, sell.TradeDate.Format("2006-01-02"), sell.Quantity-remaining)
+	}
+	return closes, nil
+}
+
+func selectSpecificLots(open []*Lot, sell repository.Trade) ([]lotClose, error) {
+	if len(sell.Lots) == 0 {
+		return nil, fmt.Errorf("%w: specific_id sell %d lists no lots", ErrInvalidLotSelection, sell.ID)
+	}
+
+	byBuy := make(map[int]*Lot, len(open))
+	for _, lot := range open {
+		byBuy[lot.BuyTradeID] = lot
+	}
+
+	total := 0.0
+	selected := make(map[int]bool, len(sell.Lots))
+	closes := make([]lotClose, 0, len(sell.Lots))
+	for _, selection := range sell.Lots {
+		if selected[selection.BuyTradeID] {
+			return nil, fmt.Errorf("%w: lot %d is selected twice", ErrInvalidLotSelection, selection.BuyTradeID)
+		}
+		selected[selection.BuyTradeID] = true
+
+		lot, ok := byBuy[selection.BuyTradeID]
+		if !ok {
+			return nil, fmt.Errorf("%w: buy %d is not an open %s lot on %s", ErrInvalidLotSelection,
+				selection.BuyTradeID, sell.Symbol, sell.TradeDate.Format("2006-01-02"))
+		}
+		if selection.Quantity <= 0 || selection.Quantity > lot.Quantity+epsilon {
+			return nil, fmt.Errorf("%w: lot %d has %g shares, %g selected", ErrInvalidLotSelection,
+				selection.BuyTradeID, lot.Quantity, selection.Quantity)
+		}
+		closes = append(closes, lotClose{lot, math.Min(selection.Quantity, lot.Quantity)})
+		total += selection.Quantity
+	}
+	if math.Abs(total-sell.Quantity) > epsilon {
+		return nil, fmt.Errorf("%w: lots cover %g shares but %g were sold", ErrInvalidLotSelection, total, sell.Quantity)
+	}
+	return closes, nil
+}
@@ -0,0 +1,166 @@
+package portfolio
+
+import (
+	"errors"
+	"testing"
+
+	"stock-api/internal/repository"
+)
+
+func TestMatchLots(t *testing.T) {
+	ledger := []repository.Trade{
+		buy(1, 1, "AAPL", "2023-01-03", 10, 100, 0),
+		buy(2, 1, "AAPL", "2023-06-01", 10, 150, 0),
+		buy(3, 1, "AAPL", "2024-01-02", 10, 120, 0),
+	}
+
+	tests := []struct {
+		name   string
+		method LotMethod
+		lots   []repository.LotSelection
+		// want is the buy id and quantity of each realised lot
+		want [][2]float64
+	}{
+		{"fifo", FIFO, nil, [][2]float64{{1, 10}, {2, 5}}},
+		{"lifo", LIFO, nil, [][2]float64{{3, 10}, {2, 5}}},
+		{"highest cost", HighestCost, nil, [][2]float64{{2, 10}, {3, 5}}},
+		{"specific id", SpecificID, []repository.LotSelection{{BuyTradeID: 3, Quantity: 5}, {BuyTradeID: 1, Quantity: 10}},
+			[][2]float64{{3, 5}, {1, 10}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := sell(4, 1, "AAPL", "2024-02-01", 15, 130, 0)
+			s.LotMethod = string(tt.method)
+			s.Lots = tt.lots
+			trades := append(append([]repository.Trade{}, ledger...), s)
+
+			open, realized, err := MatchLots(trades, func(int) LotMethod { return FIFO })
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(realized) != len(tt.want) {
+				t.Fatalf("got %d realised lots, want %d", len(realized), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if realized[i].BuyTradeID != int(want[0]) || !near(realized[i].Quantity, want[1]) {
+					t.Errorf("realised lot %d = buy %d x %g, want buy %g x %g", i,
+						realized[i].BuyTradeID, realized[i].Quantity, want[0], want[1])
+				}
+			}
+
+			remaining := 0.0
+			for _, lot := range open {
+				remaining += lot.Quantity
+			}
+			if !near(remaining, 15) {
+				t.Errorf("open lots hold %g shares, want 15", remaining)
+			}
+		})
+	}
+}
+
+func TestMatchLotsGainsAndTerms(t *testing.T) {
+	trades := []repository.Trade{
+		buy(1, 1, "AAPL", "2023-01-03", 10, 100, 10),
+		{ID: 2, AccountID: 1, Symbol: "AAPL", Type: repository.TradeSplit, TradeDate: day("2023-06-01"), SplitRatio: 2},
+		sell(3, 1, "AAPL", "2023-12-01", 10, 60, 5),
+		sell(4, 1, "AAPL", "2024-01-04", 10, 70, 0),
+	}
+
+	open, realized, err := MatchLots(trades, func(int) LotMethod { return FIFO })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(open) != 0 {
+		t.Errorf("got %d open lots, want none", len(open))
+	}
+	if len(realized) != 2 {
+		t.Fatalf("got %d realised lots, want 2", len(realized))
+	}
+
+	// The split halves the 10.10 cost per share to 5.05
+	if first := realized[0]; first.Term != ShortTerm || !near(first.CostBasis, 505) || !near(first.Gain, 600-5-505) {
+		t.Errorf("first sale = %+v, want a short-term gain of 90 on a 505 basis", first)
+	}
+	if second := realized[1]; second.Term != LongTerm || !near(second.Gain, 700-505) {
+		t.Errorf("second sale = %+v, want a long-term gain of 195", second)
+	}
+}
+
+func TestMatchLotsAccountDefaultMethod(t *testing.T) {
+	trades := []repository.Trade{
+		buy(1, 7, "AAPL", "2024-01-02", 5, 100, 0),
+		buy(2, 7, "AAPL", "2024-01-03", 5, 110, 0),
+		sell(3, 7, "AAPL", "2024-01-04", 5, 120, 0),
+	}
+	methods := func(accountID int) LotMethod {
+		if accountID == 7 {
+			return LIFO
+		}
+		return FIFO
+	}
+
+	_, realized, err := MatchLots(trades, methods)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(realized) != 1 || realized[0].BuyTradeID != 2 {
+		t.Errorf("realised = %+v, want the later lot closed", realized)
+	}
+}
+
+func TestMatchLotsRejectsBadSelections(t *testing.T) {
+	tests := []struct {
+		name    string
+		lots    []repository.LotSelection
+		wantErr error
+	}{
+		{"no lots", nil, ErrInvalidLotSelection},
+		{"unknown lot", []repository.LotSelection{{BuyTradeID: 9, Quantity: 5}}, ErrInvalidLotSelection},
+		{"more than the lot holds", []repository.LotSelection{{BuyTradeID: 1, Quantity: 6}}, ErrInvalidLotSelection},
+		{"lot selected twice", []repository.LotSelection{{BuyTradeID: 1, Quantity: 2}, {BuyTradeID: 1, Quantity: 3}}, ErrInvalidLotSelection},
+		{"short of the quantity sold", []repository.LotSelection{{BuyTradeID: 1, Quantity: 4}}, ErrInvalidLotSelection},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := sell(3, 1, "AAPL", "2024-01-04", 5, 120, 0)
+			s.LotMethod = string(SpecificID)
+			s.Lots = tt.lots
+			trades := []repository.Trade{
+				buy(1, 1, "AAPL", "2024-01-02", 5, 100, 0),
+				buy(2, 1, "AAPL", "2024-01-03", 5, 110, 0),
+				s,
+			}
+			if _, _, err := MatchLots(trades, func(int) LotMethod { return FIFO }); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	oversold := []repository.Trade{
+		buy(1, 1, "AAPL", "2024-01-02", 5, 100, 0),
+		sell(2, 1, "AAPL", "2024-01-03", 6, 100, 0),
+	}
+	if _, _, err := MatchLots(oversold, func(int) LotMethod { return FIFO }); !errors.Is(err, ErrOversold) {
+		t.Errorf("oversold error = %v, want %v", err, ErrOversold)
+	}
+}
+
+func TestHoldingTerm(t *testing.T) {
+	tests := []struct {
+		acquired, sold string
+		want           Term
+	}{
+		{"2023-01-03", "2024-01-03", ShortTerm},
+		{"2023-01-03", "2024-01-04", LongTerm},
+		{"2024-02-29", "2025-03-01", ShortTerm},
+		{"2024-02-29", "2025-03-02", LongTerm},
+	}
+	for _, tt := range tests {
+		if got := HoldingTerm(day(tt.acquired), day(tt.sold)); got != tt.want {
+			t.Errorf("HoldingTerm(%s, %s) = %s, want %s", tt.acquired, tt.sold, got, tt.want)
+		}
+	}
+}
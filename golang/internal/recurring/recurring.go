@@ -0,0 +1,324 @@
+// Package recurring finds charges that repeat on a schedule, such as
+// subscriptions, bills and salaries, in a user's transaction history.
+package recurring
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Period is how often a series repeats
+type Period string
+
+const (
+	Weekly    Period = "weekly"
+	Biweekly  Period = "biweekly"
+	Monthly   Period = "monthly"
+	Quarterly Period = "quarterly"
+	Annual    Period = "annual"
+)
+
+// periodWindow is the range of gaps, in days, counted as one period
+type periodWindow struct {
+	period   Period
+	min, max float64
+	// days is the nominal length used to compare costs across periods
+	days float64
+	// grace is how late the next charge can be before the series counts as stopped
+	grace int
+}
+
+// daysPerMonth is the length of an average month, which costs are spread over
+const daysPerMonth = 30.44
+
+var periodWindows = []periodWindow{
+	{Weekly, 6, 8, 7, 3},
+	{Biweekly, 13, 16, 14, 5},
+	{Monthly, 27, 33, daysPerMonth, 7},
+	{Quarterly, 85, 97, 91.31, 15},
+	{Annual, 355, 375, 365.25, 30},
+}
+
+// Charge is one transaction to look for patterns in
+type Charge struct {
+	ID     int
+	Date   time.Time
+	Amount float64
+	// Merchant groups charges; it should already be normalised
+	Merchant string
+	Category string
+	Account  string
+}
+
+// Options tune detection. Zero values use the defaults.
+type Options struct {
+	// AmountTolerance is how far, as a fraction, a charge may differ from the
+	// previous one in its series (default 0.25)
+	AmountTolerance float64
+	// MinOccurrences is how many charges a series needs (default 3, and 2
+	// for annual series)
+	MinOccurrences int
+	// Now decides whether a series is still active (default time.Now)
+	Now time.Time
+}
+
+// Series is one recurring charge
+type Series struct {
+	Merchant string `json:"merchant"`
+	Category string `json:"category"`
+	Account  string `json:"account"`
+	Period   Period `json:"period"`
+	// Direction is debit for money out and credit for money in
+	Direction   string    `json:"direction"`
+	Occurrences int       `json:"occurrences"`
+	FirstDate   time.Time `json:"first_date"`
+	LastDate    time.Time `json:"last_date"`
+	LastAmount  float64   `json:"last_amount"`
+	// AverageInterval is the mean gap between charges in days
+	AverageInterval float64   `json:"average_interval"`
+	NextExpected    time.Time `json:"next_expected"`
+	// ExpectedAmount is the next charge's amount, assumed equal to the last
+	ExpectedAmount float64 `json:"expected_amount"`
+	// AmountDrift is how much the absolute amount has moved since the first charge
+	AmountDrift    float64 `json:"amount_drift"`
+	AmountDriftPct float64 `json:"amount_drift_pct"`
+	// PreviousAmount and AmountChangedOn describe the latest change in
+	// amount; AmountChangedOn is nil when the amount never changed
+	PreviousAmount  float64    `json:"previous_amount"`
+	AmountChangedOn *time.Time `json:"amount_changed_on"`
+	// PriceIncrease is set when the latest change raised the cost of a debit
+	PriceIncrease bool `json:"price_increase"`
+	// MonthlyCost is the absolute amount spread over an average month
+	MonthlyCost float64 `json:"monthly_cost"`
+	// Active is false when the next charge is overdue by more than the period's grace
+	Active bool `json:"active"`
+	// Confidence is how regular the series is, from 0 to 1
+	Confidence     float64 `json:"confidence"`
+	TransactionIDs []int   `json:"transaction_ids"`
+}
+
+// Detect groups charges by merchant and direction, splits each group into
+// series of similar amounts and reports the series that repeat on a
+// weekly, biweekly, monthly, quarterly or annual schedule. Series are
+// returned active first, most expensive first.
+func Detect(charges []Charge, opts Options) []Series {
+	if opts.AmountTolerance <= 0 {
+		opts.AmountTolerance = 0.25
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	groups := make(map[string][]Charge)
+	var keys []string
+	for _, c := range charges {
+		merchant := strings.ToUpper(strings.TrimSpace(c.Merchant))
+		if merchant == "" || c.Amount == 0 {
+			continue
+		}
+		key := merchant + "|debit"
+		if c.Amount > 0 {
+			key = merchant + "|credit"
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], c)
+	}
+	sort.Strings(keys)
+
+	var found []Series
+	for _, key := range keys {
+		for _, cluster := range clusterByAmount(groups[key], opts.AmountTolerance) {
+			if s, ok := detectSeries(cluster, opts); ok {
+				found = append(found, s)
+			}
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].Active != found[j].Active {
+			return found[i].Active
+		}
+		return found[i].MonthlyCost > found[j].MonthlyCost
+	})
+	return found
+}
+
+// clusterByAmount walks a merchant's charges in date order, adding each to
+// the series whose latest amount it is closest to within tolerance. Chaining
+// on the latest amount lets a series follow gradual price changes.
+func clusterByAmount(charges []Charge, tolerance float64) [][]Charge {
+	sort.SliceStable(charges, func(i, j int) bool { return charges[i].Date.Before(charges[j].Date) })
+
+	var clusters [][]Charge
+	for _, c := range charges {
+		best, bestDiff := -1, math.Inf(1)
+		for i, cluster := range clusters {
+			last := math.Abs(cluster[len(cluster)-1].Amount)
+			diff := math.Abs(math.Abs(c.Amount) - last)
+			if diff <= last*tolerance && diff < bestDiff {
+				best, bestDiff = i, diff
+			}
+		}
+		if best < 0 {
+			clusters = append(clusters, []Charge{c})
+		} else {
+			clusters[best] = append(clusters[best], c)
+		}
+	}
+	return clusters
+}
+
+// detectSeries decides whether one cluster repeats on a schedule
+func detectSeries(cluster []Charge, opts Options) (Series, bool) {
+	// Several charges on one day (split payments, retries) count once
+	var charges []Charge
+	for _, c := range cluster {
+		if n := len(charges); n > 0 && sameDay(charges[n-1].Date, c.Date) {
+			continue
+		}
+		charges = append(charges, c)
+	}
+	if len(charges) < 2 {
+		return Series{}, false
+	}
+
+	gaps := make([]float64, len(charges)-1)
+	for i := 1; i < len(charges); i++ {
+		gaps[i-1] = charges[i].Date.Sub(charges[i-1].Date).Hours() / 24
+	}
+	median := medianOf(gaps)
+
+	var window *periodWindow
+	for i := range periodWindows {
+		if median >= periodWindows[i].min && median <= periodWindows[i].max {
+			window = &periodWindows[i]
+			break
+		}
+	}
+	if window == nil {
+		return Series{}, false
+	}
+
+	minOccurrences := opts.MinOccurrences
+	if minOccurrences <= 0 {
+		minOccurrences = 3
+		if window.period == Annual {
+			minOccurrences = 2
+		}
+	}
+	if len(charges) < minOccurrences {
+		return Series{}, false
+	}
+
+	// A skipped charge shows up as a gap of about two periods; it doesn't
+	// make the series irregular
+	regular := 0
+	for _, gap := range gaps {
+		if (gap >= window.min && gap <= window.max) || (gap >= 2*window.min && gap <= 2*window.max) {
+			regular++
+		}
+	}
+	regularity := float64(regular) / float64(len(gaps))
+	if regularity < 0.75 {
+		return Series{}, false
+	}
+
+	first, last := charges[0], charges[len(charges)-1]
+	s := Series{
+		Merchant:        strings.ToUpper(strings.TrimSpace(last.Merchant)),
+		Category:        last.Category,
+		Account:         last.Account,
+		Period:          window.period,
+		Direction:       "debit",
+		Occurrences:     len(charges),
+		FirstDate:       first.Date,
+		LastDate:        last.Date,
+		LastAmount:      last.Amount,
+		AverageInterval: round2(last.Date.Sub(first.Date).Hours() / 24 / float64(len(gaps))),
+		NextExpected:    nextDate(last.Date, window.period),
+		ExpectedAmount:  last.Amount,
+		MonthlyCost:     round2(math.Abs(last.Amount) * daysPerMonth / window.days),
+		Confidence:      round2(regularity * math.Min(1, float64(len(charges))/6)),
+	}
+	if last.Amount > 0 {
+		s.Direction = "credit"
+	}
+
+	firstAbs, lastAbs := math.Abs(first.Amount), math.Abs(last.Amount)
+	s.AmountDrift = round2(lastAbs - firstAbs)
+	if firstAbs > 0 {
+		s.AmountDriftPct = round2(s.AmountDrift / firstAbs * 100)
+	}
+	// The latest price change decides whether the charge went up, however
+	// many charges ago it happened
+	for i := len(charges) - 2; i >= 0; i-- {
+		previousAbs := math.Abs(charges[i].Amount)
+		if math.Abs(lastAbs-previousAbs) < 0.01 {
+			continue
+		}
+		s.PreviousAmount = charges[i].Amount
+		changedOn := charges[i+1].Date
+		s.AmountChangedOn = &changedOn
+		s.PriceIncrease = s.Direction == "debit" && lastAbs > previousAbs
+		break
+	}
+
+	s.Active = !opts.Now.After(s.NextExpected.AddDate(0, 0, window.grace))
+
+	s.TransactionIDs = make([]int, len(cluster))
+	for i, c := range cluster {
+		s.TransactionIDs[i] = c.ID
+	}
+	return s, true
+}
+
+// nextDate steps a date forward one period, keeping the day of the month
+// for monthly and longer periods
+func nextDate(t time.Time, period Period) time.Time {
+	switch period {
+	case Weekly:
+		return t.AddDate(0, 0, 7)
+	case Biweekly:
+		return t.AddDate(0, 0, 14)
+	case Monthly:
+		return addMonths(t, 1)
+	case Quarterly:
+		return addMonths(t, 3)
+	default:
+		return addMonths(t, 12)
+	}
+}
+
+// addMonths adds months without spilling into the next month, so a charge
+// on Jan 31 is next expected on Feb 28 or 29
+func addMonths(t time.Time, months int) time.Time {
+	next := t.AddDate(0, months, 0)
+	if next.Day() != t.Day() {
+		next = next.AddDate(0, 0, -next.Day())
+	}
+	return next
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+func medianOf(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
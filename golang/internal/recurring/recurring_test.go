@@ -0,0 +1,185 @@
+package recurring
+
+import (
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// monthly returns n charges on the given day of consecutive months
+func monthly(merchant string, first string, n int, amount func(i int) float64) []Charge {
+	start := day(first)
+	charges := make([]Charge, n)
+	for i := range charges {
+		charges[i] = Charge{ID: i + 1, Date: addMonths(start, i), Amount: amount(i), Merchant: merchant}
+	}
+	return charges
+}
+
+func find(series []Series, merchant string) *Series {
+	for i := range series {
+		if series[i].Merchant == merchant {
+			return &series[i]
+		}
+	}
+	return nil
+}
+
+func TestDetectMonthlyPriceIncrease(t *testing.T) {
+	// 15.49 for eight months, then 17.99 for the last three
+	charges := monthly("netflix.com", "2024-01-15", 11, func(i int) float64 {
+		if i >= 8 {
+			return -17.99
+		}
+		return -15.49
+	})
+
+	found := Detect(charges, Options{Now: day("2024-12-01")})
+	if len(found) != 1 {
+		t.Fatalf("found %d series, want 1", len(found))
+	}
+	s := found[0]
+	if s.Merchant != "NETFLIX.COM" || s.Period != Monthly || s.Direction != "debit" || s.Occurrences != 11 {
+		t.Errorf("series = %+v, want 11 monthly NETFLIX.COM debits", s)
+	}
+	if !s.Active || !s.NextExpected.Equal(day("2024-12-15")) || s.ExpectedAmount != -17.99 {
+		t.Errorf("series = %+v, want an active series next due 2024-12-15 for 17.99", s)
+	}
+	// The increase stays visible after the new price has repeated
+	if !s.PriceIncrease || s.PreviousAmount != -15.49 || s.AmountChangedOn == nil || !s.AmountChangedOn.Equal(day("2024-09-15")) {
+		t.Errorf("series = %+v, want a price increase from 15.49 on 2024-09-15", s)
+	}
+	if s.AmountDrift != 2.5 || s.MonthlyCost != 17.99 {
+		t.Errorf("drift = %v, monthly cost = %v, want 2.5 and 17.99", s.AmountDrift, s.MonthlyCost)
+	}
+	if len(s.TransactionIDs) != 11 {
+		t.Errorf("got %d transaction ids, want 11", len(s.TransactionIDs))
+	}
+}
+
+func TestDetectPeriods(t *testing.T) {
+	tests := []struct {
+		name   string
+		dates  []string
+		amount float64
+		want   Period
+		// wantCost is the monthly cost of one unit charge
+		wantCost float64
+	}{
+		{"weekly", []string{"2024-03-01", "2024-03-08", "2024-03-15", "2024-03-22"}, -10, Weekly, 43.49},
+		{"biweekly salary", []string{"2024-03-01", "2024-03-15", "2024-03-29", "2024-04-12"}, 2000, Biweekly, 4348.57},
+		{"quarterly", []string{"2023-06-10", "2023-09-10", "2023-12-10", "2024-03-10"}, -90, Quarterly, 30},
+		{"annual, twice is enough", []string{"2023-04-01", "2024-04-01"}, -120, Annual, 10},
+		{"monthly with a skipped month", []string{"2024-01-05", "2024-02-05", "2024-04-05", "2024-05-05"}, -9.99, Monthly, 9.99},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var charges []Charge
+			for i, d := range tt.dates {
+				charges = append(charges, Charge{ID: i + 1, Date: day(d), Amount: tt.amount, Merchant: "ACME"})
+			}
+
+			found := Detect(charges, Options{Now: day("2024-04-20")})
+			if len(found) != 1 {
+				t.Fatalf("found %d series, want 1", len(found))
+			}
+			if found[0].Period != tt.want || found[0].MonthlyCost != tt.wantCost {
+				t.Errorf("series = %s costing %v a month, want %s costing %v", found[0].Period, found[0].MonthlyCost, tt.want, tt.wantCost)
+			}
+		})
+	}
+}
+
+func TestDetectIgnoresIrregularCharges(t *testing.T) {
+	tests := []struct {
+		name    string
+		charges []Charge
+	}{
+		{"too few", monthly("GYM", "2024-01-01", 2, func(int) float64 { return -30 })},
+		{"irregular gaps", []Charge{
+			{Date: day("2024-01-01"), Amount: -20, Merchant: "TAXI"},
+			{Date: day("2024-01-04"), Amount: -20, Merchant: "TAXI"},
+			{Date: day("2024-02-20"), Amount: -20, Merchant: "TAXI"},
+			{Date: day("2024-02-27"), Amount: -20, Merchant: "TAXI"},
+			{Date: day("2024-04-15"), Amount: -20, Merchant: "TAXI"},
+		}},
+		{"no merchant", monthly("", "2024-01-01", 6, func(int) float64 { return -30 })},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if found := Detect(tt.charges, Options{Now: day("2024-06-01")}); len(found) != 0 {
+				t.Errorf("found %+v, want nothing", found)
+			}
+		})
+	}
+}
+
+func TestDetectSplitsByAmountAndDirection(t *testing.T) {
+	var charges []Charge
+	// Two plans billed by the same merchant, plus monthly refunds
+	charges = append(charges, monthly("APPLE.COM/BILL", "2024-01-03", 6, func(int) float64 { return -2.99 })...)
+	charges = append(charges, monthly("APPLE.COM/BILL", "2024-01-20", 6, func(int) float64 { return -14.99 })...)
+	charges = append(charges, monthly("APPLE.COM/BILL", "2024-01-25", 6, func(int) float64 { return 1.00 })...)
+	// A second charge on the same day counts once
+	charges = append(charges, Charge{Date: day("2024-03-03"), Amount: -2.99, Merchant: "APPLE.COM/BILL"})
+
+	found := Detect(charges, Options{Now: day("2024-07-01")})
+	if len(found) != 3 {
+		t.Fatalf("found %d series, want 3", len(found))
+	}
+	// Most expensive first
+	if found[0].LastAmount != -14.99 || found[1].LastAmount != -2.99 || found[2].LastAmount != 1 {
+		t.Errorf("series amounts = %v, %v, %v, want -14.99, -2.99 and 1", found[0].LastAmount, found[1].LastAmount, found[2].LastAmount)
+	}
+	if found[1].Occurrences != 6 || len(found[1].TransactionIDs) != 7 {
+		t.Errorf("2.99 series = %+v, want 6 occurrences from 7 transactions", found[1])
+	}
+	if found[2].Direction != "credit" || found[2].PriceIncrease {
+		t.Errorf("refund series = %+v, want a credit without a price increase", found[2])
+	}
+}
+
+func TestDetectInactiveSeries(t *testing.T) {
+	charges := append(
+		monthly("OLD GYM", "2023-01-10", 6, func(int) float64 { return -50 }),
+		monthly("NEW GYM", "2023-07-10", 6, func(int) float64 { return -40 })...,
+	)
+
+	found := Detect(charges, Options{Now: day("2024-01-15")})
+	if len(found) != 2 {
+		t.Fatalf("found %d series, want 2", len(found))
+	}
+	// Active series come first even when cheaper
+	if found[0].Merchant != "NEW GYM" || !found[0].Active {
+		t.Errorf("first series = %+v, want the active NEW GYM", found[0])
+	}
+	if old := find(found, "OLD GYM"); old == nil || old.Active {
+		t.Errorf("OLD GYM = %+v, want an inactive series", old)
+	}
+}
+
+func TestAddMonthsClampsToMonthEnd(t *testing.T) {
+	tests := []struct {
+		from   string
+		months int
+		want   string
+	}{
+		{"2024-01-31", 1, "2024-02-29"},
+		{"2023-01-31", 1, "2023-02-28"},
+		{"2024-03-31", 3, "2024-06-30"},
+		{"2024-02-29", 12, "2025-02-28"},
+		{"2024-01-15", 1, "2024-02-15"},
+	}
+	for _, tt := range tests {
+		if got := addMonths(day(tt.from), tt.months); !got.Equal(day(tt.want)) {
+			t.Errorf("addMonths(%s, %d) = %s, want %s", tt.from, tt.months, got.Format("2006-01-02"), tt.want)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type AlertRepository struct {
+	db *sql.DB
+}
+
+// AlertRule is a condition a user wants to hear about. An empty Symbol
+// applies the rule to every member of WatchlistID, or to every symbol that
+// receives new bars when WatchlistID is 0.
+type AlertRule struct {
+	ID          int    `json:"id"`
+	UserID      int    `json:"user_id"`
+	Name        string `json:"name"`
+	Symbol      string `json:"symbol"`
+	WatchlistID int    `json:"watchlist_id"`
+	Metric      string `json:"metric"`
+	// Period is the indicator period; 0 uses the metric's default
+	Period          int       `json:"period"`
+	Operator        string    `json:"operator"`
+	Threshold       float64   `json:"threshold"`
+	CooldownMinutes int       `json:"cooldown_minutes"`
+	Channels        []string  `json:"channels"`
+	WebhookURL      string    `json:"webhook_url"`
+	Email           string    `json:"email"`
+	Enabled         bool      `json:"enabled"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// AlertState is where a rule is in its firing cycle for one symbol
+type AlertState struct {
+	RuleID      int        `json:"rule_id"`
+	Symbol      string     `json:"symbol"`
+	State       string     `json:"state"`
+	LastValue   *float64   `json:"last_value"`
+	LastBarAt   *time.Time `json:"last_bar_at"`
+	LastFiredAt *time.Time `json:"last_fired_at"`
+}
+
+// AlertEvent is a fired alert as shown in the user's feed
+type AlertEvent struct {
+	ID            int        `json:"id"`
+	RuleID        int        `json:"rule_id"`
+	RuleName      string     `json:"rule_name"`
+	UserID        int        `json:"user_id"`
+	Symbol        string     `json:"symbol"`
+	Value         float64    `json:"value"`
+	BarDate       time.Time  `json:"bar_date"`
+	Message       string     `json:"message"`
+	Delivered     []string   `json:"delivered"`
+	DeliveryError string     `json:"delivery_error,omitempty"`
+	ReadAt        *time.Time `json:"read_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func NewAlertRepository(db *sql.DB) *AlertRepository {
+	return &AlertRepository{db: db}
+}
+
+const alertRuleColumns = `id, user_id, name, COALESCE(symbol, ''), COALESCE(watchlist_id, 0), metric, period, operator, threshold,
+	cooldown_minutes, channels, COALESCE(webhook_url, ''), COALESCE(email, ''), enabled, created_at, updated_at`
+
+func scanAlertRule(row interface{ Scan(...interface{}) error }) (*AlertRule, error) {
+	var rule AlertRule
+	err := row.Scan(&rule.ID, &rule.UserID, &rule.Name, &rule.Symbol, &rule.WatchlistID, &rule.Metric, &rule.Period, &rule.Operator,
+		&rule.Threshold, &rule.CooldownMinutes, pq.Array(&rule.Channels), &rule.WebhookURL, &rule.Email,
+		&rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *AlertRepository) queryRules(query string, args ...interface{}) ([]AlertRule, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []AlertRule{}
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+// CreateRule stores a new rule for its user
+func (r *AlertRepository) CreateRule(rule *AlertRule) error {
+	err := r.db.QueryRow(`
+		INSERT INTO alert_rules (user_id, name, symbol, metric, period, operator, threshold,
+			cooldown_minutes, channels, webhook_url, email, enabled, watchlist_id)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, NULLIF($10, ''), NULLIF($11, ''), $12, NULLIF($13, 0))
+		RETURNING id, created_at, updated_at
+	`, rule.UserID, rule.Name, rule.Symbol, rule.Metric, rule.Period, rule.Operator, rule.Threshold,
+		rule.CooldownMinutes, pq.Array(rule.Channels), rule.WebhookURL, rule.Email, rule.Enabled, rule.WatchlistID).Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create alert rule %q: %w", rule.Name, err)
+	}
+	return nil
+}
+
+// GetRules lists the user's rules by name
+func (r *AlertRepository) GetRules(userID int) ([]AlertRule, error) {
+	return r.queryRules(`
+		SELECT `+alertRuleColumns+`
+		FROM alert_rules
+		WHERE user_id = $1
+		ORDER BY name, id
+	`, userID)
+}
+
+// GetRule returns one of the user's rules, or nil when they have no rule with that id
+func (r *AlertRepository) GetRule(userID, id int) (*AlertRule, error) {
+	rule, err := scanAlertRule(r.db.QueryRow(`
+		SELECT `+alertRuleColumns+`
+		FROM alert_rules
+		WHERE user_id = $1 AND id = $2
+	`, userID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert rule %d: %w", id, err)
+	}
+	return rule, nil
+}
+
+// GetEnabledRulesForSymbol returns the enabled rules of every user that watch
+// the symbol, including rules that apply to any symbol or to a watchlist it's on
+func (r *AlertRepository) GetEnabledRulesForSymbol(symbol string) ([]AlertRule, error) {
+	return r.queryRules(`
+		SELECT `+alertRuleColumns+`
+		FROM alert_rules a
+		WHERE enabled
+		  AND (symbol = $1
+		       OR (symbol IS NULL AND watchlist_id IS NULL)
+		       OR (symbol IS NULL AND EXISTS (
+		           SELECT 1 FROM watchlist_symbols m WHERE m.watchlist_id = a.watchlist_id AND m.symbol = $1)))
+		ORDER BY id
+	`, symbol)
+}
+
+// UpdateRule replaces one of the user's rules, reporting false when it isn't
+// theirs. The rule's states are cleared so it re-arms under its new condition.
+func (r *AlertRepository) UpdateRule(rule *AlertRule) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		UPDATE alert_rules SET
+			name = $3, symbol = NULLIF($4, ''), metric = $5, period = $6, operator = $7, threshold = $8,
+			cooldown_minutes = $9, channels = $10, webhook_url = NULLIF($11, ''), email = NULLIF($12, ''),
+			enabled = $13, watchlist_id = NULLIF($14, 0)
+		WHERE user_id = $1 AND id = $2
+		RETURNING created_at, updated_at
+	`, rule.UserID, rule.ID, rule.Name, rule.Symbol, rule.Metric, rule.Period, rule.Operator, rule.Threshold,
+		rule.CooldownMinutes, pq.Array(rule.Channels), rule.WebhookURL, rule.Email, rule.Enabled, rule.WatchlistID).Scan(&rule.CreatedAt, &rule.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update alert rule %d: %w", rule.ID, err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM alert_rule_states WHERE rule_id = $1`, rule.ID); err != nil {
+		return false, fmt.Errorf("failed to reset alert rule %d: %w", rule.ID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit alert rule %d: %w", rule.ID, err)
+	}
+	return true, nil
+}
+
+// DeleteRule deletes one of the user's rules with its states and events, reporting whether it existed
+func (r *AlertRepository) DeleteRule(userID, id int) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM alert_rules WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete alert rule %d: %w", id, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// GetStates returns the symbol's state for each of the rules that has one, keyed by rule id
+func (r *AlertRepository) GetStates(symbol string, ruleIDs []int) (map[int]AlertState, error) {
+	ids := make([]int64, len(ruleIDs))
+	for i, id := range ruleIDs {
+		ids[i] = int64(id)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT rule_id, symbol, state, last_value, last_bar_at, last_fired_at
+		FROM alert_rule_states
+		WHERE symbol = $1 AND rule_id = ANY($2)
+	`, symbol, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert states: %w", err)
+	}
+	defer rows.Close()
+
+	states := make(map[int]AlertState)
+	for rows.Next() {
+		var s AlertState
+		if err := rows.Scan(&s.RuleID, &s.Symbol, &s.State, &s.LastValue, &s.LastBarAt, &s.LastFiredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan alert state: %w", err)
+		}
+		states[s.RuleID] = s
+	}
+	return states, rows.Err()
+}
+
+// SaveState upserts a rule's state for one symbol
+func (r *AlertRepository) SaveState(s *AlertState) error {
+	_, err := r.db.Exec(`
+		INSERT INTO alert_rule_states (rule_id, symbol, state, last_value, last_bar_at, last_fired_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (rule_id, symbol) DO UPDATE SET
+			state = EXCLUDED.state,
+			last_value = EXCLUDED.last_value,
+			last_bar_at = EXCLUDED.last_bar_at,
+			last_fired_at = EXCLUDED.last_fired_at
+	`, s.RuleID, s.Symbol, s.State, s.LastValue, s.LastBarAt, s.LastFiredAt)
+	if err != nil {
+		return fmt.Errorf("failed to save state of alert rule %d for %s: %w", s.RuleID, s.Symbol, err)
+	}
+	return nil
+}
+
+// CreateEvent records a fired alert, reporting false when the rule already
+// fired for that symbol and bar
+func (r *AlertRepository) CreateEvent(e *AlertEvent) (bool, error) {
+	err := r.db.QueryRow(`
+		INSERT INTO alert_events (rule_id, user_id, symbol, value, bar_date, message)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (rule_id, symbol, bar_date) DO NOTHING
+		RETURNING id, created_at
+	`, e.RuleID, e.UserID, e.Symbol, e.Value, e.BarDate, e.Message).Scan(&e.ID, &e.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record alert for rule %d: %w", e.RuleID, err)
+	}
+	return true, nil
+}
+
+// SetEventDelivery records which channels an event reached and why any failed
+func (r *AlertRepository) SetEventDelivery(id int, delivered []string, deliveryError string) error {
+	_, err := r.db.Exec(`
+		UPDATE alert_events SET delivered = $2, delivery_error = NULLIF($3, '')
+		WHERE id = $1
+	`, id, pq.Array(delivered), deliveryError)
+	if err != nil {
+		return fmt.Errorf("failed to record delivery of alert %d: %w", id, err)
+	}
+	return nil
+}
+
+// GetEvents returns the user's most recent alerts, newest first
+func (r *AlertRepository) GetEvents(userID int, unreadOnly bool, limit int) ([]AlertEvent, error) {
+	rows, err := r.db.Query(`
+		SELECT e.id, e.rule_id, a.name, e.user_id, e.symbol, e.value, e.bar_date, e.message,
+			e.delivered, COALESCE(e.delivery_error, ''), e.read_at, e.created_at
+		FROM alert_events e
+		JOIN alert_rules a ON a.id = e.rule_id
+		WHERE e.user_id = $1 AND (NOT $2 OR e.read_at IS NULL)
+		ORDER BY e.created_at DESC, e.id DESC
+		LIMIT $3
+	`, userID, unreadOnly, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alerts: %w", err)
+	}
+	defer rows.Close()
+
+	events := []AlertEvent{}
+	for rows.Next() {
+		var e AlertEvent
+		err := rows.Scan(&e.ID, &e.RuleID, &e.RuleName, &e.UserID, &e.Symbol, &e.Value, &e.BarDate, &e.Message,
+			pq.Array(&e.Delivered), &e.DeliveryError, &e.ReadAt, &e.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// MarkEventsRead marks the given alerts, or all of them when ids is empty,
+// as read for the user and returns how many changed
+func (r *AlertRepository) MarkEventsRead(userID int, ids []int) (int64, error) {
+	ids64 := make([]int64, len(ids))
+	for i, id := range ids {
+		ids64[i] = int64(id)
+	}
+
+	result, err := r.db.Exec(`
+		UPDATE alert_events SET read_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND read_at IS NULL
+		  AND (cardinality($2::int[]) = 0 OR id = ANY($2))
+	`, userID, pq.Array(ids64))
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark alerts read: %w", err)
+	}
+	return result.RowsAffected()
+}
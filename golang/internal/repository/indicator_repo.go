@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type IndicatorRepository struct {
+	db *sql.DB
+}
+
+// StockIntraDayIndicators holds the indicators computed for one intraday bar.
+// Nil values were not available for that bar.
+type StockIntraDayIndicators struct {
+	Symbol         string    `json:"symbol"`
+	Date           time.Time `json:"date"`
+	RVOL           *float64  `json:"rvol"`
+	PriceChangePct *float64  `json:"price_change_pct"`
+	RSI            *float64  `json:"rsi"`
+	VolumeSpike    *bool     `json:"volume_spike"`
+	ATR            *float64  `json:"atr"`
+	OBV            *int64    `json:"obv"`
+	SMA            *float64  `json:"sma"`
+	EMA            *float64  `json:"ema"`
+	MACD           *float64  `json:"macd"`
+	MACDSignal     *float64  `json:"macd_signal"`
+	MACDHist       *float64  `json:"macd_hist"`
+	BBUpper        *float64  `json:"bb_upper"`
+	BBMiddle       *float64  `json:"bb_middle"`
+	BBLower        *float64  `json:"bb_lower"`
+	VWAP           *float64  `json:"vwap"`
+}
+
+const indicatorColumns = `symbol, date, rvol, price_change_pct, rsi, volume_spike, atr, obv,
+	sma, ema, macd, macd_signal, macd_hist, bb_upper, bb_middle, bb_lower, vwap`
+
+func NewIndicatorRepository(db *sql.DB) *IndicatorRepository {
+	return &IndicatorRepository{db: db}
+}
+
+func (ind *StockIntraDayIndicators) scanTargets() []interface{} {
+	return []interface{}{
+		&ind.Symbol, &ind.Date, &ind.RVOL, &ind.PriceChangePct, &ind.RSI, &ind.VolumeSpike, &ind.ATR, &ind.OBV,
+		&ind.SMA, &ind.EMA, &ind.MACD, &ind.MACDSignal, &ind.MACDHist, &ind.BBUpper, &ind.BBMiddle, &ind.BBLower, &ind.VWAP,
+	}
+}
+
+// GetLatestIndicators returns the most recent indicator row for a symbol, or
+// nil when none has been computed yet
+func (r *IndicatorRepository) GetLatestIndicators(symbol string) (*StockIntraDayIndicators, error) {
+	query := `SELECT ` + indicatorColumns + `
+		FROM stocks_intraday_indicators
+		WHERE symbol = $1
+		ORDER BY date DESC
+		LIMIT 1`
+
+	var ind StockIntraDayIndicators
+	err := r.db.QueryRow(query, symbol).Scan(ind.scanTargets()...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest indicators for %s: %w", symbol, err)
+	}
+	return &ind, nil
+}
+
+// GetIndicators gets indicator rows for a symbol within a date range, oldest first
+func (r *IndicatorRepository) GetIndicators(symbol string, startDate, endDate time.Time) ([]StockIntraDayIndicators, error) {
+	query := `SELECT ` + indicatorColumns + `
+		FROM stocks_intraday_indicators
+		WHERE symbol = $1 AND date BETWEEN $2 AND $3
+		ORDER BY date ASC`
+
+	rows, err := r.db.Query(query, symbol, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query indicators: %w", err)
+	}
+	defer rows.Close()
+
+	var data []StockIntraDayIndicators
+	for rows.Next() {
+		var ind StockIntraDayIndicators
+		if err := rows.Scan(ind.scanTargets()...); err != nil {
+			return nil, fmt.Errorf("failed to scan indicators: %w", err)
+		}
+		data = append(data, ind)
+	}
+	return data, rows.Err()
+}
+
+// StoreIndicatorsBatch upserts indicator rows through a staging table, the same
+// way StoreStockDataBatch stores bars. Existing rows are overwritten when any
+// value changed.
+func (r *IndicatorRepository) StoreIndicatorsBatch(data []StockIntraDayIndicators) (*UpsertCounts, error) {
+	counts := &UpsertCounts{}
+	if len(data) == 0 {
+		return counts, nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		CREATE TEMP TABLE stocks_intraday_indicators_staging ON COMMIT DROP AS
+		SELECT ` + indicatorColumns + ` FROM stocks_intraday_indicators WITH NO DATA
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging table: %w", err)
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("stocks_intraday_indicators_staging",
+		"symbol", "date", "rvol", "price_change_pct", "rsi", "volume_spike", "atr", "obv",
+		"sma", "ema", "macd", "macd_signal", "macd_hist", "bb_upper", "bb_middle", "bb_lower", "vwap"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare copy: %w", err)
+	}
+	for _, d := range data {
+		_, err := stmt.Exec(d.Symbol, d.Date, d.RVOL, d.PriceChangePct, d.RSI, d.VolumeSpike, d.ATR, d.OBV,
+			d.SMA, d.EMA, d.MACD, d.MACDSignal, d.MACDHist, d.BBUpper, d.BBMiddle, d.BBLower, d.VWAP)
+		if err != nil {
+			stmt.Close()
+			return nil, fmt.Errorf("failed to copy indicators for %s on %s: %w", d.Symbol, d.Date, err)
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return nil, fmt.Errorf("failed to flush copy: %w", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close copy: %w", err)
+	}
+
+	// xmax is 0 for freshly inserted rows and set for rows updated by ON CONFLICT
+	err = tx.QueryRow(`
+		WITH merged AS (
+			INSERT INTO stocks_intraday_indicators (` + indicatorColumns + `)
+			SELECT DISTINCT ON (symbol, date) ` + indicatorColumns + `
+			FROM stocks_intraday_indicators_staging
+			ORDER BY symbol, date
+			ON CONFLICT (symbol, date) DO UPDATE SET
+				rvol = EXCLUDED.rvol,
+				price_change_pct = EXCLUDED.price_change_pct,
+				rsi = EXCLUDED.rsi,
+				volume_spike = EXCLUDED.volume_spike,
+				atr = EXCLUDED.atr,
+				obv = EXCLUDED.obv,
+				sma = EXCLUDED.sma,
+				ema = EXCLUDED.ema,
+				macd = EXCLUDED.macd,
+				macd_signal = EXCLUDED.macd_signal,
+				macd_hist = EXCLUDED.macd_hist,
+				bb_upper = EXCLUDED.bb_upper,
+				bb_middle = EXCLUDED.bb_middle,
+				bb_lower = EXCLUDED.bb_lower,
+				vwap = EXCLUDED.vwap
+			WHERE
+				(stocks_intraday_indicators.rvol, stocks_intraday_indicators.price_change_pct,
+				 stocks_intraday_indicators.rsi, stocks_intraday_indicators.volume_spike,
+				 stocks_intraday_indicators.atr, stocks_intraday_indicators.obv,
+				 stocks_intraday_indicators.sma, stocks_intraday_indicators.ema,
+				 stocks_intraday_indicators.macd, stocks_intraday_indicators.macd_signal,
+				 stocks_intraday_indicators.macd_hist, stocks_intraday_indicators.bb_upper,
+				 stocks_intraday_indicators.bb_middle, stocks_intraday_indicators.bb_lower,
+				 stocks_intraday_indicators.vwap)
+				IS DISTINCT FROM
+				(EXCLUDED.rvol, EXCLUDED.price_change_pct, EXCLUDED.rsi, EXCLUDED.volume_spike,
+				 EXCLUDED.atr, EXCLUDED.obv, EXCLUDED.sma, EXCLUDED.ema, EXCLUDED.macd,
+				 EXCLUDED.macd_signal, EXCLUDED.macd_hist, EXCLUDED.bb_upper, EXCLUDED.bb_middle,
+				 EXCLUDED.bb_lower, EXCLUDED.vwap)
+			RETURNING (xmax = 0) AS inserted
+		)
+		SELECT COUNT(*) FILTER (WHERE inserted), COUNT(*) FILTER (WHERE NOT inserted)
+		FROM merged
+	`).Scan(&counts.Inserted, &counts.Updated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge staged indicators: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit indicators: %w", err)
+	}
+
+	counts.Unchanged = len(data) - counts.Inserted - counts.Updated
+	return counts, nil
+}
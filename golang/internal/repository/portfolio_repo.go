@@ -0,0 +1,391 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type PortfolioRepository struct {
+	db *sql.DB
+}
+
+// querier runs queries on either the database or a transaction
+type querier interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+	Query(string, ...interface{}) (*sql.Rows, error)
+	QueryRow(string, ...interface{}) *sql.Row
+}
+
+// TradeType is the kind of portfolio ledger entry
+type TradeType string
+
+const (
+	TradeBuy      TradeType = "buy"
+	TradeSell     TradeType = "sell"
+	TradeDividend TradeType = "dividend"
+	TradeSplit    TradeType = "split"
+)
+
+// PortfolioAccount is a brokerage account owned by a user
+type PortfolioAccount struct {
+	ID       int    `json:"id"`
+	UserID   int    `json:"user_id"`
+	Name     string `json:"name"`
+	Broker   string `json:"broker"`
+	Currency string `json:"currency"`
+	// LotMethod is how sells are matched to lots unless the sell overrides it
+	LotMethod string    `json:"lot_method"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LotSelection closes Quantity shares of the lot opened by buy BuyTradeID
+type LotSelection struct {
+	BuyTradeID int     `json:"buy_trade_id"`
+	Quantity   float64 `json:"quantity"`
+}
+
+// Trade is one entry in an account's ledger. Quantity and Price apply to buys
+// and sells, Amount to dividends and SplitRatio to splits. LotMethod and Lots
+// only apply to sells.
+type Trade struct {
+	ID         int            `json:"id"`
+	AccountID  int            `json:"account_id"`
+	Symbol     string         `json:"symbol"`
+	Type       TradeType      `json:"type"`
+	TradeDate  time.Time      `json:"trade_date"`
+	Quantity   float64        `json:"quantity"`
+	Price      float64        `json:"price"`
+	Fees       float64        `json:"fees"`
+	Amount     float64        `json:"amount"`
+	SplitRatio float64        `json:"split_ratio"`
+	Notes      string         `json:"notes"`
+	LotMethod  string         `json:"lot_method,omitempty"`
+	Lots       []LotSelection `json:"lots,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+func NewPortfolioRepository(db *sql.DB) *PortfolioRepository {
+	return &PortfolioRepository{db: db}
+}
+
+// CreateAccount stores a new account for the user
+func (r *PortfolioRepository) CreateAccount(account *PortfolioAccount) error {
+	err := r.db.QueryRow(`
+		INSERT INTO portfolio_accounts (user_id, name, broker, currency, lot_method)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+		RETURNING id, created_at, updated_at
+	`, account.UserID, account.Name, account.Broker, account.Currency, account.LotMethod).Scan(&account.ID, &account.CreatedAt, &account.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create account %q: %w", account.Name, err)
+	}
+	return nil
+}
+
+// GetAccounts lists the user's accounts by name
+func (r *PortfolioRepository) GetAccounts(userID int) ([]PortfolioAccount, error) {
+	return getAccounts(r.db, userID)
+}
+
+func getAccounts(q querier, userID int) ([]PortfolioAccount, error) {
+	rows, err := q.Query(`
+		SELECT id, user_id, name, COALESCE(broker, ''), currency, lot_method, created_at, updated_at
+		FROM portfolio_accounts
+		WHERE user_id = $1
+		ORDER BY name
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query accounts: %w", err)
+	}
+	defer rows.Close()
+
+	accounts := []PortfolioAccount{}
+	for rows.Next() {
+		var a PortfolioAccount
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Name, &a.Broker, &a.Currency, &a.LotMethod, &a.CreatedAt, &a.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan account: %w", err)
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// GetAccount returns one of the user's accounts, or nil when they have no account with that id
+func (r *PortfolioRepository) GetAccount(userID, id int) (*PortfolioAccount, error) {
+	var a PortfolioAccount
+	err := r.db.QueryRow(`
+		SELECT id, user_id, name, COALESCE(broker, ''), currency, lot_method, created_at, updated_at
+		FROM portfolio_accounts
+		WHERE user_id = $1 AND id = $2
+	`, userID, id).Scan(&a.ID, &a.UserID, &a.Name, &a.Broker, &a.Currency, &a.LotMethod, &a.CreatedAt, &a.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account %d: %w", id, err)
+	}
+	return &a, nil
+}
+
+// DeleteAccount deletes one of the user's accounts and its trades, reporting whether it existed
+func (r *PortfolioRepository) DeleteAccount(userID, id int) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM portfolio_accounts WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete account %d: %w", id, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+const tradeColumns = `t.id, t.account_id, t.symbol, t.trade_type, t.trade_date, t.quantity, t.price,
+	t.fees, t.amount, t.split_ratio, COALESCE(t.notes, ''), COALESCE(t.lot_method, ''), t.created_at, t.updated_at`
+
+func scanTrade(row interface{ Scan(...interface{}) error }) (*Trade, error) {
+	var t Trade
+	err := row.Scan(&t.ID, &t.AccountID, &t.Symbol, &t.Type, &t.TradeDate, &t.Quantity, &t.Price,
+		&t.Fees, &t.Amount, &t.SplitRatio, &t.Notes, &t.LotMethod, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetTrades lists the user's trades oldest first, optionally limited to one
+// account (accountID > 0) and one symbol
+func (r *PortfolioRepository) GetTrades(userID, accountID int, symbol string) ([]Trade, error) {
+	return getTrades(r.db, userID, accountID, symbol)
+}
+
+func getTrades(q querier, userID, accountID int, symbol string) ([]Trade, error) {
+	rows, err := q.Query(`
+		SELECT `+tradeColumns+`
+		FROM portfolio_trades t
+		JOIN portfolio_accounts a ON a.id = t.account_id
+		WHERE a.user_id = $1
+		  AND ($2 = 0 OR t.account_id = $2)
+		  AND ($3 = '' OR t.symbol = $3)
+		ORDER BY t.trade_date, t.id
+	`, userID, accountID, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trades: %w", err)
+	}
+	defer rows.Close()
+
+	trades := []Trade{}
+	for rows.Next() {
+		t, err := scanTrade(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trade: %w", err)
+		}
+		trades = append(trades, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := attachLotSelections(q, trades); err != nil {
+		return nil, err
+	}
+	return trades, nil
+}
+
+// attachLotSelections loads the lots chosen by specific-ID sells
+func attachLotSelections(q querier, trades []Trade) error {
+	index := make(map[int]int)
+	var sellIDs []int64
+	for i, t := range trades {
+		if t.Type == TradeSell {
+			index[t.ID] = i
+			sellIDs = append(sellIDs, int64(t.ID))
+		}
+	}
+	if len(sellIDs) == 0 {
+		return nil
+	}
+
+	rows, err := q.Query(`
+		SELECT sell_trade_id, buy_trade_id, quantity
+		FROM portfolio_lot_selections
+		WHERE sell_trade_id = ANY($1)
+		ORDER BY sell_trade_id, buy_trade_id
+	`, pq.Array(sellIDs))
+	if err != nil {
+		return fmt.Errorf("failed to query lot selections: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sellID int
+		var selection LotSelection
+		if err := rows.Scan(&sellID, &selection.BuyTradeID, &selection.Quantity); err != nil {
+			return fmt.Errorf("failed to scan lot selection: %w", err)
+		}
+		t := &trades[index[sellID]]
+		t.Lots = append(t.Lots, selection)
+	}
+	return rows.Err()
+}
+
+// replaceLotSelections stores the lots a sell closes, replacing any earlier selection
+func replaceLotSelections(tx *sql.Tx, t *Trade) error {
+	if _, err := tx.Exec(`DELETE FROM portfolio_lot_selections WHERE sell_trade_id = $1`, t.ID); err != nil {
+		return fmt.Errorf("failed to clear lot selections for trade %d: %w", t.ID, err)
+	}
+	for _, selection := range t.Lots {
+		_, err := tx.Exec(`
+			INSERT INTO portfolio_lot_selections (sell_trade_id, buy_trade_id, quantity)
+			VALUES ($1, $2, $3)
+		`, t.ID, selection.BuyTradeID, selection.Quantity)
+		if err != nil {
+			return fmt.Errorf("failed to store lot selection for trade %d: %w", t.ID, err)
+		}
+	}
+	return nil
+}
+
+// LedgerTx is a transaction holding the lock on one user's ledger. Edits made
+// through it are checked against the ledger as it stands, and no other edit
+// can change the ledger between the check and the write.
+type LedgerTx struct {
+	tx     *sql.Tx
+	userID int
+}
+
+// WithLedgerLock runs fn in a transaction holding the user's ledger lock,
+// committing when fn succeeds. fn's error is returned unchanged.
+func (r *PortfolioRepository) WithLedgerLock(userID int, fn func(l *LedgerTx) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialise edits to the user's trades across requests and processes
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, fmt.Sprintf("portfolio_ledger:%d", userID)); err != nil {
+		return fmt.Errorf("failed to lock ledger for user %d: %w", userID, err)
+	}
+
+	if err := fn(&LedgerTx{tx: tx, userID: userID}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit ledger changes: %w", err)
+	}
+	return nil
+}
+
+// GetAccounts lists the user's accounts by name
+func (l *LedgerTx) GetAccounts() ([]PortfolioAccount, error) {
+	return getAccounts(l.tx, l.userID)
+}
+
+// GetTrades lists the user's trades oldest first, optionally limited to one
+// account (accountID > 0) and one symbol
+func (l *LedgerTx) GetTrades(accountID int, symbol string) ([]Trade, error) {
+	return getTrades(l.tx, l.userID, accountID, symbol)
+}
+
+// GetTrade returns one of the user's trades, or nil when they have no trade with that id
+func (l *LedgerTx) GetTrade(id int) (*Trade, error) {
+	t, err := scanTrade(l.tx.QueryRow(`
+		SELECT `+tradeColumns+`
+		FROM portfolio_trades t
+		JOIN portfolio_accounts a ON a.id = t.account_id
+		WHERE a.user_id = $1 AND t.id = $2
+	`, l.userID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trade %d: %w", id, err)
+	}
+
+	trades := []Trade{*t}
+	if err := attachLotSelections(l.tx, trades); err != nil {
+		return nil, err
+	}
+	return &trades[0], nil
+}
+
+// CreateTrades stores trades in the user's accounts, reporting false when any
+// account doesn't belong to them. Nothing is stored unless the transaction commits.
+func (l *LedgerTx) CreateTrades(trades []Trade) (bool, error) {
+	for i := range trades {
+		created, err := l.insertTrade(&trades[i])
+		if err != nil || !created {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (l *LedgerTx) insertTrade(t *Trade) (bool, error) {
+	err := l.tx.QueryRow(`
+		INSERT INTO portfolio_trades (
+			account_id, symbol, trade_type, trade_date, quantity, price,
+			fees, amount, split_ratio, notes, lot_method
+		)
+		SELECT a.id, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), NULLIF($12, '')
+		FROM portfolio_accounts a
+		WHERE a.user_id = $1 AND a.id = $2
+		RETURNING id, created_at, updated_at
+	`, l.userID, t.AccountID, t.Symbol, t.Type, t.TradeDate, t.Quantity, t.Price,
+		t.Fees, t.Amount, t.SplitRatio, t.Notes, t.LotMethod).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create trade: %w", err)
+	}
+
+	if err := replaceLotSelections(l.tx, t); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UpdateTrade replaces one of the user's trades, reporting false when the
+// trade or its new account doesn't belong to them
+func (l *LedgerTx) UpdateTrade(t *Trade) (bool, error) {
+	err := l.tx.QueryRow(`
+		UPDATE portfolio_trades t SET
+			account_id = $3, symbol = $4, trade_type = $5, trade_date = $6,
+			quantity = $7, price = $8, fees = $9, amount = $10,
+			split_ratio = $11, notes = NULLIF($12, ''), lot_method = NULLIF($13, '')
+		FROM portfolio_accounts a
+		WHERE t.id = $2 AND a.id = t.account_id AND a.user_id = $1
+		  AND EXISTS (SELECT 1 FROM portfolio_accounts n WHERE n.id = $3 AND n.user_id = $1)
+		RETURNING t.created_at, t.updated_at
+	`, l.userID, t.ID, t.AccountID, t.Symbol, t.Type, t.TradeDate,
+		t.Quantity, t.Price, t.Fees, t.Amount, t.SplitRatio, t.Notes, t.LotMethod).Scan(&t.CreatedAt, &t.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update trade %d: %w", t.ID, err)
+	}
+
+	if err := replaceLotSelections(l.tx, t); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteTrade deletes one of the user's trades, reporting whether it existed
+func (l *LedgerTx) DeleteTrade(id int) (bool, error) {
+	result, err := l.tx.Exec(`
+		DELETE FROM portfolio_trades t
+		USING portfolio_accounts a
+		WHERE t.id = $2 AND a.id = t.account_id AND a.user_id = $1
+	`, l.userID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete trade %d: %w", id, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type QuotaRepository struct {
+	db *sql.DB
+}
+
+// ProviderCallCount holds the number of calls made with one provider key
+type ProviderCallCount struct {
+	Provider   string
+	KeyID      string
+	LastMinute int
+	Today      int
+}
+
+func NewQuotaRepository(db *sql.DB) *QuotaRepository {
+	return &QuotaRepository{db: db}
+}
+
+// CallReservation is the outcome of ReserveCall
+type CallReservation struct {
+	// Allowed reports whether the call was recorded
+	Allowed bool
+	// LastMinute and Today count the key's calls before this one
+	LastMinute int
+	Today      int
+}
+
+// ReserveCall records a call for the provider key if it fits in the per-minute
+// and per-day budgets (0 means unlimited)
+func (r *QuotaRepository) ReserveCall(provider, keyID string, now time.Time, perMinute, perDay int) (*CallReservation, error) {
+	// called_at has no time zone, so the ledger is kept in UTC
+	now = now.UTC()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin quota transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialise reservations for the same key across processes
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, provider+":"+keyID); err != nil {
+		return nil, fmt.Errorf("failed to lock quota for %s: %w", provider, err)
+	}
+
+	res := &CallReservation{}
+	err = tx.QueryRow(`
+		SELECT
+			COUNT(*) FILTER (WHERE called_at > $3),
+			COUNT(*)
+		FROM api_call_ledger
+		WHERE provider = $1 AND key_id = $2 AND called_at >= $4
+	`, provider, keyID, now.Add(-time.Minute), startOfDay(now)).Scan(&res.LastMinute, &res.Today)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count calls for %s: %w", provider, err)
+	}
+
+	if (perMinute > 0 && res.LastMinute >= perMinute) || (perDay > 0 && res.Today >= perDay) {
+		return res, nil
+	}
+
+	_, err = tx.Exec(`INSERT INTO api_call_ledger (provider, key_id, called_at) VALUES ($1, $2, $3)`, provider, keyID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record call for %s: %w", provider, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit quota transaction: %w", err)
+	}
+	res.Allowed = true
+	return res, nil
+}
+
+// GetCallCounts returns the calls made in the last minute and today per provider key
+func (r *QuotaRepository) GetCallCounts(now time.Time) ([]ProviderCallCount, error) {
+	now = now.UTC()
+	rows, err := r.db.Query(`
+		SELECT provider, key_id,
+		       COUNT(*) FILTER (WHERE called_at > $1),
+		       COUNT(*)
+		FROM api_call_ledger
+		WHERE called_at >= $2
+		GROUP BY provider, key_id
+		ORDER BY provider, key_id
+	`, now.Add(-time.Minute), startOfDay(now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query call counts: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []ProviderCallCount
+	for rows.Next() {
+		var c ProviderCallCount
+		if err := rows.Scan(&c.Provider, &c.KeyID, &c.LastMinute, &c.Today); err != nil {
+			return nil, fmt.Errorf("failed to scan call count: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// PruneCalls deletes ledger entries older than before and returns how many were deleted
+func (r *QuotaRepository) PruneCalls(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM api_call_ledger WHERE called_at < $1`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune call ledger: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return deleted, nil
+}
+
+// startOfDay returns midnight UTC of the day containing t, when daily quotas reset
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
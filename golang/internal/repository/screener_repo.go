@@ -0,0 +1,444 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidScreen is wrapped by every error caused by a malformed screen
+var ErrInvalidScreen = errors.New("invalid screen")
+
+const (
+	defaultScreenLimit = 50
+	maxScreenLimit     = 500
+	maxScreenDepth     = 5
+	maxScreenFilters   = 50
+)
+
+type ScreenerRepository struct {
+	db *sql.DB
+}
+
+// ScreenFilter is either a single condition (Field, Op, Value) or a group of
+// filters combined with And or Or
+type ScreenFilter struct {
+	Field string          `json:"field,omitempty"`
+	Op    string          `json:"op,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+	And   []ScreenFilter  `json:"and,omitempty"`
+	Or    []ScreenFilter  `json:"or,omitempty"`
+}
+
+// ScreenSort orders results by one field
+type ScreenSort struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
+}
+
+// ScreenQuery describes one screener run
+type ScreenQuery struct {
+	Filter  *ScreenFilter `json:"filter,omitempty"`
+	Sort    []ScreenSort  `json:"sort,omitempty"`
+	Columns []string      `json:"columns,omitempty"`
+	Limit   int           `json:"limit,omitempty"`
+	Offset  int           `json:"offset,omitempty"`
+}
+
+// ScreenResult holds one page of matching symbols and the total match count
+type ScreenResult struct {
+	Columns []string                 `json:"columns"`
+	Rows    []map[string]interface{} `json:"rows"`
+	Total   int                      `json:"total"`
+	Limit   int                      `json:"limit"`
+	Offset  int                      `json:"offset"`
+}
+
+// SavedScreen is a named screen belonging to a user
+type SavedScreen struct {
+	ID        int         `json:"id"`
+	UserID    int         `json:"user_id"`
+	Name      string      `json:"name"`
+	Query     ScreenQuery `json:"query"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+type screenField struct {
+	expr    string
+	numeric bool
+}
+
+// screenFields is the whitelist of fields a screen may filter, sort or select
+// on. Only these expressions ever reach the SQL text; values are always bound
+// as parameters.
+var screenFields = map[string]screenField{
+	"symbol":            {expr: "sc.symbol"},
+	"company_name":      {expr: "COALESCE(m.company_name, sc.company_name)"},
+	"industry":          {expr: "COALESCE(m.industry, sc.industry)"},
+	"exchange":          {expr: "m.exchange"},
+	"currency":          {expr: "m.currency"},
+	"type":              {expr: "m.type"},
+	"market_cap":        {expr: "m.market_cap", numeric: true},
+	"pe_ratio":          {expr: "sc.pe_ratio", numeric: true},
+	"peg_ratio":         {expr: "sc.peg_ratio", numeric: true},
+	"price_to_book":     {expr: "sc.price_to_book", numeric: true},
+	"roe_ttm":           {expr: "sc.roe_ttm", numeric: true},
+	"revenue_5y_growth": {expr: "sc.revenue_5y_growth", numeric: true},
+	"operating_margin":  {expr: "sc.operating_margin", numeric: true},
+	"profit_margin":     {expr: "sc.profit_margin", numeric: true},
+	"dividend_yield":    {expr: "sc.dividend_yield", numeric: true},
+	"beta":              {expr: "sc.beta", numeric: true},
+	"composite_score":   {expr: "sc.composite_score", numeric: true},
+}
+
+const screenFrom = `
+	FROM stock_scorecards sc
+	LEFT JOIN stocks_metadata m ON m.symbol = sc.symbol
+`
+
+func NewScreenerRepository(db *sql.DB) *ScreenerRepository {
+	return &ScreenerRepository{db: db}
+}
+
+// screenBuilder accumulates bound parameters while translating a filter tree
+type screenBuilder struct {
+	args    []interface{}
+	filters int
+}
+
+func (b *screenBuilder) bind(v interface{}) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *screenBuilder) where(f *ScreenFilter, depth int) (string, error) {
+	if depth > maxScreenDepth {
+		return "", fmt.Errorf("%w: filters nested deeper than %d levels", ErrInvalidScreen, maxScreenDepth)
+	}
+	b.filters++
+	if b.filters > maxScreenFilters {
+		return "", fmt.Errorf("%w: more than %d filters", ErrInvalidScreen, maxScreenFilters)
+	}
+
+	isGroup := len(f.And) > 0 || len(f.Or) > 0
+	switch {
+	case isGroup && f.Field != "":
+		return "", fmt.Errorf("%w: a filter can't have both a field and and/or", ErrInvalidScreen)
+	case len(f.And) > 0 && len(f.Or) > 0:
+		return "", fmt.Errorf("%w: a filter can't have both and and or", ErrInvalidScreen)
+	case len(f.And) > 0:
+		return b.group(f.And, " AND ", depth)
+	case len(f.Or) > 0:
+		return b.group(f.Or, " OR ", depth)
+	}
+
+	return b.condition(f)
+}
+
+func (b *screenBuilder) group(filters []ScreenFilter, join string, depth int) (string, error) {
+	parts := make([]string, 0, len(filters))
+	for i := range filters {
+		part, err := b.where(&filters[i], depth+1)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
+	}
+	return "(" + strings.Join(parts, join) + ")", nil
+}
+
+func (b *screenBuilder) condition(f *ScreenFilter) (string, error) {
+	field, ok := screenFields[f.Field]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown field %q", ErrInvalidScreen, f.Field)
+	}
+
+	op := strings.ToLower(strings.Join(strings.Fields(f.Op), " "))
+	switch op {
+	case "is null":
+		return field.expr + " IS NULL", nil
+	case "is not null":
+		return field.expr + " IS NOT NULL", nil
+	case "=", "!=", "<", "<=", ">", ">=":
+		v, err := decodeScreenValue(f, field)
+		if err != nil {
+			return "", err
+		}
+		sqlOp := op
+		if op == "!=" {
+			sqlOp = "<>"
+		}
+		return fmt.Sprintf("%s %s %s", field.expr, sqlOp, b.bind(v)), nil
+	case "in", "not in":
+		var raw []json.RawMessage
+		if err := json.Unmarshal(f.Value, &raw); err != nil || len(raw) == 0 {
+			return "", fmt.Errorf("%w: %s %s needs a non-empty list", ErrInvalidScreen, f.Field, op)
+		}
+		params := make([]string, 0, len(raw))
+		for _, item := range raw {
+			v, err := decodeScreenValue(&ScreenFilter{Field: f.Field, Value: item}, field)
+			if err != nil {
+				return "", err
+			}
+			params = append(params, b.bind(v))
+		}
+		return fmt.Sprintf("%s %s (%s)", field.expr, strings.ToUpper(op), strings.Join(params, ", ")), nil
+	case "between":
+		var bounds []json.RawMessage
+		if err := json.Unmarshal(f.Value, &bounds); err != nil || len(bounds) != 2 {
+			return "", fmt.Errorf("%w: %s between needs [low, high]", ErrInvalidScreen, f.Field)
+		}
+		low, err := decodeScreenValue(&ScreenFilter{Field: f.Field, Value: bounds[0]}, field)
+		if err != nil {
+			return "", err
+		}
+		high, err := decodeScreenValue(&ScreenFilter{Field: f.Field, Value: bounds[1]}, field)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s BETWEEN %s AND %s", field.expr, b.bind(low), b.bind(high)), nil
+	}
+
+	return "", fmt.Errorf("%w: unknown operator %q", ErrInvalidScreen, f.Op)
+}
+
+// decodeScreenValue checks that a filter value matches its field's type
+func decodeScreenValue(f *ScreenFilter, field screenField) (interface{}, error) {
+	if field.numeric {
+		var n float64
+		if err := json.Unmarshal(f.Value, &n); err != nil {
+			return nil, fmt.Errorf("%w: %s needs a numeric value", ErrInvalidScreen, f.Field)
+		}
+		return n, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(f.Value, &s); err != nil {
+		return nil, fmt.Errorf("%w: %s needs a string value", ErrInvalidScreen, f.Field)
+	}
+	return s, nil
+}
+
+// normalise fills in defaults and validates columns, sort keys and paging
+func (q *ScreenQuery) normalise() error {
+	if q.Limit <= 0 {
+		q.Limit = defaultScreenLimit
+	}
+	if q.Limit > maxScreenLimit {
+		return fmt.Errorf("%w: limit can't exceed %d", ErrInvalidScreen, maxScreenLimit)
+	}
+	if q.Offset < 0 {
+		return fmt.Errorf("%w: offset can't be negative", ErrInvalidScreen)
+	}
+
+	if len(q.Columns) == 0 {
+		q.Columns = []string{"company_name", "industry", "composite_score"}
+	}
+	for _, c := range q.Columns {
+		if _, ok := screenFields[c]; !ok {
+			return fmt.Errorf("%w: unknown column %q", ErrInvalidScreen, c)
+		}
+	}
+	for _, s := range q.Sort {
+		if _, ok := screenFields[s.Field]; !ok {
+			return fmt.Errorf("%w: unknown sort field %q", ErrInvalidScreen, s.Field)
+		}
+	}
+	return nil
+}
+
+// ValidateScreen checks a screen without running it
+func ValidateScreen(q ScreenQuery) error {
+	if err := q.normalise(); err != nil {
+		return err
+	}
+	if q.Filter != nil {
+		var b screenBuilder
+		if _, err := b.where(q.Filter, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Screen returns the symbols matching q with the requested columns
+func (r *ScreenerRepository) Screen(q ScreenQuery) (*ScreenResult, error) {
+	if err := q.normalise(); err != nil {
+		return nil, err
+	}
+
+	var b screenBuilder
+	where := ""
+	if q.Filter != nil {
+		cond, err := b.where(q.Filter, 1)
+		if err != nil {
+			return nil, err
+		}
+		where = " WHERE " + cond
+	}
+
+	// symbol is always returned, and breaks ties so paging is stable
+	columns := []string{"symbol"}
+	for _, c := range q.Columns {
+		if c != "symbol" {
+			columns = append(columns, c)
+		}
+	}
+	selects := make([]string, len(columns))
+	for i, c := range columns {
+		selects[i] = screenFields[c].expr
+	}
+
+	orderBy := make([]string, 0, len(q.Sort)+1)
+	for _, s := range q.Sort {
+		direction := "ASC NULLS LAST"
+		if s.Desc {
+			direction = "DESC NULLS LAST"
+		}
+		orderBy = append(orderBy, screenFields[s.Field].expr+" "+direction)
+	}
+	orderBy = append(orderBy, "sc.symbol ASC")
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*)`+screenFrom+where, b.args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count screen results: %w", err)
+	}
+
+	limit := b.bind(q.Limit)
+	offset := b.bind(q.Offset)
+	query := `SELECT ` + strings.Join(selects, ", ") + screenFrom + where +
+		` ORDER BY ` + strings.Join(orderBy, ", ") + ` LIMIT ` + limit + ` OFFSET ` + offset
+
+	rows, err := r.db.Query(query, b.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run screen: %w", err)
+	}
+	defer rows.Close()
+
+	result := &ScreenResult{Columns: columns, Rows: []map[string]interface{}{}, Total: total, Limit: q.Limit, Offset: q.Offset}
+	for rows.Next() {
+		targets := make([]interface{}, len(columns))
+		for i, c := range columns {
+			if screenFields[c].numeric {
+				targets[i] = new(sql.NullFloat64)
+			} else {
+				targets[i] = new(sql.NullString)
+			}
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return nil, fmt.Errorf("failed to scan screen row: %w", err)
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, c := range columns {
+			switch v := targets[i].(type) {
+			case *sql.NullFloat64:
+				if v.Valid {
+					row[c] = v.Float64
+				} else {
+					row[c] = nil
+				}
+			case *sql.NullString:
+				if v.Valid {
+					row[c] = v.String
+				} else {
+					row[c] = nil
+				}
+			}
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result, rows.Err()
+}
+
+// SaveScreen creates or replaces a user's screen with the same name
+func (r *ScreenerRepository) SaveScreen(userID int, name string, q ScreenQuery) (*SavedScreen, error) {
+	queryJson, err := json.Marshal(q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal screen: %w", err)
+	}
+
+	screen := &SavedScreen{UserID: userID, Name: name, Query: q}
+	err = r.db.QueryRow(`
+		INSERT INTO saved_screens (user_id, name, query)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, name) DO UPDATE SET
+			query = EXCLUDED.query,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING id, created_at, updated_at
+	`, userID, name, queryJson).Scan(&screen.ID, &screen.CreatedAt, &screen.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save screen %q: %w", name, err)
+	}
+	return screen, nil
+}
+
+// GetSavedScreens lists a user's screens by name
+func (r *ScreenerRepository) GetSavedScreens(userID int) ([]SavedScreen, error) {
+	rows, err := r.db.Query(`
+		SELECT id, user_id, name, query, created_at, updated_at
+		FROM saved_screens
+		WHERE user_id = $1
+		ORDER BY name
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query saved screens: %w", err)
+	}
+	defer rows.Close()
+
+	screens := []SavedScreen{}
+	for rows.Next() {
+		screen, err := scanSavedScreen(rows)
+		if err != nil {
+			return nil, err
+		}
+		screens = append(screens, *screen)
+	}
+	return screens, rows.Err()
+}
+
+// GetSavedScreen returns one of a user's screens, or nil when the user has no
+// screen with that id
+func (r *ScreenerRepository) GetSavedScreen(userID, id int) (*SavedScreen, error) {
+	row := r.db.QueryRow(`
+		SELECT id, user_id, name, query, created_at, updated_at
+		FROM saved_screens
+		WHERE user_id = $1 AND id = $2
+	`, userID, id)
+
+	screen, err := scanSavedScreen(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return screen, err
+}
+
+// DeleteSavedScreen deletes one of a user's screens, reporting whether it existed
+func (r *ScreenerRepository) DeleteSavedScreen(userID, id int) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM saved_screens WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete saved screen %d: %w", id, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+func scanSavedScreen(row interface{ Scan(...interface{}) error }) (*SavedScreen, error) {
+	var screen SavedScreen
+	var queryJson []byte
+	err := row.Scan(&screen.ID, &screen.UserID, &screen.Name, &queryJson, &screen.CreatedAt, &screen.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan saved screen: %w", err)
+	}
+	if err := json.Unmarshal(queryJson, &screen.Query); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal saved screen %d: %w", screen.ID, err)
+	}
+	return &screen, nil
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func filter(t *testing.T, raw string) *ScreenFilter {
+	t.Helper()
+	var f ScreenFilter
+	if err := json.Unmarshal([]byte(raw), &f); err != nil {
+		t.Fatalf("bad filter %s: %v", raw, err)
+	}
+	return &f
+}
+
+func TestScreenBuilderWhere(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   string
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{"equals", `{"field": "exchange", "op": "=", "value": "NASDAQ"}`, "m.exchange = $1", []interface{}{"NASDAQ"}},
+		{"not equals", `{"field": "symbol", "op": "!=", "value": "AAPL"}`, "sc.symbol <> $1", []interface{}{"AAPL"}},
+		{"less than", `{"field": "pe_ratio", "op": "<", "value": 15}`, "sc.pe_ratio < $1", []interface{}{15.0}},
+		{"at most", `{"field": "beta", "op": "<=", "value": 1.2}`, "sc.beta <= $1", []interface{}{1.2}},
+		{"greater than", `{"field": "market_cap", "op": ">", "value": 1e9}`, "m.market_cap > $1", []interface{}{1e9}},
+		{"at least", `{"field": "roe_ttm", "op": ">=", "value": 0.15}`, "sc.roe_ttm >= $1", []interface{}{0.15}},
+		{"is null", `{"field": "dividend_yield", "op": "is null"}`, "sc.dividend_yield IS NULL", nil},
+		{"is not null with odd spacing", `{"field": "industry", "op": " IS  not NULL "}`, "COALESCE(m.industry, sc.industry) IS NOT NULL", nil},
+		{"in", `{"field": "industry", "op": "in", "value": ["Software", "Semiconductors"]}`,
+			"COALESCE(m.industry, sc.industry) IN ($1, $2)", []interface{}{"Software", "Semiconductors"}},
+		{"not in", `{"field": "composite_score", "op": "not in", "value": [0, 100]}`,
+			"sc.composite_score NOT IN ($1, $2)", []interface{}{0.0, 100.0}},
+		{"between", `{"field": "peg_ratio", "op": "between", "value": [0.5, 2]}`,
+			"sc.peg_ratio BETWEEN $1 AND $2", []interface{}{0.5, 2.0}},
+		{"and group", `{"and": [{"field": "pe_ratio", "op": "<", "value": 20}, {"field": "type", "op": "=", "value": "Common Stock"}]}`,
+			"(sc.pe_ratio < $1 AND m.type = $2)", []interface{}{20.0, "Common Stock"}},
+		{"nested groups", `{"or": [
+				{"field": "symbol", "op": "=", "value": "MSFT"},
+				{"and": [
+					{"field": "profit_margin", "op": ">", "value": 0.2},
+					{"field": "currency", "op": "in", "value": ["USD", "EUR"]}
+				]}
+			]}`,
+			"(sc.symbol = $1 OR (sc.profit_margin > $2 AND m.currency IN ($3, $4)))",
+			[]interface{}{"MSFT", 0.2, "USD", "EUR"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b screenBuilder
+			sql, err := b.where(filter(t, tt.filter), 1)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if sql != tt.wantSQL {
+				t.Errorf("sql = %q, want %q", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(b.args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", b.args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+// nestedFilter wraps a condition in depth-1 and groups
+func nestedFilter(depth int) string {
+	f := `{"field": "beta", "op": "<", "value": 1}`
+	for i := 1; i < depth; i++ {
+		f = `{"and": [` + f + `]}`
+	}
+	return f
+}
+
+func TestValidateScreen(t *testing.T) {
+	many := make([]string, maxScreenFilters)
+	for i := range many {
+		many[i] = `{"field": "beta", "op": "<", "value": 1}`
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		wantErr string
+	}{
+		{name: "defaults", query: `{}`},
+		{name: "full screen", query: `{"filter": {"field": "pe_ratio", "op": "<", "value": 20},
+			"sort": [{"field": "composite_score", "desc": true}], "columns": ["pe_ratio", "beta"], "limit": 500}`},
+		{name: "deepest allowed nesting", query: `{"filter": ` + nestedFilter(maxScreenDepth) + `}`},
+		{name: "too deep", query: `{"filter": ` + nestedFilter(maxScreenDepth+1) + `}`, wantErr: "nested deeper"},
+		{name: "too many filters", query: `{"filter": {"or": [` + strings.Join(many, ",") + `]}}`, wantErr: "more than"},
+		{name: "unknown filter field", query: `{"filter": {"field": "password", "op": "=", "value": "x"}}`, wantErr: "unknown field"},
+		{name: "unknown operator", query: `{"filter": {"field": "beta", "op": "like", "value": "1"}}`, wantErr: "unknown operator"},
+		{name: "string for a numeric field", query: `{"filter": {"field": "beta", "op": ">", "value": "1"}}`, wantErr: "numeric value"},
+		{name: "number for a text field", query: `{"filter": {"field": "exchange", "op": "=", "value": 1}}`, wantErr: "string value"},
+		{name: "mixed list", query: `{"filter": {"field": "beta", "op": "in", "value": [1, "2"]}}`, wantErr: "numeric value"},
+		{name: "empty list", query: `{"filter": {"field": "exchange", "op": "in", "value": []}}`, wantErr: "non-empty list"},
+		{name: "between one bound", query: `{"filter": {"field": "beta", "op": "between", "value": [1]}}`, wantErr: "[low, high]"},
+		{name: "field and group", query: `{"filter": {"field": "beta", "op": "<", "value": 1, "and": [{"field": "beta", "op": ">", "value": 0}]}}`, wantErr: "both a field"},
+		{name: "and with or", query: `{"filter": {"and": [{"field": "beta", "op": "<", "value": 1}], "or": [{"field": "beta", "op": ">", "value": 0}]}}`, wantErr: "both and and or"},
+		{name: "unknown column", query: `{"columns": ["secret"]}`, wantErr: "unknown column"},
+		{name: "unknown sort key", query: `{"sort": [{"field": "created_at"}]}`, wantErr: "unknown sort field"},
+		{name: "limit too large", query: `{"limit": 501}`, wantErr: "limit"},
+		{name: "negative offset", query: `{"offset": -1}`, wantErr: "offset"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var q ScreenQuery
+			if err := json.Unmarshal([]byte(tt.query), &q); err != nil {
+				t.Fatalf("bad query: %v", err)
+			}
+			err := ValidateScreen(q)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidScreen) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %s error mentioning %q", err, ErrInvalidScreen, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScreenQueryDefaults(t *testing.T) {
+	q := ScreenQuery{}
+	if err := q.normalise(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q.Limit != defaultScreenLimit {
+		t.Errorf("limit = %d, want %d", q.Limit, defaultScreenLimit)
+	}
+	if got := fmt.Sprint(q.Columns); got != "[company_name industry composite_score]" {
+		t.Errorf("columns = %s", got)
+	}
+}
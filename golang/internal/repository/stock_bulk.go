@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// UpsertCounts reports how a batch of rows was merged into its table
+type UpsertCounts struct {
+	Inserted  int `json:"inserted"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+}
+
+// Add accumulates counts from another batch
+func (c *UpsertCounts) Add(other *UpsertCounts) {
+	if other == nil {
+		return
+	}
+	c.Inserted += other.Inserted
+	c.Updated += other.Updated
+	c.Unchanged += other.Unchanged
+}
+
+type barRow struct {
+	symbol                 string
+	date                   time.Time
+	open, high, low, close float64
+	volume                 float64
+}
+
+// StoreStockDataBatch stores intraday bars in a single transaction by copying them
+// into a temporary table and merging that into stocks_intraday in one statement
+func (r *StockRepository) StoreStockDataBatch(data []StockIntraDayData) (*UpsertCounts, error) {
+	rows := make([]barRow, 0, len(data))
+	for _, d := range data {
+		rows = append(rows, barRow{d.Symbol, d.Date, d.Open, d.High, d.Low, d.Close, d.Volume})
+	}
+	return r.copyUpsertBars("stocks_intraday", rows)
+}
+
+// StoreDailyDataBatch stores daily bars the same way as StoreStockDataBatch, keeping
+// only the trading day of each date
+func (r *StockRepository) StoreDailyDataBatch(data []StockDailyData) (*UpsertCounts, error) {
+	rows := make([]barRow, 0, len(data))
+	for _, d := range data {
+		day := time.Date(d.Date.Year(), d.Date.Month(), d.Date.Day(), 0, 0, 0, 0, time.UTC)
+		rows = append(rows, barRow{d.Symbol, day, d.Open, d.High, d.Low, d.Close, d.Volume})
+	}
+	return r.copyUpsertBars("stocks_daily", rows)
+}
+
+// copyUpsertBars merges rows into table, which must have the stocks_intraday bar
+// columns and a unique (symbol, date) constraint. table is never user input.
+func (r *StockRepository) copyUpsertBars(table string, rows []barRow) (*UpsertCounts, error) {
+	counts := &UpsertCounts{}
+
+	// Providers occasionally repeat a bar across pages; keep the last one so the
+	// merge never touches the same row twice
+	type key struct {
+		symbol string
+		date   time.Time
+	}
+	index := make(map[key]int, len(rows))
+	unique := make([]barRow, 0, len(rows))
+	for _, row := range rows {
+		k := key{row.symbol, row.date.UTC()}
+		if i, ok := index[k]; ok {
+			unique[i] = row
+			continue
+		}
+		index[k] = len(unique)
+		unique = append(unique, row)
+	}
+	if len(unique) == 0 {
+		return counts, nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	staging := table + "_staging"
+	_, err = tx.Exec(fmt.Sprintf(`
+		CREATE TEMP TABLE %s ON COMMIT DROP AS
+		SELECT symbol, date, open, high, low, close, volume FROM %s WITH NO DATA
+	`, staging, table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging table: %w", err)
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn(staging, "symbol", "date", "open", "high", "low", "close", "volume"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare copy: %w", err)
+	}
+	for _, row := range unique {
+		_, err := stmt.Exec(row.symbol, row.date, row.open, row.high, row.low, row.close, int64(math.Round(row.volume)))
+		if err != nil {
+			stmt.Close()
+			return nil, fmt.Errorf("failed to copy bar for %s on %s: %w", row.symbol, row.date, err)
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return nil, fmt.Errorf("failed to flush copy: %w", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close copy: %w", err)
+	}
+
+	// xmax is 0 for freshly inserted rows and set for rows updated by ON CONFLICT
+	err = tx.QueryRow(fmt.Sprintf(`
+		WITH merged AS (
+			INSERT INTO %[1]s (symbol, date, open, high, low, close, volume, created_at)
+			SELECT symbol, date, open, high, low, close, volume, $1
+			FROM %[2]s
+			ON CONFLICT (symbol, date) DO UPDATE SET
+				open = EXCLUDED.open,
+				high = EXCLUDED.high,
+				low = EXCLUDED.low,
+				close = EXCLUDED.close,
+				volume = EXCLUDED.volume
+			WHERE
+				%[1]s.open IS DISTINCT FROM EXCLUDED.open OR
+				%[1]s.high IS DISTINCT FROM EXCLUDED.high OR
+				%[1]s.low IS DISTINCT FROM EXCLUDED.low OR
+				%[1]s.close IS DISTINCT FROM EXCLUDED.close OR
+				%[1]s.volume IS DISTINCT FROM EXCLUDED.volume
+			RETURNING (xmax = 0) AS inserted
+		)
+		SELECT COUNT(*) FILTER (WHERE inserted), COUNT(*) FILTER (WHERE NOT inserted)
+		FROM merged
+	`, table, staging), time.Now()).Scan(&counts.Inserted, &counts.Updated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge staged bars into %s: %w", table, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit bars: %w", err)
+	}
+
+	counts.Unchanged = len(unique) - counts.Inserted - counts.Updated
+	return counts, nil
+}
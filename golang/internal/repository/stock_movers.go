@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SessionSnapshot is where a symbol stands in one trading session, with the
+// metadata used to filter market movers. Fields are nil when the data isn't
+// stored.
+type SessionSnapshot struct {
+	Symbol    string    `json:"symbol"`
+	Name      string    `json:"name"`
+	Exchange  string    `json:"exchange"`
+	Industry  string    `json:"industry"`
+	LastPrice float64   `json:"last_price"`
+	LastBarAt time.Time `json:"last_bar_at"`
+	// PreviousClose is the last close before the session
+	PreviousClose *float64 `json:"previous_close"`
+	// Volume is traded so far in the session
+	Volume float64 `json:"volume"`
+	// AverageVolume is the mean daily volume over the 20 sessions before it
+	AverageVolume *float64 `json:"average_volume"`
+}
+
+// GetLatestBarTime returns the time of the newest intraday bar of any symbol,
+// and false when there are no bars
+func (r *StockRepository) GetLatestBarTime() (time.Time, bool, error) {
+	var latest sql.NullTime
+	if err := r.db.QueryRow(`SELECT MAX(date) FROM stocks_intraday`).Scan(&latest); err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to get latest bar time: %w", err)
+	}
+	return latest.Time, latest.Valid, nil
+}
+
+// GetSessionSnapshots returns every symbol with intraday bars since
+// sessionStart, comparing against daily bars before sessionDay
+func (r *StockRepository) GetSessionSnapshots(sessionStart, sessionDay time.Time) ([]SessionSnapshot, error) {
+	rows, err := r.db.Query(`
+		WITH latest AS (
+			SELECT DISTINCT ON (symbol) symbol, date, close
+			FROM stocks_intraday
+			WHERE date >= $1
+			ORDER BY symbol, date DESC
+		), session AS (
+			SELECT symbol, SUM(volume) AS volume
+			FROM stocks_intraday
+			WHERE date >= $1
+			GROUP BY symbol
+		)
+		SELECT l.symbol, COALESCE(md.company_name, ''), COALESCE(md.exchange, ''), COALESCE(md.industry, ''),
+		       l.close, l.date, COALESCE(pd.close, pi.close), s.volume, av.volume
+		FROM latest l
+		JOIN session s ON s.symbol = l.symbol
+		LEFT JOIN LATERAL (
+			SELECT d.close FROM stocks_daily d
+			WHERE d.symbol = l.symbol AND d.date < $2
+			ORDER BY d.date DESC
+			LIMIT 1
+		) pd ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT b.close FROM stocks_intraday b
+			WHERE b.symbol = l.symbol AND b.date < $1
+			ORDER BY b.date DESC
+			LIMIT 1
+		) pi ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT AVG(recent.volume) AS volume FROM (
+				SELECT d.volume FROM stocks_daily d
+				WHERE d.symbol = l.symbol AND d.date < $2
+				ORDER BY d.date DESC
+				LIMIT 20
+			) recent
+		) av ON TRUE
+		LEFT JOIN stocks_metadata md ON md.symbol = l.symbol
+		ORDER BY l.symbol
+	`, sessionStart, sessionDay.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query session snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []SessionSnapshot
+	for rows.Next() {
+		var s SessionSnapshot
+		err := rows.Scan(&s.Symbol, &s.Name, &s.Exchange, &s.Industry,
+			&s.LastPrice, &s.LastBarAt, &s.PreviousClose, &s.Volume, &s.AverageVolume)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session snapshot: %w", err)
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
@@ -32,6 +32,17 @@ type StockIntraDayData struct {
     CreatedAt time.Time `json:"created_at"`
 }
 
+type StockDailyData struct {
+    Symbol    string    `json:"symbol"`
+    Date      time.Time `json:"date"`
+    Open      float64   `json:"open"`
+    High      float64   `json:"high"`
+    Low       float64   `json:"low"`
+    Close     float64   `json:"close"`
+    Volume    float64   `json:"volume"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
 type StockSymbol struct {
     Symbol        string    `json:"symbol"`
     BatchId		  int		`json:"batch_id"`
@@ -58,38 +69,41 @@ func NewStockRepository(db *sql.DB) *StockRepository {
     return &StockRepository{db: db}
 }
 
-// StoreStockData stores a complete stock data record
-func (r *StockRepository) StoreStockData(symbol string, date time.Time, open, high, low, close, volume float64) error {
-    log.Printf("Attempting to store data for %s on %s", symbol, date.Format("2006-01-02"))
-    
+// GetDailyData gets daily bars for a symbol within a date range, oldest first
+func (r *StockRepository) GetDailyData(symbol string, startDate, endDate time.Time) ([]StockDailyData, error) {
     query := `
-        INSERT INTO stocks_intraday (symbol, date, open, high, low, close, volume, created_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-        ON CONFLICT (symbol, date) DO UPDATE SET 
-            open = EXCLUDED.open,
-            high = EXCLUDED.high,
-            low = EXCLUDED.low,
-            close = EXCLUDED.close,
-            volume = EXCLUDED.volume,
-            updated_at = EXCLUDED.created_at
-        WHERE 
-            stocks_intraday.open IS DISTINCT FROM EXCLUDED.open OR
-            stocks_intraday.high IS DISTINCT FROM EXCLUDED.high OR
-            stocks_intraday.low IS DISTINCT FROM EXCLUDED.low OR
-            stocks_intraday.close IS DISTINCT FROM EXCLUDED.close OR
-            stocks_intraday.volume IS DISTINCT FROM EXCLUDED.volume;
+        SELECT symbol, date, open, high, low, close, volume, created_at
+        FROM stocks_daily
+        WHERE symbol = $1 AND date BETWEEN $2 AND $3
+        ORDER BY date ASC
     `
-    
-    result, err := r.db.Exec(query, symbol, date, open, high, low, close, volume, time.Now())
+
+    rows, err := r.db.Query(query, symbol, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
     if err != nil {
-        log.Printf("Database error storing data for %s on %s: %v", symbol, date.Format("2006-01-02"), err)
-        return fmt.Errorf("failed to store stock data: %w", err)
+        return nil, fmt.Errorf("failed to query daily data: %w", err)
     }
-    
-    rowsAffected, _ := result.RowsAffected()
-    log.Printf("Successfully stored data for %s on %s (rows affected: %d)", symbol, date.Format("2006-01-02"), rowsAffected)
-    
-    return nil
+    defer rows.Close()
+
+    var data []StockDailyData
+    for rows.Next() {
+        var record StockDailyData
+        err := rows.Scan(
+            &record.Symbol,
+            &record.Date,
+            &record.Open,
+            &record.High,
+            &record.Low,
+            &record.Close,
+            &record.Volume,
+            &record.CreatedAt,
+        )
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan daily data: %w", err)
+        }
+        data = append(data, record)
+    }
+
+    return data, rows.Err()
 }
 
 func (r *StockRepository) StoreSymbol(symbol *StockSymbol) error {
@@ -103,7 +117,7 @@ func (r *StockRepository) StoreSymbol(symbol *StockSymbol) error {
     
     result, err := r.db.Exec(query, symbol.Symbol, symbol.BatchId, time.Now())
     if err != nil {
-        log.Printf("Database error storing data for %s: %v", symbol, err)
+        log.Printf("Database error storing data for %s: %v", symbol.Symbol, err)
         return fmt.Errorf("failed to store symbol data: %w", err)
     }
     
@@ -288,6 +302,53 @@ func (r *StockRepository) GetStockData(symbol string, startDate, endDate time.Ti
     return data, nil
 }
 
+// GetStockDataAfter gets every bar for a symbol after the given time, preceded by up
+// to lookback bars at or before it, oldest first
+func (r *StockRepository) GetStockDataAfter(symbol string, after time.Time, lookback int) ([]StockIntraDayData, error) {
+    query := `
+        SELECT symbol, date, open, high, low, close, volume, created_at
+        FROM (
+            (SELECT symbol, date, open, high, low, close, volume, created_at
+             FROM stocks_intraday
+             WHERE symbol = $1 AND date <= $2
+             ORDER BY date DESC
+             LIMIT $3)
+            UNION ALL
+            (SELECT symbol, date, open, high, low, close, volume, created_at
+             FROM stocks_intraday
+             WHERE symbol = $1 AND date > $2)
+        ) bars
+        ORDER BY date ASC
+    `
+
+    rows, err := r.db.Query(query, symbol, after, lookback)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query stock data: %w", err)
+    }
+    defer rows.Close()
+
+    var data []StockIntraDayData
+    for rows.Next() {
+        var record StockIntraDayData
+        err := rows.Scan(
+            &record.Symbol,
+            &record.Date,
+            &record.Open,
+            &record.High,
+            &record.Low,
+            &record.Close,
+            &record.Volume,
+            &record.CreatedAt,
+        )
+        if err != nil {
+            return nil, fmt.Errorf("failed to scan stock data: %w", err)
+        }
+        data = append(data, record)
+    }
+
+    return data, rows.Err()
+}
+
 // GetLatestStockData gets the most recent stock data for a symbol
 func (r *StockRepository) GetLatestStockData(symbol string) (*StockIntraDayData, error) {
     query := `
@@ -361,6 +422,16 @@ func (r *StockRepository) GetSymbolsWithMetadata() ([]string, error) {
     return symbols, nil
 }
 
+// HasSymbol reports whether the symbol is listed in stock_symbols
+func (r *StockRepository) HasSymbol(symbol string) (bool, error) {
+    var exists bool
+    err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM stock_symbols WHERE symbol = $1)`, symbol).Scan(&exists)
+    if err != nil {
+        return false, fmt.Errorf("failed to look up symbol %s: %w", symbol, err)
+    }
+    return exists, nil
+}
+
 func (r *StockRepository) GetSymbolWithMetadata(symbol string) (string, error) {
 	query := `SELECT symbol FROM stocks_metadata WHERE symbol = $1 LIMIT 1`
 
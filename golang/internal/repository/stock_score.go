@@ -7,6 +7,8 @@ import (
 	"stock-api/internal/api"
 	"time"
 	"log"
+
+	"github.com/lib/pq"
 )
 
 
@@ -25,32 +27,50 @@ type BalanceSheetRow struct {
 	ShareholderEquity       string
 }
 
+// OverviewRow is a stored company overview; ratios the provider didn't
+// report are nil
 type OverviewRow struct {
-	Symbol            string  
-	Name              string 
-	PERatio           float64 
-	PEGRatio          float64
-	PriceToBook       float64 
-	ReturnOnEquityTTM float64 
-	OperatingMargin   float64 
-	ProfitMargin      float64 
-	DividendYield     float64 
-	Beta              float64 
+	Symbol            string
+	Name              string
+	PERatio           *float64
+	PEGRatio          *float64
+	PriceToBook       *float64
+	ReturnOnEquityTTM *float64
+	OperatingMargin   *float64
+	ProfitMargin      *float64
+	DividendYield     *float64
+	Beta              *float64
 }
 
+// Scorecard holds a company's fundamentals and score. Fundamentals that
+// aren't known are nil rather than 0, so scoring can tell them apart.
 type Scorecard struct {
-	Symbol          string
-	CompanyName     string
-	PERatio         float64
-	PEGRatio        float64
-	PriceToBook     float64
-	ROE_TTM         float64
-	HistoricalROE   map[string]float64
-	Revenue5YGrowth float64
-	OperatingMargin float64
-	ProfitMargin    float64
-	DividendYield   float64
-	Beta            float64
+	Symbol          string                 `json:"symbol"`
+	CompanyName     string                 `json:"company_name"`
+	Industry        string                 `json:"industry"`
+	PERatio         *float64               `json:"pe_ratio"`
+	PEGRatio        *float64               `json:"peg_ratio"`
+	PriceToBook     *float64               `json:"price_to_book"`
+	ROE_TTM         *float64               `json:"roe_ttm"`
+	HistoricalROE   map[string]float64     `json:"historical_roe"`
+	Revenue5YGrowth *float64               `json:"revenue_5y_growth"`
+	OperatingMargin *float64               `json:"operating_margin"`
+	ProfitMargin    *float64               `json:"profit_margin"`
+	DividendYield   *float64               `json:"dividend_yield"`
+	Beta            *float64               `json:"beta"`
+	CompositeScore  *float64               `json:"composite_score"`
+	SubScores       map[string]FactorScore `json:"sub_scores"`
+}
+
+// FactorScore explains one factor's contribution to a scorecard's composite score
+type FactorScore struct {
+	Value     float64 `json:"value"`
+	Score     float64 `json:"score"`
+	// Weight is the factor's share of the composite once missing factors are left out
+	Weight    float64 `json:"weight"`
+	Method    string  `json:"method"`
+	PeerGroup string  `json:"peer_group,omitempty"`
+	Peers     int     `json:"peers,omitempty"`
 }
 
 func NewStockScoreRepository(db *sql.DB) *StockScoreRepository {
@@ -63,19 +83,29 @@ func (r *StockScoreRepository) StoreStockScorecard(card *Scorecard) error {
 		return fmt.Errorf("failed to marshal historical ROE: %w", err)
 	}
 
+	// Cards stored before scoring keep whatever score they already had
+	var subScoresJson []byte
+	if card.SubScores != nil {
+		subScoresJson, err = json.Marshal(card.SubScores)
+		if err != nil {
+			return fmt.Errorf("failed to marshal sub-scores: %w", err)
+		}
+	}
+
 	query := `
 		INSERT INTO stock_scorecards (
-			symbol, company_name, pe_ratio, peg_ratio, price_to_book, 
+			symbol, company_name, industry, pe_ratio, peg_ratio, price_to_book, 
 			roe_ttm, revenue_5y_growth, operating_margin, profit_margin, 
-			dividend_yield, beta, historical_roe, updated_at
+			dividend_yield, beta, historical_roe, composite_score, sub_scores, updated_at
 		)
 		VALUES (
-			$1, $2, $3, $4, $5,
-			$6, $7, $8, $9,
-			$10, $11, $12, CURRENT_TIMESTAMP
+			$1, $2, NULLIF($3, ''), $4, $5, $6,
+			$7, $8, $9, $10,
+			$11, $12, $13, $14, $15, CURRENT_TIMESTAMP
 		)
 		ON CONFLICT (symbol) DO UPDATE SET
 			company_name = EXCLUDED.company_name,
+			industry = EXCLUDED.industry,
 			pe_ratio = EXCLUDED.pe_ratio,
 			peg_ratio = EXCLUDED.peg_ratio,
 			price_to_book = EXCLUDED.price_to_book,
@@ -86,12 +116,15 @@ func (r *StockScoreRepository) StoreStockScorecard(card *Scorecard) error {
 			dividend_yield = EXCLUDED.dividend_yield,
 			beta = EXCLUDED.beta,
 			historical_roe = EXCLUDED.historical_roe,
+			composite_score = COALESCE(EXCLUDED.composite_score, stock_scorecards.composite_score),
+			sub_scores = COALESCE(EXCLUDED.sub_scores, stock_scorecards.sub_scores),
 			updated_at = CURRENT_TIMESTAMP
 	`
 
 	_, err = r.db.Exec(query,
 		card.Symbol,
 		card.CompanyName,
+		card.Industry,
 		card.PERatio,
 		card.PEGRatio,
 		card.PriceToBook,
@@ -102,6 +135,8 @@ func (r *StockScoreRepository) StoreStockScorecard(card *Scorecard) error {
 		card.DividendYield,
 		card.Beta,
 		historicalROEJson,
+		card.CompositeScore,
+		subScoresJson,
 	)
 
 	if err != nil {
@@ -110,23 +145,39 @@ func (r *StockScoreRepository) StoreStockScorecard(card *Scorecard) error {
 	return nil
 }
 
-func (r *StockScoreRepository) GetStockScorecard(symbol string) (*Scorecard, error) {
-	query := `
-		SELECT symbol, company_name, pe_ratio, peg_ratio, price_to_book, 
-		       roe_ttm, revenue_5y_growth, operating_margin, profit_margin, 
-		       dividend_yield, beta, historical_roe
-		FROM stock_scorecards
+// StoreScores records the composite score and breakdown of an existing
+// scorecard. A nil composite means none of the factors could be scored.
+func (r *StockScoreRepository) StoreScores(symbol string, composite *float64, subScores map[string]FactorScore) error {
+	subScoresJson, err := json.Marshal(subScores)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sub-scores: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+		UPDATE stock_scorecards
+		SET composite_score = $2, sub_scores = $3, updated_at = CURRENT_TIMESTAMP
 		WHERE symbol = $1
-	`
+	`, symbol, composite, subScoresJson)
+	if err != nil {
+		return fmt.Errorf("failed to store scores for %s: %w", symbol, err)
+	}
+	return nil
+}
 
-	row := r.db.QueryRow(query, symbol)
+const scorecardColumns = `symbol, COALESCE(company_name, ''), COALESCE(industry, ''),
+		       pe_ratio, peg_ratio, price_to_book,
+		       roe_ttm, revenue_5y_growth, operating_margin,
+		       profit_margin, dividend_yield, beta,
+		       historical_roe, composite_score, sub_scores`
 
+func scanScorecard(row interface{ Scan(...interface{}) error }) (*Scorecard, error) {
 	var card Scorecard
-	var historicalROEJson []byte
+	var historicalROEJson, subScoresJson []byte
 
 	err := row.Scan(
 		&card.Symbol,
 		&card.CompanyName,
+		&card.Industry,
 		&card.PERatio,
 		&card.PEGRatio,
 		&card.PriceToBook,
@@ -137,7 +188,34 @@ func (r *StockScoreRepository) GetStockScorecard(symbol string) (*Scorecard, err
 		&card.DividendYield,
 		&card.Beta,
 		&historicalROEJson,
+		&card.CompositeScore,
+		&subScoresJson,
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	if historicalROEJson != nil {
+		if err := json.Unmarshal(historicalROEJson, &card.HistoricalROE); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal historical ROE: %w", err)
+		}
+	}
+	if subScoresJson != nil {
+		if err := json.Unmarshal(subScoresJson, &card.SubScores); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal sub-scores: %w", err)
+		}
+	}
+
+	return &card, nil
+}
+
+func (r *StockScoreRepository) GetStockScorecard(symbol string) (*Scorecard, error) {
+	query := `SELECT ` + scorecardColumns + `
+		FROM stock_scorecards
+		WHERE symbol = $1
+	`
+
+	card, err := scanScorecard(r.db.QueryRow(query, symbol))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -145,12 +223,113 @@ func (r *StockScoreRepository) GetStockScorecard(symbol string) (*Scorecard, err
 		return nil, fmt.Errorf("failed to fetch stock scorecard: %w", err)
 	}
 
-	err = json.Unmarshal(historicalROEJson, &card.HistoricalROE)
+	return card, nil
+}
+
+// GetScorecards returns every scorecard in an industry, or every scorecard
+// when industry is empty
+func (r *StockScoreRepository) GetScorecards(industry string) ([]Scorecard, error) {
+	query := `SELECT ` + scorecardColumns + `
+		FROM stock_scorecards
+		WHERE $1 = '' OR industry = $1
+		ORDER BY symbol
+	`
+
+	rows, err := r.db.Query(query, industry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scorecards: %w", err)
+	}
+	defer rows.Close()
+
+	var cards []Scorecard
+	for rows.Next() {
+		card, err := scanScorecard(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan scorecard: %w", err)
+		}
+		cards = append(cards, *card)
+	}
+	return cards, rows.Err()
+}
+
+// ScorecardSnapshot is a scorecard as it stood after one scorecard run
+type ScorecardSnapshot struct {
+	RecordedAt time.Time `json:"recorded_at"`
+	Scorecard
+}
+
+// AppendScorecardHistory copies the current scorecards of symbols into
+// stock_scorecard_history
+func (r *StockScoreRepository) AppendScorecardHistory(symbols []string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO stock_scorecard_history (
+			symbol, company_name, industry, pe_ratio, peg_ratio, price_to_book,
+			roe_ttm, revenue_5y_growth, operating_margin, profit_margin,
+			dividend_yield, beta, composite_score, sub_scores
+		)
+		SELECT symbol, company_name, industry, pe_ratio, peg_ratio, price_to_book,
+		       roe_ttm, revenue_5y_growth, operating_margin, profit_margin,
+		       dividend_yield, beta, composite_score, sub_scores
+		FROM stock_scorecards
+		WHERE symbol = ANY($1)
+	`, pq.Array(symbols))
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal historical ROE: %w", err)
+		return fmt.Errorf("failed to append scorecard history: %w", err)
 	}
+	return nil
+}
 
-	return &card, nil
+// GetScorecardHistory returns a symbol's snapshots recorded within a time range, oldest first
+func (r *StockScoreRepository) GetScorecardHistory(symbol string, from, to time.Time) ([]ScorecardSnapshot, error) {
+	query := `
+		SELECT recorded_at, symbol, COALESCE(company_name, ''), COALESCE(industry, ''),
+		       pe_ratio, peg_ratio, price_to_book,
+		       roe_ttm, revenue_5y_growth, operating_margin,
+		       profit_margin, dividend_yield, beta,
+		       composite_score, sub_scores
+		FROM stock_scorecard_history
+		WHERE symbol = $1 AND recorded_at BETWEEN $2 AND $3
+		ORDER BY recorded_at ASC
+	`
+
+	rows, err := r.db.Query(query, symbol, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scorecard history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []ScorecardSnapshot
+	for rows.Next() {
+		var snapshot ScorecardSnapshot
+		var subScoresJson []byte
+		err := rows.Scan(
+			&snapshot.RecordedAt,
+			&snapshot.Symbol,
+			&snapshot.CompanyName,
+			&snapshot.Industry,
+			&snapshot.PERatio,
+			&snapshot.PEGRatio,
+			&snapshot.PriceToBook,
+			&snapshot.ROE_TTM,
+			&snapshot.Revenue5YGrowth,
+			&snapshot.OperatingMargin,
+			&snapshot.ProfitMargin,
+			&snapshot.DividendYield,
+			&snapshot.Beta,
+			&snapshot.CompositeScore,
+			&subScoresJson,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan scorecard history: %w", err)
+		}
+		if subScoresJson != nil {
+			if err := json.Unmarshal(subScoresJson, &snapshot.SubScores); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal sub-scores: %w", err)
+			}
+		}
+		history = append(history, snapshot)
+	}
+	return history, rows.Err()
 }
 
 func (r *StockScoreRepository) StoreOverview(o *api.OverviewResponse) error {
@@ -173,18 +352,26 @@ func (r *StockScoreRepository) StoreOverview(o *api.OverviewResponse) error {
 			created_at = CURRENT_TIMESTAMP
 	`
 
+	if o.Symbol == "" {
+		return fmt.Errorf("refusing to store overview without a symbol")
+	}
+
+	// Alpha Vantage reports missing values as "None" or "-", which are stored as NULL
 	_, err := r.db.Exec(query,
-		o.Symbol, o.Name, *api.ParseFloat(o.PERatio), *api.ParseFloat(o.PEGRatio), *api.ParseFloat(o.PriceToBook),
-		*api.ParseFloat(o.ReturnOnEquityTTM), *api.ParseFloat(o.OperatingMargin), *api.ParseFloat(o.ProfitMargin),
-		*api.ParseFloat(o.DividendYield), *api.ParseFloat(o.Beta),
+		o.Symbol, o.Name, api.ParseFloat(o.PERatio), api.ParseFloat(o.PEGRatio), api.ParseFloat(o.PriceToBook),
+		api.ParseFloat(o.ReturnOnEquityTTM), api.ParseFloat(o.OperatingMargin), api.ParseFloat(o.ProfitMargin),
+		api.ParseFloat(o.DividendYield), api.ParseFloat(o.Beta),
 	)
-	log.Printf("HERE: %f", *api.ParseFloat(o.ReturnOnEquityTTM))
-	return err
+	if err != nil {
+		log.Printf("Database error storing overview for %s: %v", o.Symbol, err)
+		return fmt.Errorf("failed to store overview for %s: %w", o.Symbol, err)
+	}
+	return nil
 }
 
 func (r *StockScoreRepository) GetOverview(symbol string) (*OverviewRow, error) {
 	query := `
-		SELECT symbol, name, pe_ratio, peg_ratio, price_to_book,
+		SELECT symbol, COALESCE(name, ''), pe_ratio, peg_ratio, price_to_book,
 		       return_on_equity_ttm, operating_margin, profit_margin,
 		       dividend_yield, beta
 		FROM stock_overviews
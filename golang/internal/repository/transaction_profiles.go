@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"stock-api/internal/statements"
+)
+
+// ImportProfile is a user's saved CSV layout for one bank's statements
+type ImportProfile struct {
+	ID        int                   `json:"id"`
+	UserID    int                   `json:"user_id"`
+	Name      string                `json:"name"`
+	Bank      string                `json:"bank"`
+	Profile   statements.CSVProfile `json:"profile"`
+	CreatedAt time.Time             `json:"created_at"`
+	UpdatedAt time.Time             `json:"updated_at"`
+}
+
+const importProfileColumns = `id, user_id, name, COALESCE(bank, ''), profile, created_at, updated_at`
+
+func scanImportProfile(row interface{ Scan(...interface{}) error }) (*ImportProfile, error) {
+	var p ImportProfile
+	var profileJson []byte
+	if err := row.Scan(&p.ID, &p.UserID, &p.Name, &p.Bank, &profileJson, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(profileJson, &p.Profile); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal import profile: %w", err)
+	}
+	return &p, nil
+}
+
+// CreateImportProfile stores a new import profile
+func (r *TransactionsRepository) CreateImportProfile(p *ImportProfile) error {
+	profileJson, err := json.Marshal(p.Profile)
+	if err != nil {
+		return fmt.Errorf("failed to marshal import profile: %w", err)
+	}
+	err = r.db.QueryRow(`
+		INSERT INTO transaction_import_profiles (user_id, name, bank, profile)
+		VALUES ($1, $2, NULLIF($3, ''), $4)
+		RETURNING id, created_at, updated_at
+	`, p.UserID, p.Name, p.Bank, profileJson).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create import profile: %w", err)
+	}
+	return nil
+}
+
+// GetImportProfiles returns the user's import profiles
+func (r *TransactionsRepository) GetImportProfiles(userID int) ([]ImportProfile, error) {
+	rows, err := r.db.Query(`SELECT `+importProfileColumns+`
+		FROM transaction_import_profiles WHERE user_id = $1 ORDER BY name`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import profiles: %w", err)
+	}
+	defer rows.Close()
+
+	var profiles []ImportProfile
+	for rows.Next() {
+		p, err := scanImportProfile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan import profile: %w", err)
+		}
+		profiles = append(profiles, *p)
+	}
+	return profiles, rows.Err()
+}
+
+// GetImportProfile returns one of the user's import profiles, or nil when the
+// user has no such profile
+func (r *TransactionsRepository) GetImportProfile(userID, id int) (*ImportProfile, error) {
+	p, err := scanImportProfile(r.db.QueryRow(`SELECT `+importProfileColumns+`
+		FROM transaction_import_profiles WHERE user_id = $1 AND id = $2`, userID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import profile %d: %w", id, err)
+	}
+	return p, nil
+}
+
+// UpdateImportProfile saves a profile's name, bank and layout
+func (r *TransactionsRepository) UpdateImportProfile(p *ImportProfile) (bool, error) {
+	profileJson, err := json.Marshal(p.Profile)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal import profile: %w", err)
+	}
+	err = r.db.QueryRow(`
+		UPDATE transaction_import_profiles
+		SET name = $3, bank = NULLIF($4, ''), profile = $5
+		WHERE user_id = $1 AND id = $2
+		RETURNING created_at, updated_at
+	`, p.UserID, p.ID, p.Name, p.Bank, profileJson).Scan(&p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update import profile %d: %w", p.ID, err)
+	}
+	return true, nil
+}
+
+// DeleteImportProfile deletes one of the user's import profiles
+func (r *TransactionsRepository) DeleteImportProfile(userID, id int) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM transaction_import_profiles WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete import profile %d: %w", id, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
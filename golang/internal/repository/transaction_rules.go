@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CategoryRule assigns Category to the transactions matching every condition
+// it sets
+type CategoryRule struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"user_id"`
+	Name   string `json:"name"`
+	// Priority orders rules; lower runs first and the first match wins
+	Priority int    `json:"priority"`
+	Category string `json:"category"`
+	// Pattern matches the description, falling back to the normalised merchant
+	Pattern string `json:"pattern"`
+	// MatchType is contains (case-insensitive) or regex
+	MatchType string `json:"match_type"`
+	// MinAmount and MaxAmount bound the absolute amount
+	MinAmount *float64 `json:"min_amount"`
+	MaxAmount *float64 `json:"max_amount"`
+	// Direction is debit (money out), credit (money in) or empty for either
+	Direction string `json:"direction"`
+	Account   string `json:"account"`
+	Merchant  string `json:"merchant"`
+	// Override lets the rule replace a category provided by the source
+	Override  bool      `json:"override"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CategoryChange is a new category for one stored transaction
+type CategoryChange struct {
+	TransactionID int
+	Category      string
+	Merchant      string
+	// RuleID is the rule that chose the category, or nil for the source's
+	RuleID *int
+}
+
+const categoryRuleColumns = `id, user_id, name, priority, category, COALESCE(pattern, ''), match_type,
+	min_amount, max_amount, COALESCE(direction, ''), COALESCE(account, ''), COALESCE(merchant, ''),
+	override, enabled, created_at, updated_at`
+
+func scanCategoryRule(row interface{ Scan(...interface{}) error }) (*CategoryRule, error) {
+	var rule CategoryRule
+	err := row.Scan(&rule.ID, &rule.UserID, &rule.Name, &rule.Priority, &rule.Category, &rule.Pattern, &rule.MatchType,
+		&rule.MinAmount, &rule.MaxAmount, &rule.Direction, &rule.Account, &rule.Merchant,
+		&rule.Override, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// CreateCategoryRule stores a new categorisation rule
+func (r *TransactionsRepository) CreateCategoryRule(rule *CategoryRule) error {
+	err := r.db.QueryRow(`
+		INSERT INTO transaction_category_rules
+			(user_id, name, priority, category, pattern, match_type, min_amount, max_amount,
+			 direction, account, merchant, override, enabled)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, NULLIF($9, ''), NULLIF($10, ''), NULLIF($11, ''), $12, $13)
+		RETURNING id, created_at, updated_at
+	`, rule.UserID, rule.Name, rule.Priority, rule.Category, rule.Pattern, rule.MatchType, rule.MinAmount, rule.MaxAmount,
+		rule.Direction, rule.Account, rule.Merchant, rule.Override, rule.Enabled).Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create category rule: %w", err)
+	}
+	return nil
+}
+
+// GetCategoryRules returns the user's categorisation rules in the order they run
+func (r *TransactionsRepository) GetCategoryRules(userID int) ([]CategoryRule, error) {
+	rows, err := r.db.Query(`SELECT `+categoryRuleColumns+`
+		FROM transaction_category_rules WHERE user_id = $1 ORDER BY priority, id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query category rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []CategoryRule
+	for rows.Next() {
+		rule, err := scanCategoryRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan category rule: %w", err)
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+// GetCategoryRule returns one of the user's rules, or nil when the user has no such rule
+func (r *TransactionsRepository) GetCategoryRule(userID, id int) (*CategoryRule, error) {
+	rule, err := scanCategoryRule(r.db.QueryRow(`SELECT `+categoryRuleColumns+`
+		FROM transaction_category_rules WHERE user_id = $1 AND id = $2`, userID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get category rule %d: %w", id, err)
+	}
+	return rule, nil
+}
+
+// UpdateCategoryRule saves every field of one of the user's rules
+func (r *TransactionsRepository) UpdateCategoryRule(rule *CategoryRule) (bool, error) {
+	err := r.db.QueryRow(`
+		UPDATE transaction_category_rules
+		SET name = $3, priority = $4, category = $5, pattern = NULLIF($6, ''), match_type = $7,
+		    min_amount = $8, max_amount = $9, direction = NULLIF($10, ''), account = NULLIF($11, ''),
+		    merchant = NULLIF($12, ''), override = $13, enabled = $14
+		WHERE user_id = $1 AND id = $2
+		RETURNING created_at, updated_at
+	`, rule.UserID, rule.ID, rule.Name, rule.Priority, rule.Category, rule.Pattern, rule.MatchType,
+		rule.MinAmount, rule.MaxAmount, rule.Direction, rule.Account, rule.Merchant,
+		rule.Override, rule.Enabled).Scan(&rule.CreatedAt, &rule.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update category rule %d: %w", rule.ID, err)
+	}
+	return true, nil
+}
+
+// DeleteCategoryRule deletes one of the user's rules. Transactions it
+// categorised keep their category until they are recategorised.
+func (r *TransactionsRepository) DeleteCategoryRule(userID, id int) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM transaction_category_rules WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete category rule %d: %w", id, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// UpdateCategories applies category changes to the user's transactions
+func (r *TransactionsRepository) UpdateCategories(userID int, changes []CategoryChange) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		UPDATE personal_transactions
+		SET category = NULLIF($3, ''), merchant = NULLIF($4, ''), category_rule_id = $5
+		WHERE user_id = $1 AND id = $2
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare category update: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, c := range changes {
+		if _, err := stmt.Exec(userID, c.TransactionID, c.Category, c.Merchant, c.RuleID); err != nil {
+			return fmt.Errorf("failed to update category of transaction %d: %w", c.TransactionID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit category updates: %w", err)
+	}
+	return nil
+}
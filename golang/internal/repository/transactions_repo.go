@@ -2,34 +2,421 @@ package repository
 
 import (
 	"database/sql"
-	"log"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
 	"stock-api/internal/api"
 )
 
 type TransactionsRepository struct {
-    db *sql.DB
+	db *sql.DB
+}
+
+// TransactionSource is a place a user imports bank transactions from
+type TransactionSource struct {
+	ID            int             `json:"id"`
+	UserID        int             `json:"user_id"`
+	Name          string          `json:"name"`
+	Kind          string          `json:"kind"`
+	SpreadsheetID string          `json:"spreadsheet_id"`
+	TableID       string          `json:"table_id"`
+	Mapping       api.RowsMapping `json:"mapping"`
+	// LastRow is the last source row imported; the next sync starts below it
+	LastRow      int        `json:"last_row"`
+	LastSyncedAt *time.Time `json:"last_synced_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
 }
 
+// PersonalTransaction is one stored bank transaction
+type PersonalTransaction struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	SourceID    *int      `json:"source_id"`
+	SourceRow   *int      `json:"source_row"`
+	ImportRunID *int      `json:"import_run_id"`
+	Fingerprint string    `json:"fingerprint"`
+	Date        time.Time `json:"date"`
+	Amount      float64   `json:"amount"`
+	Currency    string    `json:"currency"`
+	Description string    `json:"description"`
+	Category    string    `json:"category"`
+	// SourceCategory is the category as imported, before categorisation rules
+	SourceCategory string `json:"source_category"`
+	// CategoryRuleID is the rule that chose Category, or nil when it came from the source
+	CategoryRuleID *int      `json:"category_rule_id"`
+	Merchant       string    `json:"merchant"`
+	Bank           string    `json:"bank"`
+	Account        string    `json:"account"`
+	InsertedAt     time.Time `json:"inserted_at"`
+}
+
+// ImportRun records one import of a source or statement file and what
+// happened to its rows
+type ImportRun struct {
+	ID       int    `json:"id"`
+	UserID   int    `json:"user_id"`
+	SourceID *int   `json:"source_id"`
+	FileName string `json:"file_name,omitempty"`
+	// Format is csv, ofx or qif for statement files
+	Format        string         `json:"format,omitempty"`
+	Status        string         `json:"status"`
+	NewRows       int            `json:"new_rows"`
+	DuplicateRows int            `json:"duplicate_rows"`
+	RejectedRows  int            `json:"rejected_rows"`
+	RowErrors     []api.RowError `json:"row_errors"`
+	// FromRow and ToRow are the source rows the run read, when it read any
+	FromRow    *int       `json:"from_row"`
+	ToRow      *int       `json:"to_row"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// Import run statuses
+const (
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
 func NewTransactionsRepository(db *sql.DB) *TransactionsRepository {
-    return &TransactionsRepository{db: db}
+	return &TransactionsRepository{db: db}
+}
+
+const transactionSourceColumns = `id, user_id, name, kind, spreadsheet_id, table_id, mapping,
+	last_row, last_synced_at, created_at, updated_at`
+
+func scanTransactionSource(row interface{ Scan(...interface{}) error }) (*TransactionSource, error) {
+	var s TransactionSource
+	var mappingJson []byte
+	err := row.Scan(&s.ID, &s.UserID, &s.Name, &s.Kind, &s.SpreadsheetID, &s.TableID, &mappingJson,
+		&s.LastRow, &s.LastSyncedAt, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(mappingJson, &s.Mapping); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal source mapping: %w", err)
+	}
+	return &s, nil
+}
+
+// CreateSource stores a new transaction source
+func (r *TransactionsRepository) CreateSource(s *TransactionSource) error {
+	mappingJson, err := json.Marshal(s.Mapping)
+	if err != nil {
+		return fmt.Errorf("failed to marshal source mapping: %w", err)
+	}
+	err = r.db.QueryRow(`
+		INSERT INTO transaction_sources (user_id, name, kind, spreadsheet_id, table_id, mapping)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`, s.UserID, s.Name, s.Kind, s.SpreadsheetID, s.TableID, mappingJson).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction source: %w", err)
+	}
+	return nil
+}
+
+// UpdateSource saves a source's name, table and mapping. When the table or
+// mapping changes the sync position is reset so the next sync reads
+// everything again.
+func (r *TransactionsRepository) UpdateSource(s *TransactionSource) (bool, error) {
+	mappingJson, err := json.Marshal(s.Mapping)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal source mapping: %w", err)
+	}
+	err = r.db.QueryRow(`
+		UPDATE transaction_sources
+		SET name = $3, spreadsheet_id = $4, table_id = $5, mapping = $6,
+		    last_row = CASE
+		        WHEN spreadsheet_id = $4 AND table_id = $5 AND mapping = $6::jsonb THEN last_row
+		        ELSE 0
+		    END
+		WHERE user_id = $1 AND id = $2
+		RETURNING last_row, last_synced_at, created_at, updated_at
+	`, s.UserID, s.ID, s.Name, s.SpreadsheetID, s.TableID, mappingJson).Scan(&s.LastRow, &s.LastSyncedAt, &s.CreatedAt, &s.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update transaction source %d: %w", s.ID, err)
+	}
+	return true, nil
+}
+
+// SetSourceSyncPosition records the last source row imported
+func (r *TransactionsRepository) SetSourceSyncPosition(sourceID, lastRow int) error {
+	_, err := r.db.Exec(`
+		UPDATE transaction_sources SET last_row = $2, last_synced_at = CURRENT_TIMESTAMP WHERE id = $1
+	`, sourceID, lastRow)
+	if err != nil {
+		return fmt.Errorf("failed to set sync position of source %d: %w", sourceID, err)
+	}
+	return nil
+}
+
+// GetSources returns the user's transaction sources
+func (r *TransactionsRepository) GetSources(userID int) ([]TransactionSource, error) {
+	rows, err := r.db.Query(`SELECT `+transactionSourceColumns+`
+		FROM transaction_sources WHERE user_id = $1 ORDER BY name`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transaction sources: %w", err)
+	}
+	defer rows.Close()
+
+	var sources []TransactionSource
+	for rows.Next() {
+		s, err := scanTransactionSource(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction source: %w", err)
+		}
+		sources = append(sources, *s)
+	}
+	return sources, rows.Err()
+}
+
+// GetSource returns one of the user's transaction sources, or nil when the
+// user has no such source
+func (r *TransactionsRepository) GetSource(userID, id int) (*TransactionSource, error) {
+	s, err := scanTransactionSource(r.db.QueryRow(`SELECT `+transactionSourceColumns+`
+		FROM transaction_sources WHERE user_id = $1 AND id = $2`, userID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction source %d: %w", id, err)
+	}
+	return s, nil
+}
+
+// DeleteSource deletes one of the user's transaction sources. Transactions
+// imported from it are kept.
+func (r *TransactionsRepository) DeleteSource(userID, id int) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM transaction_sources WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete transaction source %d: %w", id, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// StoreTransactions inserts the run's transactions for its user, skipping
+// any whose fingerprint the user already has. It returns how many were new.
+func (r *TransactionsRepository) StoreTransactions(run *ImportRun, transactions []PersonalTransaction) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO personal_transactions
+			(user_id, source_id, source_row, import_run_id, fingerprint, date, amount, currency,
+			 description, category, source_category, category_rule_id, merchant, bank, account)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), NULLIF($11, ''), $12, NULLIF($13, ''), $14, $15)
+		ON CONFLICT (user_id, fingerprint) DO NOTHING
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare transaction insert: %w", err)
+	}
+	defer stmt.Close()
+
+	inserted := 0
+	for _, t := range transactions {
+		result, err := stmt.Exec(run.UserID, run.SourceID, t.SourceRow, run.ID, t.Fingerprint,
+			t.Date, t.Amount, t.Currency, t.Description, t.Category, t.SourceCategory, t.CategoryRuleID,
+			t.Merchant, t.Bank, t.Account)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert transaction %s: %w", t.Fingerprint, err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		inserted += int(affected)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transactions: %w", err)
+	}
+	return inserted, nil
 }
 
-func (r *TransactionsRepository) StoreTransactions(transactions []api.Transaction) error {
+// GetExistingFingerprints returns which of the fingerprints the user already has
+func (r *TransactionsRepository) GetExistingFingerprints(userID int, fingerprints []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(fingerprints) == 0 {
+		return existing, nil
+	}
+
+	rows, err := r.db.Query(`
+		SELECT fingerprint FROM personal_transactions
+		WHERE user_id = $1 AND fingerprint = ANY($2)
+	`, userID, pq.Array(fingerprints))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transaction fingerprints: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fingerprint string
+		if err := rows.Scan(&fingerprint); err != nil {
+			return nil, fmt.Errorf("failed to scan transaction fingerprint: %w", err)
+		}
+		existing[fingerprint] = true
+	}
+	return existing, rows.Err()
+}
+
+// GetTransactions returns the user's transactions dated within [from, to],
+// newest first. Zero times leave that end open; limit <= 0 returns all.
+func (r *TransactionsRepository) GetTransactions(userID int, from, to time.Time, limit int) ([]PersonalTransaction, error) {
 	query := `
-		INSERT INTO personal_transactions (date, amount, currency, description, category, bank, account)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		SELECT id, user_id, source_id, source_row, import_run_id, COALESCE(fingerprint, ''),
+		       date, amount, COALESCE(currency, ''), COALESCE(description, ''), COALESCE(category, ''),
+		       COALESCE(source_category, ''), category_rule_id, COALESCE(merchant, ''),
+		       COALESCE(bank, ''), COALESCE(account, ''), inserted_at
+		FROM personal_transactions
+		WHERE user_id = $1
+		  AND ($2::date IS NULL OR date >= $2)
+		  AND ($3::date IS NULL OR date <= $3)
+		ORDER BY date DESC, id DESC
 	`
+	args := []interface{}{userID, nullDate(from), nullDate(to)}
+	if limit > 0 {
+		query += ` LIMIT $4`
+		args = append(args, limit)
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer rows.Close()
 
-	for _, tx := range transactions {
-		_, err := r.db.Exec(query,
-			tx.Date, tx.Amount, tx.Currency, tx.Description,
-			tx.Category, tx.Bank, tx.Account,
-		)
+	var transactions []PersonalTransaction
+	for rows.Next() {
+		var t PersonalTransaction
+		err := rows.Scan(&t.ID, &t.UserID, &t.SourceID, &t.SourceRow, &t.ImportRunID, &t.Fingerprint,
+			&t.Date, &t.Amount, &t.Currency, &t.Description, &t.Category,
+			&t.SourceCategory, &t.CategoryRuleID, &t.Merchant,
+			&t.Bank, &t.Account, &t.InsertedAt)
 		if err != nil {
-			log.Printf("Failed to insert transaction: %+v → %v", tx, err)
-			return err
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
 		}
+		transactions = append(transactions, t)
 	}
+	return transactions, rows.Err()
+}
+
+func nullDate(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Format("2006-01-02")
+}
 
+// CreateImportRun records the start of an import
+func (r *TransactionsRepository) CreateImportRun(run *ImportRun) error {
+	run.Status = ImportRunning
+	err := r.db.QueryRow(`
+		INSERT INTO transaction_import_runs (user_id, source_id, file_name, format, status)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5)
+		RETURNING id, started_at
+	`, run.UserID, run.SourceID, run.FileName, run.Format, run.Status).Scan(&run.ID, &run.StartedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create import run: %w", err)
+	}
 	return nil
 }
+
+// FinishImportRun stores the outcome of an import
+func (r *TransactionsRepository) FinishImportRun(run *ImportRun) error {
+	if run.RowErrors == nil {
+		run.RowErrors = []api.RowError{}
+	}
+	rowErrorsJson, err := json.Marshal(run.RowErrors)
+	if err != nil {
+		return fmt.Errorf("failed to marshal row errors: %w", err)
+	}
+
+	var errText sql.NullString
+	if run.Error != "" {
+		errText = sql.NullString{String: run.Error, Valid: true}
+	}
+
+	var finishedAt time.Time
+	err = r.db.QueryRow(`
+		UPDATE transaction_import_runs
+		SET status = $2, new_rows = $3, duplicate_rows = $4, rejected_rows = $5,
+		    row_errors = $6, error = $7, from_row = $8, to_row = $9, finished_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING finished_at
+	`, run.ID, run.Status, run.NewRows, run.DuplicateRows, run.RejectedRows,
+		rowErrorsJson, errText, run.FromRow, run.ToRow).Scan(&finishedAt)
+	if err != nil {
+		return fmt.Errorf("failed to finish import run %d: %w", run.ID, err)
+	}
+	run.FinishedAt = &finishedAt
+	return nil
+}
+
+const importRunColumns = `id, user_id, source_id, COALESCE(file_name, ''), COALESCE(format, ''), status, new_rows, duplicate_rows, rejected_rows,
+	row_errors, COALESCE(error, ''), from_row, to_row, started_at, finished_at`
+
+func scanImportRun(row interface{ Scan(...interface{}) error }) (*ImportRun, error) {
+	var run ImportRun
+	var rowErrorsJson []byte
+	err := row.Scan(&run.ID, &run.UserID, &run.SourceID, &run.FileName, &run.Format, &run.Status, &run.NewRows, &run.DuplicateRows,
+		&run.RejectedRows, &rowErrorsJson, &run.Error, &run.FromRow, &run.ToRow, &run.StartedAt, &run.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(rowErrorsJson, &run.RowErrors); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal row errors: %w", err)
+	}
+	return &run, nil
+}
+
+// GetImportRuns returns the user's most recent import runs
+func (r *TransactionsRepository) GetImportRuns(userID, limit int) ([]ImportRun, error) {
+	rows, err := r.db.Query(`SELECT `+importRunColumns+`
+		FROM transaction_import_runs WHERE user_id = $1
+		ORDER BY started_at DESC, id DESC LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []ImportRun
+	for rows.Next() {
+		run, err := scanImportRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan import run: %w", err)
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+// GetImportRun returns one of the user's import runs, or nil when the user
+// has no such run
+func (r *TransactionsRepository) GetImportRun(userID, id int) (*ImportRun, error) {
+	run, err := scanImportRun(r.db.QueryRow(`SELECT `+importRunColumns+`
+		FROM transaction_import_runs WHERE user_id = $1 AND id = $2`, userID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import run %d: %w", id, err)
+	}
+	return run, nil
+}
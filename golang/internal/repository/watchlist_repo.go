@@ -0,0 +1,299 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type WatchlistRepository struct {
+	db *sql.DB
+}
+
+// Watchlist is a named, ordered group of symbols owned by a user
+type Watchlist struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	Symbols   []string  `json:"symbols"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WatchlistQuote is the latest market data stored for one watchlist member.
+// Fields are nil when the data isn't stored.
+type WatchlistQuote struct {
+	Symbol    string     `json:"symbol"`
+	Position  int        `json:"position"`
+	LastPrice *float64   `json:"last_price"`
+	LastBarAt *time.Time `json:"last_bar_at"`
+	// PreviousClose is the last close before the latest bar's session
+	PreviousClose *float64 `json:"previous_close"`
+	// Volume is traded so far in the latest bar's session
+	Volume *float64 `json:"volume"`
+	// AverageVolume is the mean daily volume over the 20 sessions before it
+	AverageVolume  *float64 `json:"average_volume"`
+	CompositeScore *float64 `json:"composite_score"`
+}
+
+func NewWatchlistRepository(db *sql.DB) *WatchlistRepository {
+	return &WatchlistRepository{db: db}
+}
+
+const watchlistColumns = `w.id, w.user_id, w.name,
+	COALESCE(ARRAY(SELECT s.symbol FROM watchlist_symbols s WHERE s.watchlist_id = w.id ORDER BY s.position, s.symbol), '{}'),
+	w.created_at, w.updated_at`
+
+func scanWatchlist(row interface{ Scan(...interface{}) error }) (*Watchlist, error) {
+	var w Watchlist
+	if err := row.Scan(&w.ID, &w.UserID, &w.Name, pq.Array(&w.Symbols), &w.CreatedAt, &w.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// insertSymbols appends symbols to the end of a watchlist, skipping ones already on it
+func insertSymbols(exec interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+}, watchlistID int, symbols []string) error {
+	_, err := exec.Exec(`
+		INSERT INTO watchlist_symbols (watchlist_id, symbol, position)
+		SELECT $1, o.symbol,
+			COALESCE((SELECT MAX(position) FROM watchlist_symbols WHERE watchlist_id = $1), 0) + o.pos
+		FROM unnest($2::text[]) WITH ORDINALITY AS o(symbol, pos)
+		ON CONFLICT (watchlist_id, symbol) DO NOTHING
+	`, watchlistID, pq.Array(symbols))
+	if err != nil {
+		return fmt.Errorf("failed to add symbols to watchlist %d: %w", watchlistID, err)
+	}
+	return nil
+}
+
+// CreateWatchlist stores a new watchlist with its initial symbols
+func (r *WatchlistRepository) CreateWatchlist(w *Watchlist) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO watchlists (user_id, name)
+		VALUES ($1, $2)
+		RETURNING id, created_at, updated_at
+	`, w.UserID, w.Name).Scan(&w.ID, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create watchlist %q: %w", w.Name, err)
+	}
+
+	if len(w.Symbols) > 0 {
+		if err := insertSymbols(tx, w.ID, w.Symbols); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit watchlist %q: %w", w.Name, err)
+	}
+	return nil
+}
+
+// GetWatchlists lists the user's watchlists by name
+func (r *WatchlistRepository) GetWatchlists(userID int) ([]Watchlist, error) {
+	rows, err := r.db.Query(`
+		SELECT `+watchlistColumns+`
+		FROM watchlists w
+		WHERE w.user_id = $1
+		ORDER BY w.name
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query watchlists: %w", err)
+	}
+	defer rows.Close()
+
+	watchlists := []Watchlist{}
+	for rows.Next() {
+		w, err := scanWatchlist(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan watchlist: %w", err)
+		}
+		watchlists = append(watchlists, *w)
+	}
+	return watchlists, rows.Err()
+}
+
+// GetWatchlist returns one of the user's watchlists, or nil when they have no watchlist with that id
+func (r *WatchlistRepository) GetWatchlist(userID, id int) (*Watchlist, error) {
+	w, err := scanWatchlist(r.db.QueryRow(`
+		SELECT `+watchlistColumns+`
+		FROM watchlists w
+		WHERE w.user_id = $1 AND w.id = $2
+	`, userID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watchlist %d: %w", id, err)
+	}
+	return w, nil
+}
+
+// RenameWatchlist renames one of the user's watchlists, reporting whether it exists
+func (r *WatchlistRepository) RenameWatchlist(userID, id int, name string) (bool, error) {
+	result, err := r.db.Exec(`UPDATE watchlists SET name = $3 WHERE user_id = $1 AND id = $2`, userID, id, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to rename watchlist %d: %w", id, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// DeleteWatchlist deletes one of the user's watchlists and disables the alert
+// rules limited to it, reporting whether it existed
+func (r *WatchlistRepository) DeleteWatchlist(userID, id int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Deleting the watchlist clears the rules' watchlist_id, which would
+	// leave them watching every symbol, so they're disabled first
+	_, err = tx.Exec(`
+		UPDATE alert_rules SET enabled = FALSE
+		WHERE watchlist_id = $2
+		  AND EXISTS (SELECT 1 FROM watchlists WHERE user_id = $1 AND id = $2)
+	`, userID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to disable alert rules for watchlist %d: %w", id, err)
+	}
+
+	result, err := tx.Exec(`DELETE FROM watchlists WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete watchlist %d: %w", id, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit deletion of watchlist %d: %w", id, err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// AddSymbols appends symbols to a watchlist; callers check ownership first
+func (r *WatchlistRepository) AddSymbols(id int, symbols []string) error {
+	return insertSymbols(r.db, id, symbols)
+}
+
+// RemoveSymbol removes a symbol from a watchlist, reporting whether it was on it
+func (r *WatchlistRepository) RemoveSymbol(id int, symbol string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM watchlist_symbols WHERE watchlist_id = $1 AND symbol = $2`, id, symbol)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove %s from watchlist %d: %w", symbol, id, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// ReorderSymbols sets the watchlist's order to that of symbols, which must
+// hold every member
+func (r *WatchlistRepository) ReorderSymbols(id int, symbols []string) error {
+	_, err := r.db.Exec(`
+		UPDATE watchlist_symbols m SET position = o.pos
+		FROM unnest($2::text[]) WITH ORDINALITY AS o(symbol, pos)
+		WHERE m.watchlist_id = $1 AND m.symbol = o.symbol
+	`, id, pq.Array(symbols))
+	if err != nil {
+		return fmt.Errorf("failed to reorder watchlist %d: %w", id, err)
+	}
+	return nil
+}
+
+// GetWatchedSymbols returns the symbols on a watchlist, or on any user's
+// watchlist when watchlistID is 0, alphabetically
+func (r *WatchlistRepository) GetWatchedSymbols(watchlistID int) ([]string, error) {
+	rows, err := r.db.Query(`
+		SELECT DISTINCT symbol
+		FROM watchlist_symbols
+		WHERE $1 = 0 OR watchlist_id = $1
+		ORDER BY symbol
+	`, watchlistID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query watched symbols: %w", err)
+	}
+	defer rows.Close()
+
+	symbols := []string{}
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err != nil {
+			return nil, fmt.Errorf("failed to scan watched symbol: %w", err)
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols, rows.Err()
+}
+
+// GetQuotes returns the latest stored data for every member of a watchlist in
+// display order. Sessions are US/Eastern trading days; intraday bars are UTC.
+func (r *WatchlistRepository) GetQuotes(id int) ([]WatchlistQuote, error) {
+	rows, err := r.db.Query(`
+		SELECT m.symbol, m.position, l.close, l.date,
+		       COALESCE(pd.close, pi.close), dv.volume, av.volume, sc.composite_score
+		FROM watchlist_symbols m
+		LEFT JOIN LATERAL (
+			SELECT b.date, b.close,
+			       (((b.date AT TIME ZONE 'UTC') AT TIME ZONE 'America/New_York')::date) AS session,
+			       ((((b.date AT TIME ZONE 'UTC') AT TIME ZONE 'America/New_York')::date)::timestamp
+			           AT TIME ZONE 'America/New_York') AT TIME ZONE 'UTC' AS session_start
+			FROM stocks_intraday b
+			WHERE b.symbol = m.symbol
+			ORDER BY b.date DESC
+			LIMIT 1
+		) l ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT d.close FROM stocks_daily d
+			WHERE d.symbol = m.symbol AND d.date < l.session
+			ORDER BY d.date DESC
+			LIMIT 1
+		) pd ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT b.close FROM stocks_intraday b
+			WHERE b.symbol = m.symbol AND b.date < l.session_start
+			ORDER BY b.date DESC
+			LIMIT 1
+		) pi ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT SUM(b.volume) AS volume FROM stocks_intraday b
+			WHERE b.symbol = m.symbol AND b.date >= l.session_start AND b.date <= l.date
+		) dv ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT AVG(recent.volume) AS volume FROM (
+				SELECT d.volume FROM stocks_daily d
+				WHERE d.symbol = m.symbol AND d.date < l.session
+				ORDER BY d.date DESC
+				LIMIT 20
+			) recent
+		) av ON TRUE
+		LEFT JOIN stock_scorecards sc ON sc.symbol = m.symbol
+		WHERE m.watchlist_id = $1
+		ORDER BY m.position, m.symbol
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query watchlist %d quotes: %w", id, err)
+	}
+	defer rows.Close()
+
+	quotes := []WatchlistQuote{}
+	for rows.Next() {
+		var q WatchlistQuote
+		err := rows.Scan(&q.Symbol, &q.Position, &q.LastPrice, &q.LastBarAt,
+			&q.PreviousClose, &q.Volume, &q.AverageVolume, &q.CompositeScore)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan watchlist quote: %w", err)
+		}
+		quotes = append(quotes, q)
+	}
+	return quotes, rows.Err()
+}
@@ -0,0 +1,230 @@
+// Package risk computes risk statistics from return series. Returns are
+// simple returns, daily unless noted; NaN marks a missing observation.
+package risk
+
+import (
+	"math"
+	"sort"
+)
+
+// TradingDays is the number of daily returns in a year, used to annualise
+const TradingDays = 252
+
+// Returns converts prices to simple returns, one shorter than prices. A
+// return is NaN when either price is missing or not positive.
+func Returns(prices []float64) []float64 {
+	if len(prices) < 2 {
+		return nil
+	}
+	returns := make([]float64, len(prices)-1)
+	for i := 1; i < len(prices); i++ {
+		prev, cur := prices[i-1], prices[i]
+		if math.IsNaN(prev) || math.IsNaN(cur) || prev <= 0 {
+			returns[i-1] = math.NaN()
+			continue
+		}
+		returns[i-1] = cur/prev - 1
+	}
+	return returns
+}
+
+// valid drops missing observations
+func valid(xs []float64) []float64 {
+	out := make([]float64, 0, len(xs))
+	for _, x := range xs {
+		if !math.IsNaN(x) {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+func mean(xs []float64) float64 {
+	total := 0.0
+	for _, x := range xs {
+		total += x
+	}
+	return total / float64(len(xs))
+}
+
+// stdDev is the sample standard deviation
+func stdDev(xs []float64) float64 {
+	if len(xs) < 2 {
+		return math.NaN()
+	}
+	m := mean(xs)
+	sum := 0.0
+	for _, x := range xs {
+		sum += (x - m) * (x - m)
+	}
+	return math.Sqrt(sum / float64(len(xs)-1))
+}
+
+// Volatility is the annualised standard deviation of daily returns
+func Volatility(returns []float64) (float64, bool) {
+	sd := stdDev(valid(returns))
+	if math.IsNaN(sd) {
+		return 0, false
+	}
+	return sd * math.Sqrt(TradingDays), true
+}
+
+// Beta is the slope of asset returns on index returns over the days both
+// have a return
+func Beta(asset, index []float64) (float64, bool) {
+	var xs, ys []float64
+	for i := range asset {
+		if i < len(index) && !math.IsNaN(asset[i]) && !math.IsNaN(index[i]) {
+			xs = append(xs, index[i])
+			ys = append(ys, asset[i])
+		}
+	}
+	if len(xs) < 2 {
+		return 0, false
+	}
+
+	mx, my := mean(xs), mean(ys)
+	cov, varX := 0.0, 0.0
+	for i := range xs {
+		cov += (xs[i] - mx) * (ys[i] - my)
+		varX += (xs[i] - mx) * (xs[i] - mx)
+	}
+	if varX == 0 {
+		return 0, false
+	}
+	return cov / varX, true
+}
+
+// Pair is the sample covariance and correlation of two return series over
+// the N periods where both have a return
+type Pair struct {
+	Covariance  float64
+	Correlation float64
+	N           int
+}
+
+// Correlate compares two aligned return series, skipping periods where
+// either is missing. ok is false with fewer than two shared periods or when
+// either series doesn't vary.
+func Correlate(a, b []float64) (Pair, bool) {
+	var n int
+	var sumA, sumB float64
+	for i := range a {
+		if i < len(b) && !math.IsNaN(a[i]) && !math.IsNaN(b[i]) {
+			n++
+			sumA += a[i]
+			sumB += b[i]
+		}
+	}
+	if n < 2 {
+		return Pair{N: n}, false
+	}
+
+	meanA, meanB := sumA/float64(n), sumB/float64(n)
+	var cov, varA, varB float64
+	for i := range a {
+		if i < len(b) && !math.IsNaN(a[i]) && !math.IsNaN(b[i]) {
+			da, db := a[i]-meanA, b[i]-meanB
+			cov += da * db
+			varA += da * da
+			varB += db * db
+		}
+	}
+	pair := Pair{Covariance: cov / float64(n-1), N: n}
+	if varA == 0 || varB == 0 {
+		return pair, false
+	}
+	pair.Correlation = cov / math.Sqrt(varA*varB)
+	return pair, true
+}
+
+// Sharpe is the annualised mean excess return over its volatility.
+// riskFree is an annual rate.
+func Sharpe(returns []float64, riskFree float64) (float64, bool) {
+	rs := valid(returns)
+	sd := stdDev(rs)
+	if math.IsNaN(sd) || sd == 0 {
+		return 0, false
+	}
+	excess := mean(rs) - riskFree/TradingDays
+	return excess / sd * math.Sqrt(TradingDays), true
+}
+
+// Sortino is like Sharpe but only penalises returns below the risk-free rate
+func Sortino(returns []float64, riskFree float64) (float64, bool) {
+	rs := valid(returns)
+	if len(rs) < 2 {
+		return 0, false
+	}
+	target := riskFree / TradingDays
+	sum := 0.0
+	for _, r := range rs {
+		if r < target {
+			sum += (r - target) * (r - target)
+		}
+	}
+	downside := math.Sqrt(sum / float64(len(rs)))
+	if downside == 0 {
+		return 0, false
+	}
+	return (mean(rs) - target) / downside * math.Sqrt(TradingDays), true
+}
+
+// Drawdown is the largest peak-to-trough fall of a value series. Depth is a
+// positive fraction; Peak and Trough index the series.
+type Drawdown struct {
+	Depth  float64
+	Peak   int
+	Trough int
+}
+
+// MaxDrawdown finds the largest fall from a running peak in levels
+func MaxDrawdown(levels []float64) Drawdown {
+	var worst Drawdown
+	peak := -1
+	for i, level := range levels {
+		if math.IsNaN(level) {
+			continue
+		}
+		if peak < 0 || level > levels[peak] {
+			peak = i
+			continue
+		}
+		if levels[peak] <= 0 {
+			continue
+		}
+		if depth := 1 - level/levels[peak]; depth > worst.Depth {
+			worst = Drawdown{Depth: depth, Peak: peak, Trough: i}
+		}
+	}
+	return worst
+}
+
+// HistoricalVaR is the one-day loss, as a positive fraction, not exceeded
+// on confidence of the observed days
+func HistoricalVaR(returns []float64, confidence float64) (float64, bool) {
+	rs := valid(returns)
+	if len(rs) == 0 {
+		return 0, false
+	}
+	sort.Float64s(rs)
+
+	// Interpolate between the observations either side of the quantile
+	pos := (1 - confidence) * float64(len(rs)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	q := rs[lo] + (rs[hi]-rs[lo])*(pos-float64(lo))
+	return -q, true
+}
+
+// ParametricVaR is the one-day loss at confidence assuming normally
+// distributed returns with the sample mean and standard deviation
+func ParametricVaR(returns []float64, confidence float64) (float64, bool) {
+	rs := valid(returns)
+	sd := stdDev(rs)
+	if math.IsNaN(sd) {
+		return 0, false
+	}
+	z := math.Sqrt2 * math.Erfinv(2*confidence-1)
+	return -(mean(rs) - z*sd), true
+}
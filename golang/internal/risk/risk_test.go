@@ -0,0 +1,107 @@
+package risk
+
+import (
+	"math"
+	"testing"
+)
+
+// Expected values were worked out independently with Python's statistics module
+var (
+	asset = []float64{0.01, -0.02, 0.015, 0.005, -0.01, 0.02, -0.005, 0, 0.01, -0.015}
+	index = []float64{0.008, -0.01, 0.01, 0.002, -0.006, 0.012, -0.004, 0.001, 0.006, -0.009}
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestReturns(t *testing.T) {
+	got := Returns([]float64{100, 110, math.NaN(), 99, 0, 5})
+	if len(got) != 5 || !near(got[0], 0.1) || !math.IsNaN(got[1]) || !math.IsNaN(got[2]) || !near(got[3], -1) || !math.IsNaN(got[4]) {
+		t.Errorf("Returns = %v, want [0.1 NaN NaN -1 NaN]", got)
+	}
+	if Returns([]float64{100}) != nil {
+		t.Error("Returns of one price isn't nil")
+	}
+}
+
+func TestStatistics(t *testing.T) {
+	// A missing day is skipped rather than treated as a zero return
+	withGap := append(append([]float64{}, asset...), math.NaN())
+
+	tests := []struct {
+		name string
+		fn   func() (float64, bool)
+		want float64
+	}{
+		{"volatility", func() (float64, bool) { return Volatility(withGap) }, 0.21099763031844695},
+		{"beta", func() (float64, bool) { return Beta(withGap, index) }, 1.652097902097902},
+		{"sharpe", func() (float64, bool) { return Sharpe(withGap, 0.0252) }, 1.0748935884147297},
+		{"sortino", func() (float64, bool) { return Sortino(withGap, 0.0252) }, 1.6387839359208785},
+		{"historical VaR", func() (float64, bool) { return HistoricalVaR(withGap, 0.95) }, 0.01775},
+		{"parametric VaR", func() (float64, bool) { return ParametricVaR(withGap, 0.95) }, 0.020862738702112683},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.fn()
+			if !ok || math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("got %v, %v, want %v", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatisticsNeedData(t *testing.T) {
+	flat := []float64{0.01, 0.01, 0.01}
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"volatility of one return", second(Volatility([]float64{0.01}))},
+		{"beta on a flat index", second(Beta(asset[:3], flat))},
+		{"sharpe without variation", second(Sharpe(flat, 0))},
+		{"sortino without losses", second(Sortino(flat, 0))},
+		{"historical VaR of nothing", second(HistoricalVaR([]float64{math.NaN()}, 0.95))},
+	}
+	for _, tt := range tests {
+		if tt.ok {
+			t.Errorf("%s: ok = true, want false", tt.name)
+		}
+	}
+}
+
+func second(_ float64, ok bool) bool {
+	return ok
+}
+
+func TestCorrelate(t *testing.T) {
+	a := append(append([]float64{}, asset...), math.NaN(), 0.5)
+	b := append(append([]float64{}, index...), 0.5, math.NaN())
+
+	pair, ok := Correlate(a, b)
+	if !ok || pair.N != len(asset) {
+		t.Fatalf("Correlate = %+v, %v, want %d shared periods", pair, ok, len(asset))
+	}
+	if math.Abs(pair.Correlation-0.9909123289674503) > 1e-9 || math.Abs(pair.Covariance-0.000105) > 1e-12 {
+		t.Errorf("Correlate = %+v, want correlation 0.99091 and covariance 0.000105", pair)
+	}
+
+	if _, ok := Correlate(asset, []float64{0.01, 0.01, 0.01}); ok {
+		t.Error("correlation with a flat series is ok")
+	}
+	if pair, ok := Correlate([]float64{0.01}, []float64{0.02}); ok || pair.N != 1 {
+		t.Errorf("Correlate of one period = %+v, %v, want N 1 and not ok", pair, ok)
+	}
+}
+
+func TestMaxDrawdown(t *testing.T) {
+	levels := []float64{100, 120, 90, math.NaN(), 130, 104, 110, 140}
+	got := MaxDrawdown(levels)
+	if !near(got.Depth, 0.25) || got.Peak != 1 || got.Trough != 2 {
+		t.Errorf("MaxDrawdown = %+v, want 25%% from 1 to 2", got)
+	}
+
+	if got := MaxDrawdown([]float64{1, 2, 3}); got.Depth != 0 {
+		t.Errorf("MaxDrawdown of a rising series = %+v, want none", got)
+	}
+}
@@ -0,0 +1,238 @@
+// Package scoring turns the raw ratios on a scorecard into a 0-100 composite
+// score. Each factor is normalised to 0-100, either by its percentile rank
+// among peer companies or against fixed thresholds, and the composite is the
+// weighted average of those sub-scores.
+package scoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"stock-api/internal/repository"
+)
+
+// Direction says whether a larger factor value is better or worse
+type Direction string
+
+const (
+	HigherIsBetter Direction = "higher"
+	LowerIsBetter  Direction = "lower"
+)
+
+// Method is how a factor value is normalised to 0-100
+type Method string
+
+const (
+	// Percentile ranks the value among the peer group
+	Percentile Method = "percentile"
+	// Threshold maps Min..Max linearly onto 0..100, clamping outside the range
+	Threshold Method = "threshold"
+)
+
+// Factor is one weighted input to the composite score
+type Factor struct {
+	Name      string    `json:"name"`
+	Weight    float64   `json:"weight"`
+	Direction Direction `json:"direction"`
+	Method    Method    `json:"method"`
+	Min       float64   `json:"min,omitempty"`
+	Max       float64   `json:"max,omitempty"`
+	// PositiveOnly treats zero and negative values as meaningless (e.g. P/E of
+	// a loss-making company): they score 0 and are left out of peer rankings.
+	// Missing values are skipped whatever the factor.
+	PositiveOnly bool `json:"positive_only,omitempty"`
+}
+
+// Model is a set of factors plus how peer groups are chosen
+type Model struct {
+	Factors []Factor `json:"factors"`
+	// MinPeers is the smallest industry that is ranked on its own; smaller
+	// industries are ranked against every scored company instead
+	MinPeers int `json:"min_peers"`
+}
+
+// PeerGroup is a labelled set of scorecards used for percentile ranks
+type PeerGroup struct {
+	Name  string
+	Cards []repository.Scorecard
+}
+
+// factorValues maps factor names to scorecard fields; nil means the value is missing
+var factorValues = map[string]func(*repository.Scorecard) *float64{
+	"pe_ratio":          func(c *repository.Scorecard) *float64 { return c.PERatio },
+	"peg_ratio":         func(c *repository.Scorecard) *float64 { return c.PEGRatio },
+	"price_to_book":     func(c *repository.Scorecard) *float64 { return c.PriceToBook },
+	"roe_ttm":           func(c *repository.Scorecard) *float64 { return c.ROE_TTM },
+	"revenue_5y_growth": func(c *repository.Scorecard) *float64 { return c.Revenue5YGrowth },
+	"operating_margin":  func(c *repository.Scorecard) *float64 { return c.OperatingMargin },
+	"profit_margin":     func(c *repository.Scorecard) *float64 { return c.ProfitMargin },
+	"dividend_yield":    func(c *repository.Scorecard) *float64 { return c.DividendYield },
+	"beta":              func(c *repository.Scorecard) *float64 { return c.Beta },
+}
+
+// DefaultModel favours profitability and growth, with valuation as a
+// secondary check
+func DefaultModel() Model {
+	return Model{
+		MinPeers: 5,
+		Factors: []Factor{
+			{Name: "roe_ttm", Weight: 0.20, Direction: HigherIsBetter, Method: Percentile},
+			{Name: "revenue_5y_growth", Weight: 0.20, Direction: HigherIsBetter, Method: Percentile},
+			{Name: "operating_margin", Weight: 0.15, Direction: HigherIsBetter, Method: Percentile},
+			{Name: "profit_margin", Weight: 0.10, Direction: HigherIsBetter, Method: Percentile},
+			{Name: "pe_ratio", Weight: 0.15, Direction: LowerIsBetter, Method: Percentile, PositiveOnly: true},
+			{Name: "peg_ratio", Weight: 0.10, Direction: LowerIsBetter, Method: Threshold, Min: 0.5, Max: 3, PositiveOnly: true},
+			{Name: "price_to_book", Weight: 0.05, Direction: LowerIsBetter, Method: Percentile, PositiveOnly: true},
+			{Name: "dividend_yield", Weight: 0.05, Direction: HigherIsBetter, Method: Threshold, Min: 0, Max: 0.05},
+		},
+	}
+}
+
+// LoadModel reads a model from a JSON file
+func LoadModel(path string) (Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Model{}, fmt.Errorf("failed to read scoring model: %w", err)
+	}
+
+	var m Model
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Model{}, fmt.Errorf("failed to parse scoring model: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return Model{}, err
+	}
+	return m, nil
+}
+
+// Validate checks that every factor is known and consistently configured
+func (m Model) Validate() error {
+	if len(m.Factors) == 0 {
+		return fmt.Errorf("scoring model has no factors")
+	}
+
+	total := 0.0
+	for _, f := range m.Factors {
+		if _, ok := factorValues[f.Name]; !ok {
+			return fmt.Errorf("unknown scoring factor %q", f.Name)
+		}
+		if f.Weight < 0 {
+			return fmt.Errorf("factor %s has a negative weight", f.Name)
+		}
+		if f.Direction != HigherIsBetter && f.Direction != LowerIsBetter {
+			return fmt.Errorf("factor %s has invalid direction %q", f.Name, f.Direction)
+		}
+		switch f.Method {
+		case Percentile:
+		case Threshold:
+			if f.Max <= f.Min {
+				return fmt.Errorf("factor %s needs max greater than min", f.Name)
+			}
+		default:
+			return fmt.Errorf("factor %s has invalid method %q", f.Name, f.Method)
+		}
+		total += f.Weight
+	}
+	if total == 0 {
+		return fmt.Errorf("scoring model weights sum to zero")
+	}
+	return nil
+}
+
+// Score computes the composite score of card and the sub-score of every
+// factor the card has a value for. Percentile factors rank against industry
+// when it has at least MinPeers cards, and against universe otherwise.
+// Missing factors are left out and the remaining weights renormalised; ok is
+// false when no factor could be scored.
+func (m Model) Score(card *repository.Scorecard, industry, universe PeerGroup) (composite float64, subScores map[string]repository.FactorScore, ok bool) {
+	peers := universe
+	if len(industry.Cards) >= m.MinPeers && industry.Name != "" {
+		peers = industry
+	}
+
+	subScores = make(map[string]repository.FactorScore, len(m.Factors))
+	weighted, totalWeight := 0.0, 0.0
+
+	for _, f := range m.Factors {
+		v := factorValues[f.Name](card)
+		if v == nil {
+			continue
+		}
+		value := *v
+		fs := repository.FactorScore{Value: value, Weight: f.Weight, Method: string(f.Method)}
+
+		switch {
+		case f.PositiveOnly && value <= 0:
+			fs.Score = 0
+		case f.Method == Threshold:
+			fs.Score = f.threshold(value)
+		default:
+			fs.Score, fs.Peers = f.percentile(value, peers.Cards)
+			fs.PeerGroup = peers.Name
+		}
+
+		subScores[f.Name] = fs
+		weighted += f.Weight * fs.Score
+		totalWeight += f.Weight
+	}
+
+	if totalWeight == 0 {
+		return 0, subScores, false
+	}
+	// Report each factor's share of the weights actually used
+	for name, fs := range subScores {
+		fs.Weight /= totalWeight
+		subScores[name] = fs
+	}
+	return weighted / totalWeight, subScores, true
+}
+
+func (f Factor) threshold(value float64) float64 {
+	score := (value - f.Min) / (f.Max - f.Min) * 100
+	if f.Direction == LowerIsBetter {
+		score = 100 - score
+	}
+	return clamp(score)
+}
+
+// percentile ranks value among the peers' values, counting ties as half, and
+// returns the rank with the number of peers that were compared
+func (f Factor) percentile(value float64, peers []repository.Scorecard) (float64, int) {
+	below, equal, n := 0, 0, 0
+	for i := range peers {
+		pv := factorValues[f.Name](&peers[i])
+		if pv == nil {
+			continue
+		}
+		v := *pv
+		if f.PositiveOnly && v <= 0 {
+			continue
+		}
+		n++
+		if v < value {
+			below++
+		} else if v == value {
+			equal++
+		}
+	}
+	if n == 0 {
+		return 50, 0
+	}
+
+	rank := (float64(below) + float64(equal)/2) / float64(n) * 100
+	if f.Direction == LowerIsBetter {
+		rank = 100 - rank
+	}
+	return clamp(rank), n
+}
+
+func clamp(score float64) float64 {
+	if score < 0 {
+		return 0
+	}
+	if score > 100 {
+		return 100
+	}
+	return score
+}
@@ -0,0 +1,181 @@
+package scoring
+
+import (
+	"math"
+	"testing"
+
+	"stock-api/internal/repository"
+)
+
+func ptr(v float64) *float64 { return &v }
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// roeCards returns cards whose only value is the given ROE
+func roeCards(values ...float64) []repository.Scorecard {
+	cards := make([]repository.Scorecard, len(values))
+	for i, v := range values {
+		cards[i] = repository.Scorecard{ROE_TTM: ptr(v)}
+	}
+	return cards
+}
+
+func TestPercentile(t *testing.T) {
+	peers := roeCards(0.05, 0.10, 0.10, 0.20)
+	peers = append(peers, repository.Scorecard{}, repository.Scorecard{ROE_TTM: ptr(-0.1)})
+
+	tests := []struct {
+		name      string
+		factor    Factor
+		value     float64
+		want      float64
+		wantPeers int
+	}{
+		{"highest", Factor{Name: "roe_ttm", Direction: HigherIsBetter}, 0.30, 100, 5},
+		{"lowest", Factor{Name: "roe_ttm", Direction: HigherIsBetter}, -0.2, 0, 5},
+		// -0.1 and 0.05 below, two ties counted as half each
+		{"ties count as half", Factor{Name: "roe_ttm", Direction: HigherIsBetter}, 0.10, 60, 5},
+		{"lower is better flips the rank", Factor{Name: "roe_ttm", Direction: LowerIsBetter}, 0.10, 40, 5},
+		{"positive only drops non-positive peers", Factor{Name: "roe_ttm", Direction: HigherIsBetter, PositiveOnly: true}, 0.10, 50, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, n := tt.factor.percentile(tt.value, peers)
+			if !near(got, tt.want) || n != tt.wantPeers {
+				t.Errorf("percentile(%v) = %v over %d peers, want %v over %d", tt.value, got, n, tt.want, tt.wantPeers)
+			}
+		})
+	}
+
+	if got, n := (Factor{Name: "beta"}).percentile(1, peers); got != 50 || n != 0 {
+		t.Errorf("percentile without peer values = %v over %d, want 50 over 0", got, n)
+	}
+}
+
+func TestThreshold(t *testing.T) {
+	higher := Factor{Direction: HigherIsBetter, Min: 0, Max: 0.05}
+	lower := Factor{Direction: LowerIsBetter, Min: 0.5, Max: 3}
+
+	tests := []struct {
+		name   string
+		factor Factor
+		value  float64
+		want   float64
+	}{
+		{"at min", higher, 0, 0},
+		{"midway", higher, 0.025, 50},
+		{"at max", higher, 0.05, 100},
+		{"clamped above max", higher, 0.2, 100},
+		{"clamped below min", higher, -0.01, 0},
+		{"lower is better at min", lower, 0.5, 100},
+		{"lower is better midway", lower, 1.75, 50},
+		{"lower is better clamped", lower, 10, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.factor.threshold(tt.value); !near(got, tt.want) {
+				t.Errorf("threshold(%v) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Factor{Name: "roe_ttm", Weight: 1, Direction: HigherIsBetter, Method: Percentile}
+
+	tests := []struct {
+		name    string
+		factors []Factor
+		wantErr bool
+	}{
+		{"default model", DefaultModel().Factors, false},
+		{"no factors", nil, true},
+		{"unknown factor", []Factor{{Name: "eps", Weight: 1, Direction: HigherIsBetter, Method: Percentile}}, true},
+		{"negative weight", []Factor{valid, {Name: "beta", Weight: -1, Direction: LowerIsBetter, Method: Percentile}}, true},
+		{"invalid direction", []Factor{{Name: "beta", Weight: 1, Direction: "sideways", Method: Percentile}}, true},
+		{"invalid method", []Factor{{Name: "beta", Weight: 1, Direction: LowerIsBetter, Method: "zscore"}}, true},
+		{"threshold without a range", []Factor{{Name: "beta", Weight: 1, Direction: LowerIsBetter, Method: Threshold, Min: 2, Max: 2}}, true},
+		{"weights sum to zero", []Factor{{Name: "beta", Weight: 0, Direction: LowerIsBetter, Method: Percentile}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Model{Factors: tt.factors}.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScore(t *testing.T) {
+	model := Model{
+		MinPeers: 3,
+		Factors: []Factor{
+			{Name: "roe_ttm", Weight: 0.5, Direction: HigherIsBetter, Method: Percentile},
+			{Name: "dividend_yield", Weight: 0.25, Direction: HigherIsBetter, Method: Threshold, Min: 0, Max: 0.04},
+			{Name: "pe_ratio", Weight: 0.25, Direction: LowerIsBetter, Method: Threshold, Min: 10, Max: 30, PositiveOnly: true},
+		},
+	}
+	universe := PeerGroup{Name: "all", Cards: roeCards(0.05, 0.10, 0.15, 0.20)}
+	industry := PeerGroup{Name: "Software", Cards: roeCards(0.10, 0.30, 0.40)}
+
+	t.Run("every factor present", func(t *testing.T) {
+		card := repository.Scorecard{ROE_TTM: ptr(0.30), DividendYield: ptr(0.01), PERatio: ptr(20)}
+		composite, sub, ok := model.Score(&card, industry, universe)
+		if !ok {
+			t.Fatal("card wasn't scored")
+		}
+		// ROE ranks 50 in its industry, the yield scores 25 and P/E 50
+		if !near(sub["roe_ttm"].Score, 50) || sub["roe_ttm"].PeerGroup != "Software" || sub["roe_ttm"].Peers != 3 {
+			t.Errorf("roe_ttm = %+v, want 50 against 3 Software peers", sub["roe_ttm"])
+		}
+		if !near(composite, 0.5*50+0.25*25+0.25*50) {
+			t.Errorf("composite = %v, want 43.75", composite)
+		}
+	})
+
+	t.Run("small industry ranks against the universe", func(t *testing.T) {
+		card := repository.Scorecard{ROE_TTM: ptr(0.30)}
+		small := PeerGroup{Name: "Tiny", Cards: roeCards(0.30)}
+		_, sub, _ := model.Score(&card, small, universe)
+		if sub["roe_ttm"].PeerGroup != "all" || !near(sub["roe_ttm"].Score, 100) {
+			t.Errorf("roe_ttm = %+v, want 100 against all", sub["roe_ttm"])
+		}
+	})
+
+	t.Run("missing factors are re-weighted", func(t *testing.T) {
+		card := repository.Scorecard{DividendYield: ptr(0.02), PERatio: ptr(10)}
+		composite, sub, ok := model.Score(&card, industry, universe)
+		if !ok || len(sub) != 2 {
+			t.Fatalf("got %d sub-scores (ok %v), want 2", len(sub), ok)
+		}
+		if _, scored := sub["roe_ttm"]; scored {
+			t.Error("missing ROE was scored")
+		}
+		if !near(sub["dividend_yield"].Weight, 0.5) || !near(sub["pe_ratio"].Weight, 0.5) {
+			t.Errorf("weights = %v and %v, want 0.5 each", sub["dividend_yield"].Weight, sub["pe_ratio"].Weight)
+		}
+		if !near(composite, 75) {
+			t.Errorf("composite = %v, want 75", composite)
+		}
+	})
+
+	t.Run("non-positive values score zero", func(t *testing.T) {
+		card := repository.Scorecard{PERatio: ptr(-5)}
+		composite, sub, ok := model.Score(&card, industry, universe)
+		if !ok || sub["pe_ratio"].Score != 0 || composite != 0 {
+			t.Errorf("composite = %v, pe_ratio = %+v, want 0", composite, sub["pe_ratio"])
+		}
+	})
+
+	t.Run("nothing to score", func(t *testing.T) {
+		if _, _, ok := model.Score(&repository.Scorecard{}, industry, universe); ok {
+			t.Error("card without values was scored")
+		}
+	})
+}
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/mail"
+	"strings"
+	"time"
+
+	"stock-api/internal/alerts"
+	"stock-api/internal/repository"
+)
+
+var (
+	// ErrInvalidAlertRule is wrapped by every error caused by a malformed alert rule
+	ErrInvalidAlertRule = errors.New("invalid alert rule")
+	// ErrAlertRuleNotFound is returned when a user has no rule with the requested id
+	ErrAlertRuleNotFound = errors.New("alert rule not found")
+)
+
+// AlertService manages users' alert rules and evaluates them as data arrives
+type AlertService struct {
+	alertRepo     *repository.AlertRepository
+	stockRepo     *repository.StockRepository
+	watchlistRepo *repository.WatchlistRepository
+	notifiers     map[string]alerts.Notifier
+}
+
+// AlertRun summarises one evaluation of a symbol's rules
+type AlertRun struct {
+	Symbol    string `json:"symbol"`
+	Evaluated int    `json:"evaluated"`
+	Fired     int    `json:"fired"`
+}
+
+// NewAlertService creates an alert service delivering through the given
+// notifiers. The in-app feed is always available.
+func NewAlertService(alertRepo *repository.AlertRepository, stockRepo *repository.StockRepository, watchlistRepo *repository.WatchlistRepository, notifiers ...alerts.Notifier) *AlertService {
+	s := &AlertService{
+		alertRepo:     alertRepo,
+		stockRepo:     stockRepo,
+		watchlistRepo: watchlistRepo,
+		notifiers:     map[string]alerts.Notifier{alerts.ChannelInApp: alerts.InAppNotifier{}},
+	}
+	for _, n := range notifiers {
+		s.notifiers[n.Channel()] = n
+	}
+	return s
+}
+
+// normaliseRule validates a rule and fills in defaults
+func (s *AlertService) normaliseRule(rule *repository.AlertRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidAlertRule)
+	}
+	if len(rule.Name) > 128 {
+		return fmt.Errorf("%w: name is longer than 128 characters", ErrInvalidAlertRule)
+	}
+	rule.Symbol = strings.ToUpper(strings.TrimSpace(rule.Symbol))
+	if len(rule.Symbol) > 10 {
+		return fmt.Errorf("%w: symbol is longer than 10 characters", ErrInvalidAlertRule)
+	}
+	if rule.WatchlistID != 0 {
+		if rule.Symbol != "" {
+			return fmt.Errorf("%w: a rule watches either a symbol or a watchlist", ErrInvalidAlertRule)
+		}
+		w, err := s.watchlistRepo.GetWatchlist(rule.UserID, rule.WatchlistID)
+		if err != nil {
+			return err
+		}
+		if w == nil {
+			return fmt.Errorf("%w: watchlist %d not found", ErrInvalidAlertRule, rule.WatchlistID)
+		}
+	}
+
+	metric, err := alerts.ParseMetric(rule.Metric)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAlertRule, err)
+	}
+	rule.Metric = string(metric)
+	if err := alerts.ValidatePeriod(metric, rule.Period); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAlertRule, err)
+	}
+	op, err := alerts.ParseOperator(rule.Operator)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAlertRule, err)
+	}
+	rule.Operator = string(op)
+	if math.IsNaN(rule.Threshold) || math.IsInf(rule.Threshold, 0) {
+		return fmt.Errorf("%w: threshold must be a number", ErrInvalidAlertRule)
+	}
+	if rule.CooldownMinutes < 0 {
+		return fmt.Errorf("%w: cooldown_minutes can't be negative", ErrInvalidAlertRule)
+	}
+
+	if len(rule.Channels) == 0 {
+		rule.Channels = []string{alerts.ChannelInApp}
+	}
+	seen := make(map[string]bool)
+	channels := make([]string, 0, len(rule.Channels))
+	for _, ch := range rule.Channels {
+		ch = strings.ToLower(strings.TrimSpace(ch))
+		if seen[ch] {
+			continue
+		}
+		if _, ok := s.notifiers[ch]; !ok {
+			return fmt.Errorf("%w: channel %q is not available", ErrInvalidAlertRule, ch)
+		}
+		seen[ch] = true
+		channels = append(channels, ch)
+	}
+	rule.Channels = channels
+
+	rule.WebhookURL = strings.TrimSpace(rule.WebhookURL)
+	if seen[alerts.ChannelWebhook] {
+		if rule.WebhookURL == "" {
+			return fmt.Errorf("%w: webhook channel needs an http or https webhook_url", ErrInvalidAlertRule)
+		}
+	}
+	if rule.WebhookURL != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		validate := func(ctx context.Context, raw string) error { return alerts.ValidateWebhookURL(ctx, raw) }
+		if w, ok := s.notifiers[alerts.ChannelWebhook].(*alerts.WebhookNotifier); ok {
+			validate = w.ValidateURL
+		}
+		if err := validate(ctx, rule.WebhookURL); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidAlertRule, err)
+		}
+	}
+
+	rule.Email = strings.TrimSpace(rule.Email)
+	if seen[alerts.ChannelEmail] && rule.Email == "" {
+		return fmt.Errorf("%w: email channel needs an email address", ErrInvalidAlertRule)
+	}
+	if rule.Email != "" {
+		// Store the bare address so display names and header text never reach the mail headers
+		addr, err := mail.ParseAddress(rule.Email)
+		if err != nil {
+			return fmt.Errorf("%w: invalid email address: %v", ErrInvalidAlertRule, err)
+		}
+		rule.Email = addr.Address
+	}
+	return nil
+}
+
+// CreateRule creates an alert rule for the user
+func (s *AlertService) CreateRule(userID int, rule *repository.AlertRule) error {
+	rule.UserID = userID
+	if err := s.normaliseRule(rule); err != nil {
+		return err
+	}
+	return s.alertRepo.CreateRule(rule)
+}
+
+// GetRules lists the user's alert rules
+func (s *AlertService) GetRules(userID int) ([]repository.AlertRule, error) {
+	return s.alertRepo.GetRules(userID)
+}
+
+// GetRule returns one of the user's alert rules
+func (s *AlertService) GetRule(userID, id int) (*repository.AlertRule, error) {
+	rule, err := s.alertRepo.GetRule(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, ErrAlertRuleNotFound
+	}
+	return rule, nil
+}
+
+// UpdateRule replaces one of the user's alert rules and re-arms it
+func (s *AlertService) UpdateRule(userID int, rule *repository.AlertRule) error {
+	rule.UserID = userID
+	if err := s.normaliseRule(rule); err != nil {
+		return err
+	}
+	ok, err := s.alertRepo.UpdateRule(rule)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAlertRuleNotFound
+	}
+	return nil
+}
+
+// DeleteRule deletes one of the user's alert rules
+func (s *AlertService) DeleteRule(userID, id int) error {
+	ok, err := s.alertRepo.DeleteRule(userID, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAlertRuleNotFound
+	}
+	return nil
+}
+
+// GetEvents returns the user's alert feed, newest first
+func (s *AlertService) GetEvents(userID int, unreadOnly bool, limit int) ([]repository.AlertEvent, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return s.alertRepo.GetEvents(userID, unreadOnly, limit)
+}
+
+// MarkRead marks alerts in the user's feed as read; no ids marks all of them
+func (s *AlertService) MarkRead(userID int, ids []int) (int64, error) {
+	return s.alertRepo.MarkEventsRead(userID, ids)
+}
+
+// DataStored evaluates the symbol's rules, logging rather than returning
+// failures so alerting never fails the write that triggered it
+func (s *AlertService) DataStored(ctx context.Context, symbol string) {
+	run, err := s.EvaluateSymbol(ctx, symbol)
+	if err != nil {
+		log.Printf("Failed to evaluate alerts for %s: %v", symbol, err)
+		return
+	}
+	if run.Fired > 0 {
+		log.Printf("Fired %d alerts for %s", run.Fired, symbol)
+	}
+}
+
+// EvaluateSymbol runs every enabled rule watching the symbol over the bars it
+// hasn't seen yet. A rule seeing the symbol for the first time only evaluates
+// the latest bar, so new rules don't fire on history.
+func (s *AlertService) EvaluateSymbol(ctx context.Context, symbol string) (*AlertRun, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	run := &AlertRun{Symbol: symbol}
+
+	rules, err := s.alertRepo.GetEnabledRulesForSymbol(symbol)
+	if err != nil || len(rules) == 0 {
+		return run, err
+	}
+
+	lookback := 0
+	ids := make([]int, len(rules))
+	for i, rule := range rules {
+		ids[i] = rule.ID
+		if n := alerts.Lookback(alerts.Metric(rule.Metric), rule.Period); n > lookback {
+			lookback = n
+		}
+	}
+
+	states, err := s.alertRepo.GetStates(symbol, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load from the oldest bar any rule has already seen, so bars stored
+	// since then are stepped through rather than skipped. Rules without state
+	// only need the latest bar, which the lookback before now covers.
+	after := time.Now()
+	for _, rule := range rules {
+		if state, ok := states[rule.ID]; ok && state.LastBarAt != nil && state.LastBarAt.Before(after) {
+			after = *state.LastBarAt
+		}
+	}
+
+	bars, err := s.stockRepo.GetStockDataAfter(symbol, after, lookback)
+	if err != nil {
+		return nil, err
+	}
+	if len(bars) == 0 {
+		return run, nil
+	}
+
+	for _, rule := range rules {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		state, ok := states[rule.ID]
+		if !ok {
+			state = repository.AlertState{RuleID: rule.ID, Symbol: symbol, State: string(alerts.Armed)}
+		}
+		fired, evaluated, err := s.evaluateRule(ctx, rule, &state, bars)
+		if err != nil {
+			return nil, err
+		}
+		if evaluated {
+			run.Evaluated++
+		}
+		run.Fired += fired
+	}
+	return run, nil
+}
+
+// evaluateRule steps one rule's state through the bars after its last
+// evaluated bar, recording and delivering each time it fires
+func (s *AlertService) evaluateRule(ctx context.Context, rule repository.AlertRule, state *repository.AlertState, bars []repository.StockIntraDayData) (int, bool, error) {
+	last := len(bars) - 1
+	start := last
+	if state.LastBarAt != nil {
+		if !bars[last].Date.After(*state.LastBarAt) {
+			return 0, false, nil
+		}
+		for start > 0 && bars[start-1].Date.After(*state.LastBarAt) {
+			start--
+		}
+	}
+
+	metric := alerts.Metric(rule.Metric)
+	op := alerts.Operator(rule.Operator)
+	cooldown := time.Duration(rule.CooldownMinutes) * time.Minute
+	series := alerts.Series(metric, rule.Period, bars)
+
+	var lastFired time.Time
+	if state.LastFiredAt != nil {
+		lastFired = *state.LastFiredAt
+	}
+
+	fired := 0
+	current := alerts.State(state.State)
+	for i := start; i <= last; i++ {
+		previous := math.NaN()
+		if i > 0 {
+			previous = series[i-1]
+		}
+		triggered := alerts.Triggered(op, rule.Threshold, previous, series[i])
+
+		next, fire := alerts.Step(current, lastFired, cooldown, triggered, bars[i].Date)
+		current = next
+		if fire {
+			lastFired = bars[i].Date
+			if err := s.fire(ctx, rule, bars[i], series[i]); err != nil {
+				return fired, true, err
+			}
+			fired++
+		}
+	}
+
+	state.State = string(current)
+	barAt := bars[last].Date
+	state.LastBarAt = &barAt
+	if !lastFired.IsZero() {
+		state.LastFiredAt = &lastFired
+	}
+	state.LastValue = nil
+	if v := series[last]; !math.IsNaN(v) {
+		state.LastValue = &v
+	}
+	return fired, true, s.alertRepo.SaveState(state)
+}
+
+// fire records an alert and delivers it on each of the rule's channels. An
+// alert already recorded for the bar isn't delivered again.
+func (s *AlertService) fire(ctx context.Context, rule repository.AlertRule, bar repository.StockIntraDayData, value float64) error {
+	message := fmt.Sprintf("%s (%g)",
+		alerts.Describe(bar.Symbol, alerts.Metric(rule.Metric), rule.Period, alerts.Operator(rule.Operator), rule.Threshold), value)
+	event := &repository.AlertEvent{
+		RuleID:  rule.ID,
+		UserID:  rule.UserID,
+		Symbol:  bar.Symbol,
+		Value:   value,
+		BarDate: bar.Date,
+		Message: message,
+	}
+	created, err := s.alertRepo.CreateEvent(event)
+	if err != nil || !created {
+		return err
+	}
+
+	n := alerts.Notification{
+		EventID:    event.ID,
+		RuleID:     rule.ID,
+		RuleName:   rule.Name,
+		Symbol:     bar.Symbol,
+		Metric:     alerts.Metric(rule.Metric),
+		Operator:   alerts.Operator(rule.Operator),
+		Threshold:  rule.Threshold,
+		Value:      value,
+		BarDate:    bar.Date,
+		Message:    message,
+		WebhookURL: rule.WebhookURL,
+		Email:      rule.Email,
+	}
+
+	delivered := []string{}
+	var failures []string
+	for _, ch := range rule.Channels {
+		notifier, ok := s.notifiers[ch]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("%s: channel not configured", ch))
+			continue
+		}
+		if err := notifier.Notify(ctx, n); err != nil {
+			log.Printf("Failed to deliver alert %d over %s: %v", event.ID, ch, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", ch, err))
+			continue
+		}
+		delivered = append(delivered, ch)
+	}
+	return s.alertRepo.SetEventDelivery(event.ID, delivered, strings.Join(failures, "; "))
+}
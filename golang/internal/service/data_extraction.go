@@ -2,6 +2,7 @@ package service
 
 import (
     "context"
+    "errors"
     "fmt"
     "log"
     "time"
@@ -10,104 +11,95 @@ import (
     "stock-api/internal/util"
 )
 
+// DataListener is told when new bars or indicators have been stored for a symbol
+type DataListener interface {
+    DataStored(ctx context.Context, symbol string)
+}
+
+// BatchListener is a DataListener that is also told when a batch extraction
+// has finished storing bars for symbols
+type BatchListener interface {
+    DataListener
+    BatchStored(ctx context.Context, symbols []string)
+}
+
 // DataExtractionService handles fetching and storing financial data from external APIs
 type DataExtractionService struct {
-    alphaVantageClient *api.AlphaVantageClient
-    finnhubClient      *api.FinnhubClient
-    polygonClient      *api.PolygonClient
-    stockRepo          *repository.StockRepository
-    stockScoreRepo     *repository.StockScoreRepository
+    providers      *api.Registry
+    stockRepo      *repository.StockRepository
+    stockScoreRepo *repository.StockScoreRepository
+    listeners      []DataListener
 }
 
-// NewDataExtractionService creates a new data extraction service
-func NewDataExtractionService(alphaVantageClient *api.AlphaVantageClient, finnhubClient *api.FinnhubClient, polygonClient *api.PolygonClient, stockRepo *repository.StockRepository, stockScoreRepo *repository.StockScoreRepository) *DataExtractionService {
+// NewDataExtractionService creates a new data extraction service backed by the
+// given provider registry
+func NewDataExtractionService(providers *api.Registry, stockRepo *repository.StockRepository, stockScoreRepo *repository.StockScoreRepository) *DataExtractionService {
     return &DataExtractionService{
-        alphaVantageClient: alphaVantageClient,
-        finnhubClient:      finnhubClient,
-        polygonClient:      polygonClient,
-        stockRepo:          stockRepo,
-        stockScoreRepo:     stockScoreRepo,
+        providers:      providers,
+        stockRepo:      stockRepo,
+        stockScoreRepo: stockScoreRepo,
     }
 }
 
-// ExtractAndStoreStockData fetches stock data from external API and stores it in the database
-func (s *DataExtractionService) ExtractAndStoreStockData(ctx context.Context, symbol string, from time.Time, to time.Time) error {
-    log.Printf("Starting data extraction for symbol: %s", symbol)
+// AddDataListener registers a listener told whenever new intraday bars are stored
+func (s *DataExtractionService) AddDataListener(listener DataListener) {
+    s.listeners = append(s.listeners, listener)
+}
+
+// ExtractAndStoreStockData fetches interval * timespan bars from external API and stores them in the database.
+// Only intraday timespans are accepted; daily history goes through BatchExtractAndStoreDailyData.
+func (s *DataExtractionService) ExtractAndStoreStockData(ctx context.Context, symbol string, from time.Time, to time.Time, interval int, timespan api.Timespan) (*repository.UpsertCounts, error) {
+    if err := checkIntraday(timespan); err != nil {
+        return nil, err
+    }
+    log.Printf("Starting data extraction for symbol: %s (%d-%s bars)", symbol, interval, timespan)
 
-    // Get time series data from Polygon
-    timeSeries, symbol, err := s.polygonClient.GetIntradayBars(ctx, symbol, from, to, 5)
+    // Get time series data from the first bars provider that succeeds
+    timeSeries, err := s.providers.GetBars(ctx, symbol, from, to, interval, timespan)
     if err != nil {
-        return fmt.Errorf("failed to get time series data: %w", err)
+        return nil, fmt.Errorf("failed to get time series data: %w", err)
     }
 
-    log.Printf("Processing %d data points for symbol %s", len(timeSeries), symbol)
+    if len(timeSeries) == 0 {
+        return nil, fmt.Errorf("no data was stored for symbol %s", symbol)
+    }
 
-    // Process and store each data point
-    storedCount := 0
-    errorCount := 0
-    
-    for _, data := range timeSeries {
-        log.Printf("Processing data for %s", symbol)
-        
-        parsedDate := time.UnixMilli(data.Timestamp)
-
-        // Parse numeric values
-        // open, err := api.ParseFloat(data.Open)
-        // if err != nil {
-        //     log.Printf("Failed to parse open price for %s on %s: %v", symbol, date, err)
-        //     errorCount++
-        //     continue
-        // }
-
-        // high, err := api.ParseFloat(data.High)
-        // if err != nil {
-        //     log.Printf("Failed to parse high price for %s on %s: %v", symbol, date, err)
-        //     errorCount++
-        //     continue
-        // }
-
-        // low, err := api.ParseFloat(data.Low)
-        // if err != nil {
-        //     log.Printf("Failed to parse low price for %s on %s: %v", symbol, date, err)
-        //     errorCount++
-        //     continue
-        // }
-
-        // close, err := api.ParseFloat(data.Close)
-        // if err != nil {
-        //     log.Printf("Failed to parse close price for %s on %s: %v", symbol, date, err)
-        //     errorCount++
-        //     continue
-        // }
-
-        // volume, err := api.ParseFloat(data.Volume)
-        // if err != nil {
-        //     log.Printf("Failed to parse volume for %s on %s: %v", symbol, date, err)
-        //     errorCount++
-        //     continue
-        // }
-
-        // log.Printf("Storing data for %s on %s: O=%.2f, H=%.2f, L=%.2f, C=%.2f, V=%.0f", 
-        //     symbol, data.Timestamp, data.Open, data.High, data.Low, data.Close, data.Volume)
-
-        // Store in database
-        err = s.stockRepo.StoreStockData(symbol, parsedDate, data.Open, data.High, data.Low, data.Close, data.Volume)
-        if err != nil {
-            log.Printf("Failed to store data for %s on %s: %v", symbol, parsedDate, err)
-            errorCount++
-            continue
+    data := make([]repository.StockIntraDayData, 0, len(timeSeries))
+    for _, bar := range timeSeries {
+        data = append(data, repository.StockIntraDayData{
+            Symbol: symbol,
+            Date:   bar.Timestamp,
+            Open:   bar.Open,
+            High:   bar.High,
+            Low:    bar.Low,
+            Close:  bar.Close,
+            Volume: bar.Volume,
+        })
+    }
+
+    // Store all bars in one round trip
+    counts, err := s.stockRepo.StoreStockDataBatch(data)
+    if err != nil {
+        return nil, fmt.Errorf("failed to store data for symbol %s: %w", symbol, err)
+    }
+
+    log.Printf("Completed data extraction for symbol: %s - Inserted: %d, Updated: %d, Unchanged: %d",
+        symbol, counts.Inserted, counts.Updated, counts.Unchanged)
+
+    if counts.Inserted+counts.Updated > 0 {
+        for _, listener := range s.listeners {
+            listener.DataStored(ctx, symbol)
         }
-        
-        storedCount++
-        log.Printf("Successfully stored data for %s on %s", symbol, parsedDate)
     }
 
-    log.Printf("Completed data extraction for symbol: %s - Stored: %d, Errors: %d", symbol, storedCount, errorCount)
-    
-    if storedCount == 0 {
-        return fmt.Errorf("no data was stored for symbol %s", symbol)
+    return counts, nil
+}
+
+// checkIntraday rejects timespans whose bars don't belong in stocks_intraday
+func checkIntraday(timespan api.Timespan) error {
+    if !timespan.Intraday() {
+        return fmt.Errorf("%s bars aren't intraday; extract daily history instead", timespan)
     }
-    
     return nil
 }
 
@@ -150,32 +142,43 @@ func (s *DataExtractionService) ExtractAndStoreStockData(ctx context.Context, sy
 //     return nil
 // }
 
-// BatchExtractData extracts data for multiple symbols
-func (s *DataExtractionService) BatchExtractData(ctx context.Context, symbols []string, from time.Time, to time.Time) error {
+// BatchExtractData extracts data for multiple symbols. Symbols that fail are
+// reported in the result without failing the batch.
+func (s *DataExtractionService) BatchExtractData(ctx context.Context, symbols []string, from time.Time, to time.Time, interval int, timespan api.Timespan) (*BatchResult, error) {
+    if err := checkIntraday(timespan); err != nil {
+        return nil, err
+    }
     log.Printf("Starting batch extraction for %d symbols", len(symbols))
-    
+
+    result := &BatchResult{Stored: []string{}, Failed: map[string]string{}, Rows: &repository.UpsertCounts{}}
     for i, symbol := range symbols {
         log.Printf("Processing symbol %d/%d: %s", i+1, len(symbols), symbol)
-        
-        // Add delay between requests to respect rate limits
-        // if i > 0 {
-        //     log.Printf("Waiting 12 seconds before next request...")
-        //     time.Sleep(12 * time.Second) // Alpha Vantage allows 5 requests per minute
-        // }
 
-        err := s.ExtractAndStoreStockData(ctx, symbol, from, to)
+        counts, err := s.ExtractAndStoreStockData(ctx, symbol, from, to, interval, timespan)
         if err != nil {
             log.Printf("Failed to extract data for %s: %v", symbol, err)
+            result.Failed[symbol] = err.Error()
             continue
         }
+        result.Stored = append(result.Stored, symbol)
+        result.Rows.Add(counts)
     }
-    
-    log.Printf("Completed batch extraction for %d symbols", len(symbols))
-    return nil
-} 
+
+    log.Printf("Completed batch extraction for %d symbols - Inserted: %d, Updated: %d, Unchanged: %d",
+        len(symbols), result.Rows.Inserted, result.Rows.Updated, result.Rows.Unchanged)
+
+    if len(result.Stored) > 0 {
+        for _, listener := range s.listeners {
+            if batch, ok := listener.(BatchListener); ok {
+                batch.BatchStored(ctx, result.Stored)
+            }
+        }
+    }
+    return result, nil
+}
 
 func (s *DataExtractionService) ExtractAndStoreSymbols(ctx context.Context, exchange string) error {
-    stocks, err := s.finnhubClient.GetStockSymbols(ctx, exchange)
+    stocks, err := s.providers.GetStockSymbols(ctx, exchange)
     if err != nil {
         return fmt.Errorf("failed to get stock symbols: %w", err)
     }
@@ -222,7 +225,7 @@ func (s *DataExtractionService) ExtractAndStoreStockMetaData(ctx context.Context
     log.Printf("Starting metadata extraction for stock symbols in exchange: %s", exchange)
 
     // Get stock symbols from Finnhub
-    stocks, err := s.finnhubClient.GetStockSymbols(ctx, exchange)
+    stocks, err := s.providers.GetStockSymbols(ctx, exchange)
     if err != nil {
         return fmt.Errorf("failed to get stock symbols: %w", err)
     }
@@ -282,7 +285,7 @@ func (s *DataExtractionService) ExtractAndStoreCompanyData(ctx context.Context,
 	errorCount := 0
 
 	for _, symbol := range symbols {
-		profile, err := s.finnhubClient.GetCompanyProfile(ctx, symbol)
+		profile, err := s.providers.GetCompanyProfile(ctx, symbol)
 		if err != nil {
 			log.Printf("failed to get company profile for %s: %v", symbol, err)
 			errorCount++
@@ -340,68 +343,112 @@ func (s *DataExtractionService) ExtractAndStoreCompanyData(ctx context.Context,
 	return nil
 }
 
-func (s *DataExtractionService) BatchExtractAndStoreStockOverview(ctx context.Context, symbols []string) error {
-	storedCount := 0
-	errorCount := 0
+// BatchResult reports what happened to each symbol of a batch extraction
+type BatchResult struct {
+	Stored  []string          `json:"stored"`
+	Failed  map[string]string `json:"failed,omitempty"`
+	Skipped []string          `json:"skipped,omitempty"`
+	// Rows is set by batches that write bars
+	Rows *repository.UpsertCounts `json:"rows,omitempty"`
+}
 
-	for _, symbol := range symbols {
-		overview, err := s.alphaVantageClient.GetOverview(ctx, symbol)
-		if err != nil {
-			log.Printf("failed to get overview data for %s: %v", symbol, err)
-			errorCount++
+// runSymbolBatch calls extract for each symbol. Failures for a single symbol are
+// recorded and the batch moves on, but once a provider reports its quota is used
+// up the remaining symbols are skipped rather than hammering it further.
+func runSymbolBatch(symbols []string, kind string, extract func(symbol string) error) (*BatchResult, error) {
+	result := &BatchResult{Stored: []string{}, Failed: map[string]string{}}
+
+	for i, symbol := range symbols {
+		err := extract(symbol)
+		if err == nil {
+			result.Stored = append(result.Stored, symbol)
+			log.Printf("Successfully stored %s data for %s", kind, symbol)
 			continue
 		}
-        log.Printf("OVERVIEW: %s", overview.PERatio)
 
-        err = s.stockScoreRepo.StoreOverview(overview)
-        if err != nil {
-            return err
-        }
-        storedCount++
-        log.Printf("Successfully stored overview data for %s", symbol)
-    }
+		if errors.Is(err, api.ErrQuotaExceeded) || errors.Is(err, api.ErrRateLimited) {
+			result.Skipped = append(result.Skipped, symbols[i:]...)
+			log.Printf("Provider quota exhausted while extracting %s data, skipping %d symbols: %v", kind, len(result.Skipped), err)
+			return result, fmt.Errorf("stopped %s batch after %d of %d symbols: %w", kind, i, len(symbols), err)
+		}
 
-	return nil
-}
+		log.Printf("failed to extract %s data for %s: %v", kind, symbol, err)
+		result.Failed[symbol] = err.Error()
+	}
 
-func (s *DataExtractionService) BatchExtractAndStoreStockIncomeStatment(ctx context.Context, symbols []string) error {
-	storedCount := 0
-	errorCount := 0
+	if len(result.Stored) == 0 && len(result.Failed) > 0 {
+		return result, fmt.Errorf("no %s data was stored for provided symbols", kind)
+	}
 
-	for _, symbol := range symbols {
-		incomeStatement, err := s.alphaVantageClient.GetIncomeStatement(ctx, symbol)
+	return result, nil
+}
+
+func (s *DataExtractionService) BatchExtractAndStoreStockOverview(ctx context.Context, symbols []string) (*BatchResult, error) {
+	return runSymbolBatch(symbols, "overview", func(symbol string) error {
+		overview, err := s.providers.GetOverview(ctx, symbol)
 		if err != nil {
-			log.Printf("failed to get income data for %s: %v", symbol, err)
-			errorCount++
-			continue
+			return err
 		}
+		return s.stockScoreRepo.StoreOverview(overview)
+	})
+}
 
-        s.stockScoreRepo.StoreIncomeStatement(symbol, incomeStatement)
-        storedCount++
-        log.Printf("Successfully stored income data for %s", symbol)
-    }
+func (s *DataExtractionService) BatchExtractAndStoreStockIncomeStatment(ctx context.Context, symbols []string) (*BatchResult, error) {
+	return runSymbolBatch(symbols, "income statement", func(symbol string) error {
+		incomeStatement, err := s.providers.GetIncomeStatement(ctx, symbol)
+		if err != nil {
+			return err
+		}
+		return s.stockScoreRepo.StoreIncomeStatement(symbol, incomeStatement)
+	})
+}
 
-	return nil
+func (s *DataExtractionService) BatchExtractAndStoreStockBalanceStatement(ctx context.Context, symbols []string) (*BatchResult, error) {
+	return runSymbolBatch(symbols, "balance sheet", func(symbol string) error {
+		balanceSheet, err := s.providers.GetBalanceSheet(ctx, symbol)
+		if err != nil {
+			return err
+		}
+		return s.stockScoreRepo.StoreBalanceSheet(symbol, balanceSheet)
+	})
 }
 
-func (s *DataExtractionService) BatchExtractAndStoreStockBalanceStatement(ctx context.Context, symbols []string) error {
-	storedCount := 0
-	errorCount := 0
+// BatchExtractAndStoreDailyData fetches daily bars for each symbol and stores them in stocks_daily
+func (s *DataExtractionService) BatchExtractAndStoreDailyData(ctx context.Context, symbols []string, from time.Time, to time.Time) (*BatchResult, error) {
+	// Daily bars are stamped at midnight US/Eastern by the providers
+	eastern, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		eastern = time.UTC
+	}
 
-	for _, symbol := range symbols {
-		balanceSheet, err := s.alphaVantageClient.GetBalanceSheet(ctx, symbol)
-        log.Printf("Balance sheet %s", balanceSheet.AnnualReports[0].TotalShareholderEquity)
-        
+	return runSymbolBatch(symbols, "daily", func(symbol string) error {
+		bars, err := s.providers.GetBars(ctx, symbol, from, to, 1, api.TimespanDay)
 		if err != nil {
-			log.Printf("failed to get balance sheet data for %s: %v", symbol, err)
-			errorCount++
-			continue
+			return err
+		}
+		if len(bars) == 0 {
+			return fmt.Errorf("no daily bars returned for %s", symbol)
 		}
 
-        s.stockScoreRepo.StoreBalanceSheet(symbol, balanceSheet)
-        storedCount++
-        log.Printf("Successfully stored balance sheet data for %s", symbol)
-    }
+		data := make([]repository.StockDailyData, 0, len(bars))
+		for _, bar := range bars {
+			data = append(data, repository.StockDailyData{
+				Symbol: symbol,
+				Date:   bar.Timestamp.In(eastern),
+				Open:   bar.Open,
+				High:   bar.High,
+				Low:    bar.Low,
+				Close:  bar.Close,
+				Volume: bar.Volume,
+			})
+		}
 
-	return nil
+		counts, err := s.stockRepo.StoreDailyDataBatch(data)
+		if err != nil {
+			return err
+		}
+		log.Printf("Stored %d daily bars for %s - Inserted: %d, Updated: %d, Unchanged: %d",
+			len(bars), symbol, counts.Inserted, counts.Updated, counts.Unchanged)
+		return nil
+	})
 }
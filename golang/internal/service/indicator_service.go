@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"stock-api/internal/indicators"
+	"stock-api/internal/repository"
+)
+
+// indicatorLookback is how many warm-up windows of already-processed bars are
+// reloaded on each incremental run, so Wilder-smoothed values settle before
+// the first new bar
+const indicatorLookback = 3
+
+// IndicatorService computes technical indicators for stored intraday bars
+type IndicatorService struct {
+	stockRepo     *repository.StockRepository
+	indicatorRepo *repository.IndicatorRepository
+	params        indicators.Params
+	listeners     []DataListener
+}
+
+// IndicatorValues holds the requested indicators for one bar
+type IndicatorValues struct {
+	Date   time.Time              `json:"date"`
+	Values map[string]interface{} `json:"values"`
+}
+
+// indicatorFields maps the names accepted by GetIndicators to stored columns
+var indicatorFields = map[string]func(*repository.StockIntraDayIndicators) interface{}{
+	"rvol":             func(i *repository.StockIntraDayIndicators) interface{} { return i.RVOL },
+	"price_change_pct": func(i *repository.StockIntraDayIndicators) interface{} { return i.PriceChangePct },
+	"rsi":              func(i *repository.StockIntraDayIndicators) interface{} { return i.RSI },
+	"volume_spike":     func(i *repository.StockIntraDayIndicators) interface{} { return i.VolumeSpike },
+	"atr":              func(i *repository.StockIntraDayIndicators) interface{} { return i.ATR },
+	"obv":              func(i *repository.StockIntraDayIndicators) interface{} { return i.OBV },
+	"sma":              func(i *repository.StockIntraDayIndicators) interface{} { return i.SMA },
+	"ema":              func(i *repository.StockIntraDayIndicators) interface{} { return i.EMA },
+	"macd":             func(i *repository.StockIntraDayIndicators) interface{} { return i.MACD },
+	"macd_signal":      func(i *repository.StockIntraDayIndicators) interface{} { return i.MACDSignal },
+	"macd_hist":        func(i *repository.StockIntraDayIndicators) interface{} { return i.MACDHist },
+	"bb_upper":         func(i *repository.StockIntraDayIndicators) interface{} { return i.BBUpper },
+	"bb_middle":        func(i *repository.StockIntraDayIndicators) interface{} { return i.BBMiddle },
+	"bb_lower":         func(i *repository.StockIntraDayIndicators) interface{} { return i.BBLower },
+	"vwap":             func(i *repository.StockIntraDayIndicators) interface{} { return i.VWAP },
+}
+
+func NewIndicatorService(stockRepo *repository.StockRepository, indicatorRepo *repository.IndicatorRepository) *IndicatorService {
+	return &IndicatorService{
+		stockRepo:     stockRepo,
+		indicatorRepo: indicatorRepo,
+		params:        indicators.DefaultParams(),
+	}
+}
+
+// AddDataListener registers a listener told whenever new indicators are stored
+func (s *IndicatorService) AddDataListener(listener DataListener) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// ParseIndicatorNames splits a comma-separated list of indicator names,
+// returning every known name when the list is empty
+func ParseIndicatorNames(list string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := indicatorFields[name]; !ok {
+			return nil, fmt.Errorf("unknown indicator %q", name)
+		}
+		names = append(names, name)
+	}
+
+	if len(names) == 0 {
+		for name := range indicatorFields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	return names, nil
+}
+
+// ComputeAndStore computes indicators for the bars of a symbol that have none
+// yet and upserts them. Only bars past every warm-up window are stored.
+func (s *IndicatorService) ComputeAndStore(ctx context.Context, symbol string) (*repository.UpsertCounts, error) {
+	latest, err := s.indicatorRepo.GetLatestIndicators(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	var after time.Time
+	if latest != nil {
+		after = latest.Date
+	}
+
+	bars, err := s.stockRepo.GetStockDataAfter(symbol, after, indicatorLookback*s.params.WarmUp())
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	set := indicators.Compute(bars, s.params)
+
+	// OBV is cumulative, so continue from the stored value rather than from
+	// the start of the reloaded window
+	obvOffset := 0.0
+	if latest != nil && latest.OBV != nil {
+		for i, bar := range bars {
+			if bar.Date.Equal(latest.Date) {
+				obvOffset = float64(*latest.OBV) - set.OBV[i]
+				break
+			}
+		}
+	}
+
+	var rows []repository.StockIntraDayIndicators
+	for i, bar := range bars {
+		if latest != nil && !bar.Date.After(latest.Date) {
+			continue
+		}
+		if !set.RSI.Valid(i) || !set.ATR.Valid(i) || !set.RVOL.Valid(i) {
+			continue
+		}
+
+		obv := int64(math.Round(set.OBV[i] + obvOffset))
+		spike := set.VolumeSpike[i]
+		rows = append(rows, repository.StockIntraDayIndicators{
+			Symbol:         symbol,
+			Date:           bar.Date,
+			RVOL:           valueAt(set.RVOL, i),
+			PriceChangePct: valueAt(set.PriceChangePct, i),
+			RSI:            valueAt(set.RSI, i),
+			VolumeSpike:    &spike,
+			ATR:            valueAt(set.ATR, i),
+			OBV:            &obv,
+			SMA:            valueAt(set.SMA, i),
+			EMA:            valueAt(set.EMA, i),
+			MACD:           valueAt(set.MACD.MACD, i),
+			MACDSignal:     valueAt(set.MACD.Signal, i),
+			MACDHist:       valueAt(set.MACD.Histogram, i),
+			BBUpper:        valueAt(set.Bollinger.Upper, i),
+			BBMiddle:       valueAt(set.Bollinger.Middle, i),
+			BBLower:        valueAt(set.Bollinger.Lower, i),
+			VWAP:           valueAt(set.VWAP, i),
+		})
+	}
+
+	counts, err := s.indicatorRepo.StoreIndicatorsBatch(rows)
+	if err != nil {
+		return nil, err
+	}
+	if counts.Inserted+counts.Updated > 0 {
+		for _, listener := range s.listeners {
+			listener.DataStored(ctx, symbol)
+		}
+	}
+	return counts, nil
+}
+
+// BatchComputeAndStore runs ComputeAndStore for each symbol
+func (s *IndicatorService) BatchComputeAndStore(ctx context.Context, symbols []string) (*BatchResult, error) {
+	rows := &repository.UpsertCounts{}
+	result, err := runSymbolBatch(symbols, "indicator", func(symbol string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		counts, err := s.ComputeAndStore(ctx, symbol)
+		rows.Add(counts)
+		return err
+	})
+	result.Rows = rows
+	return result, err
+}
+
+// GetIndicators returns the named indicators for a symbol within a date range
+func (s *IndicatorService) GetIndicators(symbol string, names []string, startDate, endDate time.Time) ([]IndicatorValues, error) {
+	data, err := s.indicatorRepo.GetIndicators(symbol, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]IndicatorValues, 0, len(data))
+	for i := range data {
+		v := IndicatorValues{Date: data[i].Date, Values: make(map[string]interface{}, len(names))}
+		for _, name := range names {
+			v.Values[name] = indicatorFields[name](&data[i])
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func valueAt(s indicators.Series, i int) *float64 {
+	if !s.Valid(i) {
+		return nil
+	}
+	v := s[i]
+	return &v
+}
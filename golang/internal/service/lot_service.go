@@ -0,0 +1,229 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"stock-api/internal/portfolio"
+	"stock-api/internal/repository"
+)
+
+// LotService reports cost basis and gains lot by lot and imports broker executions
+type LotService struct {
+	portfolioRepo *repository.PortfolioRepository
+	stockRepo     *repository.StockRepository
+}
+
+// GainTotals sums proceeds, cost basis and gain over a set of realised lots
+type GainTotals struct {
+	Proceeds  float64 `json:"proceeds"`
+	CostBasis float64 `json:"cost_basis"`
+	Gain      float64 `json:"gain"`
+}
+
+func (g *GainTotals) add(lot portfolio.RealizedLot) {
+	g.Proceeds += lot.Proceeds
+	g.CostBasis += lot.CostBasis
+	g.Gain += lot.Gain
+}
+
+// RealizedGainsReport lists the lots closed by sells in one tax year
+type RealizedGainsReport struct {
+	Year      int                     `json:"year"`
+	Lots      []portfolio.RealizedLot `json:"lots"`
+	ShortTerm GainTotals              `json:"short_term"`
+	LongTerm  GainTotals              `json:"long_term"`
+	Total     GainTotals              `json:"total"`
+}
+
+// OpenLot is an unsold lot valued at the latest stored price. Valuation
+// fields are nil when no price is available for the symbol.
+type OpenLot struct {
+	portfolio.Lot
+	CostBasis      float64  `json:"cost_basis"`
+	LastPrice      *float64 `json:"last_price"`
+	MarketValue    *float64 `json:"market_value"`
+	UnrealizedGain *float64 `json:"unrealized_gain"`
+	// Term is how a gain would be classified if the lot were sold today
+	Term portfolio.Term `json:"term"`
+}
+
+// OpenLotsReport lists open lots with totals over the priced ones
+type OpenLotsReport struct {
+	Lots           []OpenLot `json:"lots"`
+	CostBasis      float64   `json:"cost_basis"`
+	MarketValue    float64   `json:"market_value"`
+	UnrealizedGain float64   `json:"unrealized_gain"`
+	// Unpriced lists symbols whose lots are missing from the market value
+	Unpriced []string `json:"unpriced"`
+}
+
+// LotImportResult summarises a broker CSV import
+type LotImportResult struct {
+	Imported   int `json:"imported"`
+	Duplicates int `json:"duplicates"`
+	Skipped    int `json:"skipped"`
+}
+
+func NewLotService(portfolioRepo *repository.PortfolioRepository, stockRepo *repository.StockRepository) *LotService {
+	return &LotService{portfolioRepo: portfolioRepo, stockRepo: stockRepo}
+}
+
+// matchLots matches the user's sells to lots, optionally for one account
+func (s *LotService) matchLots(userID, accountID int) ([]portfolio.Lot, []portfolio.RealizedLot, error) {
+	trades, err := s.portfolioRepo.GetTrades(userID, accountID, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	accounts, err := s.portfolioRepo.GetAccounts(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return portfolio.MatchLots(trades, accountLotMethods(accounts))
+}
+
+// RealizedGains reports the lots closed by sells dated in the given calendar year
+func (s *LotService) RealizedGains(userID, year, accountID int) (*RealizedGainsReport, error) {
+	_, realized, err := s.matchLots(userID, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RealizedGainsReport{Year: year, Lots: []portfolio.RealizedLot{}}
+	for _, lot := range realized {
+		if lot.Sold.Year() != year {
+			continue
+		}
+		report.Lots = append(report.Lots, lot)
+		if lot.Term == portfolio.LongTerm {
+			report.LongTerm.add(lot)
+		} else {
+			report.ShortTerm.add(lot)
+		}
+		report.Total.add(lot)
+	}
+	return report, nil
+}
+
+// OpenLots lists the user's unsold lots, optionally for one account, valued
+// at the latest stored price
+func (s *LotService) OpenLots(userID, accountID int) (*OpenLotsReport, error) {
+	lots, _, err := s.matchLots(userID, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report := &OpenLotsReport{Lots: []OpenLot{}, Unpriced: []string{}}
+	prices := make(map[string]*float64)
+	for _, lot := range lots {
+		price, ok := prices[lot.Symbol]
+		if !ok {
+			if latest, err := s.stockRepo.GetLatestPrice(lot.Symbol); err == nil {
+				price = &latest
+			} else {
+				report.Unpriced = append(report.Unpriced, lot.Symbol)
+			}
+			prices[lot.Symbol] = price
+		}
+
+		open := OpenLot{
+			Lot:       lot,
+			CostBasis: lot.Quantity * lot.CostPerShare,
+			LastPrice: price,
+			Term:      portfolio.HoldingTerm(lot.Acquired, now),
+		}
+		report.CostBasis += open.CostBasis
+		if price != nil {
+			marketValue := lot.Quantity * *price
+			gain := marketValue - open.CostBasis
+			open.MarketValue = &marketValue
+			open.UnrealizedGain = &gain
+			report.MarketValue += marketValue
+			report.UnrealizedGain += gain
+		}
+		report.Lots = append(report.Lots, open)
+	}
+	return report, nil
+}
+
+// ImportCSV records the buys and sells in a broker CSV export against one of
+// the user's accounts. Rows matching a trade already in the account are
+// skipped so the same export can be imported twice, and nothing is stored
+// unless the whole file leaves a valid ledger.
+func (s *LotService) ImportCSV(userID, accountID int, r io.Reader) (*LotImportResult, error) {
+	account, err := s.portfolioRepo.GetAccount(userID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, fmt.Errorf("account %d: %w", accountID, ErrPortfolioNotFound)
+	}
+
+	parsed, err := portfolio.ParseBrokerCSV(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTrade, err)
+	}
+
+	result := &LotImportResult{Skipped: parsed.Skipped}
+	err = s.portfolioRepo.WithLedgerLock(userID, func(l *repository.LedgerTx) error {
+		existing, err := l.GetTrades(accountID, "")
+		if err != nil {
+			return err
+		}
+		seen := make(map[string]int)
+		for _, t := range existing {
+			seen[importKey(t)]++
+		}
+
+		var trades []repository.Trade
+		symbols := []string{}
+		for i := range parsed.Trades {
+			t := parsed.Trades[i]
+			t.AccountID = accountID
+			if err := normaliseTrade(&t); err != nil {
+				return fmt.Errorf("csv trade %d: %w", i+1, err)
+			}
+
+			key := importKey(t)
+			if seen[key] > 0 {
+				seen[key]--
+				result.Duplicates++
+				continue
+			}
+			trades = append(trades, t)
+			symbols = append(symbols, t.Symbol)
+		}
+
+		if len(trades) == 0 {
+			return nil
+		}
+
+		err = checkLedger(l, symbols, func(ledger []repository.Trade) []repository.Trade {
+			return appendPending(ledger, trades)
+		})
+		if err != nil {
+			return err
+		}
+
+		created, err := l.CreateTrades(trades)
+		if err != nil {
+			return err
+		}
+		if !created {
+			return fmt.Errorf("account %d: %w", accountID, ErrPortfolioNotFound)
+		}
+		result.Imported = len(trades)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importKey identifies an execution for duplicate detection
+func importKey(t repository.Trade) string {
+	return fmt.Sprintf("%s|%s|%s|%g|%g", t.TradeDate.Format("2006-01-02"), t.Type, t.Symbol, t.Quantity, t.Price)
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"stock-api/internal/repository"
+)
+
+// ErrInvalidMarketQuery is wrapped by errors caused by a malformed movers query
+var ErrInvalidMarketQuery = errors.New("invalid market query")
+
+// Market mover rankings
+const (
+	MoversGainers = "gainers"
+	MoversLosers  = "losers"
+	MoversActive  = "active"
+	MoversUnusual = "unusual"
+)
+
+const (
+	// marketMoversTTL bounds how stale cached movers get when bars arrive
+	// without going through an extraction batch
+	marketMoversTTL = 5 * time.Minute
+	// defaultUnusualRVOL is the paced relative volume a symbol needs to count as unusual
+	defaultUnusualRVOL = 2.0
+	// sessionMinutes is the length of the regular US session
+	sessionMinutes = 390
+	// minSessionMinutes keeps paced relative volume sane in the first minutes of the session
+	minSessionMinutes = 15
+)
+
+// MarketService ranks the symbols traded in the latest session. Rankings
+// are computed from one snapshot query and cached until new bars arrive.
+type MarketService struct {
+	stockRepo *repository.StockRepository
+	session   *time.Location
+
+	mu         sync.Mutex
+	movers     []Mover
+	sessionDay time.Time
+	computedAt time.Time
+	stale      bool
+}
+
+// Mover is one symbol's move in the latest session. RelativeVolume compares
+// the session's volume with the volume an average day would have traded by
+// the time of the last bar; it is nil without daily volume history.
+type Mover struct {
+	repository.SessionSnapshot
+	Change         *float64 `json:"change"`
+	ChangePct      *float64 `json:"change_pct"`
+	RelativeVolume *float64 `json:"relative_volume"`
+}
+
+// MoversQuery selects a ranking and filters it by stocks_metadata fields
+type MoversQuery struct {
+	Type     string
+	Limit    int
+	Exchange string
+	Industry string
+	// MinRelativeVolume is the unusual-volume cut-off; 0 uses the default
+	MinRelativeVolume float64
+}
+
+// MoversResult is one ranking of the latest session
+type MoversResult struct {
+	Type       string    `json:"type"`
+	Session    string    `json:"session"`
+	ComputedAt time.Time `json:"computed_at"`
+	Movers     []Mover   `json:"movers"`
+}
+
+func NewMarketService(stockRepo *repository.StockRepository) *MarketService {
+	session, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		session = time.UTC
+	}
+	return &MarketService{stockRepo: stockRepo, session: session, stale: true}
+}
+
+// DataStored marks the cached movers stale so the next request recomputes them
+func (s *MarketService) DataStored(ctx context.Context, symbol string) {
+	s.mu.Lock()
+	s.stale = true
+	s.mu.Unlock()
+}
+
+// BatchStored recomputes the cached movers once an extraction batch has landed
+func (s *MarketService) BatchStored(ctx context.Context, symbols []string) {
+	if err := s.Refresh(ctx); err != nil {
+		log.Printf("Failed to refresh market movers: %v", err)
+	}
+}
+
+// Refresh recomputes movers for the session of the newest stored bar
+func (s *MarketService) Refresh(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refreshLocked(ctx)
+}
+
+func (s *MarketService) refreshLocked(ctx context.Context) error {
+	latest, ok, err := s.stockRepo.GetLatestBarTime()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		s.movers, s.sessionDay, s.computedAt, s.stale = nil, time.Time{}, time.Now(), false
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Bars are stored in UTC; sessions are US/Eastern trading days
+	local := latest.In(s.session)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, s.session)
+	snapshots, err := s.stockRepo.GetSessionSnapshots(day.UTC(), day)
+	if err != nil {
+		return err
+	}
+
+	open := day.Add(9*time.Hour + 30*time.Minute)
+	movers := make([]Mover, len(snapshots))
+	for i, snap := range snapshots {
+		m := Mover{SessionSnapshot: snap}
+		if snap.PreviousClose != nil && *snap.PreviousClose > 0 {
+			change := snap.LastPrice - *snap.PreviousClose
+			pct := change / *snap.PreviousClose * 100
+			m.Change = &change
+			m.ChangePct = &pct
+		}
+		if snap.AverageVolume != nil && *snap.AverageVolume > 0 {
+			elapsed := snap.LastBarAt.Sub(open).Minutes()
+			if elapsed < minSessionMinutes {
+				elapsed = minSessionMinutes
+			}
+			if elapsed > sessionMinutes {
+				elapsed = sessionMinutes
+			}
+			rvol := snap.Volume / (*snap.AverageVolume * elapsed / sessionMinutes)
+			m.RelativeVolume = &rvol
+		}
+		movers[i] = m
+	}
+
+	s.movers, s.sessionDay, s.computedAt, s.stale = movers, day, time.Now(), false
+	return nil
+}
+
+// Movers returns the top of one ranking, refreshing the cache when new bars
+// have been stored since it was computed
+func (s *MarketService) Movers(ctx context.Context, q MoversQuery) (*MoversResult, error) {
+	q.Type = strings.ToLower(strings.TrimSpace(q.Type))
+	if q.Type == "" {
+		q.Type = MoversGainers
+	}
+	switch q.Type {
+	case MoversGainers, MoversLosers, MoversActive, MoversUnusual:
+	default:
+		return nil, fmt.Errorf("%w: unknown movers type %q (use gainers, losers, active or unusual)", ErrInvalidMarketQuery, q.Type)
+	}
+	if q.Limit <= 0 {
+		q.Limit = 20
+	}
+	if q.Limit > 100 {
+		return nil, fmt.Errorf("%w: limit can't exceed 100", ErrInvalidMarketQuery)
+	}
+	if q.MinRelativeVolume <= 0 {
+		q.MinRelativeVolume = defaultUnusualRVOL
+	}
+
+	s.mu.Lock()
+	if s.stale || time.Since(s.computedAt) > marketMoversTTL {
+		if err := s.refreshLocked(ctx); err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+	}
+	all, day, computedAt := s.movers, s.sessionDay, s.computedAt
+	s.mu.Unlock()
+
+	var picked []Mover
+	for _, m := range all {
+		if q.Exchange != "" && !strings.EqualFold(m.Exchange, q.Exchange) {
+			continue
+		}
+		if q.Industry != "" && !strings.EqualFold(m.Industry, q.Industry) {
+			continue
+		}
+		switch q.Type {
+		case MoversGainers:
+			if m.ChangePct == nil || *m.ChangePct <= 0 {
+				continue
+			}
+		case MoversLosers:
+			if m.ChangePct == nil || *m.ChangePct >= 0 {
+				continue
+			}
+		case MoversUnusual:
+			if m.RelativeVolume == nil || *m.RelativeVolume < q.MinRelativeVolume {
+				continue
+			}
+		}
+		picked = append(picked, m)
+	}
+
+	sort.SliceStable(picked, func(i, j int) bool {
+		switch q.Type {
+		case MoversGainers:
+			return *picked[i].ChangePct > *picked[j].ChangePct
+		case MoversLosers:
+			return *picked[i].ChangePct < *picked[j].ChangePct
+		case MoversUnusual:
+			return *picked[i].RelativeVolume > *picked[j].RelativeVolume
+		default:
+			return picked[i].Volume > picked[j].Volume
+		}
+	})
+	if len(picked) > q.Limit {
+		picked = picked[:q.Limit]
+	}
+	if picked == nil {
+		picked = []Mover{}
+	}
+
+	result := &MoversResult{Type: q.Type, ComputedAt: computedAt, Movers: picked}
+	if !day.IsZero() {
+		result.Session = day.Format("2006-01-02")
+	}
+	return result, nil
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"stock-api/internal/performance"
+	"stock-api/internal/repository"
+)
+
+// ErrInvalidAnalytics is wrapped by every error caused by a malformed analytics request
+var ErrInvalidAnalytics = errors.New("invalid analytics request")
+
+// CashSymbol marks a position held in cash, valued at 1 per unit
+const CashSymbol = "CASH"
+
+// PositionQuantity is the quantity of a symbol held from Date until the next
+// entry for the same symbol
+type PositionQuantity struct {
+	Date     time.Time `json:"date"`
+	Symbol   string    `json:"symbol"`
+	Quantity float64   `json:"quantity"`
+}
+
+// PerformanceRequest describes a portfolio by its external cash flows and
+// position quantities over time
+type PerformanceRequest struct {
+	CashFlows []performance.Flow
+	Positions []PositionQuantity
+	// Start and End bound the valuation; zero values mean the first position
+	// or flow and today
+	Start     time.Time
+	End       time.Time
+	Windows   []string
+	Benchmark string
+}
+
+// CurvePoint is one day of the equity curve. Returns are cumulative from the
+// first day.
+type CurvePoint struct {
+	Date             time.Time `json:"date"`
+	Value            float64   `json:"value"`
+	NetFlow          float64   `json:"net_flow"`
+	CumulativeReturn float64   `json:"cumulative_return"`
+	BenchmarkReturn  *float64  `json:"benchmark_return"`
+}
+
+// PerformanceReport holds return summaries per window and the daily equity curve
+type PerformanceReport struct {
+	Start     time.Time                  `json:"start"`
+	End       time.Time                  `json:"end"`
+	Benchmark string                     `json:"benchmark,omitempty"`
+	Windows   []performance.WindowReturn `json:"windows"`
+	Curve     []CurvePoint               `json:"curve"`
+}
+
+// PerformanceService values caller-supplied portfolios against stored prices
+type PerformanceService struct {
+	prices *priceHistory
+}
+
+func NewPerformanceService(stockRepo *repository.StockRepository, extractionService *DataExtractionService) *PerformanceService {
+	return &PerformanceService{prices: newPriceHistory(stockRepo, extractionService)}
+}
+
+// Performance values the portfolio on every trading day and measures its
+// time- and money-weighted returns over each window against the benchmark
+func (s *PerformanceService) Performance(ctx context.Context, req PerformanceRequest) (*PerformanceReport, error) {
+	if err := s.normalisePerformanceRequest(&req); err != nil {
+		return nil, err
+	}
+
+	closes := make(map[string][]performance.Close)
+	for _, p := range req.Positions {
+		if p.Symbol == CashSymbol {
+			continue
+		}
+		if _, ok := closes[p.Symbol]; ok {
+			continue
+		}
+		symbolCloses, err := s.prices.dailyCloses(p.Symbol, req.Start, req.End)
+		if err != nil {
+			return nil, err
+		}
+		closes[p.Symbol] = symbolCloses
+	}
+
+	var benchmarkCloses []performance.Close
+	if req.Benchmark != "" {
+		var err error
+		benchmarkCloses, err = s.prices.benchmarkCloses(ctx, req.Benchmark, req.Start, req.End)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dates := tradingDays(req.Start, req.End, closes, benchmarkCloses)
+	if len(dates) == 0 {
+		return nil, fmt.Errorf("%w: no prices between %s and %s", ErrInvalidAnalytics,
+			req.Start.Format("2006-01-02"), req.End.Format("2006-01-02"))
+	}
+
+	days, err := s.valueDays(dates, req, closes)
+	if err != nil {
+		return nil, err
+	}
+
+	var benchmark []float64
+	if req.Benchmark != "" {
+		benchmark = performance.Align(benchmarkCloses, dates)
+	}
+
+	report := &PerformanceReport{
+		Start:     dates[0],
+		End:       dates[len(dates)-1],
+		Benchmark: req.Benchmark,
+		Windows:   []performance.WindowReturn{},
+		Curve:     make([]CurvePoint, len(days)),
+	}
+
+	for _, window := range req.Windows {
+		result, ok, err := performance.Measure(days, benchmark, window)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidAnalytics, err)
+		}
+		if ok {
+			report.Windows = append(report.Windows, result)
+		}
+	}
+
+	growth := performance.GrowthIndex(performance.DailyReturns(days))
+	for i, day := range days {
+		point := CurvePoint{
+			Date:             day.Date,
+			Value:            day.Value,
+			NetFlow:          day.Flow,
+			CumulativeReturn: growth[i] - 1,
+		}
+		if benchmark != nil && !math.IsNaN(benchmark[0]) && !math.IsNaN(benchmark[i]) && benchmark[0] > 0 {
+			b := benchmark[i]/benchmark[0] - 1
+			point.BenchmarkReturn = &b
+		}
+		report.Curve[i] = point
+	}
+
+	return report, nil
+}
+
+func (s *PerformanceService) normalisePerformanceRequest(req *PerformanceRequest) error {
+	if len(req.Positions) == 0 {
+		return fmt.Errorf("%w: at least one position is required", ErrInvalidAnalytics)
+	}
+
+	var first time.Time
+	for i := range req.Positions {
+		p := &req.Positions[i]
+		p.Symbol = strings.ToUpper(strings.TrimSpace(p.Symbol))
+		if p.Symbol == "" {
+			return fmt.Errorf("%w: position %d has no symbol", ErrInvalidAnalytics, i+1)
+		}
+		if p.Date.IsZero() {
+			return fmt.Errorf("%w: position %d has no date", ErrInvalidAnalytics, i+1)
+		}
+		if p.Quantity < 0 {
+			return fmt.Errorf("%w: position %d has a negative quantity", ErrInvalidAnalytics, i+1)
+		}
+		if first.IsZero() || p.Date.Before(first) {
+			first = p.Date
+		}
+	}
+	for i, f := range req.CashFlows {
+		if f.Date.IsZero() {
+			return fmt.Errorf("%w: cash flow %d has no date", ErrInvalidAnalytics, i+1)
+		}
+		if f.Date.Before(first) {
+			first = f.Date
+		}
+	}
+	sort.SliceStable(req.Positions, func(i, j int) bool { return req.Positions[i].Date.Before(req.Positions[j].Date) })
+
+	if req.Start.IsZero() {
+		req.Start = first
+	}
+	if req.End.IsZero() {
+		req.End = time.Now()
+	}
+	req.Start = s.prices.sessionDay(req.Start)
+	req.End = s.prices.sessionDay(req.End)
+	if req.End.Before(req.Start) {
+		return fmt.Errorf("%w: end is before start", ErrInvalidAnalytics)
+	}
+
+	if len(req.Windows) == 0 {
+		req.Windows = performance.DefaultWindows
+	}
+	for _, window := range req.Windows {
+		if _, err := performance.WindowStart(window, req.End); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidAnalytics, err)
+		}
+	}
+
+	req.Benchmark = strings.ToUpper(strings.TrimSpace(req.Benchmark))
+	return nil
+}
+
+// tradingDays is every day between start and end with a close for any symbol
+func tradingDays(start, end time.Time, closes map[string][]performance.Close, benchmark []performance.Close) []time.Time {
+	seen := make(map[time.Time]bool)
+	add := func(series []performance.Close) {
+		for _, c := range series {
+			if !c.Date.Before(start) && !c.Date.After(end) {
+				seen[c.Date] = true
+			}
+		}
+	}
+	for _, series := range closes {
+		add(series)
+	}
+	add(benchmark)
+
+	dates := make([]time.Time, 0, len(seen))
+	for date := range seen {
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates
+}
+
+// valueDays values the positions at each date's closes and books each cash
+// flow in the period on the first trading day on or after it
+func (s *PerformanceService) valueDays(dates []time.Time, req PerformanceRequest, closes map[string][]performance.Close) ([]performance.Day, error) {
+	prices := make(map[string][]float64, len(closes))
+	for symbol, series := range closes {
+		prices[symbol] = performance.Align(series, dates)
+	}
+
+	days := make([]performance.Day, len(dates))
+	held := make(map[string]float64)
+	next := 0
+	for i, date := range dates {
+		for next < len(req.Positions) && !s.prices.sessionDay(req.Positions[next].Date).After(date) {
+			held[req.Positions[next].Symbol] = req.Positions[next].Quantity
+			next++
+		}
+
+		value := 0.0
+		for symbol, quantity := range held {
+			if quantity == 0 {
+				continue
+			}
+			if symbol == CashSymbol {
+				value += quantity
+				continue
+			}
+			price := prices[symbol][i]
+			if math.IsNaN(price) {
+				return nil, fmt.Errorf("%w: no price for %s on %s", ErrInvalidAnalytics, symbol, date.Format("2006-01-02"))
+			}
+			value += quantity * price
+		}
+		days[i] = performance.Day{Date: date, Value: value}
+	}
+
+	for _, f := range req.CashFlows {
+		day := s.prices.sessionDay(f.Date)
+		if day.Before(req.Start) || day.After(req.End) {
+			continue
+		}
+		i := sort.Search(len(dates), func(i int) bool { return !dates[i].Before(day) })
+		if i == len(dates) {
+			i = len(dates) - 1
+		}
+		days[i].Flow += f.Amount
+	}
+
+	return days, nil
+}
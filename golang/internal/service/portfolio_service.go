@@ -0,0 +1,374 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"stock-api/internal/portfolio"
+	"stock-api/internal/repository"
+)
+
+var (
+	// ErrInvalidTrade is wrapped by every error caused by a malformed or impossible trade
+	ErrInvalidTrade = errors.New("invalid trade")
+	// ErrInvalidAccount is wrapped by errors caused by malformed account details
+	ErrInvalidAccount = errors.New("invalid account")
+	// ErrPortfolioNotFound is returned when a user has no account or trade with the requested id
+	ErrPortfolioNotFound = errors.New("not found")
+)
+
+// PortfolioService manages users' accounts and trades and derives their holdings
+type PortfolioService struct {
+	portfolioRepo *repository.PortfolioRepository
+	stockRepo     *repository.StockRepository
+}
+
+// Holding is an open position valued at the latest stored price. Valuation
+// fields are nil when no price is available for the symbol.
+type Holding struct {
+	portfolio.Position
+	LastPrice         *float64 `json:"last_price"`
+	MarketValue       *float64 `json:"market_value"`
+	UnrealizedGain    *float64 `json:"unrealized_gain"`
+	UnrealizedGainPct *float64 `json:"unrealized_gain_pct"`
+}
+
+// HoldingsSummary lists open positions with portfolio totals. Realised gains
+// and dividends include positions that have since been closed.
+type HoldingsSummary struct {
+	Holdings       []Holding `json:"holdings"`
+	CostBasis      float64   `json:"cost_basis"`
+	MarketValue    float64   `json:"market_value"`
+	UnrealizedGain float64   `json:"unrealized_gain"`
+	RealizedGain   float64   `json:"realized_gain"`
+	Dividends      float64   `json:"dividends"`
+	// Unpriced lists symbols whose holdings are missing from the market value
+	Unpriced []string `json:"unpriced"`
+}
+
+func NewPortfolioService(portfolioRepo *repository.PortfolioRepository, stockRepo *repository.StockRepository) *PortfolioService {
+	return &PortfolioService{portfolioRepo: portfolioRepo, stockRepo: stockRepo}
+}
+
+// CreateAccount creates an account for the user
+func (s *PortfolioService) CreateAccount(userID int, account *repository.PortfolioAccount) error {
+	account.UserID = userID
+	account.Name = strings.TrimSpace(account.Name)
+	if account.Name == "" {
+		return fmt.Errorf("%w: account name is required", ErrInvalidAccount)
+	}
+	account.Currency = strings.ToUpper(strings.TrimSpace(account.Currency))
+	if account.Currency == "" {
+		account.Currency = "USD"
+	}
+	if len(account.Currency) != 3 {
+		return fmt.Errorf("%w: currency must be a 3-letter code", ErrInvalidAccount)
+	}
+	method, err := portfolio.ParseLotMethod(account.LotMethod)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAccount, err)
+	}
+	account.LotMethod = string(method)
+	return s.portfolioRepo.CreateAccount(account)
+}
+
+// GetAccounts lists the user's accounts
+func (s *PortfolioService) GetAccounts(userID int) ([]repository.PortfolioAccount, error) {
+	return s.portfolioRepo.GetAccounts(userID)
+}
+
+// DeleteAccount deletes one of the user's accounts with all of its trades
+func (s *PortfolioService) DeleteAccount(userID, id int) error {
+	deleted, err := s.portfolioRepo.DeleteAccount(userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("account %d: %w", id, ErrPortfolioNotFound)
+	}
+	return nil
+}
+
+// GetTrades lists the user's trades, optionally for one account and symbol
+func (s *PortfolioService) GetTrades(userID, accountID int, symbol string) ([]repository.Trade, error) {
+	return s.portfolioRepo.GetTrades(userID, accountID, strings.ToUpper(strings.TrimSpace(symbol)))
+}
+
+// CreateTrade records a trade after checking it leaves no position oversold
+func (s *PortfolioService) CreateTrade(userID int, t *repository.Trade) error {
+	if err := normaliseTrade(t); err != nil {
+		return err
+	}
+
+	return s.portfolioRepo.WithLedgerLock(userID, func(l *repository.LedgerTx) error {
+		err := checkLedger(l, []string{t.Symbol}, func(trades []repository.Trade) []repository.Trade {
+			return appendPending(trades, []repository.Trade{*t})
+		})
+		if err != nil {
+			return err
+		}
+
+		trades := []repository.Trade{*t}
+		created, err := l.CreateTrades(trades)
+		if err != nil {
+			return err
+		}
+		if !created {
+			return fmt.Errorf("account %d: %w", t.AccountID, ErrPortfolioNotFound)
+		}
+		*t = trades[0]
+		return nil
+	})
+}
+
+// UpdateTrade replaces a trade after checking the edited ledger
+func (s *PortfolioService) UpdateTrade(userID int, t *repository.Trade) error {
+	if err := normaliseTrade(t); err != nil {
+		return err
+	}
+
+	return s.portfolioRepo.WithLedgerLock(userID, func(l *repository.LedgerTx) error {
+		existing, err := l.GetTrade(t.ID)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return fmt.Errorf("trade %d: %w", t.ID, ErrPortfolioNotFound)
+		}
+
+		err = checkLedger(l, []string{existing.Symbol, t.Symbol}, func(trades []repository.Trade) []repository.Trade {
+			for i := range trades {
+				if trades[i].ID == t.ID {
+					trades[i] = *t
+				}
+			}
+			return trades
+		})
+		if err != nil {
+			return err
+		}
+
+		updated, err := l.UpdateTrade(t)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return fmt.Errorf("account %d: %w", t.AccountID, ErrPortfolioNotFound)
+		}
+		return nil
+	})
+}
+
+// DeleteTrade deletes a trade unless that would leave a later sell oversold
+func (s *PortfolioService) DeleteTrade(userID, id int) error {
+	return s.portfolioRepo.WithLedgerLock(userID, func(l *repository.LedgerTx) error {
+		existing, err := l.GetTrade(id)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return fmt.Errorf("trade %d: %w", id, ErrPortfolioNotFound)
+		}
+
+		err = checkLedger(l, []string{existing.Symbol}, func(trades []repository.Trade) []repository.Trade {
+			kept := trades[:0]
+			for _, trade := range trades {
+				if trade.ID != id {
+					kept = append(kept, trade)
+				}
+			}
+			return kept
+		})
+		if err != nil {
+			return err
+		}
+
+		deleted, err := l.DeleteTrade(id)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return fmt.Errorf("trade %d: %w", id, ErrPortfolioNotFound)
+		}
+		return nil
+	})
+}
+
+// GetHoldings derives the user's open positions, optionally for one account,
+// and values them at the latest stored price
+func (s *PortfolioService) GetHoldings(userID, accountID int) (*HoldingsSummary, error) {
+	trades, err := s.portfolioRepo.GetTrades(userID, accountID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	positions, err := portfolio.Replay(trades)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &HoldingsSummary{Holdings: []Holding{}, Unpriced: []string{}}
+	prices := make(map[string]*float64)
+	for _, p := range positions {
+		summary.RealizedGain += p.RealizedGain
+		summary.Dividends += p.Dividends
+		if p.Quantity == 0 {
+			continue
+		}
+
+		price, ok := prices[p.Symbol]
+		if !ok {
+			if latest, err := s.stockRepo.GetLatestPrice(p.Symbol); err == nil {
+				price = &latest
+			} else {
+				summary.Unpriced = append(summary.Unpriced, p.Symbol)
+			}
+			prices[p.Symbol] = price
+		}
+
+		h := Holding{Position: p, LastPrice: price}
+		summary.CostBasis += p.CostBasis
+		if price != nil {
+			marketValue := p.Quantity * *price
+			gain := marketValue - p.CostBasis
+			h.MarketValue = &marketValue
+			h.UnrealizedGain = &gain
+			if p.CostBasis != 0 {
+				pct := gain / p.CostBasis * 100
+				h.UnrealizedGainPct = &pct
+			}
+			summary.MarketValue += marketValue
+			summary.UnrealizedGain += gain
+		}
+		summary.Holdings = append(summary.Holdings, h)
+	}
+
+	return summary, nil
+}
+
+// checkLedger replays the user's trades in symbols with edit applied, failing
+// when the edited ledger would sell more shares than are held or a sell's lot
+// selection can't be matched. It reads through l so the ledger can't change
+// before the edit is written.
+func checkLedger(l *repository.LedgerTx, symbols []string, edit func([]repository.Trade) []repository.Trade) error {
+	var trades []repository.Trade
+	seen := make(map[string]bool)
+	for _, symbol := range symbols {
+		if seen[symbol] {
+			continue
+		}
+		seen[symbol] = true
+
+		symbolTrades, err := l.GetTrades(0, symbol)
+		if err != nil {
+			return err
+		}
+		trades = append(trades, symbolTrades...)
+	}
+
+	edited := edit(trades)
+	if _, err := portfolio.Replay(edited); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTrade, err)
+	}
+
+	accounts, err := l.GetAccounts()
+	if err != nil {
+		return err
+	}
+	if _, _, err := portfolio.MatchLots(edited, accountLotMethods(accounts)); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTrade, err)
+	}
+	return nil
+}
+
+// appendPending adds trades that aren't stored yet to ledger. They get
+// stand-in ids after the existing ones so same-day trades replay in the
+// order they'll have once stored.
+func appendPending(ledger, pending []repository.Trade) []repository.Trade {
+	maxID := 0
+	for _, t := range ledger {
+		if t.ID > maxID {
+			maxID = t.ID
+		}
+	}
+	for i, t := range pending {
+		t.ID = maxID + i + 1
+		ledger = append(ledger, t)
+	}
+	return ledger
+}
+
+// accountLotMethods returns a lookup of each account's default lot method
+func accountLotMethods(accounts []repository.PortfolioAccount) func(accountID int) portfolio.LotMethod {
+	methods := make(map[int]portfolio.LotMethod, len(accounts))
+	for _, a := range accounts {
+		methods[a.ID] = portfolio.LotMethod(a.LotMethod)
+	}
+	return func(accountID int) portfolio.LotMethod {
+		if method, ok := methods[accountID]; ok && method != "" {
+			return method
+		}
+		return portfolio.FIFO
+	}
+}
+
+// normaliseTrade cleans up user input and checks the fields each trade type needs
+func normaliseTrade(t *repository.Trade) error {
+	t.Symbol = strings.ToUpper(strings.TrimSpace(t.Symbol))
+	t.Type = repository.TradeType(strings.ToLower(string(t.Type)))
+
+	if t.AccountID == 0 {
+		return fmt.Errorf("%w: account_id is required", ErrInvalidTrade)
+	}
+	if t.Symbol == "" {
+		return fmt.Errorf("%w: symbol is required", ErrInvalidTrade)
+	}
+	if t.TradeDate.IsZero() {
+		return fmt.Errorf("%w: trade_date is required", ErrInvalidTrade)
+	}
+	if t.Fees < 0 {
+		return fmt.Errorf("%w: fees can't be negative", ErrInvalidTrade)
+	}
+
+	switch t.Type {
+	case repository.TradeBuy, repository.TradeSell:
+		if t.Quantity <= 0 {
+			return fmt.Errorf("%w: %s needs a positive quantity", ErrInvalidTrade, t.Type)
+		}
+		if t.Price < 0 {
+			return fmt.Errorf("%w: price can't be negative", ErrInvalidTrade)
+		}
+	case repository.TradeDividend:
+		if t.Amount <= 0 {
+			return fmt.Errorf("%w: dividend needs a positive amount", ErrInvalidTrade)
+		}
+	case repository.TradeSplit:
+		if t.SplitRatio <= 0 {
+			return fmt.Errorf("%w: split needs a positive split_ratio", ErrInvalidTrade)
+		}
+	default:
+		return fmt.Errorf("%w: type must be buy, sell, dividend or split", ErrInvalidTrade)
+	}
+
+	if t.Type != repository.TradeSell {
+		if t.LotMethod != "" || len(t.Lots) > 0 {
+			return fmt.Errorf("%w: lot_method and lots only apply to sells", ErrInvalidTrade)
+		}
+		return nil
+	}
+	if len(t.Lots) > 0 && t.LotMethod == "" {
+		t.LotMethod = string(portfolio.SpecificID)
+	}
+	if t.LotMethod != "" {
+		method, err := portfolio.ParseLotMethod(t.LotMethod)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTrade, err)
+		}
+		t.LotMethod = string(method)
+	}
+	if len(t.Lots) > 0 && t.LotMethod != string(portfolio.SpecificID) {
+		return fmt.Errorf("%w: lots can only be chosen with lot_method specific_id", ErrInvalidTrade)
+	}
+	return nil
+}
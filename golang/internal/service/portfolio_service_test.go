@@ -0,0 +1,49 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"stock-api/internal/portfolio"
+	"stock-api/internal/repository"
+)
+
+func TestAppendPendingSameDaySell(t *testing.T) {
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	ledger := []repository.Trade{
+		{ID: 7, AccountID: 1, Symbol: "AAPL", Type: repository.TradeBuy, TradeDate: date, Quantity: 10, Price: 100},
+	}
+	sell := repository.Trade{AccountID: 1, Symbol: "AAPL", Type: repository.TradeSell, TradeDate: date, Quantity: 10, Price: 105}
+
+	edited := appendPending(ledger, []repository.Trade{sell})
+	if len(edited) != 2 || edited[1].ID != 8 {
+		t.Fatalf("pending trade = %+v, want stand-in id 8", edited[len(edited)-1])
+	}
+
+	positions, err := portfolio.Replay(edited)
+	if err != nil {
+		t.Fatalf("same-day sell after a buy rejected: %v", err)
+	}
+	if len(positions) != 1 || positions[0].Quantity != 0 {
+		t.Errorf("positions = %+v, want AAPL closed out", positions)
+	}
+}
+
+func TestAppendPendingKeepsBatchOrder(t *testing.T) {
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	pending := []repository.Trade{
+		{AccountID: 1, Symbol: "AAPL", Type: repository.TradeBuy, TradeDate: date, Quantity: 5, Price: 100},
+		{AccountID: 1, Symbol: "AAPL", Type: repository.TradeSell, TradeDate: date, Quantity: 5, Price: 101},
+	}
+
+	edited := appendPending(nil, pending)
+	if edited[0].ID != 1 || edited[1].ID != 2 {
+		t.Fatalf("stand-in ids = %d, %d, want 1, 2", edited[0].ID, edited[1].ID)
+	}
+	if pending[0].ID != 0 {
+		t.Errorf("appendPending changed the caller's trades")
+	}
+	if _, err := portfolio.Replay(edited); err != nil {
+		t.Errorf("buy then sell in one batch rejected: %v", err)
+	}
+}
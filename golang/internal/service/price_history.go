@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"stock-api/internal/performance"
+	"stock-api/internal/repository"
+)
+
+// priceLookback is how far before the start prices are loaded, so a
+// position can be valued on a first day that falls on a market holiday
+const priceLookback = 10 * 24 * time.Hour
+
+// priceHistory loads daily closes for the analytics services
+type priceHistory struct {
+	stockRepo         *repository.StockRepository
+	extractionService *DataExtractionService
+	session           *time.Location
+}
+
+func newPriceHistory(stockRepo *repository.StockRepository, extractionService *DataExtractionService) *priceHistory {
+	// Closing prices are taken per US/Eastern trading day
+	session, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		session = time.UTC
+	}
+	return &priceHistory{stockRepo: stockRepo, extractionService: extractionService, session: session}
+}
+
+// sessionDay returns midnight US/Eastern on t's calendar date. Request dates
+// are calendar dates, so t isn't converted to Eastern time first.
+func (h *priceHistory) sessionDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, h.session)
+}
+
+// dailyCloses loads a symbol's closes from shortly before start through the end of end's day
+func (h *priceHistory) dailyCloses(symbol string, start, end time.Time) ([]performance.Close, error) {
+	bars, err := h.stockRepo.GetStockData(symbol, start.Add(-priceLookback), end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	return performance.DailyCloses(bars, h.session), nil
+}
+
+// storedDailyCloses loads a symbol's closes from stocks_daily, dated
+// midnight US/Eastern like dailyCloses
+func (h *priceHistory) storedDailyCloses(symbol string, start, end time.Time) ([]performance.Close, error) {
+	bars, err := h.stockRepo.GetDailyData(symbol, start.Add(-priceLookback), end)
+	if err != nil {
+		return nil, err
+	}
+	closes := make([]performance.Close, 0, len(bars))
+	for _, bar := range bars {
+		closes = append(closes, performance.Close{Date: h.sessionDay(bar.Date), Price: bar.Close})
+	}
+	return closes, nil
+}
+
+// benchmarkCloses loads the benchmark's closes from stocks_daily. When none
+// are stored for the period, daily bars are extracted first, but only for
+// symbols listed in stock_symbols so arbitrary input can't spend provider quota.
+func (h *priceHistory) benchmarkCloses(ctx context.Context, symbol string, start, end time.Time) ([]performance.Close, error) {
+	closes, err := h.storedDailyCloses(symbol, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if len(closes) > 0 && !closes[len(closes)-1].Date.Before(start) {
+		return closes, nil
+	}
+
+	listed, err := h.stockRepo.HasSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if !listed {
+		return nil, fmt.Errorf("%w: no prices stored for %s and it isn't a known symbol", ErrInvalidAnalytics, symbol)
+	}
+
+	log.Printf("No stored daily prices for benchmark %s, extracting daily bars", symbol)
+	if _, err := h.extractionService.BatchExtractAndStoreDailyData(ctx, []string{symbol}, start.Add(-priceLookback), end.AddDate(0, 0, 1)); err != nil {
+		return nil, fmt.Errorf("could not load benchmark %s: %w", symbol, err)
+	}
+	return h.storedDailyCloses(symbol, start, end)
+}
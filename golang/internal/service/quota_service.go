@@ -0,0 +1,199 @@
+package service
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "log"
+    "sort"
+    "sync"
+    "time"
+    "stock-api/internal/api"
+    "stock-api/internal/config"
+    "stock-api/internal/repository"
+)
+
+// pruneInterval is how often old ledger entries are deleted
+const pruneInterval = time.Hour
+
+// QuotaService enforces persistent per-provider call budgets backed by the api_call_ledger table
+type QuotaService struct {
+    quotaRepo *repository.QuotaRepository
+    budgets   map[string]config.ProviderQuota
+    // failOpen allows calls when the ledger can't be reached
+    failOpen bool
+    // retention is how long ledger entries are kept before the current day
+    retention time.Duration
+
+    mu sync.Mutex
+    // exhaustedUntil is when each used-up provider key has budget again
+    exhaustedUntil map[string]time.Time
+    lastPruned     time.Time
+}
+
+// QuotaUsage reports the used and remaining budget of one provider key.
+// Remaining values are nil when the budget is unlimited.
+type QuotaUsage struct {
+    Provider        string    `json:"provider"`
+    KeyID           string    `json:"key_id,omitempty"`
+    PerMinuteLimit  int       `json:"per_minute_limit"`
+    PerDayLimit     int       `json:"per_day_limit"`
+    UsedLastMinute  int       `json:"used_last_minute"`
+    UsedToday       int       `json:"used_today"`
+    RemainingMinute *int      `json:"remaining_minute"`
+    RemainingToday  *int      `json:"remaining_today"`
+    DayResetsAt     time.Time `json:"day_resets_at"`
+}
+
+// NewQuotaService creates a quota service. With failOpen, calls are allowed
+// when the ledger can't be reached; otherwise they are refused so the budget
+// holds through database outages. Ledger entries older than retention before
+// the current day are pruned.
+func NewQuotaService(quotaRepo *repository.QuotaRepository, budgets map[string]config.ProviderQuota, failOpen bool, retention time.Duration) *QuotaService {
+    return &QuotaService{
+        quotaRepo:      quotaRepo,
+        budgets:        budgets,
+        failOpen:       failOpen,
+        retention:      retention,
+        exhaustedUntil: make(map[string]time.Time),
+    }
+}
+
+// Acquire implements api.QuotaTracker
+func (s *QuotaService) Acquire(ctx context.Context, provider, apiKey string) error {
+    now := time.Now()
+    s.pruneIfDue(now)
+    budget := s.budgets[provider]
+    key := keyID(apiKey)
+
+    res, err := s.quotaRepo.ReserveCall(provider, key, now, budget.PerMinute, budget.PerDay)
+    if err != nil {
+        if s.failOpen {
+            log.Printf("Failed to check quota for %s, allowing call: %v", provider, err)
+            return nil
+        }
+        return fmt.Errorf("could not check %s quota, refusing call: %w", provider, err)
+    }
+
+    dayUsed := budget.PerDay > 0 && res.Today >= budget.PerDay
+    if !res.Allowed {
+        until := now.Add(time.Minute)
+        if dayUsed {
+            until = nextDay(now)
+        }
+        s.markExhausted(provider, key, until)
+        return fmt.Errorf("%w: %s budget of %d/min, %d/day used up", api.ErrQuotaExceeded, provider, budget.PerMinute, budget.PerDay)
+    }
+    // The call just reserved may have been the last of the day
+    if budget.PerDay > 0 && res.Today+1 >= budget.PerDay {
+        s.markExhausted(provider, key, nextDay(now))
+    }
+    return nil
+}
+
+// Exhausted implements api.QuotaTracker. It reports what this process has
+// learned from the ledger, so it costs no database call.
+func (s *QuotaService) Exhausted(provider, apiKey string) bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    until, ok := s.exhaustedUntil[provider+":"+keyID(apiKey)]
+    return ok && time.Now().Before(until)
+}
+
+func (s *QuotaService) markExhausted(provider, key string, until time.Time) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.exhaustedUntil[provider+":"+key] = until
+}
+
+// pruneIfDue deletes ledger entries past retention, at most once per pruneInterval
+func (s *QuotaService) pruneIfDue(now time.Time) {
+    s.mu.Lock()
+    if now.Sub(s.lastPruned) < pruneInterval {
+        s.mu.Unlock()
+        return
+    }
+    s.lastPruned = now
+    s.mu.Unlock()
+
+    y, m, d := now.UTC().Date()
+    before := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Add(-s.retention)
+    deleted, err := s.quotaRepo.PruneCalls(before)
+    if err != nil {
+        log.Printf("Failed to prune call ledger: %v", err)
+        return
+    }
+    if deleted > 0 {
+        log.Printf("Pruned %d call ledger entries before %s", deleted, before.Format(time.RFC3339))
+    }
+}
+
+// nextDay returns midnight UTC after t, when daily budgets reset
+func nextDay(t time.Time) time.Time {
+    y, m, d := t.UTC().Date()
+    return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+// GetUsage returns the usage of every provider that has a budget or was called today
+func (s *QuotaService) GetUsage(ctx context.Context) ([]QuotaUsage, error) {
+    now := time.Now().UTC()
+    counts, err := s.quotaRepo.GetCallCounts(now)
+    if err != nil {
+        return nil, err
+    }
+
+    resetsAt := nextDay(now)
+
+    seen := make(map[string]bool)
+    var usage []QuotaUsage
+    for _, c := range counts {
+        seen[c.Provider] = true
+        usage = append(usage, s.usageFor(c.Provider, c.KeyID, c.LastMinute, c.Today, resetsAt))
+    }
+    for provider := range s.budgets {
+        if !seen[provider] {
+            usage = append(usage, s.usageFor(provider, "", 0, 0, resetsAt))
+        }
+    }
+
+    sort.Slice(usage, func(i, j int) bool {
+        if usage[i].Provider != usage[j].Provider {
+            return usage[i].Provider < usage[j].Provider
+        }
+        return usage[i].KeyID < usage[j].KeyID
+    })
+    return usage, nil
+}
+
+func (s *QuotaService) usageFor(provider, key string, lastMinute, today int, resetsAt time.Time) QuotaUsage {
+    budget := s.budgets[provider]
+    return QuotaUsage{
+        Provider:        provider,
+        KeyID:           key,
+        PerMinuteLimit:  budget.PerMinute,
+        PerDayLimit:     budget.PerDay,
+        UsedLastMinute:  lastMinute,
+        UsedToday:       today,
+        RemainingMinute: remaining(budget.PerMinute, lastMinute),
+        RemainingToday:  remaining(budget.PerDay, today),
+        DayResetsAt:     resetsAt,
+    }
+}
+
+func remaining(limit, used int) *int {
+    if limit <= 0 {
+        return nil
+    }
+    left := limit - used
+    if left < 0 {
+        left = 0
+    }
+    return &left
+}
+
+// keyID identifies an API key in the ledger without storing the key itself
+func keyID(apiKey string) string {
+    sum := sha256.Sum256([]byte(apiKey))
+    return hex.EncodeToString(sum[:])[:16]
+}
@@ -0,0 +1,424 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"stock-api/internal/performance"
+	"stock-api/internal/repository"
+	"stock-api/internal/risk"
+)
+
+// maxRiskHoldings caps the size of a basket
+const maxRiskHoldings = 200
+
+// RiskHolding is one symbol in a basket and its weight. Weights are
+// normalised to sum to 1.
+type RiskHolding struct {
+	Symbol string  `json:"symbol"`
+	Weight float64 `json:"weight"`
+}
+
+// RiskRequest selects a basket, the index beta is measured against and the
+// period. Zero values fall back to SPY, the year to today, a 0% risk-free
+// rate and 95% confidence.
+type RiskRequest struct {
+	Holdings []RiskHolding
+	Index    string
+	Start    time.Time
+	End      time.Time
+	// RiskFreeRate is an annual rate used by Sharpe and Sortino
+	RiskFreeRate float64
+	Confidence   float64
+}
+
+// RiskMetrics describes a basket's daily returns over the period.
+// Volatility, Sharpe and Sortino are annualised; VaR is a one-day loss as a
+// positive fraction. Metrics that can't be computed from the data are nil.
+type RiskMetrics struct {
+	Holdings       []RiskHolding `json:"holdings"`
+	Index          string        `json:"index"`
+	Start          time.Time     `json:"start"`
+	End            time.Time     `json:"end"`
+	Observations   int           `json:"observations"`
+	RiskFreeRate   float64       `json:"risk_free_rate"`
+	Confidence     float64       `json:"confidence"`
+	Volatility     *float64      `json:"volatility"`
+	Beta           *float64      `json:"beta"`
+	Sharpe         *float64      `json:"sharpe"`
+	Sortino        *float64      `json:"sortino"`
+	MaxDrawdown    float64       `json:"max_drawdown"`
+	DrawdownPeak   *time.Time    `json:"drawdown_peak"`
+	DrawdownTrough *time.Time    `json:"drawdown_trough"`
+	HistoricalVaR  *float64      `json:"historical_var"`
+	ParametricVaR  *float64      `json:"parametric_var"`
+}
+
+// RiskService computes risk metrics for symbols and baskets from stored prices
+type RiskService struct {
+	prices *priceHistory
+}
+
+func NewRiskService(stockRepo *repository.StockRepository, extractionService *DataExtractionService) *RiskService {
+	return &RiskService{prices: newPriceHistory(stockRepo, extractionService)}
+}
+
+// Risk computes the basket's risk metrics over the days every holding traded
+func (s *RiskService) Risk(ctx context.Context, req RiskRequest) (*RiskMetrics, error) {
+	if err := s.normaliseRiskRequest(&req); err != nil {
+		return nil, err
+	}
+
+	// Keep only the days on which every holding has a close
+	var dates []time.Time
+	prices := make(map[string]map[time.Time]float64, len(req.Holdings))
+	for i, h := range req.Holdings {
+		closes, err := s.prices.dailyCloses(h.Symbol, req.Start, req.End)
+		if err != nil {
+			return nil, err
+		}
+		byDate := make(map[time.Time]float64, len(closes))
+		for _, c := range closes {
+			if !c.Date.Before(req.Start) && !c.Date.After(req.End) {
+				byDate[c.Date] = c.Price
+			}
+		}
+		if len(byDate) == 0 {
+			return nil, fmt.Errorf("%w: no prices for %s between %s and %s", ErrInvalidAnalytics,
+				h.Symbol, req.Start.Format("2006-01-02"), req.End.Format("2006-01-02"))
+		}
+		prices[h.Symbol] = byDate
+
+		if i == 0 {
+			for date := range byDate {
+				dates = append(dates, date)
+			}
+			continue
+		}
+		common := dates[:0]
+		for _, date := range dates {
+			if _, ok := byDate[date]; ok {
+				common = append(common, date)
+			}
+		}
+		dates = common
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	if len(dates) < 3 {
+		return nil, fmt.Errorf("%w: the holdings share only %d trading days in the period", ErrInvalidAnalytics, len(dates))
+	}
+
+	// Daily-rebalanced basket returns and the value they compound to
+	returns := make([]float64, len(dates)-1)
+	for _, h := range req.Holdings {
+		series := make([]float64, len(dates))
+		for i, date := range dates {
+			series[i] = prices[h.Symbol][date]
+		}
+		for i, r := range risk.Returns(series) {
+			returns[i] += h.Weight * r
+		}
+	}
+	levels := make([]float64, len(dates))
+	levels[0] = 1
+	for i, r := range returns {
+		levels[i+1] = levels[i] * (1 + r)
+	}
+
+	metrics := &RiskMetrics{
+		Holdings:     req.Holdings,
+		Index:        req.Index,
+		Start:        dates[0],
+		End:          dates[len(dates)-1],
+		Observations: len(returns),
+		RiskFreeRate: req.RiskFreeRate,
+		Confidence:   req.Confidence,
+	}
+
+	set := func(v float64, ok bool) *float64 {
+		if !ok || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil
+		}
+		return &v
+	}
+	metrics.Volatility = set(risk.Volatility(returns))
+	metrics.Sharpe = set(risk.Sharpe(returns, req.RiskFreeRate))
+	metrics.Sortino = set(risk.Sortino(returns, req.RiskFreeRate))
+	metrics.HistoricalVaR = set(risk.HistoricalVaR(returns, req.Confidence))
+	metrics.ParametricVaR = set(risk.ParametricVaR(returns, req.Confidence))
+
+	drawdown := risk.MaxDrawdown(levels)
+	metrics.MaxDrawdown = drawdown.Depth
+	if drawdown.Depth > 0 {
+		metrics.DrawdownPeak = &dates[drawdown.Peak]
+		metrics.DrawdownTrough = &dates[drawdown.Trough]
+	}
+
+	indexCloses, err := s.prices.benchmarkCloses(ctx, req.Index, req.Start, req.End)
+	if err != nil {
+		return nil, err
+	}
+	indexByDate := make(map[time.Time]float64, len(indexCloses))
+	for _, c := range indexCloses {
+		indexByDate[c.Date] = c.Price
+	}
+	indexSeries := make([]float64, len(dates))
+	for i, date := range dates {
+		if price, ok := indexByDate[date]; ok {
+			indexSeries[i] = price
+		} else {
+			indexSeries[i] = math.NaN()
+		}
+	}
+	metrics.Beta = set(risk.Beta(returns, risk.Returns(indexSeries)))
+
+	return metrics, nil
+}
+
+func (s *RiskService) normaliseRiskRequest(req *RiskRequest) error {
+	if len(req.Holdings) == 0 {
+		return fmt.Errorf("%w: at least one holding is required", ErrInvalidAnalytics)
+	}
+
+	// Merge repeated symbols and normalise the weights
+	weights := make(map[string]float64)
+	var order []string
+	total := 0.0
+	for i, h := range req.Holdings {
+		symbol := strings.ToUpper(strings.TrimSpace(h.Symbol))
+		if symbol == "" {
+			return fmt.Errorf("%w: holding %d has no symbol", ErrInvalidAnalytics, i+1)
+		}
+		if h.Weight <= 0 {
+			return fmt.Errorf("%w: %s needs a positive weight", ErrInvalidAnalytics, symbol)
+		}
+		if _, ok := weights[symbol]; !ok {
+			order = append(order, symbol)
+		}
+		weights[symbol] += h.Weight
+		total += h.Weight
+	}
+	if len(order) > maxRiskHoldings {
+		return fmt.Errorf("%w: at most %d holdings are allowed", ErrInvalidAnalytics, maxRiskHoldings)
+	}
+	req.Holdings = make([]RiskHolding, len(order))
+	for i, symbol := range order {
+		req.Holdings[i] = RiskHolding{Symbol: symbol, Weight: weights[symbol] / total}
+	}
+
+	req.Index = strings.ToUpper(strings.TrimSpace(req.Index))
+	if req.Index == "" {
+		req.Index = "SPY"
+	}
+
+	if req.End.IsZero() {
+		req.End = time.Now()
+	}
+	req.End = s.prices.sessionDay(req.End)
+	if req.Start.IsZero() {
+		req.Start = req.End.AddDate(-1, 0, 0)
+	}
+	req.Start = s.prices.sessionDay(req.Start)
+	if !req.Start.Before(req.End) {
+		return fmt.Errorf("%w: start must be before end", ErrInvalidAnalytics)
+	}
+
+	if req.Confidence == 0 {
+		req.Confidence = 0.95
+	}
+	if req.Confidence <= 0.5 || req.Confidence >= 1 {
+		return fmt.Errorf("%w: confidence must be between 0.5 and 1", ErrInvalidAnalytics)
+	}
+	return nil
+}
+
+const (
+	maxCorrelationSymbols = 500
+	// defaultMinOverlap is the fewest shared returns a pair needs to be compared
+	defaultMinOverlap = 20
+)
+
+// CorrelationRequest selects the symbols and the return frequency: "daily"
+// or an intraday bucket such as "5m" or "1h". Zero dates fall back to the
+// last year for daily returns and the last 30 days for intraday ones.
+type CorrelationRequest struct {
+	Symbols    []string
+	Frequency  string
+	Start      time.Time
+	End        time.Time
+	MinOverlap int
+}
+
+// CorrelationMatrix holds pairwise statistics of the symbols' returns in
+// Symbols order. Each pair is compared over the periods both have a return;
+// entries are nil when a pair shares fewer than MinOverlap returns.
+type CorrelationMatrix struct {
+	Symbols      []string     `json:"symbols"`
+	Frequency    string       `json:"frequency"`
+	Start        time.Time    `json:"start"`
+	End          time.Time    `json:"end"`
+	Periods      int          `json:"periods"`
+	MinOverlap   int          `json:"min_overlap"`
+	Correlation  [][]*float64 `json:"correlation"`
+	Covariance   [][]*float64 `json:"covariance"`
+	Observations [][]int      `json:"observations"`
+	// Missing lists requested symbols with no prices in the period
+	Missing []string `json:"missing"`
+}
+
+// parseFrequency returns the bucket size of an intraday frequency, or 0 for daily
+func parseFrequency(frequency string) (time.Duration, error) {
+	switch strings.ToLower(frequency) {
+	case "", "daily", "1d":
+		return 0, nil
+	}
+	bucket, err := time.ParseDuration(strings.ToLower(frequency))
+	if err != nil || bucket < time.Minute || bucket > 12*time.Hour {
+		return 0, fmt.Errorf("%w: frequency must be daily or an intraday bucket between 1m and 12h", ErrInvalidAnalytics)
+	}
+	return bucket, nil
+}
+
+// Correlation builds correlation and covariance matrices of the symbols'
+// returns. Prices are loaded one symbol at a time and reduced to one close
+// per period, so only the bucketed closes are held in memory. A return is
+// only taken between consecutive periods, so returns spanning a gap are
+// dropped rather than attributed to the period after it.
+func (s *RiskService) Correlation(req CorrelationRequest) (*CorrelationMatrix, error) {
+	bucket, err := parseFrequency(req.Frequency)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := make([]string, 0, len(req.Symbols))
+	seen := make(map[string]bool)
+	for _, symbol := range req.Symbols {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if symbol != "" && !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	if len(symbols) < 2 {
+		return nil, fmt.Errorf("%w: at least two symbols are required", ErrInvalidAnalytics)
+	}
+	if len(symbols) > maxCorrelationSymbols {
+		return nil, fmt.Errorf("%w: at most %d symbols are allowed", ErrInvalidAnalytics, maxCorrelationSymbols)
+	}
+
+	if req.End.IsZero() {
+		req.End = time.Now()
+	}
+	req.End = s.prices.sessionDay(req.End)
+	if req.Start.IsZero() {
+		if bucket == 0 {
+			req.Start = req.End.AddDate(-1, 0, 0)
+		} else {
+			req.Start = req.End.AddDate(0, 0, -30)
+		}
+	}
+	req.Start = s.prices.sessionDay(req.Start)
+	if !req.Start.Before(req.End) {
+		return nil, fmt.Errorf("%w: start must be before end", ErrInvalidAnalytics)
+	}
+	if req.MinOverlap <= 0 {
+		req.MinOverlap = defaultMinOverlap
+	}
+
+	matrix := &CorrelationMatrix{
+		Frequency:  "daily",
+		Start:      req.Start,
+		End:        req.End,
+		MinOverlap: req.MinOverlap,
+		Missing:    []string{},
+	}
+	if bucket > 0 {
+		matrix.Frequency = bucket.String()
+	}
+
+	closes := make([]map[time.Time]float64, 0, len(symbols))
+	periods := make(map[time.Time]bool)
+	for _, symbol := range symbols {
+		bars, err := s.prices.stockRepo.GetStockData(symbol, req.Start, req.End.AddDate(0, 0, 1))
+		if err != nil {
+			return nil, err
+		}
+
+		var symbolCloses map[time.Time]float64
+		if bucket == 0 {
+			daily := performance.DailyCloses(bars, s.prices.session)
+			symbolCloses = make(map[time.Time]float64, len(daily))
+			for _, c := range daily {
+				symbolCloses[c.Date] = c.Price
+			}
+		} else {
+			symbolCloses = performance.BucketCloses(bars, bucket)
+		}
+
+		if len(symbolCloses) == 0 {
+			matrix.Missing = append(matrix.Missing, symbol)
+			continue
+		}
+		for period := range symbolCloses {
+			periods[period] = true
+		}
+		matrix.Symbols = append(matrix.Symbols, symbol)
+		closes = append(closes, symbolCloses)
+	}
+	if len(matrix.Symbols) < 2 {
+		return nil, fmt.Errorf("%w: fewer than two symbols have prices in the period", ErrInvalidAnalytics)
+	}
+
+	grid := make([]time.Time, 0, len(periods))
+	for period := range periods {
+		grid = append(grid, period)
+	}
+	sort.Slice(grid, func(i, j int) bool { return grid[i].Before(grid[j]) })
+	matrix.Periods = len(grid) - 1
+
+	returns := make([][]float64, len(closes))
+	for k, symbolCloses := range closes {
+		series := make([]float64, len(grid))
+		for i, period := range grid {
+			if price, ok := symbolCloses[period]; ok {
+				series[i] = price
+			} else {
+				series[i] = math.NaN()
+			}
+		}
+		returns[k] = risk.Returns(series)
+		// The closes are no longer needed once the returns are aligned
+		closes[k] = nil
+	}
+
+	n := len(returns)
+	matrix.Correlation = make([][]*float64, n)
+	matrix.Covariance = make([][]*float64, n)
+	matrix.Observations = make([][]int, n)
+	for i := 0; i < n; i++ {
+		matrix.Correlation[i] = make([]*float64, n)
+		matrix.Covariance[i] = make([]*float64, n)
+		matrix.Observations[i] = make([]int, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			pair, ok := risk.Correlate(returns[i], returns[j])
+			matrix.Observations[i][j], matrix.Observations[j][i] = pair.N, pair.N
+			if pair.N < req.MinOverlap || pair.N < 2 {
+				continue
+			}
+			cov := pair.Covariance
+			matrix.Covariance[i][j], matrix.Covariance[j][i] = &cov, &cov
+			if ok {
+				corr := pair.Correlation
+				matrix.Correlation[i][j], matrix.Correlation[j][i] = &corr, &corr
+			}
+		}
+	}
+
+	return matrix, nil
+}
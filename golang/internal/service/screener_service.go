@@ -0,0 +1,82 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"stock-api/internal/repository"
+)
+
+// ErrScreenNotFound is returned when a user has no saved screen with the requested id
+var ErrScreenNotFound = errors.New("saved screen not found")
+
+// ScreenerService runs stock screens and manages users' saved screens
+type ScreenerService struct {
+	screenerRepo *repository.ScreenerRepository
+}
+
+func NewScreenerService(screenerRepo *repository.ScreenerRepository) *ScreenerService {
+	return &ScreenerService{screenerRepo: screenerRepo}
+}
+
+// Screen runs an ad-hoc screen
+func (s *ScreenerService) Screen(q repository.ScreenQuery) (*repository.ScreenResult, error) {
+	return s.screenerRepo.Screen(q)
+}
+
+// SaveScreen validates a screen and saves it under name for the user,
+// replacing any screen of theirs with the same name
+func (s *ScreenerService) SaveScreen(userID int, name string, q repository.ScreenQuery) (*repository.SavedScreen, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", repository.ErrInvalidScreen)
+	}
+	if err := repository.ValidateScreen(q); err != nil {
+		return nil, err
+	}
+	return s.screenerRepo.SaveScreen(userID, name, q)
+}
+
+// GetSavedScreens lists the user's saved screens
+func (s *ScreenerService) GetSavedScreens(userID int) ([]repository.SavedScreen, error) {
+	return s.screenerRepo.GetSavedScreens(userID)
+}
+
+// RunSavedScreen runs one of the user's saved screens, overriding its paging
+// when limit or offset are positive
+func (s *ScreenerService) RunSavedScreen(userID, id, limit, offset int) (*repository.SavedScreen, *repository.ScreenResult, error) {
+	screen, err := s.screenerRepo.GetSavedScreen(userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if screen == nil {
+		return nil, nil, ErrScreenNotFound
+	}
+
+	q := screen.Query
+	if limit > 0 {
+		q.Limit = limit
+	}
+	if offset > 0 {
+		q.Offset = offset
+	}
+
+	result, err := s.screenerRepo.Screen(q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return screen, result, nil
+}
+
+// DeleteSavedScreen deletes one of the user's saved screens
+func (s *ScreenerService) DeleteSavedScreen(userID, id int) error {
+	deleted, err := s.screenerRepo.DeleteSavedScreen(userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrScreenNotFound
+	}
+	return nil
+}
@@ -2,18 +2,22 @@ package service
 
 import (
     "context"
+    "fmt"
+    "log"
     "time"
     "stock-api/internal/repository"
+    "stock-api/internal/scoring"
     "stock-api/internal/util"
 )
 
 type StockService struct {
     repo *repository.StockRepository
     scoreRepo *repository.StockScoreRepository
+    model scoring.Model
 }
 
-func NewStockService(repo *repository.StockRepository, scoreRepo *repository.StockScoreRepository) *StockService {
-    return &StockService{repo: repo, scoreRepo: scoreRepo}
+func NewStockService(repo *repository.StockRepository, scoreRepo *repository.StockScoreRepository, model scoring.Model) *StockService {
+    return &StockService{repo: repo, scoreRepo: scoreRepo, model: model}
 }
 
 func (s *StockService) GetStockSummary(symbol string) (float64, error) {
@@ -25,6 +29,11 @@ func (s *StockService) GetStockData(symbol string, startDate, endDate time.Time)
     return s.repo.GetStockData(symbol, startDate, endDate)
 }
 
+// GetDailyData gets daily bars for a symbol within a date range
+func (s *StockService) GetDailyData(symbol string, startDate, endDate time.Time) ([]repository.StockDailyData, error) {
+    return s.repo.GetDailyData(symbol, startDate, endDate)
+}
+
 // GetLatestStockData gets the most recent stock data for a symbol
 func (s *StockService) GetLatestStockData(symbol string) (*repository.StockIntraDayData, error) {
     return s.repo.GetLatestStockData(symbol)
@@ -61,30 +70,177 @@ func (s *StockService) DeleteStockMetadata(symbol string) error {
 }
 
 
-func (s *StockService) CalculateLongTermScoreCard(ctx context.Context, symbols []string) error{
+// CalculateLongTermScoreCard builds scorecards from the stored fundamentals and
+// then rescores every card whose peer group includes one of them, so peers'
+// percentile ranks reflect the new values. A symbol missing fundamentals is
+// recorded as failed and the rest are still scored.
+func (s *StockService) CalculateLongTermScoreCard(ctx context.Context, symbols []string) (*BatchResult, error) {
+    result := &BatchResult{Stored: []string{}, Failed: map[string]string{}}
+    industries := make(map[string]bool)
     for _, symbol := range symbols {
-        overview, _ := s.scoreRepo.GetOverview(symbol)
-        income, _ := s.scoreRepo.GetIncomeStatement(symbol)
-        balance, _ := s.scoreRepo.GetBalanceSheet(symbol)
-
-        card := repository.Scorecard{
-            Symbol:          symbol,
-            CompanyName:     overview.Name,
-            PERatio:         overview.PERatio,
-            PEGRatio:        overview.PEGRatio,
-            PriceToBook:     overview.PriceToBook,
-            ROE_TTM:         overview.ReturnOnEquityTTM,
-            OperatingMargin: overview.OperatingMargin,
-            ProfitMargin:    overview.ProfitMargin,
-            DividendYield:   overview.DividendYield,
-            Beta:            overview.Beta,
-            Revenue5YGrowth: util.Calculate5YRevenueGrowth(income),
-            HistoricalROE:   util.CalculateHistoricalROE(income, balance),
+        if err := ctx.Err(); err != nil {
+            return result, err
         }
-        err :=  s.scoreRepo.StoreStockScorecard(&card);
+
+        industry, err := s.buildScorecard(symbol)
         if err != nil {
-            return err
+            log.Printf("Failed to build scorecard for %s: %v", symbol, err)
+            result.Failed[symbol] = err.Error()
+            continue
+        }
+        result.Stored = append(result.Stored, symbol)
+        industries[industry] = true
+    }
+
+    if len(result.Stored) == 0 {
+        return result, fmt.Errorf("no scorecards were calculated for provided symbols")
+    }
+
+    // Snapshot every card that was rescored, which covers the stored ones and
+    // their peers, so peers' history shows the change too. Symbols that failed
+    // keep their old card and get no snapshot. Cards rescored before an error
+    // are still snapshotted.
+    rescored, scoreErr := s.scoreIndustries(ctx, industries)
+    if len(rescored) > 0 {
+        if err := s.scoreRepo.AppendScorecardHistory(rescored); err != nil {
+            return result, err
         }
     }
-    return nil
+    return result, scoreErr
+}
+
+// buildScorecard stores the scorecard of a symbol from its fundamentals and
+// returns the industry it is ranked in
+func (s *StockService) buildScorecard(symbol string) (string, error) {
+    overview, err := s.scoreRepo.GetOverview(symbol)
+    if err != nil {
+        return "", err
+    }
+    income, err := s.scoreRepo.GetIncomeStatement(symbol)
+    if err != nil {
+        return "", err
+    }
+    balance, err := s.scoreRepo.GetBalanceSheet(symbol)
+    if err != nil {
+        return "", err
+    }
+
+    // Metadata is optional; without an industry the card is ranked against everyone
+    industry := ""
+    if metadata, err := s.repo.GetStockMetadata(symbol); err == nil {
+        industry = util.DerefStr(metadata.Industry)
+    }
+
+    card := repository.Scorecard{
+        Symbol:          symbol,
+        CompanyName:     overview.Name,
+        Industry:        industry,
+        PERatio:         overview.PERatio,
+        PEGRatio:        overview.PEGRatio,
+        PriceToBook:     overview.PriceToBook,
+        ROE_TTM:         overview.ReturnOnEquityTTM,
+        OperatingMargin: overview.OperatingMargin,
+        ProfitMargin:    overview.ProfitMargin,
+        DividendYield:   overview.DividendYield,
+        Beta:            overview.Beta,
+        Revenue5YGrowth: util.Calculate5YRevenueGrowth(income),
+        HistoricalROE:   util.CalculateHistoricalROE(income, balance),
+    }
+    if err := s.scoreRepo.StoreStockScorecard(&card); err != nil {
+        return "", err
+    }
+    return industry, nil
+}
+
+// scoreIndustries recomputes the scores of every card in the given industries and
+// of every card ranked against all companies, whose peer group always changes.
+// It returns the symbols it rescored, including those done before an error.
+func (s *StockService) scoreIndustries(ctx context.Context, industries map[string]bool) ([]string, error) {
+    cards, err := s.scoreRepo.GetScorecards("")
+    if err != nil {
+        return nil, err
+    }
+
+    universe := scoring.PeerGroup{Name: "all", Cards: cards}
+    byIndustry := make(map[string][]repository.Scorecard)
+    for _, card := range cards {
+        if card.Industry != "" {
+            byIndustry[card.Industry] = append(byIndustry[card.Industry], card)
+        }
+    }
+
+    var rescored []string
+    for i := range cards {
+        industryCards := byIndustry[cards[i].Industry]
+        rankedOnAll := cards[i].Industry == "" || len(industryCards) < s.model.MinPeers
+        if !industries[cards[i].Industry] && !rankedOnAll {
+            continue
+        }
+        if err := ctx.Err(); err != nil {
+            return rescored, err
+        }
+
+        industry := scoring.PeerGroup{Name: cards[i].Industry, Cards: industryCards}
+        var stored *float64
+        composite, subScores, ok := s.model.Score(&cards[i], industry, universe)
+        if ok {
+            stored = &composite
+        }
+        if err := s.scoreRepo.StoreScores(cards[i].Symbol, stored, subScores); err != nil {
+            return rescored, err
+        }
+        rescored = append(rescored, cards[i].Symbol)
+    }
+    return rescored, nil
+}
+
+// GetScorecard returns the stored scorecard with its score breakdown, or nil
+// when the symbol has not been scored
+func (s *StockService) GetScorecard(symbol string) (*repository.Scorecard, error) {
+    return s.scoreRepo.GetStockScorecard(symbol)
+}
+
+// ScorecardTrend is a symbol's scorecard history with the change in each
+// factor between the first and last snapshot
+type ScorecardTrend struct {
+    Symbol    string                         `json:"symbol"`
+    Snapshots []repository.ScorecardSnapshot `json:"snapshots"`
+    Change    map[string]float64             `json:"change"`
+}
+
+// GetScorecardHistory returns the scorecard snapshots of a symbol within a time range
+func (s *StockService) GetScorecardHistory(symbol string, from, to time.Time) (*ScorecardTrend, error) {
+    history, err := s.scoreRepo.GetScorecardHistory(symbol, from, to)
+    if err != nil {
+        return nil, err
+    }
+
+    trend := &ScorecardTrend{Symbol: symbol, Snapshots: history, Change: map[string]float64{}}
+    if len(history) < 2 {
+        return trend, nil
+    }
+
+    // Factors missing from either end have no change to report
+    first, last := history[0], history[len(history)-1]
+    change := func(name string, from, to *float64) {
+        if from != nil && to != nil {
+            trend.Change[name] = *to - *from
+        }
+    }
+    change("pe_ratio", first.PERatio, last.PERatio)
+    change("peg_ratio", first.PEGRatio, last.PEGRatio)
+    change("price_to_book", first.PriceToBook, last.PriceToBook)
+    change("roe_ttm", first.ROE_TTM, last.ROE_TTM)
+    change("revenue_5y_growth", first.Revenue5YGrowth, last.Revenue5YGrowth)
+    change("operating_margin", first.OperatingMargin, last.OperatingMargin)
+    change("profit_margin", first.ProfitMargin, last.ProfitMargin)
+    change("dividend_yield", first.DividendYield, last.DividendYield)
+    change("beta", first.Beta, last.Beta)
+    change("composite_score", first.CompositeScore, last.CompositeScore)
+    return trend, nil
+}
+
+// ScoringModel returns the model used to score scorecards
+func (s *StockService) ScoringModel() scoring.Model {
+    return s.model
 }
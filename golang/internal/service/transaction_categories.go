@@ -0,0 +1,195 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"stock-api/internal/categorize"
+	"stock-api/internal/repository"
+)
+
+var (
+	// ErrInvalidCategoryRule is wrapped by errors caused by a malformed categorisation rule
+	ErrInvalidCategoryRule = errors.New("invalid category rule")
+	// ErrCategoryRuleNotFound is returned when a user has no rule with the requested id
+	ErrCategoryRuleNotFound = errors.New("category rule not found")
+)
+
+// RecategorizeRequest selects the transactions to run the rules over again.
+// Zero dates leave that end of the range open.
+type RecategorizeRequest struct {
+	From time.Time
+	To   time.Time
+	// Preview reports the changes without saving them
+	Preview bool
+}
+
+// RecategorizedRow is a transaction whose category the rules change
+type RecategorizedRow struct {
+	TransactionID int       `json:"transaction_id"`
+	Date          time.Time `json:"date"`
+	Amount        float64   `json:"amount"`
+	Description   string    `json:"description"`
+	Merchant      string    `json:"merchant"`
+	OldCategory   string    `json:"old_category"`
+	NewCategory   string    `json:"new_category"`
+	// RuleID is the rule that chose NewCategory, or nil when it is the source's
+	RuleID *int `json:"rule_id"`
+}
+
+// RecategorizeResult reports what running the rules over history changed
+type RecategorizeResult struct {
+	Preview bool `json:"preview"`
+	Scanned int  `json:"scanned"`
+	// Changed counts transactions whose category changes
+	Changed int                `json:"changed"`
+	Rows    []RecategorizedRow `json:"rows"`
+}
+
+// categorizer compiles the user's enabled categorisation rules
+func (s *TransactionService) categorizer(userID int) (*categorize.Categorizer, error) {
+	rules, err := s.transactionRepo.GetCategoryRules(userID)
+	if err != nil {
+		return nil, err
+	}
+	return categorize.New(rules)
+}
+
+// categorise fills in the merchant and the rule-chosen category of a
+// transaction about to be stored, keeping the imported category as its source category
+func categorise(c *categorize.Categorizer, t *repository.PersonalTransaction) {
+	t.SourceCategory = t.Category
+	result := c.Categorize(categorize.Transaction{
+		Description:    t.Description,
+		Amount:         t.Amount,
+		Account:        t.Account,
+		SourceCategory: t.SourceCategory,
+	})
+	t.Category = result.Category
+	t.Merchant = result.Merchant
+	t.CategoryRuleID = nil
+	if result.RuleID != 0 {
+		ruleID := result.RuleID
+		t.CategoryRuleID = &ruleID
+	}
+}
+
+// CreateCategoryRule saves a categorisation rule for the user
+func (s *TransactionService) CreateCategoryRule(userID int, rule *repository.CategoryRule) error {
+	rule.UserID = userID
+	if err := categorize.Normalise(rule); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCategoryRule, err)
+	}
+	return s.transactionRepo.CreateCategoryRule(rule)
+}
+
+// GetCategoryRules returns the user's rules in the order they run
+func (s *TransactionService) GetCategoryRules(userID int) ([]repository.CategoryRule, error) {
+	rules, err := s.transactionRepo.GetCategoryRules(userID)
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		rules = []repository.CategoryRule{}
+	}
+	return rules, nil
+}
+
+// GetCategoryRule returns one of the user's rules
+func (s *TransactionService) GetCategoryRule(userID, id int) (*repository.CategoryRule, error) {
+	rule, err := s.transactionRepo.GetCategoryRule(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, ErrCategoryRuleNotFound
+	}
+	return rule, nil
+}
+
+// UpdateCategoryRule changes one of the user's rules. Stored transactions
+// keep their categories until they are recategorised.
+func (s *TransactionService) UpdateCategoryRule(userID int, rule *repository.CategoryRule) error {
+	rule.UserID = userID
+	if err := categorize.Normalise(rule); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCategoryRule, err)
+	}
+	updated, err := s.transactionRepo.UpdateCategoryRule(rule)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrCategoryRuleNotFound
+	}
+	return nil
+}
+
+// DeleteCategoryRule removes one of the user's rules
+func (s *TransactionService) DeleteCategoryRule(userID, id int) error {
+	deleted, err := s.transactionRepo.DeleteCategoryRule(userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrCategoryRuleNotFound
+	}
+	return nil
+}
+
+// Recategorize runs the user's current rules over their stored transactions,
+// starting again from each transaction's source category
+func (s *TransactionService) Recategorize(userID int, req RecategorizeRequest) (*RecategorizeResult, error) {
+	if !req.From.IsZero() && !req.To.IsZero() && req.To.Before(req.From) {
+		return nil, fmt.Errorf("%w: to is before from", ErrInvalidTransactionQuery)
+	}
+
+	c, err := s.categorizer(userID)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := s.transactionRepo.GetTransactions(userID, req.From, req.To, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RecategorizeResult{Preview: req.Preview, Scanned: len(transactions), Rows: []RecategorizedRow{}}
+	var changes []repository.CategoryChange
+	for _, t := range transactions {
+		oldCategory, oldMerchant, oldRuleID := t.Category, t.Merchant, t.CategoryRuleID
+		t.Category = t.SourceCategory
+		categorise(c, &t)
+
+		ruleChanged := (oldRuleID == nil) != (t.CategoryRuleID == nil) ||
+			oldRuleID != nil && *oldRuleID != *t.CategoryRuleID
+		if t.Category == oldCategory && t.Merchant == oldMerchant && !ruleChanged {
+			continue
+		}
+		changes = append(changes, repository.CategoryChange{
+			TransactionID: t.ID,
+			Category:      t.Category,
+			Merchant:      t.Merchant,
+			RuleID:        t.CategoryRuleID,
+		})
+		if t.Category != oldCategory {
+			result.Rows = append(result.Rows, RecategorizedRow{
+				TransactionID: t.ID,
+				Date:          t.Date,
+				Amount:        t.Amount,
+				Description:   t.Description,
+				Merchant:      t.Merchant,
+				OldCategory:   oldCategory,
+				NewCategory:   t.Category,
+				RuleID:        t.CategoryRuleID,
+			})
+		}
+	}
+	result.Changed = len(result.Rows)
+
+	if !req.Preview && len(changes) > 0 {
+		if err := s.transactionRepo.UpdateCategories(userID, changes); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"stock-api/internal/api"
+	"stock-api/internal/repository"
+	"stock-api/internal/statements"
+)
+
+var (
+	// ErrInvalidImportProfile is wrapped by errors caused by a malformed import profile
+	ErrInvalidImportProfile = errors.New("invalid import profile")
+	// ErrImportProfileNotFound is returned when a user has no import profile with the requested id
+	ErrImportProfileNotFound = errors.New("import profile not found")
+)
+
+// statementSourceKey namespaces statement file fingerprints. Files carry no
+// stable row numbers, so overlapping downloads of the same account
+// deduplicate against each other whatever their format. Transactions with
+// their own id (OFX FITIDs) are keyed by it instead.
+const statementSourceKey = "statement"
+
+// externalFingerprint identifies a transaction by the id its bank gave it,
+// which is unique within an account
+func externalFingerprint(account, id string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00id\x00%s\x00%s", statementSourceKey, strings.ToLower(strings.TrimSpace(account)), id)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// StatementImport is one statement file to import
+type StatementImport struct {
+	FileName string
+	// Format is csv, ofx, qfx or qif; empty uses the file extension
+	Format string
+	Data   io.Reader
+	// ProfileID picks a saved CSV profile; Profile, when set, is used instead
+	ProfileID int
+	Profile   *statements.CSVProfile
+	// Account, Bank and Currency apply to transactions the file doesn't label
+	Account  string
+	Bank     string
+	Currency string
+	// DateFormat is a Go time layout for QIF dates
+	DateFormat string
+	// DryRun parses and checks for duplicates without storing anything
+	DryRun bool
+}
+
+// StatementRow is one parsed transaction and whether the user already has it
+type StatementRow struct {
+	repository.PersonalTransaction
+	Duplicate bool `json:"duplicate"`
+}
+
+// StatementImportResult reports what an import stored, or would store on a dry run
+type StatementImportResult struct {
+	Format        string         `json:"format"`
+	DryRun        bool           `json:"dry_run"`
+	NewRows       int            `json:"new_rows"`
+	DuplicateRows int            `json:"duplicate_rows"`
+	RejectedRows  int            `json:"rejected_rows"`
+	RowErrors     []api.RowError `json:"row_errors"`
+	// Rows are the parsed transactions, returned for dry runs
+	Rows []StatementRow `json:"rows,omitempty"`
+	// Run is the recorded import, absent for dry runs
+	Run *repository.ImportRun `json:"run,omitempty"`
+}
+
+// ImportStatement parses a statement file and stores the transactions the
+// user doesn't already have
+func (s *TransactionService) ImportStatement(ctx context.Context, userID int, req StatementImport) (*StatementImportResult, error) {
+	format, err := statements.DetectFormat(req.Format, req.FileName)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := statements.Options{
+		Account:    strings.TrimSpace(req.Account),
+		Bank:       strings.TrimSpace(req.Bank),
+		Currency:   strings.ToUpper(strings.TrimSpace(req.Currency)),
+		DateFormat: strings.TrimSpace(req.DateFormat),
+	}
+	switch {
+	case req.Profile != nil:
+		opts.Profile = *req.Profile
+	case req.ProfileID > 0:
+		profile, err := s.transactionRepo.GetImportProfile(userID, req.ProfileID)
+		if err != nil {
+			return nil, err
+		}
+		if profile == nil {
+			return nil, ErrImportProfileNotFound
+		}
+		opts.Profile = profile.Profile
+		if opts.Bank == "" {
+			opts.Bank = profile.Bank
+		}
+	}
+
+	if err := statements.ValidateDateFormat(opts.DateFormat); err != nil {
+		return nil, fmt.Errorf("%w: %v", statements.ErrInvalidStatement, err)
+	}
+	st, err := statements.Parse(format, req.Data, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	categorizer, err := s.categorizer(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &StatementImportResult{Format: format, DryRun: req.DryRun, RowErrors: st.Errors}
+	var transactions []repository.PersonalTransaction
+	occurrences := make(map[string]int)
+	for _, row := range st.Transactions {
+		if reason := rejectTransaction(row); reason != "" {
+			result.RowErrors = append(result.RowErrors, api.RowError{Row: row.Row, Error: reason})
+			continue
+		}
+		// Identical transactions within one file are told apart by how many
+		// came before them, which survives re-downloads with different ranges
+		var fingerprint string
+		if row.ExternalID != "" {
+			fingerprint = externalFingerprint(row.Account, row.ExternalID)
+		} else {
+			key := TransactionFingerprint(statementSourceKey, 0, row.Date, row.Amount, row.Description, row.Account)
+			occurrences[key]++
+			fingerprint = TransactionFingerprint(statementSourceKey, occurrences[key], row.Date, row.Amount, row.Description, row.Account)
+		}
+
+		sourceRow := row.Row
+		t := repository.PersonalTransaction{
+			UserID:      userID,
+			SourceRow:   &sourceRow,
+			Fingerprint: fingerprint,
+			Date:        row.Date,
+			Amount:      row.Amount,
+			Currency:    row.Currency,
+			Description: row.Description,
+			Category:    row.Category,
+			Bank:        row.Bank,
+			Account:     row.Account,
+		}
+		categorise(categorizer, &t)
+		transactions = append(transactions, t)
+	}
+	if result.RowErrors == nil {
+		result.RowErrors = []api.RowError{}
+	}
+	result.RejectedRows = len(result.RowErrors)
+
+	if req.DryRun {
+		fingerprints := make([]string, len(transactions))
+		for i, t := range transactions {
+			fingerprints[i] = t.Fingerprint
+		}
+		existing, err := s.transactionRepo.GetExistingFingerprints(userID, fingerprints)
+		if err != nil {
+			return nil, err
+		}
+		result.Rows = make([]StatementRow, len(transactions))
+		for i, t := range transactions {
+			result.Rows[i] = StatementRow{PersonalTransaction: t, Duplicate: existing[t.Fingerprint]}
+			if existing[t.Fingerprint] {
+				result.DuplicateRows++
+			} else {
+				result.NewRows++
+			}
+		}
+		return result, nil
+	}
+
+	run := &repository.ImportRun{UserID: userID, FileName: req.FileName, Format: format}
+	if err := s.transactionRepo.CreateImportRun(run); err != nil {
+		return nil, err
+	}
+	run.RowErrors = result.RowErrors
+	result.Run = run
+
+	inserted, err := s.transactionRepo.StoreTransactions(run, transactions)
+	if err != nil {
+		_, err = s.failRun(run, err)
+		return nil, err
+	}
+
+	run.Status = repository.ImportCompleted
+	run.NewRows = inserted
+	run.DuplicateRows = len(transactions) - inserted
+	run.RejectedRows = result.RejectedRows
+	if err := s.transactionRepo.FinishImportRun(run); err != nil {
+		return nil, err
+	}
+	result.NewRows, result.DuplicateRows = run.NewRows, run.DuplicateRows
+
+	log.Printf("Imported %s statement %q for user %d: %d new, %d duplicate, %d rejected",
+		format, req.FileName, userID, run.NewRows, run.DuplicateRows, run.RejectedRows)
+	return result, nil
+}
+
+// normaliseImportProfile trims and validates a profile's name and layout
+func normaliseImportProfile(p *repository.ImportProfile) error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Bank = strings.TrimSpace(p.Bank)
+	if p.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidImportProfile)
+	}
+	if len(p.Name) > 128 || len(p.Bank) > 128 {
+		return fmt.Errorf("%w: name and bank must be at most 128 characters", ErrInvalidImportProfile)
+	}
+	if err := p.Profile.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidImportProfile, err)
+	}
+	return nil
+}
+
+// checkImportProfileName rejects a name another of the user's profiles already has
+func (s *TransactionService) checkImportProfileName(p *repository.ImportProfile) error {
+	existing, err := s.transactionRepo.GetImportProfiles(p.UserID)
+	if err != nil {
+		return err
+	}
+	for _, e := range existing {
+		if e.ID != p.ID && strings.EqualFold(e.Name, p.Name) {
+			return fmt.Errorf("%w: a profile named %q already exists", ErrInvalidImportProfile, p.Name)
+		}
+	}
+	return nil
+}
+
+// CreateImportProfile saves a CSV layout for the user
+func (s *TransactionService) CreateImportProfile(userID int, p *repository.ImportProfile) error {
+	p.UserID = userID
+	if err := normaliseImportProfile(p); err != nil {
+		return err
+	}
+	if err := s.checkImportProfileName(p); err != nil {
+		return err
+	}
+	return s.transactionRepo.CreateImportProfile(p)
+}
+
+// GetImportProfiles returns the user's CSV layouts
+func (s *TransactionService) GetImportProfiles(userID int) ([]repository.ImportProfile, error) {
+	profiles, err := s.transactionRepo.GetImportProfiles(userID)
+	if err != nil {
+		return nil, err
+	}
+	if profiles == nil {
+		profiles = []repository.ImportProfile{}
+	}
+	return profiles, nil
+}
+
+// UpdateImportProfile changes one of the user's CSV layouts
+func (s *TransactionService) UpdateImportProfile(userID int, p *repository.ImportProfile) error {
+	p.UserID = userID
+	if err := normaliseImportProfile(p); err != nil {
+		return err
+	}
+	if err := s.checkImportProfileName(p); err != nil {
+		return err
+	}
+	updated, err := s.transactionRepo.UpdateImportProfile(p)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrImportProfileNotFound
+	}
+	return nil
+}
+
+// DeleteImportProfile removes one of the user's CSV layouts
+func (s *TransactionService) DeleteImportProfile(userID, id int) error {
+	deleted, err := s.transactionRepo.DeleteImportProfile(userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrImportProfileNotFound
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"stock-api/internal/categorize"
+	"stock-api/internal/recurring"
+)
+
+// RecurringQuery selects the history scanned for recurring charges
+type RecurringQuery struct {
+	// Months is how far back to look (default 24, at most 60). Annual
+	// charges need at least two years to be seen twice.
+	Months int
+	// IncludeInactive also reports series whose next charge is overdue
+	IncludeInactive bool
+	// IncludeCredits also reports recurring income, such as salaries
+	IncludeCredits bool
+}
+
+// RecurringResult lists a user's recurring charges
+type RecurringResult struct {
+	Recurring []recurring.Series `json:"recurring"`
+	// MonthlyTotal is the monthly cost of the active recurring debits
+	MonthlyTotal float64 `json:"monthly_total"`
+	// PriceIncreases counts the active series whose latest charge went up
+	PriceIncreases int `json:"price_increases"`
+}
+
+// Recurring finds the user's subscriptions and other charges that repeat on
+// a schedule, grouping transactions by normalised merchant
+func (s *TransactionService) Recurring(userID int, query RecurringQuery) (*RecurringResult, error) {
+	if query.Months < 0 || query.Months > 60 {
+		return nil, fmt.Errorf("%w: months must be between 1 and 60", ErrInvalidTransactionQuery)
+	}
+	if query.Months == 0 {
+		query.Months = 24
+	}
+
+	now := time.Now()
+	from := now.AddDate(0, -query.Months, 0)
+	transactions, err := s.transactionRepo.GetTransactions(userID, from, time.Time{}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	charges := make([]recurring.Charge, 0, len(transactions))
+	for _, t := range transactions {
+		merchant := t.Merchant
+		// Transactions stored before merchants were recorded have none
+		if merchant == "" {
+			merchant = categorize.NormaliseMerchant(t.Description)
+		}
+		charges = append(charges, recurring.Charge{
+			ID:       t.ID,
+			Date:     t.Date,
+			Amount:   t.Amount,
+			Merchant: merchant,
+			Category: t.Category,
+			Account:  t.Account,
+		})
+	}
+
+	result := &RecurringResult{Recurring: []recurring.Series{}}
+	for _, series := range recurring.Detect(charges, recurring.Options{Now: now}) {
+		if !series.Active && !query.IncludeInactive {
+			continue
+		}
+		if series.Direction == categorize.DirectionCredit && !query.IncludeCredits {
+			continue
+		}
+		result.Recurring = append(result.Recurring, series)
+		if series.Active && series.Direction == categorize.DirectionDebit {
+			result.MonthlyTotal += series.MonthlyCost
+			if series.PriceIncrease {
+				result.PriceIncreases++
+			}
+		}
+	}
+	result.MonthlyTotal = math.Round(result.MonthlyTotal*100) / 100
+	return result, nil
+}
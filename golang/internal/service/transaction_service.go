@@ -2,32 +2,334 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
+	"sort"
+	"strings"
+	"time"
+
 	"stock-api/internal/api"
 	"stock-api/internal/repository"
+	"stock-api/internal/statements"
+)
+
+var (
+	// ErrInvalidTransactionSource is wrapped by errors caused by a malformed transaction source
+	ErrInvalidTransactionSource = errors.New("invalid transaction source")
+	// ErrInvalidTransactionQuery is wrapped by errors caused by a malformed transaction query
+	ErrInvalidTransactionQuery = errors.New("invalid transaction query")
+	// ErrTransactionSourceNotFound is returned when a user has no source with the requested id
+	ErrTransactionSourceNotFound = errors.New("transaction source not found")
+	// ErrImportRunNotFound is returned when a user has no import run with the requested id
+	ErrImportRunNotFound = errors.New("import run not found")
+	// ErrSourceFetch is wrapped by errors reading a source's rows from its provider
+	ErrSourceFetch = errors.New("could not fetch transactions from source")
 )
 
+// TransactionService imports bank transactions into each user's own ledger
 type TransactionService struct {
-	rowsClient *api.RowsClient
-    transactionRepo *repository.TransactionsRepository
+	rowsClient      *api.RowsClient
+	transactionRepo *repository.TransactionsRepository
 }
 
 func NewTransactionService(rowsClient *api.RowsClient, transactionRepo *repository.TransactionsRepository) *TransactionService {
-    return &TransactionService{rowsClient: rowsClient, transactionRepo: transactionRepo}
+	return &TransactionService{rowsClient: rowsClient, transactionRepo: transactionRepo}
 }
 
+// TransactionFingerprint identifies a transaction across imports of the same
+// source, so re-importing a source never duplicates rows. The source row is
+// part of it so genuinely repeated transactions (two identical coffees on one
+// day) are kept apart.
+func TransactionFingerprint(sourceKey string, row int, date time.Time, amount float64, description, account string) string {
+	normalise := func(s string) string {
+		return strings.ToLower(strings.Join(strings.Fields(s), " "))
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%s\x00%.2f\x00%s\x00%s",
+		sourceKey, row, date.Format("2006-01-02"), amount, normalise(description), normalise(account))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normaliseSource trims and validates a source's fields and mapping
+func normaliseSource(src *repository.TransactionSource) error {
+	src.Name = strings.TrimSpace(src.Name)
+	src.Kind = strings.ToLower(strings.TrimSpace(src.Kind))
+	src.SpreadsheetID = strings.TrimSpace(src.SpreadsheetID)
+	src.TableID = strings.TrimSpace(src.TableID)
 
-func (s *TransactionService) GetBankTransactions(ctx context.Context) error {
-	bankTransactionRows, err := s.rowsClient.FetchRows(ctx, "3EOtDHWVB88EJRLjimH0Zq", "3a5b5c99-8f7d-417c-ac85-87f0adf50b53")
+	if src.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidTransactionSource)
+	}
+	if len(src.Name) > 128 {
+		return fmt.Errorf("%w: name is longer than 128 characters", ErrInvalidTransactionSource)
+	}
+	if src.Kind == "" {
+		src.Kind = "rows"
+	}
+	if src.Kind != "rows" {
+		return fmt.Errorf("%w: unknown kind %q (use rows)", ErrInvalidTransactionSource, src.Kind)
+	}
+	if src.SpreadsheetID == "" || src.TableID == "" {
+		return fmt.Errorf("%w: spreadsheet_id and table_id are required", ErrInvalidTransactionSource)
+	}
+
+	src.Mapping = src.Mapping.WithDefaults()
+	if err := statements.ValidateDateFormat(src.Mapping.DateFormat); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTransactionSource, err)
+	}
+	return nil
+}
+
+// checkSourceName rejects a name another of the user's sources already has
+func (s *TransactionService) checkSourceName(src *repository.TransactionSource) error {
+	existing, err := s.transactionRepo.GetSources(src.UserID)
 	if err != nil {
-		log.Printf("failed to get transactionData data: %v", err)
+		return err
+	}
+	for _, e := range existing {
+		if e.ID != src.ID && strings.EqualFold(e.Name, src.Name) {
+			return fmt.Errorf("%w: a source named %q already exists", ErrInvalidTransactionSource, src.Name)
+		}
 	}
+	return nil
+}
 
-	err = s.transactionRepo.StoreTransactions(bankTransactionRows)
+// CreateSource registers a transaction source for the user
+func (s *TransactionService) CreateSource(userID int, src *repository.TransactionSource) error {
+	src.UserID = userID
+	if err := normaliseSource(src); err != nil {
+		return err
+	}
+	if err := s.checkSourceName(src); err != nil {
+		return err
+	}
+	return s.transactionRepo.CreateSource(src)
+}
+
+// UpdateSource changes one of the user's sources. Changing its table or
+// mapping makes the next sync read the whole table again.
+func (s *TransactionService) UpdateSource(userID int, src *repository.TransactionSource) error {
+	src.UserID = userID
+	if err := normaliseSource(src); err != nil {
+		return err
+	}
+	if err := s.checkSourceName(src); err != nil {
+		return err
+	}
+	updated, err := s.transactionRepo.UpdateSource(src)
 	if err != nil {
 		return err
 	}
-	log.Printf("Successfully stored transaction data")
+	if !updated {
+		return ErrTransactionSourceNotFound
+	}
+	return nil
+}
+
+// GetSources returns the user's transaction sources
+func (s *TransactionService) GetSources(userID int) ([]repository.TransactionSource, error) {
+	sources, err := s.transactionRepo.GetSources(userID)
+	if err != nil {
+		return nil, err
+	}
+	if sources == nil {
+		sources = []repository.TransactionSource{}
+	}
+	return sources, nil
+}
 
+// DeleteSource removes one of the user's transaction sources, keeping the
+// transactions already imported from it
+func (s *TransactionService) DeleteSource(userID, id int) error {
+	deleted, err := s.transactionRepo.DeleteSource(userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrTransactionSourceNotFound
+	}
 	return nil
 }
+
+// DefaultSourceID returns the user's only source, for callers that don't name one
+func (s *TransactionService) DefaultSourceID(userID int) (int, error) {
+	sources, err := s.transactionRepo.GetSources(userID)
+	if err != nil {
+		return 0, err
+	}
+	switch len(sources) {
+	case 0:
+		return 0, fmt.Errorf("%w: register a transaction source first", ErrInvalidTransactionSource)
+	case 1:
+		return sources[0].ID, nil
+	default:
+		return 0, fmt.Errorf("%w: source_id is required when there are several sources", ErrInvalidTransactionSource)
+	}
+}
+
+// ImportFromSource reads one of the user's sources and stores the rows the
+// user doesn't already have. It reads from below the last imported row unless
+// full is set. The returned run reports new, duplicate and rejected rows; it
+// is also returned, marked failed, when the import fails.
+func (s *TransactionService) ImportFromSource(ctx context.Context, userID, sourceID int, full bool) (*repository.ImportRun, error) {
+	src, err := s.transactionRepo.GetSource(userID, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	if src == nil {
+		return nil, ErrTransactionSourceNotFound
+	}
+
+	fromRow := src.LastRow + 1
+	if full || fromRow < 2 {
+		fromRow = 2
+	}
+	run := &repository.ImportRun{UserID: userID, SourceID: &src.ID, FromRow: &fromRow}
+	if err := s.transactionRepo.CreateImportRun(run); err != nil {
+		return nil, err
+	}
+
+	sync, err := s.rowsClient.FetchRows(ctx, src.SpreadsheetID, src.TableID, src.Mapping, fromRow)
+	if err != nil {
+		return s.failRun(run, fmt.Errorf("%w %q: %v", ErrSourceFetch, src.Name, err))
+	}
+
+	categorizer, err := s.categorizer(userID)
+	if err != nil {
+		return s.failRun(run, err)
+	}
+
+	run.RowErrors = sync.Errors
+	sourceKey := fmt.Sprintf("source:%d", src.ID)
+	var transactions []repository.PersonalTransaction
+	for _, row := range sync.Transactions {
+		if reason := rejectTransaction(row); reason != "" {
+			run.RowErrors = append(run.RowErrors, api.RowError{Row: row.Row, Error: reason})
+			continue
+		}
+		sourceRow := row.Row
+		t := repository.PersonalTransaction{
+			UserID:      userID,
+			SourceID:    &src.ID,
+			SourceRow:   &sourceRow,
+			Fingerprint: TransactionFingerprint(sourceKey, row.Row, row.Date, row.Amount, row.Description, row.Account),
+			Date:        row.Date,
+			Amount:      row.Amount,
+			Currency:    row.Currency,
+			Description: row.Description,
+			Category:    row.Category,
+			Bank:        row.Bank,
+			Account:     row.Account,
+		}
+		categorise(categorizer, &t)
+		transactions = append(transactions, t)
+	}
+	sort.Slice(run.RowErrors, func(i, j int) bool { return run.RowErrors[i].Row < run.RowErrors[j].Row })
+	if sync.LastRow > 0 {
+		run.ToRow = &sync.LastRow
+	}
+
+	inserted, err := s.transactionRepo.StoreTransactions(run, transactions)
+	if err != nil {
+		return s.failRun(run, err)
+	}
+
+	// Move the sync position past rejected rows too: a row that never parses
+	// (a subtotal, a zero-amount hold) would otherwise be re-read on every sync.
+	// Rejected rows are listed on the run; once fixed, a full sync picks them
+	// up and fingerprints skip the rows already stored.
+	lastRow := fromRow - 1
+	if sync.LastRow > lastRow {
+		lastRow = sync.LastRow
+	}
+	if err := s.transactionRepo.SetSourceSyncPosition(src.ID, lastRow); err != nil {
+		return s.failRun(run, err)
+	}
+
+	run.Status = repository.ImportCompleted
+	run.NewRows = inserted
+	run.DuplicateRows = len(transactions) - inserted
+	run.RejectedRows = len(run.RowErrors)
+	if err := s.transactionRepo.FinishImportRun(run); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Imported transactions for user %d from source %d rows %d-%d: %d new, %d duplicate, %d rejected",
+		userID, src.ID, fromRow, sync.LastRow, run.NewRows, run.DuplicateRows, run.RejectedRows)
+	return run, nil
+}
+
+// failRun records a failed import and returns the cause
+func (s *TransactionService) failRun(run *repository.ImportRun, cause error) (*repository.ImportRun, error) {
+	run.Status = repository.ImportFailed
+	run.Error = cause.Error()
+	run.RejectedRows = len(run.RowErrors)
+	if err := s.transactionRepo.FinishImportRun(run); err != nil {
+		log.Printf("Failed to record failed import run %d: %v", run.ID, err)
+	}
+	return run, cause
+}
+
+// rejectTransaction returns why a row can't be stored, or "" when it can
+func rejectTransaction(t api.Transaction) string {
+	switch {
+	case t.Date.IsZero():
+		return "missing or invalid date"
+	case t.Amount == 0:
+		return "missing or zero amount"
+	case strings.TrimSpace(t.Description) == "":
+		return "missing description"
+	}
+	return ""
+}
+
+// GetTransactions returns the user's transactions dated within [from, to]
+func (s *TransactionService) GetTransactions(userID int, from, to time.Time, limit int) ([]repository.PersonalTransaction, error) {
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return nil, fmt.Errorf("%w: to is before from", ErrInvalidTransactionQuery)
+	}
+	if limit < 0 || limit > 5000 {
+		return nil, fmt.Errorf("%w: limit must be between 1 and 5000", ErrInvalidTransactionQuery)
+	}
+	if limit == 0 {
+		limit = 500
+	}
+	transactions, err := s.transactionRepo.GetTransactions(userID, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	if transactions == nil {
+		transactions = []repository.PersonalTransaction{}
+	}
+	return transactions, nil
+}
+
+// GetImportRuns returns the user's most recent import runs
+func (s *TransactionService) GetImportRuns(userID, limit int) ([]repository.ImportRun, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	runs, err := s.transactionRepo.GetImportRuns(userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if runs == nil {
+		runs = []repository.ImportRun{}
+	}
+	return runs, nil
+}
+
+// GetImportRun returns one of the user's import runs
+func (s *TransactionService) GetImportRun(userID, id int) (*repository.ImportRun, error) {
+	run, err := s.transactionRepo.GetImportRun(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		return nil, ErrImportRunNotFound
+	}
+	return run, nil
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"stock-api/internal/repository"
+)
+
+var (
+	// ErrInvalidWatchlist is wrapped by every error caused by a malformed watchlist change
+	ErrInvalidWatchlist = errors.New("invalid watchlist")
+	// ErrWatchlistNotFound is returned when a user has no watchlist with the requested id
+	ErrWatchlistNotFound = errors.New("watchlist not found")
+)
+
+// maxWatchlistSymbols bounds a watchlist so its summary stays one cheap query
+const maxWatchlistSymbols = 200
+
+// WatchlistService manages users' watchlists
+type WatchlistService struct {
+	watchlistRepo *repository.WatchlistRepository
+}
+
+// WatchlistItem is one member of a watchlist summary. Change fields are nil
+// without a latest price and previous close; RelativeVolume compares the
+// session's volume so far with the average daily volume.
+type WatchlistItem struct {
+	repository.WatchlistQuote
+	DayChange      *float64 `json:"day_change"`
+	DayChangePct   *float64 `json:"day_change_pct"`
+	RelativeVolume *float64 `json:"relative_volume"`
+}
+
+// WatchlistSummary is a watchlist with the latest data for each member
+type WatchlistSummary struct {
+	Watchlist *repository.Watchlist `json:"watchlist"`
+	Items     []WatchlistItem       `json:"items"`
+}
+
+func NewWatchlistService(watchlistRepo *repository.WatchlistRepository) *WatchlistService {
+	return &WatchlistService{watchlistRepo: watchlistRepo}
+}
+
+// normaliseSymbols upper-cases symbols and drops blanks and duplicates, keeping order
+func normaliseSymbols(symbols []string) ([]string, error) {
+	seen := make(map[string]bool)
+	out := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if symbol == "" || seen[symbol] {
+			continue
+		}
+		if len(symbol) > 10 {
+			return nil, fmt.Errorf("%w: symbol %q is longer than 10 characters", ErrInvalidWatchlist, symbol)
+		}
+		seen[symbol] = true
+		out = append(out, symbol)
+	}
+	return out, nil
+}
+
+func normaliseWatchlistName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name is required", ErrInvalidWatchlist)
+	}
+	if len(name) > 128 {
+		return "", fmt.Errorf("%w: name is longer than 128 characters", ErrInvalidWatchlist)
+	}
+	return name, nil
+}
+
+// CreateWatchlist creates a watchlist for the user
+func (s *WatchlistService) CreateWatchlist(userID int, w *repository.Watchlist) error {
+	w.UserID = userID
+	name, err := normaliseWatchlistName(w.Name)
+	if err != nil {
+		return err
+	}
+	w.Name = name
+	if w.Symbols, err = normaliseSymbols(w.Symbols); err != nil {
+		return err
+	}
+	if len(w.Symbols) > maxWatchlistSymbols {
+		return fmt.Errorf("%w: a watchlist holds at most %d symbols", ErrInvalidWatchlist, maxWatchlistSymbols)
+	}
+	return s.watchlistRepo.CreateWatchlist(w)
+}
+
+// GetWatchlists lists the user's watchlists
+func (s *WatchlistService) GetWatchlists(userID int) ([]repository.Watchlist, error) {
+	return s.watchlistRepo.GetWatchlists(userID)
+}
+
+// GetWatchlist returns one of the user's watchlists
+func (s *WatchlistService) GetWatchlist(userID, id int) (*repository.Watchlist, error) {
+	w, err := s.watchlistRepo.GetWatchlist(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if w == nil {
+		return nil, ErrWatchlistNotFound
+	}
+	return w, nil
+}
+
+// RenameWatchlist renames one of the user's watchlists
+func (s *WatchlistService) RenameWatchlist(userID, id int, name string) (*repository.Watchlist, error) {
+	name, err := normaliseWatchlistName(name)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.watchlistRepo.RenameWatchlist(userID, id, name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrWatchlistNotFound
+	}
+	return s.GetWatchlist(userID, id)
+}
+
+// DeleteWatchlist deletes one of the user's watchlists. Alert rules limited to
+// it are kept but disabled.
+func (s *WatchlistService) DeleteWatchlist(userID, id int) error {
+	ok, err := s.watchlistRepo.DeleteWatchlist(userID, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrWatchlistNotFound
+	}
+	return nil
+}
+
+// AddSymbols appends symbols to the end of one of the user's watchlists,
+// ignoring ones already on it
+func (s *WatchlistService) AddSymbols(userID, id int, symbols []string) (*repository.Watchlist, error) {
+	w, err := s.GetWatchlist(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	symbols, err = normaliseSymbols(symbols)
+	if err != nil {
+		return nil, err
+	}
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("%w: at least one symbol is required", ErrInvalidWatchlist)
+	}
+
+	members := make(map[string]bool, len(w.Symbols))
+	for _, symbol := range w.Symbols {
+		members[symbol] = true
+	}
+	added := 0
+	for _, symbol := range symbols {
+		if !members[symbol] {
+			added++
+		}
+	}
+	if len(w.Symbols)+added > maxWatchlistSymbols {
+		return nil, fmt.Errorf("%w: a watchlist holds at most %d symbols", ErrInvalidWatchlist, maxWatchlistSymbols)
+	}
+
+	if err := s.watchlistRepo.AddSymbols(id, symbols); err != nil {
+		return nil, err
+	}
+	return s.GetWatchlist(userID, id)
+}
+
+// RemoveSymbol removes a symbol from one of the user's watchlists
+func (s *WatchlistService) RemoveSymbol(userID, id int, symbol string) (*repository.Watchlist, error) {
+	if _, err := s.GetWatchlist(userID, id); err != nil {
+		return nil, err
+	}
+	ok, err := s.watchlistRepo.RemoveSymbol(id, strings.ToUpper(strings.TrimSpace(symbol)))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not on the watchlist", ErrWatchlistNotFound, symbol)
+	}
+	return s.GetWatchlist(userID, id)
+}
+
+// ReorderSymbols puts one of the user's watchlists in the given order, which
+// must list every member exactly once
+func (s *WatchlistService) ReorderSymbols(userID, id int, symbols []string) (*repository.Watchlist, error) {
+	w, err := s.GetWatchlist(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	symbols, err = normaliseSymbols(symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	current := append([]string(nil), w.Symbols...)
+	proposed := append([]string(nil), symbols...)
+	sort.Strings(current)
+	sort.Strings(proposed)
+	if strings.Join(current, ",") != strings.Join(proposed, ",") {
+		return nil, fmt.Errorf("%w: the new order must list every symbol on the watchlist exactly once", ErrInvalidWatchlist)
+	}
+
+	if err := s.watchlistRepo.ReorderSymbols(id, symbols); err != nil {
+		return nil, err
+	}
+	return s.GetWatchlist(userID, id)
+}
+
+// Summary returns the latest price, day change, relative volume and composite
+// score for each member of one of the user's watchlists
+func (s *WatchlistService) Summary(userID, id int) (*WatchlistSummary, error) {
+	w, err := s.GetWatchlist(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	quotes, err := s.watchlistRepo.GetQuotes(id)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]WatchlistItem, len(quotes))
+	for i, q := range quotes {
+		item := WatchlistItem{WatchlistQuote: q}
+		if q.LastPrice != nil && q.PreviousClose != nil && *q.PreviousClose > 0 {
+			change := *q.LastPrice - *q.PreviousClose
+			pct := change / *q.PreviousClose * 100
+			item.DayChange = &change
+			item.DayChangePct = &pct
+		}
+		if q.Volume != nil && q.AverageVolume != nil && *q.AverageVolume > 0 {
+			rvol := *q.Volume / *q.AverageVolume
+			item.RelativeVolume = &rvol
+		}
+		items[i] = item
+	}
+	return &WatchlistSummary{Watchlist: w, Items: items}, nil
+}
+
+// WatchedSymbols returns the symbols on a watchlist, or on every user's
+// watchlists when watchlistID is 0, for use as an extraction source
+func (s *WatchlistService) WatchedSymbols(watchlistID int) ([]string, error) {
+	return s.watchlistRepo.GetWatchedSymbols(watchlistID)
+}
@@ -0,0 +1,243 @@
+package statements
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"stock-api/internal/api"
+)
+
+// CSVProfile describes one bank's CSV export. Column fields name a header;
+// empty ones are matched against the header names banks commonly use.
+type CSVProfile struct {
+	// Delimiter is a single character; empty means ","
+	Delimiter string `json:"delimiter,omitempty"`
+	// SkipLines drops preamble lines above the header
+	SkipLines int    `json:"skip_lines,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Amount    string `json:"amount,omitempty"`
+	// Debit and Credit are used instead of Amount by banks that split money
+	// out and money in; debits are stored as negative amounts
+	Debit       string `json:"debit,omitempty"`
+	Credit      string `json:"credit,omitempty"`
+	Description string `json:"description,omitempty"`
+	Currency    string `json:"currency,omitempty"`
+	Category    string `json:"category,omitempty"`
+	Account     string `json:"account,omitempty"`
+	// DateFormat is a Go time layout; empty tries the common date formats
+	DateFormat string `json:"date_format,omitempty"`
+	// DecimalComma reads amounts like "1.234,56"
+	DecimalComma bool `json:"decimal_comma,omitempty"`
+	// InvertSign flips amounts for banks that export spending as positive numbers
+	InvertSign bool `json:"invert_sign,omitempty"`
+}
+
+// csvColumns maps each field to the header names banks commonly use for it
+var csvColumns = map[string][]string{
+	"date":        {"date", "transaction date", "posting date", "posted date", "booking date", "trans. date"},
+	"amount":      {"amount", "transaction amount", "amount (eur)", "amount (usd)", "amount (gbp)"},
+	"debit":       {"debit", "debit amount", "withdrawal", "withdrawals", "money out", "paid out"},
+	"credit":      {"credit", "credit amount", "deposit", "deposits", "money in", "paid in"},
+	"description": {"description", "payee", "merchant", "details", "narrative", "name", "memo", "reference"},
+	"currency":    {"currency", "ccy"},
+	"category":    {"category"},
+	"account":     {"account", "account name", "account number"},
+}
+
+var csvDateLayouts = []string{"2006-01-02", "01/02/2006", "1/2/2006", "01/02/06", "1/2/06", "02.01.2006", "2006/01/02", time.RFC3339}
+
+// Validate checks the profile can be used to read a CSV
+func (p CSVProfile) Validate() error {
+	if p.Delimiter != "" && utf8.RuneCountInString(p.Delimiter) != 1 && p.Delimiter != `\t` {
+		return fmt.Errorf("delimiter must be a single character")
+	}
+	if p.SkipLines < 0 || p.SkipLines > 100 {
+		return fmt.Errorf("skip_lines must be between 0 and 100")
+	}
+	return ValidateDateFormat(p.DateFormat)
+}
+
+func (p CSVProfile) column(field string) string {
+	switch field {
+	case "date":
+		return p.Date
+	case "amount":
+		return p.Amount
+	case "debit":
+		return p.Debit
+	case "credit":
+		return p.Credit
+	case "description":
+		return p.Description
+	case "currency":
+		return p.Currency
+	case "category":
+		return p.Category
+	case "account":
+		return p.Account
+	}
+	return ""
+}
+
+func parseCSV(r io.Reader, profile CSVProfile) (*Statement, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidStatement, err)
+	}
+
+	buffered := bufio.NewReader(r)
+	for i := 0; i < profile.SkipLines; i++ {
+		if _, err := buffered.ReadString('\n'); err != nil {
+			return nil, fmt.Errorf("%w: file ends within the %d skipped lines", ErrInvalidStatement, profile.SkipLines)
+		}
+	}
+
+	reader := csv.NewReader(buffered)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.LazyQuotes = true
+	switch profile.Delimiter {
+	case "":
+	case `\t`:
+		reader.Comma = '\t'
+	default:
+		reader.Comma, _ = utf8.DecodeRuneInString(profile.Delimiter)
+	}
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: file is empty", ErrInvalidStatement)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidStatement, err)
+	}
+
+	names := make([]string, len(header))
+	for i, name := range header {
+		names[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+	}
+	columns := make(map[string]int)
+	for field, aliases := range csvColumns {
+		if want := strings.ToLower(strings.TrimSpace(profile.column(field))); want != "" {
+			aliases = []string{want}
+		}
+		for _, alias := range aliases {
+			if _, found := columns[field]; found {
+				break
+			}
+			for i, name := range names {
+				if name == alias {
+					columns[field] = i
+					break
+				}
+			}
+		}
+		if _, found := columns[field]; !found && profile.column(field) != "" {
+			return nil, fmt.Errorf("%w: no %q column for %s", ErrInvalidStatement, profile.column(field), field)
+		}
+	}
+
+	_, hasDebit := columns["debit"]
+	_, hasCredit := columns["credit"]
+	_, hasAmount := columns["amount"]
+	splitAmounts := profile.Debit != "" || profile.Credit != "" || (!hasAmount && (hasDebit || hasCredit))
+	if !hasAmount && !splitAmounts {
+		return nil, fmt.Errorf("%w: no amount, debit or credit column", ErrInvalidStatement)
+	}
+	for _, field := range []string{"date", "description"} {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("%w: no %s column", ErrInvalidStatement, field)
+		}
+	}
+
+	st := &Statement{Transactions: []api.Transaction{}}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		line += profile.SkipLines
+		if err != nil {
+			st.Errors = append(st.Errors, api.RowError{Row: line, Error: err.Error()})
+			continue
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		get := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		t, err := csvTransaction(get, profile, splitAmounts)
+		if err != nil {
+			st.Errors = append(st.Errors, api.RowError{Row: line, Error: err.Error()})
+			continue
+		}
+		t.Row = line
+		st.Transactions = append(st.Transactions, *t)
+	}
+	return st, nil
+}
+
+// csvTransaction builds a transaction from one record's fields
+func csvTransaction(get func(string) string, profile CSVProfile, splitAmounts bool) (*api.Transaction, error) {
+	if get("date") == "" {
+		return nil, fmt.Errorf("missing date")
+	}
+	date, err := parseDate(get("date"), profile.DateFormat, csvDateLayouts)
+	if err != nil {
+		return nil, err
+	}
+
+	var amount float64
+	if splitAmounts {
+		debit, credit := get("debit"), get("credit")
+		if debit == "" && credit == "" {
+			return nil, fmt.Errorf("missing debit and credit")
+		}
+		if debit != "" {
+			v, err := parseAmount(debit, profile.DecimalComma)
+			if err != nil {
+				return nil, err
+			}
+			amount -= math.Abs(v)
+		}
+		if credit != "" {
+			v, err := parseAmount(credit, profile.DecimalComma)
+			if err != nil {
+				return nil, err
+			}
+			amount += math.Abs(v)
+		}
+	} else {
+		if get("amount") == "" {
+			return nil, fmt.Errorf("missing amount")
+		}
+		if amount, err = parseAmount(get("amount"), profile.DecimalComma); err != nil {
+			return nil, err
+		}
+	}
+	if profile.InvertSign {
+		amount = -amount
+	}
+
+	return &api.Transaction{
+		Date:        date,
+		Amount:      amount,
+		Currency:    get("currency"),
+		Description: get("description"),
+		Category:    get("category"),
+		Account:     get("account"),
+	}, nil
+}
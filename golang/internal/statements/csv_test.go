@@ -0,0 +1,107 @@
+package statements
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// wantTransaction is the date, description and amount expected of one transaction
+type wantTransaction struct {
+	date, description string
+	amount            float64
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		profile       CSVProfile
+		want          []wantTransaction
+		wantErrorRows []int
+	}{
+		{
+			name: "common headers",
+			input: "\ufeffPosting Date,Payee,Amount\n" +
+				"01/03/2024,COFFEE SHOP,-3.50\n" +
+				"\n" +
+				"01/04/2024,SALARY,\"2,000.00\"\n",
+			want: []wantTransaction{{"2024-01-03", "COFFEE SHOP", -3.5}, {"2024-01-04", "SALARY", 2000}},
+		},
+		{
+			name: "profile with preamble, debit and credit columns",
+			input: "Acme Bank export\n" +
+				"Generated 2024-02-01\n" +
+				"Booked;Text;Out;In\n" +
+				"03.01.2024;Supermarket;12,30;\n" +
+				"04.01.2024;Refund;;1.000,00\n",
+			profile: CSVProfile{Delimiter: ";", SkipLines: 2, Date: "Booked", Description: "Text", Debit: "Out", Credit: "In",
+				DateFormat: "02.01.2006", DecimalComma: true},
+			want: []wantTransaction{{"2024-01-03", "Supermarket", -12.3}, {"2024-01-04", "Refund", 1000}},
+		},
+		{
+			name:    "inverted sign",
+			input:   "Date,Description,Amount\n2024-01-03,Card payment,25.00\n",
+			profile: CSVProfile{InvertSign: true},
+			want:    []wantTransaction{{"2024-01-03", "Card payment", -25}},
+		},
+		{
+			name: "bad rows are reported by line",
+			input: "Date,Description,Amount\n" +
+				"2024-01-03,OK,1.00\n" +
+				"yesterday,BAD DATE,1.00\n" +
+				"2024-01-05,NO AMOUNT,\n",
+			want:          []wantTransaction{{"2024-01-03", "OK", 1}},
+			wantErrorRows: []int{3, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, err := parseCSV(strings.NewReader(tt.input), tt.profile)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(st.Transactions) != len(tt.want) {
+				t.Fatalf("got %d transactions, want %d", len(st.Transactions), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := st.Transactions[i]
+				if !got.Date.Equal(day(want.date)) || got.Amount != want.amount || got.Description != want.description {
+					t.Errorf("transaction %d = %s %v %q, want %s %v %q", i,
+						got.Date.Format("2006-01-02"), got.Amount, got.Description, want.date, want.amount, want.description)
+				}
+			}
+			if len(st.Errors) != len(tt.wantErrorRows) {
+				t.Fatalf("got errors %+v, want rows %v", st.Errors, tt.wantErrorRows)
+			}
+			for i, row := range tt.wantErrorRows {
+				if st.Errors[i].Row != row {
+					t.Errorf("error %d is on row %d, want %d", i, st.Errors[i].Row, row)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCSVRejectsUnusableFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		profile CSVProfile
+	}{
+		{"empty", "", CSVProfile{}},
+		{"no amount column", "Date,Description\n2024-01-03,COFFEE\n", CSVProfile{}},
+		{"no date column", "Description,Amount\nCOFFEE,1\n", CSVProfile{}},
+		{"profile column missing", "Date,Description,Amount\n", CSVProfile{Amount: "Value"}},
+		{"bad delimiter", "Date,Description,Amount\n", CSVProfile{Delimiter: ";;"}},
+		{"file shorter than the preamble", "one line\n", CSVProfile{SkipLines: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseCSV(strings.NewReader(tt.input), tt.profile); !errors.Is(err, ErrInvalidStatement) {
+				t.Errorf("error = %v, want %v", err, ErrInvalidStatement)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package statements
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock-api/internal/api"
+)
+
+// ofxTag matches an opening or closing tag and the value that follows it.
+// OFX 1.x is SGML and leaves leaf elements unclosed, so values run to the
+// next tag or line break; OFX 2.x XML reads the same way.
+var ofxTag = regexp.MustCompile(`<(/?[A-Za-z0-9.]+)>([^<\r\n]*)`)
+
+// parseOFX reads the STMTTRN records of an OFX or QFX download. Account,
+// bank and currency come from the enclosing statement; the account is only
+// read from BANKACCTFROM or CCACCTFROM, never from the payee account of a
+// transfer inside a STMTTRN.
+func parseOFX(r io.Reader) (*Statement, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidStatement, err)
+	}
+
+	matches := ofxTag.FindAllStringSubmatch(string(data), -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%w: no OFX elements found", ErrInvalidStatement)
+	}
+
+	st := &Statement{Transactions: []api.Transaction{}}
+	var account, bank, currency string
+	var fields map[string]string
+	inAccount := false
+	n := 0
+
+	flush := func() {
+		if fields == nil {
+			return
+		}
+		n++
+		t, err := ofxTransaction(fields)
+		if err != nil {
+			st.Errors = append(st.Errors, api.RowError{Row: n, Error: err.Error()})
+		} else {
+			t.Row = n
+			t.ExternalID = fields["FITID"]
+			t.Account, t.Bank = account, bank
+			if t.Currency == "" {
+				t.Currency = currency
+			}
+			st.Transactions = append(st.Transactions, *t)
+		}
+		fields = nil
+	}
+
+	for _, m := range matches {
+		tag := strings.ToUpper(m[1])
+		value := html.UnescapeString(strings.TrimSpace(m[2]))
+		switch tag {
+		case "STMTTRN":
+			flush()
+			fields = map[string]string{}
+		case "/STMTTRN", "/BANKTRANLIST":
+			flush()
+		case "BANKACCTFROM", "CCACCTFROM":
+			inAccount = fields == nil
+		case "/BANKACCTFROM", "/CCACCTFROM":
+			inAccount = false
+		case "CURDEF":
+			currency = value
+		case "ACCTID":
+			if inAccount {
+				account = value
+			} else if fields != nil && value != "" {
+				fields[tag] = value
+			}
+		case "ORG":
+			bank = value
+		default:
+			if fields != nil && value != "" && !strings.HasPrefix(tag, "/") {
+				fields[tag] = value
+			}
+		}
+	}
+	flush()
+
+	if n == 0 {
+		return nil, fmt.Errorf("%w: no transactions found", ErrInvalidStatement)
+	}
+	return st, nil
+}
+
+// ofxTransaction builds a transaction from one STMTTRN record's fields
+func ofxTransaction(fields map[string]string) (*api.Transaction, error) {
+	posted := fields["DTPOSTED"]
+	if len(posted) < 8 {
+		return nil, fmt.Errorf("missing or invalid DTPOSTED %q", posted)
+	}
+	// Dates are YYYYMMDD optionally followed by a time and zone
+	date, err := time.Parse("20060102", posted[:8])
+	if err != nil {
+		return nil, fmt.Errorf("invalid DTPOSTED %q", posted)
+	}
+
+	rawAmount := fields["TRNAMT"]
+	if rawAmount == "" {
+		return nil, fmt.Errorf("missing TRNAMT")
+	}
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(rawAmount, ",", "."), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRNAMT %q", rawAmount)
+	}
+
+	description := fields["NAME"]
+	if memo := fields["MEMO"]; description == "" {
+		description = memo
+	} else if memo != "" && !strings.Contains(strings.ToLower(description), strings.ToLower(memo)) {
+		description += " " + memo
+	}
+	if description == "" {
+		description = fields["PAYEE"]
+	}
+
+	return &api.Transaction{
+		Date:        date,
+		Amount:      amount,
+		Currency:    fields["CURRENCY"],
+		Description: description,
+	}, nil
+}
@@ -0,0 +1,84 @@
+package statements
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const sgmlStatement = `OFXHEADER:100
+DATA:OFXSGML
+
+<OFX>
+<SIGNONMSGSRSV1><SONRS><FI><ORG>Acme Bank</ORG></FI></SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>USD
+<BANKACCTFROM>
+<BANKID>123456789
+<ACCTID>0001112222
+</BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20240103120000[-5:EST]
+<TRNAMT>-42.10
+<FITID>T1
+<NAME>GROCERY &amp; MORE
+<MEMO>STORE 12
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>XFER
+<DTPOSTED>20240104
+<TRNAMT>-100.00
+<FITID>T2
+<NAME>TRANSFER
+<BANKACCTTO>
+<BANKID>987654321
+<ACCTID>9998887777
+</BANKACCTTO>
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>2024
+<TRNAMT>5.00
+<FITID>T3
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+func TestParseOFX(t *testing.T) {
+	st, err := parseOFX(strings.NewReader(sgmlStatement))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(st.Transactions) != 2 {
+		t.Fatalf("got %d transactions, want 2", len(st.Transactions))
+	}
+
+	first := st.Transactions[0]
+	if !first.Date.Equal(day("2024-01-03")) || first.Amount != -42.1 || first.Description != "GROCERY & MORE STORE 12" {
+		t.Errorf("first = %+v, want the grocery debit on 2024-01-03", first)
+	}
+	if first.ExternalID != "T1" || first.Account != "0001112222" || first.Bank != "Acme Bank" || first.Currency != "USD" {
+		t.Errorf("first = %+v, want FITID T1 in account 0001112222 at Acme Bank in USD", first)
+	}
+
+	// The transfer's destination account must not replace the statement's
+	if transfer := st.Transactions[1]; transfer.Account != "0001112222" || transfer.ExternalID != "T2" {
+		t.Errorf("transfer = %+v, want it kept in account 0001112222", transfer)
+	}
+
+	if len(st.Errors) != 1 || st.Errors[0].Row != 3 {
+		t.Errorf("errors = %+v, want the third transaction's bad date", st.Errors)
+	}
+}
+
+func TestParseOFXNeedsTransactions(t *testing.T) {
+	for _, input := range []string{"not ofx at all", "<OFX><BANKTRANLIST></BANKTRANLIST></OFX>"} {
+		if _, err := parseOFX(strings.NewReader(input)); !errors.Is(err, ErrInvalidStatement) {
+			t.Errorf("parseOFX(%q) error = %v, want %v", input, err, ErrInvalidStatement)
+		}
+	}
+}
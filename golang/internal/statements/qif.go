@@ -0,0 +1,128 @@
+package statements
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"stock-api/internal/api"
+)
+
+var qifDateLayouts = []string{"1/2/2006", "1/2/06", "2006-01-02", "2.1.2006"}
+
+// parseQIF reads the bank, cash and card records of a QIF download. Records
+// inside an !Account block name the account of the records that follow it.
+func parseQIF(r io.Reader, dateFormat string) (*Statement, error) {
+	st := &Statement{Transactions: []api.Transaction{}}
+	scanner := bufio.NewScanner(r)
+
+	var account string
+	inAccount, inOther := false, false
+	fields := map[string]string{}
+	start, line, records := 0, 0, 0
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "!") {
+			header := strings.ToLower(strings.TrimSpace(text))
+			switch {
+			case header == "!account":
+				inAccount, inOther = true, false
+			case strings.HasPrefix(header, "!type:"):
+				kind := strings.TrimPrefix(header, "!type:")
+				inAccount = false
+				// Investment, category and class lists aren't bank transactions
+				inOther = kind != "bank" && kind != "cash" && kind != "ccard" && kind != "oth a" && kind != "oth l"
+			}
+			continue
+		}
+
+		if text == "^" {
+			if inAccount {
+				account = fields["N"]
+			} else if !inOther && len(fields) > 0 {
+				records++
+				t, err := qifTransaction(fields, dateFormat)
+				if err != nil {
+					st.Errors = append(st.Errors, api.RowError{Row: start, Error: err.Error()})
+				} else {
+					t.Row = start
+					t.Account = account
+					st.Transactions = append(st.Transactions, *t)
+				}
+			}
+			fields = map[string]string{}
+			start = 0
+			continue
+		}
+
+		if start == 0 {
+			start = line
+		}
+		code, value := text[:1], strings.TrimSpace(text[1:])
+		// Split lines (S, E, $) repeat per split; the first of each is enough
+		if _, seen := fields[code]; !seen {
+			fields[code] = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidStatement, err)
+	}
+	if records == 0 {
+		return nil, fmt.Errorf("%w: no transactions found", ErrInvalidStatement)
+	}
+	return st, nil
+}
+
+// qifTransaction builds a transaction from one record's fields
+func qifTransaction(fields map[string]string, dateFormat string) (*api.Transaction, error) {
+	rawDate := fields["D"]
+	if rawDate == "" {
+		return nil, fmt.Errorf("missing date")
+	}
+	// Quicken writes dates like 12/31'24 and pads with spaces
+	normalised := strings.ReplaceAll(strings.ReplaceAll(rawDate, "'", "/"), " ", "")
+	date, err := parseDate(normalised, dateFormat, qifDateLayouts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q", rawDate)
+	}
+
+	rawAmount := fields["T"]
+	if rawAmount == "" {
+		rawAmount = fields["U"]
+	}
+	if rawAmount == "" {
+		return nil, fmt.Errorf("missing amount")
+	}
+	amount, err := parseAmount(rawAmount, false)
+	if err != nil {
+		return nil, err
+	}
+
+	description := fields["P"]
+	if description == "" {
+		description = fields["M"]
+	}
+
+	// Transfers are written as [Account]; they aren't spending categories
+	category := fields["L"]
+	if strings.HasPrefix(category, "[") {
+		category = ""
+	}
+
+	return &api.Transaction{
+		Date:        date,
+		Amount:      amount,
+		Description: description,
+		Category:    category,
+	}, nil
+}
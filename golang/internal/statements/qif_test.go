@@ -0,0 +1,76 @@
+package statements
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseQIF(t *testing.T) {
+	input := "!Account\n" +
+		"NEveryday\n" +
+		"TBank\n" +
+		"^\n" +
+		"!Type:Bank\n" +
+		"D12/31'23\n" +
+		"T-1,250.00\n" +
+		"PRENT\n" +
+		"LHousing\n" +
+		"^\n" +
+		"D 1/ 2'24\n" +
+		"U-20.00\n" +
+		"MMoved to savings\n" +
+		"L[Savings]\n" +
+		"SGroceries\n" +
+		"$-10.00\n" +
+		"SHousehold\n" +
+		"$-10.00\n" +
+		"^\n" +
+		"Dnever\n" +
+		"T1.00\n" +
+		"^\n" +
+		"!Type:Invst\n" +
+		"D1/3/24\n" +
+		"NBuy\n" +
+		"T100.00\n" +
+		"^\n"
+
+	st, err := parseQIF(strings.NewReader(input), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(st.Transactions) != 2 {
+		t.Fatalf("got %d transactions, want 2", len(st.Transactions))
+	}
+
+	rent, transfer := st.Transactions[0], st.Transactions[1]
+	if !rent.Date.Equal(day("2023-12-31")) || rent.Amount != -1250 || rent.Description != "RENT" || rent.Category != "Housing" {
+		t.Errorf("rent = %+v, want -1250 RENT in Housing on 2023-12-31", rent)
+	}
+	if rent.Account != "Everyday" || rent.Row != 6 {
+		t.Errorf("rent = %+v, want account Everyday starting on line 6", rent)
+	}
+	if !transfer.Date.Equal(day("2024-01-02")) || transfer.Amount != -20 || transfer.Description != "Moved to savings" || transfer.Category != "" {
+		t.Errorf("transfer = %+v, want -20 with no category on 2024-01-02", transfer)
+	}
+
+	if len(st.Errors) != 1 || st.Errors[0].Row != 20 {
+		t.Errorf("errors = %+v, want the bad date on line 20", st.Errors)
+	}
+}
+
+func TestParseQIFDateFormat(t *testing.T) {
+	input := "!Type:Bank\nD31/12/2023\nT-5.00\nPCAFE\n^\n"
+
+	st, err := parseQIF(strings.NewReader(input), "02/01/2006")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(st.Transactions) != 1 || !st.Transactions[0].Date.Equal(day("2023-12-31")) {
+		t.Errorf("got %+v, want one transaction on 2023-12-31", st.Transactions)
+	}
+
+	if _, err := parseQIF(strings.NewReader("!Type:Bank\n"), ""); !errors.Is(err, ErrInvalidStatement) {
+		t.Errorf("empty QIF error = %v, want %v", err, ErrInvalidStatement)
+	}
+}
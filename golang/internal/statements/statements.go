@@ -0,0 +1,162 @@
+// Package statements parses bank statement downloads (CSV, OFX/QFX and QIF)
+// into transactions.
+package statements
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock-api/internal/api"
+)
+
+// ErrInvalidStatement is wrapped by every error caused by an unreadable statement
+var ErrInvalidStatement = errors.New("invalid statement")
+
+// Statement formats
+const (
+	FormatCSV = "csv"
+	FormatOFX = "ofx"
+	FormatQIF = "qif"
+)
+
+// Options fill in what a statement doesn't say about itself
+type Options struct {
+	// Account, Bank and Currency apply to transactions that don't carry their own
+	Account  string
+	Bank     string
+	Currency string
+	// DateFormat is a Go time layout for QIF dates; empty tries the usual US formats
+	DateFormat string
+	// Profile describes the CSV layout
+	Profile CSVProfile
+}
+
+// Statement is a parsed statement. Each transaction's Row is the line it
+// starts on (CSV, QIF) or its position in the file (OFX).
+type Statement struct {
+	Format       string
+	Transactions []api.Transaction
+	// Errors are the rows that couldn't be read
+	Errors []api.RowError
+}
+
+// DetectFormat resolves a format hint, falling back to the file extension
+func DetectFormat(hint, fileName string) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(hint))
+	if format == "" {
+		format = strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), "."))
+	}
+	switch format {
+	case "csv", "txt":
+		return FormatCSV, nil
+	case "ofx", "qfx":
+		return FormatOFX, nil
+	case "qif":
+		return FormatQIF, nil
+	case "":
+		return "", fmt.Errorf("%w: format is required (csv, ofx, qfx or qif)", ErrInvalidStatement)
+	}
+	return "", fmt.Errorf("%w: unknown format %q (use csv, ofx, qfx or qif)", ErrInvalidStatement, format)
+}
+
+// Parse reads a statement in the given format
+func Parse(format string, r io.Reader, opts Options) (*Statement, error) {
+	var (
+		st  *Statement
+		err error
+	)
+	switch format {
+	case FormatCSV:
+		st, err = parseCSV(r, opts.Profile)
+	case FormatOFX:
+		st, err = parseOFX(r)
+	case FormatQIF:
+		st, err = parseQIF(r, opts.DateFormat)
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidStatement, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	st.Format = format
+	for i := range st.Transactions {
+		t := &st.Transactions[i]
+		if t.Account == "" {
+			t.Account = opts.Account
+		}
+		if t.Bank == "" {
+			t.Bank = opts.Bank
+		}
+		if t.Currency == "" {
+			t.Currency = opts.Currency
+		}
+	}
+	return st, nil
+}
+
+// ValidateDateFormat checks that a non-empty layout is a full Go date layout
+func ValidateDateFormat(layout string) error {
+	if layout == "" {
+		return nil
+	}
+	// A usable layout round-trips a reference date
+	ref := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	if parsed, err := time.Parse(layout, ref.Format(layout)); err != nil || !parsed.Equal(ref) {
+		return fmt.Errorf("date_format %q is not a Go date layout such as 2006-01-02", layout)
+	}
+	return nil
+}
+
+// parseDate parses s with layout, or with each of the fallbacks when layout is empty
+func parseDate(s, layout string, fallbacks []string) (time.Time, error) {
+	if layout != "" {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q (expected format %s)", s, layout)
+		}
+		return t, nil
+	}
+	for _, l := range fallbacks {
+		if t, err := time.Parse(l, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// parseAmount accepts currency symbols, thousands separators, trailing minus
+// signs and accounting-style negatives such as "(1,234.50)". With
+// decimalComma the roles of "." and "," are swapped.
+func parseAmount(s string, decimalComma bool) (float64, error) {
+	raw := strings.TrimSpace(s)
+	negative := strings.HasPrefix(raw, "(") && strings.HasSuffix(raw, ")") || strings.HasSuffix(raw, "-")
+
+	cleaned := strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '.' || r == ',' || r == '-' {
+			return r
+		}
+		return -1
+	}, raw)
+	cleaned = strings.TrimSuffix(cleaned, "-")
+	if decimalComma {
+		cleaned = strings.ReplaceAll(cleaned, ".", "")
+		cleaned = strings.ReplaceAll(cleaned, ",", ".")
+	} else {
+		cleaned = strings.ReplaceAll(cleaned, ",", "")
+	}
+
+	v, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if negative && v > 0 {
+		v = -v
+	}
+	return v, nil
+}
@@ -0,0 +1,103 @@
+package statements
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		hint, fileName string
+		want           string
+	}{
+		{"", "export.CSV", FormatCSV},
+		{"", "download.qfx", FormatOFX},
+		{"QIF", "statement.csv", FormatQIF},
+		{"txt", "", FormatCSV},
+	}
+	for _, tt := range tests {
+		got, err := DetectFormat(tt.hint, tt.fileName)
+		if err != nil || got != tt.want {
+			t.Errorf("DetectFormat(%q, %q) = %q, %v, want %q", tt.hint, tt.fileName, got, err, tt.want)
+		}
+	}
+
+	for _, fileName := range []string{"statement", "statement.pdf"} {
+		if _, err := DetectFormat("", fileName); !errors.Is(err, ErrInvalidStatement) {
+			t.Errorf("DetectFormat(%q) error = %v, want %v", fileName, err, ErrInvalidStatement)
+		}
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in           string
+		decimalComma bool
+		want         float64
+	}{
+		{"12.50", false, 12.5},
+		{"-12.50", false, -12.5},
+		{"$1,234.56", false, 1234.56},
+		{"(1,234.56)", false, -1234.56},
+		{"45.00-", false, -45},
+		{"1.234,56", true, 1234.56},
+		{"-0,99 €", true, -0.99},
+	}
+	for _, tt := range tests {
+		got, err := parseAmount(tt.in, tt.decimalComma)
+		if err != nil || got != tt.want {
+			t.Errorf("parseAmount(%q, %v) = %v, %v, want %v", tt.in, tt.decimalComma, got, err, tt.want)
+		}
+	}
+	if _, err := parseAmount("n/a", false); err == nil {
+		t.Error("parseAmount accepted n/a")
+	}
+}
+
+func TestValidateDateFormat(t *testing.T) {
+	for _, layout := range []string{"", "2006-01-02", "02/01/2006", "Jan 2, 2006"} {
+		if err := ValidateDateFormat(layout); err != nil {
+			t.Errorf("ValidateDateFormat(%q) = %v", layout, err)
+		}
+	}
+	for _, layout := range []string{"YYYY-MM-DD", "2006-01", "01/02"} {
+		if err := ValidateDateFormat(layout); err == nil {
+			t.Errorf("ValidateDateFormat(%q) accepted an incomplete layout", layout)
+		}
+	}
+}
+
+func TestParseFillsInOptions(t *testing.T) {
+	input := "Date,Description,Amount,Account\n" +
+		"2024-01-03,COFFEE,-3.50,\n" +
+		"2024-01-04,REFUND,3.50,Savings\n"
+
+	st, err := Parse(FormatCSV, strings.NewReader(input), Options{Account: "Checking", Bank: "Acme", Currency: "USD"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if st.Format != FormatCSV || len(st.Transactions) != 2 {
+		t.Fatalf("got %+v, want two csv transactions", st)
+	}
+	first, second := st.Transactions[0], st.Transactions[1]
+	if first.Account != "Checking" || first.Bank != "Acme" || first.Currency != "USD" {
+		t.Errorf("first = %+v, want the account, bank and currency from the options", first)
+	}
+	if second.Account != "Savings" {
+		t.Errorf("second account = %q, want its own Savings", second.Account)
+	}
+
+	if _, err := Parse("pdf", strings.NewReader(input), Options{}); !errors.Is(err, ErrInvalidStatement) {
+		t.Errorf("unknown format error = %v, want %v", err, ErrInvalidStatement)
+	}
+}
@@ -18,26 +18,28 @@ func DerefStr(s *string) string {
 	return *s
 }
 
-func Calculate5YRevenueGrowth(income *api.IncomeStatement) float64 {
+// Calculate5YRevenueGrowth returns the revenue growth in percent over five
+// years, or nil when there aren't six years of usable revenue
+func Calculate5YRevenueGrowth(income *api.IncomeStatement) *float64 {
 	if len(income.AnnualReports) < 6 {
-		return 0
+		return nil
 	}
-	currRev := *api.ParseFloat(income.AnnualReports[0].TotalRevenue)
-	pastRev := *api.ParseFloat(income.AnnualReports[5].TotalRevenue)
-	if pastRev == 0 {
-		return 0
+	currRev := api.ParseFloat(income.AnnualReports[0].TotalRevenue)
+	pastRev := api.ParseFloat(income.AnnualReports[5].TotalRevenue)
+	if currRev == nil || pastRev == nil || *pastRev == 0 {
+		return nil
 	}
-	return ((currRev - pastRev) / pastRev) * 100
+	return FloatPtr(((*currRev - *pastRev) / *pastRev) * 100)
 }
 
 func CalculateHistoricalROE(income *api.IncomeStatement, balance *api.BalanceSheet) map[string]float64 {
 	roe := make(map[string]float64)
 	for i := 0; i < 5 && i < len(income.AnnualReports) && i < len(balance.AnnualReports); i++ {
 		year := income.AnnualReports[i].FiscalDateEnding[:4]
-		netInc := *api.ParseFloat(income.AnnualReports[i].NetIncome)
-		equity := *api.ParseFloat(balance.AnnualReports[i].TotalShareholderEquity)
-		if equity != 0 {
-			roe[year] = (netInc / equity) * 100
+		netInc := api.ParseFloat(income.AnnualReports[i].NetIncome)
+		equity := api.ParseFloat(balance.AnnualReports[i].TotalShareholderEquity)
+		if netInc != nil && equity != nil && *equity != 0 {
+			roe[year] = (*netInc / *equity) * 100
 		}
 	}
 	return roe
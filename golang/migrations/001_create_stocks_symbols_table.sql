@@ -6,4 +6,4 @@ CREATE TABLE IF NOT EXISTS stock_symbols (
 );
 
 
-CREATE INDEX IF NOT EXISTS idx_stocks_symbols_batch_id ON stock_symbols(symbol);
+CREATE INDEX IF NOT EXISTS idx_stocks_symbols_batch_id ON stock_symbols(batch_id);
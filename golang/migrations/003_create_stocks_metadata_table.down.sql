@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS stocks_metadata;
+DROP FUNCTION IF EXISTS update_metadata_updated_at_column();
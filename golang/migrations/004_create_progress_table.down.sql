@@ -0,0 +1 @@
+DROP TABLE IF EXISTS airflow_progress_tracker;
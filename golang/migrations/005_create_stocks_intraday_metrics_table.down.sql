@@ -0,0 +1 @@
+DROP TABLE IF EXISTS stocks_intraday_indicators;
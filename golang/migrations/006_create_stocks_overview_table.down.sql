@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS stock_balance_sheets;
+DROP TABLE IF EXISTS stock_income_statements;
+DROP TABLE IF EXISTS stock_scorecards;
+DROP TABLE IF EXISTS stock_overviews;
@@ -4,4 +4,4 @@ CREATE TABLE users (
     password TEXT
 );
 
-CREATE INDEX IF NOT EXISTS idx_users ON users(idx);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users ON users(username);
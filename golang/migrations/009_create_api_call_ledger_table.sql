@@ -0,0 +1,12 @@
+-- Create api_call_ledger table recording every outbound call to an external provider
+CREATE TABLE IF NOT EXISTS api_call_ledger (
+    id BIGSERIAL PRIMARY KEY,
+    provider VARCHAR(32) NOT NULL,
+    key_id VARCHAR(16) NOT NULL,
+    called_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_call_ledger_provider_key_called_at ON api_call_ledger(provider, key_id, called_at);
+
+COMMENT ON TABLE api_call_ledger IS 'Outbound provider calls used to enforce per-minute and per-day quotas';
+COMMENT ON COLUMN api_call_ledger.key_id IS 'Truncated SHA-256 of the API key, so keys are never stored';
@@ -0,0 +1,26 @@
+-- Create stocks_daily table for storing daily OHLCV history
+CREATE TABLE IF NOT EXISTS stocks_daily (
+    id SERIAL PRIMARY KEY,
+    symbol VARCHAR(10) NOT NULL REFERENCES stock_symbols(symbol) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    open DECIMAL(10,4) NOT NULL,
+    high DECIMAL(10,4) NOT NULL,
+    low DECIMAL(10,4) NOT NULL,
+    close DECIMAL(10,4) NOT NULL,
+    volume BIGINT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    -- Ensure unique combination of symbol and date
+    UNIQUE(symbol, date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stocks_daily_date ON stocks_daily(date);
+
+CREATE TRIGGER update_stocks_daily_updated_at
+    BEFORE UPDATE ON stocks_daily
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON TABLE stocks_daily IS 'Split-adjusted daily bars for long-horizon history';
+COMMENT ON COLUMN stocks_daily.date IS 'Trading day (US/Eastern)';
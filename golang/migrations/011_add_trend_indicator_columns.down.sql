@@ -0,0 +1,10 @@
+ALTER TABLE stocks_intraday_indicators
+    DROP COLUMN IF EXISTS sma,
+    DROP COLUMN IF EXISTS ema,
+    DROP COLUMN IF EXISTS macd,
+    DROP COLUMN IF EXISTS macd_signal,
+    DROP COLUMN IF EXISTS macd_hist,
+    DROP COLUMN IF EXISTS bb_upper,
+    DROP COLUMN IF EXISTS bb_middle,
+    DROP COLUMN IF EXISTS bb_lower,
+    DROP COLUMN IF EXISTS vwap;
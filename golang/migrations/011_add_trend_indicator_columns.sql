@@ -0,0 +1,15 @@
+-- Trend and volume-weighted indicators computed by the Go indicator engine
+ALTER TABLE stocks_intraday_indicators
+    ADD COLUMN IF NOT EXISTS sma FLOAT,
+    ADD COLUMN IF NOT EXISTS ema FLOAT,
+    ADD COLUMN IF NOT EXISTS macd FLOAT,
+    ADD COLUMN IF NOT EXISTS macd_signal FLOAT,
+    ADD COLUMN IF NOT EXISTS macd_hist FLOAT,
+    ADD COLUMN IF NOT EXISTS bb_upper FLOAT,
+    ADD COLUMN IF NOT EXISTS bb_middle FLOAT,
+    ADD COLUMN IF NOT EXISTS bb_lower FLOAT,
+    ADD COLUMN IF NOT EXISTS vwap FLOAT;
+
+COMMENT ON COLUMN stocks_intraday_indicators.sma IS '20-bar simple moving average of close';
+COMMENT ON COLUMN stocks_intraday_indicators.ema IS '20-bar exponential moving average of close';
+COMMENT ON COLUMN stocks_intraday_indicators.vwap IS 'Session VWAP, reset each US/Eastern trading day';
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_stock_scorecards_composite_score;
+DROP INDEX IF EXISTS idx_stock_scorecards_industry;
+
+ALTER TABLE stock_scorecards
+    DROP COLUMN IF EXISTS composite_score,
+    DROP COLUMN IF EXISTS sub_scores,
+    DROP COLUMN IF EXISTS industry;
@@ -0,0 +1,11 @@
+-- Composite score and per-factor breakdown produced by the scoring model
+ALTER TABLE stock_scorecards
+    ADD COLUMN IF NOT EXISTS composite_score NUMERIC(6, 2),
+    ADD COLUMN IF NOT EXISTS sub_scores JSONB,
+    ADD COLUMN IF NOT EXISTS industry VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_stock_scorecards_industry ON stock_scorecards(industry);
+CREATE INDEX IF NOT EXISTS idx_stock_scorecards_composite_score ON stock_scorecards(composite_score DESC);
+
+COMMENT ON COLUMN stock_scorecards.composite_score IS 'Weighted 0-100 score across all factors';
+COMMENT ON COLUMN stock_scorecards.sub_scores IS 'Per-factor value, 0-100 score, weight and peer group';
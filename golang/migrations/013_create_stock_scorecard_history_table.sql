@@ -0,0 +1,24 @@
+-- Append-only snapshots of stock_scorecards, one per symbol per scorecard run
+CREATE TABLE IF NOT EXISTS stock_scorecard_history (
+    id BIGSERIAL PRIMARY KEY,
+    symbol VARCHAR(10) NOT NULL,
+    recorded_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    company_name VARCHAR(128),
+    industry VARCHAR(255),
+    pe_ratio NUMERIC(10, 4),
+    peg_ratio NUMERIC(10, 4),
+    price_to_book NUMERIC(10, 4),
+    roe_ttm NUMERIC(10, 4),
+    revenue_5y_growth NUMERIC(10, 4),
+    operating_margin NUMERIC(10, 4),
+    profit_margin NUMERIC(10, 4),
+    dividend_yield NUMERIC(10, 4),
+    beta NUMERIC(10, 4),
+    composite_score NUMERIC(6, 2),
+    sub_scores JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_scorecard_history_symbol_recorded_at
+    ON stock_scorecard_history(symbol, recorded_at);
+
+COMMENT ON TABLE stock_scorecard_history IS 'Scorecard snapshots written by every scorecard calculation; never updated';
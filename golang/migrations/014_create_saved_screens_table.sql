@@ -0,0 +1,15 @@
+-- Named screener queries saved by each user
+CREATE TABLE IF NOT EXISTS saved_screens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(128) NOT NULL,
+    query JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, name)
+);
+
+CREATE TRIGGER update_saved_screens_updated_at
+    BEFORE UPDATE ON saved_screens
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,45 @@
+-- Brokerage accounts owned by a user
+CREATE TABLE IF NOT EXISTS portfolio_accounts (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(128) NOT NULL,
+    broker VARCHAR(128),
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, name)
+);
+
+-- Trade ledger; holdings are derived by replaying it in trade_date order
+CREATE TABLE IF NOT EXISTS portfolio_trades (
+    id SERIAL PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES portfolio_accounts(id) ON DELETE CASCADE,
+    symbol VARCHAR(10) NOT NULL,
+    trade_type VARCHAR(16) NOT NULL CHECK (trade_type IN ('buy', 'sell', 'dividend', 'split')),
+    trade_date TIMESTAMP NOT NULL,
+    quantity NUMERIC(20, 6) NOT NULL DEFAULT 0,
+    price NUMERIC(20, 6) NOT NULL DEFAULT 0,
+    fees NUMERIC(14, 4) NOT NULL DEFAULT 0,
+    amount NUMERIC(14, 4) NOT NULL DEFAULT 0,
+    split_ratio NUMERIC(12, 6) NOT NULL DEFAULT 0,
+    notes TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_portfolio_trades_account_symbol_date
+    ON portfolio_trades(account_id, symbol, trade_date);
+
+CREATE TRIGGER update_portfolio_accounts_updated_at
+    BEFORE UPDATE ON portfolio_accounts
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_portfolio_trades_updated_at
+    BEFORE UPDATE ON portfolio_trades
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON COLUMN portfolio_trades.quantity IS 'Shares bought or sold';
+COMMENT ON COLUMN portfolio_trades.amount IS 'Cash received for dividends';
+COMMENT ON COLUMN portfolio_trades.split_ratio IS 'New shares per old share, e.g. 4 for a 4-for-1 split';
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS portfolio_lot_selections;
+
+ALTER TABLE portfolio_trades DROP COLUMN IF EXISTS lot_method;
+ALTER TABLE portfolio_accounts DROP COLUMN IF EXISTS lot_method;
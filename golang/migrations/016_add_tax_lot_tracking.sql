@@ -0,0 +1,18 @@
+-- Lot relief method per account, optionally overridden per sell
+ALTER TABLE portfolio_accounts
+    ADD COLUMN IF NOT EXISTS lot_method VARCHAR(16) NOT NULL DEFAULT 'fifo'
+        CHECK (lot_method IN ('fifo', 'lifo', 'highest_cost', 'specific_id'));
+
+ALTER TABLE portfolio_trades
+    ADD COLUMN IF NOT EXISTS lot_method VARCHAR(16)
+        CHECK (lot_method IN ('fifo', 'lifo', 'highest_cost', 'specific_id'));
+
+-- Lots closed by specific-ID sells
+CREATE TABLE IF NOT EXISTS portfolio_lot_selections (
+    sell_trade_id INTEGER NOT NULL REFERENCES portfolio_trades(id) ON DELETE CASCADE,
+    buy_trade_id INTEGER NOT NULL REFERENCES portfolio_trades(id) ON DELETE CASCADE,
+    quantity NUMERIC(20, 6) NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (sell_trade_id, buy_trade_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_portfolio_lot_selections_buy ON portfolio_lot_selections(buy_trade_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS alert_events;
+DROP TABLE IF EXISTS alert_rule_states;
+DROP TABLE IF EXISTS alert_rules;
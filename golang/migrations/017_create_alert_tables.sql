@@ -0,0 +1,65 @@
+-- Alert rules owned by a user; a NULL symbol applies the rule to every symbol
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(128) NOT NULL,
+    symbol VARCHAR(10),
+    metric VARCHAR(32) NOT NULL,
+    period INTEGER NOT NULL DEFAULT 0,
+    operator VARCHAR(16) NOT NULL CHECK (operator IN ('>', '>=', '<', '<=', 'crosses_above', 'crosses_below')),
+    threshold NUMERIC(20, 6) NOT NULL,
+    cooldown_minutes INTEGER NOT NULL DEFAULT 0 CHECK (cooldown_minutes >= 0),
+    channels TEXT[] NOT NULL DEFAULT '{in_app}',
+    webhook_url TEXT,
+    email VARCHAR(255),
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_rules_user ON alert_rules(user_id);
+CREATE INDEX IF NOT EXISTS idx_alert_rules_symbol ON alert_rules(symbol) WHERE enabled;
+
+-- Where each rule is in its firing cycle for each symbol it has seen
+CREATE TABLE IF NOT EXISTS alert_rule_states (
+    rule_id INTEGER NOT NULL REFERENCES alert_rules(id) ON DELETE CASCADE,
+    symbol VARCHAR(10) NOT NULL,
+    state VARCHAR(16) NOT NULL DEFAULT 'armed' CHECK (state IN ('armed', 'fired', 'cooldown')),
+    last_value NUMERIC(24, 6),
+    last_bar_at TIMESTAMP,
+    last_fired_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (rule_id, symbol)
+);
+
+-- Fired alerts; also the in-app feed
+CREATE TABLE IF NOT EXISTS alert_events (
+    id SERIAL PRIMARY KEY,
+    rule_id INTEGER NOT NULL REFERENCES alert_rules(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    symbol VARCHAR(10) NOT NULL,
+    value NUMERIC(24, 6) NOT NULL,
+    bar_date TIMESTAMP NOT NULL,
+    message TEXT NOT NULL,
+    delivered TEXT[] NOT NULL DEFAULT '{}',
+    delivery_error TEXT,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(rule_id, symbol, bar_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_events_user_created ON alert_events(user_id, created_at DESC);
+
+CREATE TRIGGER update_alert_rules_updated_at
+    BEFORE UPDATE ON alert_rules
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_alert_rule_states_updated_at
+    BEFORE UPDATE ON alert_rule_states
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON COLUMN alert_rules.period IS 'Indicator period; 0 uses the metric default';
+COMMENT ON COLUMN alert_rules.channels IS 'Delivery channels: in_app, webhook, email';
+COMMENT ON COLUMN alert_events.delivered IS 'Channels the alert reached';
@@ -0,0 +1,4 @@
+ALTER TABLE alert_rules DROP COLUMN IF EXISTS watchlist_id;
+
+DROP TABLE IF EXISTS watchlist_symbols;
+DROP TABLE IF EXISTS watchlists;
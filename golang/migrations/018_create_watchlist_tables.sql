@@ -0,0 +1,32 @@
+-- Named groups of symbols owned by a user
+CREATE TABLE IF NOT EXISTS watchlists (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(128) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, name)
+);
+
+-- Watchlist members in display order
+CREATE TABLE IF NOT EXISTS watchlist_symbols (
+    watchlist_id INTEGER NOT NULL REFERENCES watchlists(id) ON DELETE CASCADE,
+    symbol VARCHAR(10) NOT NULL,
+    position INTEGER NOT NULL,
+    added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (watchlist_id, symbol)
+);
+
+CREATE INDEX IF NOT EXISTS idx_watchlist_symbols_symbol ON watchlist_symbols(symbol);
+
+CREATE TRIGGER update_watchlists_updated_at
+    BEFORE UPDATE ON watchlists
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Alert rules without a symbol can be limited to a watchlist's members
+ALTER TABLE alert_rules
+    ADD COLUMN IF NOT EXISTS watchlist_id INTEGER REFERENCES watchlists(id) ON DELETE SET NULL;
+
+COMMENT ON COLUMN watchlist_symbols.position IS 'Display order within the watchlist, ascending';
+COMMENT ON COLUMN alert_rules.watchlist_id IS 'Limits a rule without a symbol to the watchlist''s members; rules are disabled when their watchlist is deleted';
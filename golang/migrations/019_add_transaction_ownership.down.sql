@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_personal_transactions_user_date;
+DROP INDEX IF EXISTS idx_personal_transactions_user_fingerprint;
+
+ALTER TABLE personal_transactions
+    DROP COLUMN IF EXISTS fingerprint,
+    DROP COLUMN IF EXISTS import_run_id,
+    DROP COLUMN IF EXISTS source_row,
+    DROP COLUMN IF EXISTS source_id,
+    DROP COLUMN IF EXISTS user_id;
+
+DROP TABLE IF EXISTS transaction_import_runs;
+DROP TABLE IF EXISTS transaction_sources;
@@ -0,0 +1,51 @@
+-- Where a user's bank transactions come from; Rows tables for now
+CREATE TABLE IF NOT EXISTS transaction_sources (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(128) NOT NULL,
+    kind VARCHAR(16) NOT NULL DEFAULT 'rows' CHECK (kind IN ('rows')),
+    spreadsheet_id VARCHAR(128) NOT NULL,
+    table_id VARCHAR(128) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, name)
+);
+
+-- One attempt to import a source, with what happened to its rows
+CREATE TABLE IF NOT EXISTS transaction_import_runs (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source_id INTEGER REFERENCES transaction_sources(id) ON DELETE SET NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'completed', 'failed')),
+    new_rows INTEGER NOT NULL DEFAULT 0,
+    duplicate_rows INTEGER NOT NULL DEFAULT 0,
+    rejected_rows INTEGER NOT NULL DEFAULT 0,
+    row_errors JSONB NOT NULL DEFAULT '[]',
+    error TEXT,
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_import_runs_user ON transaction_import_runs(user_id, started_at DESC);
+
+-- Existing rows predate ownership and stay unowned, so no user sees them
+ALTER TABLE personal_transactions
+    ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS source_id INTEGER REFERENCES transaction_sources(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS source_row INTEGER,
+    ADD COLUMN IF NOT EXISTS import_run_id INTEGER REFERENCES transaction_import_runs(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS fingerprint VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_personal_transactions_user_fingerprint
+    ON personal_transactions(user_id, fingerprint);
+CREATE INDEX IF NOT EXISTS idx_personal_transactions_user_date
+    ON personal_transactions(user_id, date);
+
+CREATE TRIGGER update_transaction_sources_updated_at
+    BEFORE UPDATE ON transaction_sources
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON COLUMN personal_transactions.fingerprint IS 'SHA-256 of source, source row, date, amount, description and account';
+COMMENT ON COLUMN personal_transactions.source_row IS 'Row number within the source the transaction was read from';
+COMMENT ON COLUMN transaction_import_runs.row_errors IS 'Rejected rows as [{"row": n, "error": "..."}]';
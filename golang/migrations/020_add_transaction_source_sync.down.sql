@@ -0,0 +1,8 @@
+ALTER TABLE transaction_import_runs
+    DROP COLUMN IF EXISTS to_row,
+    DROP COLUMN IF EXISTS from_row;
+
+ALTER TABLE transaction_sources
+    DROP COLUMN IF EXISTS last_synced_at,
+    DROP COLUMN IF EXISTS last_row,
+    DROP COLUMN IF EXISTS mapping;
@@ -0,0 +1,13 @@
+-- Per-source header mapping and incremental sync position
+ALTER TABLE transaction_sources
+    ADD COLUMN IF NOT EXISTS mapping JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS last_row INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_synced_at TIMESTAMP;
+
+-- The source rows each import read
+ALTER TABLE transaction_import_runs
+    ADD COLUMN IF NOT EXISTS from_row INTEGER,
+    ADD COLUMN IF NOT EXISTS to_row INTEGER;
+
+COMMENT ON COLUMN transaction_sources.mapping IS 'Header name for each transaction field, e.g. {"date": "Booked", "date_format": "02/01/2006"}';
+COMMENT ON COLUMN transaction_sources.last_row IS 'Last source row imported; the next sync starts below it';
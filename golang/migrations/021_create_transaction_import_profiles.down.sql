@@ -0,0 +1,5 @@
+ALTER TABLE transaction_import_runs
+    DROP COLUMN IF EXISTS format,
+    DROP COLUMN IF EXISTS file_name;
+
+DROP TABLE IF EXISTS transaction_import_profiles;
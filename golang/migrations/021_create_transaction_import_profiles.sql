@@ -0,0 +1,24 @@
+-- Per-bank CSV layouts used when importing statement files
+CREATE TABLE IF NOT EXISTS transaction_import_profiles (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(128) NOT NULL,
+    bank VARCHAR(128),
+    profile JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, name)
+);
+
+-- Statement file imports have no source
+ALTER TABLE transaction_import_runs
+    ADD COLUMN IF NOT EXISTS file_name VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS format VARCHAR(8);
+
+CREATE TRIGGER update_transaction_import_profiles_updated_at
+    BEFORE UPDATE ON transaction_import_profiles
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON COLUMN transaction_import_profiles.profile IS 'CSV layout: delimiter, skip_lines, column headers, date_format, decimal_comma, invert_sign';
+COMMENT ON COLUMN transaction_import_runs.format IS 'csv, ofx or qif for statement file imports; NULL for source syncs';
@@ -0,0 +1,6 @@
+ALTER TABLE personal_transactions
+    DROP COLUMN IF EXISTS category_rule_id,
+    DROP COLUMN IF EXISTS source_category,
+    DROP COLUMN IF EXISTS merchant;
+
+DROP TABLE IF EXISTS transaction_category_rules;
@@ -0,0 +1,40 @@
+-- User-defined rules that assign categories to bank transactions
+CREATE TABLE IF NOT EXISTS transaction_category_rules (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(128) NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 100,
+    category VARCHAR(128) NOT NULL,
+    pattern TEXT,
+    match_type VARCHAR(16) NOT NULL DEFAULT 'contains' CHECK (match_type IN ('contains', 'regex')),
+    min_amount NUMERIC(12,2),
+    max_amount NUMERIC(12,2),
+    direction VARCHAR(8) CHECK (direction IN ('debit', 'credit')),
+    account VARCHAR(128),
+    merchant VARCHAR(128),
+    override BOOLEAN NOT NULL DEFAULT FALSE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_category_rules_user ON transaction_category_rules(user_id, priority);
+
+-- Keep what the source said apart from what the rules decided
+ALTER TABLE personal_transactions
+    ADD COLUMN IF NOT EXISTS merchant TEXT,
+    ADD COLUMN IF NOT EXISTS source_category VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS category_rule_id INTEGER REFERENCES transaction_category_rules(id) ON DELETE SET NULL;
+
+UPDATE personal_transactions SET source_category = category WHERE source_category IS NULL;
+
+CREATE TRIGGER update_transaction_category_rules_updated_at
+    BEFORE UPDATE ON transaction_category_rules
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON COLUMN transaction_category_rules.priority IS 'Rules are tried in ascending priority; the first match wins';
+COMMENT ON COLUMN transaction_category_rules.min_amount IS 'Lower bound on the absolute amount';
+COMMENT ON COLUMN transaction_category_rules.override IS 'Whether the rule replaces a category provided by the source';
+COMMENT ON COLUMN personal_transactions.source_category IS 'Category as imported, before rules';
+COMMENT ON COLUMN personal_transactions.merchant IS 'Description with card-processor noise stripped';
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_api_call_ledger_called_at;
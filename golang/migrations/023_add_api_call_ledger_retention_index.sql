@@ -0,0 +1,2 @@
+-- Let ledger retention delete old calls without scanning every provider's rows
+CREATE INDEX IF NOT EXISTS idx_api_call_ledger_called_at ON api_call_ledger(called_at);
@@ -0,0 +1,8 @@
+// Package migrations embeds the numbered SQL migrations so the binary can apply
+// them itself. NNN_name.sql holds the up migration and NNN_name.down.sql reverts it.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS